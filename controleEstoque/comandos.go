@@ -0,0 +1,722 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"controleEstoque/estoque"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Erro para indicar que o comando digitado não existe
+var errComandoDesconhecido = errors.New("comando desconhecido (use: importar, exportar, relatorio, inventario, buscar, compras, producao, auditoria, gtin, vender, etiquetas, estornar, servidor)")
+
+// executarComando escolhe o comando da linha de comando pelo primeiro argumento
+func executarComando(nome string, argumentos []string) error {
+	switch nome {
+	case "importar":
+		return comandoImportar(argumentos)
+	case "exportar":
+		return comandoExportar(argumentos)
+	case "relatorio":
+		return comandoRelatorio(argumentos)
+	case "inventario":
+		return comandoInventario(argumentos)
+	case "buscar":
+		return comandoBuscar(argumentos)
+	case "compras":
+		return comandoCompras(argumentos)
+	case "producao":
+		return comandoProducao(argumentos)
+	case "auditoria":
+		return comandoAuditoria(argumentos)
+	case "gtin":
+		return comandoGTIN(argumentos)
+	case "vender":
+		return comandoVender(os.Stdin, argumentos)
+	case "etiquetas":
+		return comandoEtiquetas(argumentos)
+	case "estornar":
+		return comandoEstornar(argumentos)
+	case "servidor":
+		return comandoServidor(argumentos)
+	}
+	return errComandoDesconhecido
+}
+
+// comandoImportar importa produtos e saldos de abertura de uma planilha CSV ou XLSX
+// Exemplo: go run . importar -arquivo produtos.xlsx -colunas "sku=Código,nome=Descrição" -simular
+func comandoImportar(argumentos []string) error {
+	flags := flag.NewFlagSet("importar", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	arquivo := flags.String("arquivo", "", "planilha a importar (.csv ou .xlsx)")
+	colunas := flags.String("colunas", "", "mapeamento campo=Título separado por vírgula (campos: sku, nome, categoria, quantidade, local, custo)")
+	simular := flags.Bool("simular", false, "apenas valida e mostra o resultado, sem gravar (dry-run)")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	formato, err := estoque.FormatoPorArquivo(*arquivo)
+	if err != nil {
+		return err
+	}
+	mapa, err := lerMapaColunas(*colunas)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*arquivo)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	servico := estoque.NovoServicoEstoque(abrirRepositorio(*caminhoEstoque))
+	servico.DefinirAuditoria(estoque.NovoRepositorioAuditoriaArquivo(*caminhoEstoque))
+	relatorio, err := servico.Importar(contextoDoUsuario(), f, estoque.OpcoesImportacao{Formato: formato, Colunas: mapa, Simulacao: *simular})
+
+	for _, erroLinha := range relatorio.Erros {
+		fmt.Println("❌", erroLinha)
+	}
+	if err != nil {
+		return err
+	}
+
+	if relatorio.Simulacao {
+		fmt.Println("Simulação (nada foi gravado):")
+	}
+	fmt.Printf("Linhas: %d | Criados: %d | Atualizados: %d | Movimentos: %d\n",
+		relatorio.Linhas, relatorio.Criados, relatorio.Atualizados, relatorio.Movimentos)
+	return nil
+}
+
+// comandoExportar exporta o estoque atual ou o livro de movimentações para CSV ou XLSX
+// Exemplo: go run . exportar -tipo movimentos -arquivo movimentos.csv
+func comandoExportar(argumentos []string) error {
+	flags := flag.NewFlagSet("exportar", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	arquivo := flags.String("arquivo", "", "planilha de saída (.csv ou .xlsx)")
+	tipo := flags.String("tipo", "estoque", "o que exportar: estoque ou movimentos")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	formato, err := estoque.FormatoPorArquivo(*arquivo)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*arquivo)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	repo := abrirRepositorio(*caminhoEstoque)
+	switch *tipo {
+	case "estoque":
+		err = estoque.ExportarEstoque(repo, f, formato)
+	case "movimentos":
+		err = estoque.ExportarMovimentos(repo, f, formato)
+	default:
+		err = fmt.Errorf("tipo de exportação inválido: %s", *tipo)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✅ Exportado para", *arquivo)
+	return nil
+}
+
+// comandoRelatorio mostra os relatórios de valorização, curva ABC e giro do estoque
+// Exemplo: go run . relatorio -tipo abc -dias 90 -formato csv
+func comandoRelatorio(argumentos []string) error {
+	flags := flag.NewFlagSet("relatorio", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	tipo := flags.String("tipo", "valor", "relatório: valor, abc, giro ou reposicao")
+	metodo := flags.String("metodo", string(estoque.CustoMedio), "método de custo da valorização: medio ou fifo")
+	dias := flags.Int("dias", 30, "período em dias da curva ABC e do giro")
+	formato := flags.String("formato", string(estoque.RelatorioTexto), "formato de saída: texto, csv ou json")
+	data := flags.String("data", "", "estoque como estava no fim do dia AAAA-MM-DD (só com o estoque em eventos)")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	servico := estoque.NovoServicoEstoque(abrirRepositorio(*caminhoEstoque))
+	fim := time.Now()
+	if *data != "" {
+		dia, err := time.ParseInLocation("2006-01-02", *data, time.Local)
+		if err != nil {
+			return err
+		}
+		fim = dia.AddDate(0, 0, 1).Add(-time.Nanosecond)       // último instante do dia
+		if servico, err = servico.EstoqueEm(fim); err != nil { // EstoqueEm vem do arquivo eventos.go
+			return err
+		}
+	}
+	periodo := estoque.UltimosDias(fim, *dias)
+
+	var tabela estoque.Tabela
+	switch *tipo {
+	case "valor":
+		valores, err := servico.ValorizarEstoque(estoque.MetodoCusto(*metodo))
+		if err != nil {
+			return err
+		}
+		tabela = estoque.TabelaValorizacao(valores, estoque.MetodoCusto(*metodo))
+	case "abc":
+		tabela = estoque.TabelaCurvaABC(servico.CurvaABC(periodo))
+	case "giro":
+		tabela = estoque.TabelaGiro(servico.GiroEstoque(periodo))
+	case "reposicao":
+		compras := estoque.NovoServicoCompras(servico, estoque.NovoRepositorioComprasArquivo(*caminhoEstoque))
+		tabela = estoque.TabelaReposicao(compras.RelatorioReposicao())
+	default:
+		return fmt.Errorf("tipo de relatório inválido: %s", *tipo)
+	}
+
+	return tabela.Escrever(os.Stdout, estoque.FormatoRelatorio(*formato))
+}
+
+// comandoInventario conduz a contagem física do estoque em etapas, guardando a sessão em arquivo
+// Etapas: abrir (-modo bloquear|reconciliar), contar (-produto -local -quantidade), diferencas, aprovar (-motivo) e cancelar
+func comandoInventario(argumentos []string) error {
+	if len(argumentos) == 0 {
+		return errors.New("informe a etapa do inventário: abrir, contar, diferencas, aprovar ou cancelar")
+	}
+	etapa := argumentos[0]
+
+	flags := flag.NewFlagSet("inventario "+etapa, flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	modo := flags.String("modo", string(estoque.ContagemBloqueiaVendas), "abrir: bloquear ou reconciliar as vendas durante a contagem")
+	produto := flags.String("produto", "", "contar: ID do produto")
+	local := flags.String("local", estoque.LocalPadrao, "contar: local contado")
+	quantidade := flags.Int("quantidade", 0, "contar: quantidade contada")
+	motivo := flags.String("motivo", "", "aprovar: motivo dos ajustes")
+	if err := flags.Parse(argumentos[1:]); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+
+	switch etapa {
+	case "abrir":
+		contagem, err := servico.AbrirContagem(estoque.ModoContagem(*modo))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Contagem %s aberta com %d itens\n", contagem.ID, len(contagem.Itens))
+	case "contar":
+		if err := servico.RegistrarContagem(*produto, *local, *quantidade); err != nil {
+			return err
+		}
+	case "diferencas":
+		diferencas, err := servico.DiferencasContagem()
+		if err != nil {
+			return err
+		}
+		for _, d := range diferencas {
+			fmt.Printf("Produto: %s | Local: %s | Esperado: %d | Contado: %d | Diferença: %+d\n", d.Produto, d.Local, d.Esperado, d.Contado, d.Diferenca)
+		}
+		return nil // só leitura, a sessão não muda
+	case "aprovar":
+		movimentos, err := servico.AprovarContagem(contextoDoUsuario(), *motivo)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Contagem aprovada com %d ajustes\n", len(movimentos))
+	case "cancelar":
+		if err := servico.CancelarContagem(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("etapa do inventário inválida: %s", etapa)
+	}
+
+	return salvarContagem(servico, *caminhoEstoque)
+}
+
+// comandoBuscar procura produtos por nome, categoria e faixa de estoque, uma página por vez
+// Exemplo: go run . buscar -nome cobogo -ordem quantidade -desc -limite 10
+func comandoBuscar(argumentos []string) error {
+	flags := flag.NewFlagSet("buscar", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	nome := flags.String("nome", "", "parte do nome (sem diferenciar acentos)")
+	categoria := flags.String("categoria", "", "categoria do produto")
+	minimo := flags.Int("min", -1, "quantidade mínima (-1 = sem limite)")
+	maximo := flags.Int("max", -1, "quantidade máxima (-1 = sem limite)")
+	ordem := flags.String("ordem", string(estoque.OrdenarPorNome), "ordenação: nome, quantidade ou categoria")
+	decrescente := flags.Bool("desc", false, "ordem decrescente")
+	limite := flags.Int("limite", estoque.TamanhoPaginaPadrao, "produtos por página")
+	cursor := flags.String("cursor", "", "cursor da próxima página")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	consulta := estoque.Consulta{
+		Nome:        *nome,
+		Categoria:   *categoria,
+		Ordenacao:   estoque.CampoOrdenacao(*ordem),
+		Decrescente: *decrescente,
+		Limite:      *limite,
+		Cursor:      *cursor,
+	}
+	if *minimo >= 0 {
+		consulta.QuantidadeMinima = minimo
+	}
+	if *maximo >= 0 {
+		consulta.QuantidadeMaxima = maximo
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	pagina, err := servico.ConsultarEstoque(consulta)
+	if err != nil {
+		return err
+	}
+
+	for _, produto := range pagina.Produtos {
+		fmt.Printf("%s | %s | Categoria: %s | Quantidade: %d\n", produto.ID, produto.Nome, produto.Categoria, produto.Quantidade)
+	}
+	if pagina.ProximoCursor != "" {
+		fmt.Println("Próxima página: -cursor", pagina.ProximoCursor)
+	}
+	return nil
+}
+
+// comandoCompras cadastra fornecedores e conduz os pedidos de compra, gravados em estoque.compras.json
+// Etapas: fornecedor (-nome -contato -prazo), reposicao (-produto -fornecedor -min -max), pedido (-fornecedor -produto -quantidade -custo),
+// gerar (pedidos da reposição), pedidos, enviar (-pedido) e receber (-pedido -produto -quantidade -local)
+func comandoCompras(argumentos []string) error {
+	if len(argumentos) == 0 {
+		return errors.New("informe a etapa de compras: fornecedor, reposicao, pedido, gerar, pedidos, enviar ou receber")
+	}
+	etapa := argumentos[0]
+
+	flags := flag.NewFlagSet("compras "+etapa, flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	nome := flags.String("nome", "", "fornecedor: nome do fornecedor")
+	contato := flags.String("contato", "", "fornecedor: telefone ou e-mail")
+	prazo := flags.Int("prazo", 0, "fornecedor: prazo de entrega em dias")
+	fornecedor := flags.String("fornecedor", "", "reposicao, pedido: ID do fornecedor")
+	produto := flags.String("produto", "", "reposicao, pedido, receber: ID do produto")
+	minimo := flags.Int("min", 0, "reposicao: estoque mínimo (ponto de pedido)")
+	maximo := flags.Int("max", 0, "reposicao: estoque máximo (0 = o dobro do mínimo)")
+	quantidade := flags.Int("quantidade", 0, "pedido, receber: quantidade")
+	custo := flags.Float64("custo", 0, "pedido: custo unitário")
+	pedido := flags.String("pedido", "", "enviar, receber: ID do pedido")
+	local := flags.String("local", estoque.LocalPadrao, "receber: local de entrada")
+	if err := flags.Parse(argumentos[1:]); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	compras := estoque.NovoServicoCompras(servico, estoque.NovoRepositorioComprasArquivo(*caminhoEstoque))
+
+	switch etapa {
+	case "fornecedor":
+		novo := estoque.NovoFornecedor(*nome, *contato)
+		novo.PrazoEntregaDias = *prazo
+		if err := compras.CadastrarFornecedor(novo); err != nil {
+			return err
+		}
+		fmt.Println("✅ Fornecedor cadastrado:", novo.ID)
+	case "reposicao":
+		return compras.DefinirReposicao(contextoDoUsuario(), *produto, *fornecedor, *minimo, *maximo)
+	case "pedido":
+		criado, err := compras.CriarPedido(*fornecedor, []estoque.ItemPedido{{ProdutoID: *produto, Quantidade: *quantidade, CustoUnitario: *custo}})
+		if err != nil {
+			return err
+		}
+		fmt.Println("✅ Pedido criado em rascunho:", criado.ID)
+	case "gerar":
+		pedidos, err := compras.GerarPedidosReposicao()
+		for _, p := range pedidos {
+			fmt.Printf("✅ Pedido %s para o fornecedor %s com %d itens\n", p.ID, p.FornecedorID, len(p.Itens))
+		}
+		return err
+	case "pedidos":
+		for _, p := range compras.ListarPedidos() {
+			fmt.Printf("Pedido: %s | Fornecedor: %s | Status: %s\n", p.ID, p.FornecedorID, p.Status)
+			for _, item := range p.Itens {
+				fmt.Printf("  %s | Pedido: %d | Recebido: %d | Custo: %.2f\n", item.Produto, item.Quantidade, item.Recebido, item.CustoUnitario)
+			}
+		}
+	case "enviar":
+		enviado, err := compras.EnviarPedido(*pedido)
+		if err != nil {
+			return err
+		}
+		fmt.Println("✅ Pedido enviado, status:", enviado.Status)
+	case "receber":
+		recebido, err := compras.ReceberPedido(contextoDoUsuario(), *pedido, *local, []estoque.ItemRecebido{{ProdutoID: *produto, Quantidade: *quantidade}})
+		if err != nil {
+			return err
+		}
+		fmt.Println("✅ Recebimento registrado, status:", recebido.Status)
+	default:
+		return fmt.Errorf("etapa de compras inválida: %s", etapa)
+	}
+	return nil
+}
+
+// comandoProducao define a estrutura dos produtos fabricados e executa ordens de produção
+// Etapas: estrutura (-produto -componentes "id=2,id=4") e ordem (-produto -quantidade -local -lote -cura)
+func comandoProducao(argumentos []string) error {
+	if len(argumentos) == 0 {
+		return errors.New("informe a etapa da produção: estrutura ou ordem")
+	}
+	etapa := argumentos[0]
+
+	flags := flag.NewFlagSet("producao "+etapa, flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	produto := flags.String("produto", "", "ID do produto fabricado")
+	componentes := flags.String("componentes", "", "estrutura: componentes por unidade no formato ID=quantidade separados por vírgula")
+	quantidade := flags.Int("quantidade", 0, "ordem: quantidade a produzir")
+	local := flags.String("local", "", "ordem: local de consumo e de entrada (vazio = qualquer local)")
+	lote := flags.String("lote", "", "ordem: código do lote produzido")
+	cura := flags.Int("cura", 0, "ordem: dias de cura do lote")
+	if err := flags.Parse(argumentos[1:]); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+
+	switch etapa {
+	case "estrutura":
+		estrutura, err := lerComponentes(*componentes)
+		if err != nil {
+			return err
+		}
+		if err := servico.DefinirEstrutura(contextoDoUsuario(), *produto, estrutura); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Estrutura definida com %d componentes\n", len(estrutura))
+	case "ordem":
+		ordem, err := servico.Produzir(contextoDoUsuario(), estoque.OrdemProducao{ProdutoID: *produto, Quantidade: *quantidade, Local: *local, Lote: *lote, DiasCura: *cura})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Ordem %s: %d unidades produzidas a %.2f cada\n", ordem.ID, ordem.Quantidade, ordem.CustoUnitario)
+		for _, consumo := range ordem.Consumos {
+			fmt.Printf("  Consumido: %s | Quantidade: %d\n", consumo.ProdutoID, consumo.Quantidade)
+		}
+	default:
+		return fmt.Errorf("etapa da produção inválida: %s", etapa)
+	}
+	return nil
+}
+
+// comandoAuditoria lista quem alterou o estoque, filtrando por produto, usuário e período (datas AAAA-MM-DD, inclusivas)
+// Exemplo: go run . auditoria -produto viga-1712 -usuario maria -de 2024-05-01 -ate 2024-05-31
+func comandoAuditoria(argumentos []string) error {
+	flags := flag.NewFlagSet("auditoria", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	produto := flags.String("produto", "", "ID do produto")
+	usuario := flags.String("usuario", "", "usuário que fez a alteração")
+	de := flags.String("de", "", "primeiro dia do período (AAAA-MM-DD)")
+	ate := flags.String("ate", "", "último dia do período (AAAA-MM-DD)")
+	verificar := flags.Bool("verificar", false, "confere se a trilha foi adulterada")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	filtro := estoque.FiltroAuditoria{ProdutoID: *produto, Usuario: *usuario}
+	if *de != "" {
+		dia, err := time.ParseInLocation("2006-01-02", *de, time.Local)
+		if err != nil {
+			return err
+		}
+		filtro.Inicio = dia
+	}
+	if *ate != "" {
+		dia, err := time.ParseInLocation("2006-01-02", *ate, time.Local)
+		if err != nil {
+			return err
+		}
+		filtro.Fim = dia.AddDate(0, 0, 1).Add(-time.Nanosecond) // último instante do dia
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	if *verificar {
+		if err := servico.VerificarAuditoria(); err != nil {
+			return err
+		}
+		fmt.Println("✅ Trilha de auditoria íntegra")
+	}
+
+	for _, registro := range servico.ConsultarAuditoria(filtro) {
+		antes := 0
+		if registro.Antes != nil {
+			antes = registro.Antes.Quantidade
+		}
+		fmt.Printf("%s | %s (%s) | %s | %s | Quantidade: %d -> %d\n",
+			registro.Data.Format("02/01/2006 15:04:05"), registro.Ator.Usuario, registro.Ator.Sistema,
+			registro.Operacao, registro.ProdutoID, antes, registro.Depois.Quantidade)
+	}
+	return nil
+}
+
+// comandoGTIN grava o código de barras (EAN-13/GTIN) do produto, conferindo o dígito verificador
+// Exemplo: go run . gtin -produto b718deb38a28d492 -codigo 7891234567895
+func comandoGTIN(argumentos []string) error {
+	flags := flag.NewFlagSet("gtin", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	produto := flags.String("produto", "", "ID do produto")
+	codigo := flags.String("codigo", "", "código de barras com o dígito verificador (vazio remove o código)")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	if err := servico.DefinirGTIN(contextoDoUsuario(), *produto, *codigo); err != nil {
+		return err
+	}
+	fmt.Println("✅ Código de barras gravado")
+	return nil
+}
+
+// comandoVender registra uma venda para cada código de barras lido, um por linha
+// O leitor USB funciona como teclado: cada leitura digita o código e um Enter. Termina com linha "fim" ou fim da entrada
+// Exemplo: go run . vender -local loja
+func comandoVender(entrada io.Reader, argumentos []string) error {
+	flags := flag.NewFlagSet("vender", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	local := flags.String("local", "", "local da venda (vazio = todos os locais)")
+	quantidade := flags.Int("quantidade", 1, "unidades vendidas a cada leitura")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	ctx := contextoDoUsuario()
+
+	fmt.Println("Leia os códigos de barras (\"fim\" para encerrar):")
+	leitor := bufio.NewScanner(entrada)
+	for leitor.Scan() {
+		codigo := estoque.NormalizarGTIN(leitor.Text())
+		if codigo == "" {
+			continue
+		}
+		if strings.EqualFold(codigo, "fim") {
+			break
+		}
+		produto, err := servico.VenderPorGTIN(ctx, codigo, *local, *quantidade)
+		if err != nil {
+			fmt.Println("❌", codigo, err) // uma leitura errada não encerra o caixa
+			continue
+		}
+		fmt.Printf("✅ %s | Vendido: %d | Saldo: %d\n", produto.Nome, *quantidade, produto.Quantidade)
+	}
+	return leitor.Err()
+}
+
+// comandoEtiquetas gera a folha de etiquetas com código de barras em SVG ou PDF (pela extensão do arquivo)
+// Exemplo: go run . etiquetas -produto b718deb38a28d492 -lotes -arquivo etiquetas.pdf
+func comandoEtiquetas(argumentos []string) error {
+	flags := flag.NewFlagSet("etiquetas", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	produtos := flags.String("produto", "", "IDs dos produtos separados por vírgula (vazio = todos com código de barras)")
+	lotes := flags.Bool("lotes", false, "uma etiqueta por lote, com o código do lote e a data de cura")
+	arquivo := flags.String("arquivo", "etiquetas.svg", "arquivo gerado (.svg ou .pdf)")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	if *produtos != "" {
+		ids = strings.Split(*produtos, ",")
+	}
+	var etiquetas []estoque.Etiqueta
+	if *lotes {
+		etiquetas, err = servico.EtiquetasLotes(ids...)
+	} else {
+		etiquetas, err = servico.EtiquetasProdutos(ids...)
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*arquivo)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	formato := estoque.FormatoEtiqueta(strings.TrimPrefix(strings.ToLower(filepath.Ext(*arquivo)), "."))
+	if err := estoque.EscreverEtiquetas(f, formato, etiquetas); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %d etiquetas geradas em %s\n", len(etiquetas), *arquivo)
+	return nil
+}
+
+// comandoEstornar desfaz um movimento lançado por engano com um movimento de compensação
+// Os IDs dos movimentos estão na exportação do livro (exportar -tipo movimentos)
+// Exemplo: go run . estornar -movimento 3f9a1c0d2b7e4a55 -motivo "entrada digitada duas vezes"
+func comandoEstornar(argumentos []string) error {
+	flags := flag.NewFlagSet("estornar", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	movimento := flags.String("movimento", "", "ID do movimento a estornar")
+	motivo := flags.String("motivo", "", "motivo do estorno")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	servico, err := novoServico(*caminhoEstoque)
+	if err != nil {
+		return err
+	}
+	estorno, err := servico.Estornar(contextoDoUsuario(), *movimento, *motivo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Estorno %s | Tipo: %s | Local: %s | Quantidade: %d\n", estorno.ID, estorno.Tipo, estorno.Local, estorno.Quantidade)
+	return nil
+}
+
+// abrirRepositorio escolhe o repositório pelo caminho do estoque:
+// um arquivo .json usa o RepositorioArquivo; qualquer outro caminho é um diretório de eventos (RepositorioEventos)
+func abrirRepositorio(caminhoEstoque string) estoque.RepositorioEstoque {
+	if filepath.Ext(caminhoEstoque) == ".json" {
+		return estoque.NovoRepositorioArquivo(caminhoEstoque)
+	}
+	return estoque.NovoRepositorioEventos(caminhoEstoque)
+}
+
+// novoServico cria o serviço sobre o arquivo de estoque e retoma a contagem aberta, se houver
+// Assim uma contagem aberta em modo bloquear também bloqueia os comandos executados depois dela;
+// o servidor, que já está no ar, relê o arquivo da contagem a cada venda (servidor.go)
+func novoServico(caminhoEstoque string) (*estoque.ServicoEstoque, error) {
+	return montarServico(abrirRepositorio(caminhoEstoque), caminhoEstoque)
+}
+
+// montarServico cria o serviço sobre o repositório já aberto (ex: envolvido pelos decoradores do servidor),
+// liga a auditoria e retoma a contagem aberta, como em novoServico
+func montarServico(repo estoque.RepositorioEstoque, caminhoEstoque string) (*estoque.ServicoEstoque, error) {
+	servico := estoque.NovoServicoEstoque(repo)
+	servico.DefinirAuditoria(estoque.NovoRepositorioAuditoriaArquivo(caminhoEstoque)) // estoque.json -> estoque.auditoria.jsonl
+
+	contagem, err := lerContagem(caminhoEstoque)
+	if err != nil || contagem == nil {
+		return servico, err
+	}
+	return servico, servico.RetomarContagem(*contagem)
+}
+
+// lerContagem lê a contagem aberta gravada por salvarContagem; sem arquivo, devolve nil (nenhuma contagem aberta)
+func lerContagem(caminhoEstoque string) (*estoque.Contagem, error) {
+	dados, err := os.ReadFile(caminhoContagem(caminhoEstoque))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var contagem estoque.Contagem
+	if err := json.Unmarshal(dados, &contagem); err != nil {
+		return nil, err
+	}
+	return &contagem, nil
+}
+
+// contextoDoUsuario identifica quem está usando a linha de comando para a trilha de auditoria:
+// a variável ESTOQUE_USUARIO, ou o usuário do sistema operacional (USER ou USERNAME)
+func contextoDoUsuario() context.Context {
+	usuario := os.Getenv("ESTOQUE_USUARIO")
+	if usuario == "" {
+		usuario = os.Getenv("USER")
+	}
+	if usuario == "" {
+		usuario = os.Getenv("USERNAME")
+	}
+	return estoque.ComAtor(context.Background(), estoque.Ator{Usuario: usuario, Sistema: "cli"})
+}
+
+// salvarContagem grava a contagem aberta em arquivo, ou apaga o arquivo quando ela foi encerrada
+func salvarContagem(servico *estoque.ServicoEstoque, caminhoEstoque string) error {
+	contagem, aberta := servico.ContagemAberta()
+	if !aberta {
+		err := os.Remove(caminhoContagem(caminhoEstoque))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	dados, err := json.MarshalIndent(contagem, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(caminhoContagem(caminhoEstoque), dados, 0644)
+}
+
+// caminhoContagem retorna o arquivo da contagem aberta: estoque.json -> estoque.inventario.json
+func caminhoContagem(caminhoEstoque string) string {
+	return strings.TrimSuffix(caminhoEstoque, filepath.Ext(caminhoEstoque)) + ".inventario.json"
+}
+
+// lerComponentes converte "id=2,id=4" na estrutura do produto, mantendo a ordem digitada
+func lerComponentes(texto string) ([]estoque.Componente, error) {
+	var componentes []estoque.Componente
+	if strings.TrimSpace(texto) == "" {
+		return componentes, nil
+	}
+	for _, par := range strings.Split(texto, ",") {
+		id, quantidade, ok := strings.Cut(par, "=")
+		numero, err := strconv.Atoi(strings.TrimSpace(quantidade))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("componente inválido: %q", par)
+		}
+		componentes = append(componentes, estoque.Componente{ProdutoID: strings.TrimSpace(id), Quantidade: numero})
+	}
+	return componentes, nil
+}
+
+// lerMapaColunas converte "sku=Código,nome=Descrição" em um mapa campo -> título
+func lerMapaColunas(texto string) (map[string]string, error) {
+	mapa := map[string]string{}
+	if strings.TrimSpace(texto) == "" {
+		return mapa, nil
+	}
+	for _, par := range strings.Split(texto, ",") {
+		campo, titulo, ok := strings.Cut(par, "=")
+		if !ok {
+			return nil, fmt.Errorf("mapeamento de coluna inválido: %q", par)
+		}
+		mapa[strings.TrimSpace(campo)] = strings.TrimSpace(titulo)
+	}
+	return mapa, nil
+}
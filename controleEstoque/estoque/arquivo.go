@@ -2,57 +2,335 @@ package estoque
 
 import (
 	"encoding/json" // serve para codificar e decodificar dados em formato JSON
-	"errors"        // serve para criar e manipular erros
+	"errors"        // serve para criar o erro de arquivo ilegível
+	"fmt"           // serve para dizer qual arquivo está ilegível
 	"os"            // serve para interagir com o sistema operacional (ler e escrever arquivos)
+	"path/filepath" // serve para trocar a extensão do arquivo de movimentos
+	"strings"       // serve para manipular o caminho do arquivo
+	"sync"          // serve para proteger a cópia em memória do arquivo
+	"time"          // serve para guardar a data de modificação do arquivo
 )
 
+// Erro para indicar que o arquivo de estoque (ou de movimentos) existe, mas não é um JSON válido
+// O repositório não grava por cima dele: um arquivo estragado não vira um estoque vazio
+var ErrArquivoIlegivel = errors.New("arquivo de estoque ilegível")
+
 // RepositorioArquivo implementa o RepositorioEstoque armazenando produtos em um arquivo JSON
 // Cada vez que um produto é adicionado ou atualizado, o arquivo é reescrito com o estado atual do estoque
+// A reescrita vai para um arquivo temporário, com fsync, que depois troca de nome com o original:
+// uma queda no meio deixa o arquivo antigo inteiro
+// Os movimentos ficam em um segundo arquivo ao lado, com o sufixo ".movimentos.json"
+// Os produtos lidos ficam em memória com um índice por ID; o arquivo só é lido de novo se mudar no disco
+// As gravações pegam também a trava do arquivo ".trava" (trava_unix.go): entre a releitura, a comparação
+// de versões e a escrita, nenhum outro processo grava o mesmo estoque
 type RepositorioArquivo struct {
 	caminho string
+	caminhoMovimentos string
+	caminhoTrava string
+	produtos []Produto // cópia em memória do conteúdo do arquivo
+	indice map[string]int // ID -> posição em produtos
+	modificado time.Time // data de modificação do arquivo quando foi lido, para saber se outro processo o alterou
+	tamanho int64 // tamanho do arquivo quando foi lido
+	carregado bool // indica se o arquivo já foi lido pelo menos uma vez
+	mu sync.Mutex // protege a cópia em memória e a escrita do arquivo
 }
 
 // cria um repositório persistido em arquivo
 func NovoRepositorioArquivo(caminho string) *RepositorioArquivo {
 	return &RepositorioArquivo{
 		caminho: caminho,
+		caminhoMovimentos: strings.TrimSuffix(caminho, filepath.Ext(caminho)) + ".movimentos.json", // estoque.json -> estoque.movimentos.json
+		caminhoTrava: strings.TrimSuffix(caminho, filepath.Ext(caminho)) + ".trava", // estoque.json -> estoque.trava
 	}
 }
 
 func (r *RepositorioArquivo) Listar() []Produto {
-	dados, err := os.ReadFile(r.caminho) // lê o conteúdo do arquivo
-	if err != nil { //
-	// arquivo ainda não existe -> estoque vazio
-	return []Produto{} // retorna uma lista vazia de produtos	
-	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.carregar() // lê o conteúdo do arquivo, se ele mudou desde a última leitura; ilegível, fica a última cópia lida
 
-	var produtos []Produto // cria uma variável para armazenar os produtos lidos do arquivo
-	json.Unmarshal(dados, &produtos) // decodifica os dados JSON para a variável produtos
+	produtos := make([]Produto, len(r.produtos)) // cópia para o chamador não alterar a memória do repositório
+	for i, produto := range r.produtos {
+		produtos[i] = produto.clonar()
+	}
 	return produtos // retorna a lista de produtos 
 }
 
 func (r *RepositorioArquivo) Adicionar(produto Produto) {
-	produtos := r.Listar() // lê os produtos atuais do arquivo
-	produtos = append(produtos, produto) // adiciona o novo produto à lista
-	
-	dados, _ := json.MarshalIndent(produtos,"", " ") // codifica a lista de produtos em JSON com indentação
-	os.WriteFile(r.caminho, dados, 0644) // os.WriteFile grava os dados no arquivo especificado pelo caminho, 0644 significa as permissões do arquivo
+	destravar, err := r.travarParaGravar() // lê os produtos atuais do arquivo
+	if err != nil {
+		return // sem retorno de erro na interface: sem a trava ou com o arquivo ilegível, nada é gravado
+	}
+	defer destravar()
+
+	produtos := append(r.produtos[:len(r.produtos):len(r.produtos)], produto.clonar()) // adiciona o novo produto à lista
+	r.gravar(produtos)
 }
 
+// Atualizar grava o produto se ele estiver na mesma versão que está no arquivo, senão retorna ErrConflito
 func (r *RepositorioArquivo) Atualizar(produto Produto) error {
-	produtos := r.Listar() // lê os produtos atuais do arquivo
+	destravar, err := r.travarParaGravar() // lê os produtos atuais do arquivo
+	if err != nil {
+		return err
+	}
+	defer destravar()
+
+	i, existe := r.indice[produto.ID] // usa o índice para encontrar o produto com o ID correspondente
+	if !existe {
+		return ErrProdutoNaoEncontrado // retorna erro se o produto não for encontrado
+	}
+
+	novo, err := compararEGravar(r.produtos[i], produto) // só grava se ninguém alterou o produto desde a leitura (concorrencia.go)
+	if err != nil {
+		return err
+	}
+
+	produtos := append([]Produto(nil), r.produtos...) // atualiza o produto em uma nova lista
+	produtos[i] = novo
+	return r.gravar(produtos) // retorna nil se a atualização for bem-sucedida
+}
+
+// AtualizarVarios grava vários produtos em uma única escrita do arquivo: todos ou nenhum
+func (r *RepositorioArquivo) AtualizarVarios(produtos []Produto) error {
+	destravar, err := r.travarParaGravar()
+	if err != nil {
+		return err
+	}
+	defer destravar()
+
+	novos, err := compararEGravarVarios(r.produtos, r.indice, produtos) // compararEGravarVarios vem do arquivo concorrencia.go
+	if err != nil {
+		return err
+	}
+
+	lista := append([]Produto(nil), r.produtos...)
+	for i, novo := range novos {
+		lista[i] = novo
+	}
+	return r.gravar(lista)
+}
+
+// GravarOperacao grava os produtos novos e alterados em uma única escrita do arquivo (todos ou nenhum)
+// e só depois acrescenta os movimentos da operação ao livro
+// Produtos e livro são dois arquivos: uma queda entre as duas trocas de nome deixa o estoque gravado e o
+// livro sem os movimentos dessa operação (o estoque vale; o livro nunca traz movimento de operação não gravada)
+// Para gravar tudo de uma vez, use o RepositorioEventos (eventos.go)
+func (r *RepositorioArquivo) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	destravar, err := r.travarParaGravar()
+	if err != nil {
+		return err
+	}
+	defer destravar()
+	if len(movimentos) > 0 {
+		if _, err := r.lerMovimentos(); err != nil {
+			return err // com o livro ilegível, nem os produtos são gravados
+		}
+	}
+
+	atualizados, err := compararEGravarVarios(r.produtos, r.indice, alterados) // compararEGravarVarios vem do arquivo concorrencia.go
+	if err != nil {
+		return err
+	}
+	incluidos, err := compararEIncluir(r.produtos, r.indice, novos)
+	if err != nil {
+		return err
+	}
+
+	lista := append([]Produto(nil), r.produtos...)
+	for i, novo := range atualizados {
+		lista[i] = novo
+	}
+	if err := r.gravar(append(lista, incluidos...)); err != nil {
+		return err // sem produtos gravados, nenhum movimento é registrado
+	}
+	return r.acrescentarMovimentos(movimentos)
+}
+
+// Buscar devolve o produto com o ID informado usando o índice, sem percorrer a lista
+func (r *RepositorioArquivo) Buscar(id string) (Produto, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.carregar(); err != nil {
+		return Produto{}, err
+	}
+
+	i, existe := r.indice[id]
+	if !existe {
+		return Produto{}, ErrProdutoNaoEncontrado
+	}
+	return r.produtos[i].clonar(), nil
+}
+
+// Consultar filtra, ordena e pagina os produtos do arquivo (aplicarConsulta vem do arquivo consulta.go)
+func (r *RepositorioArquivo) Consultar(consulta Consulta) (PaginaProdutos, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.carregar(); err != nil {
+		return PaginaProdutos{}, err
+	}
+	return aplicarConsulta(r.produtos, consulta)
+}
+
+// travarParaGravar bloqueia o mutex e a trava entre processos e relê o arquivo do disco
+// Se o arquivo estiver ilegível, solta as travas e devolve o erro
+// A releitura é forçada: outro processo pode ter gravado com a mesma data de modificação e o mesmo tamanho
+// Devolve a função que solta as duas travas
+func (r *RepositorioArquivo) travarParaGravar() (func(), error) {
+	r.mu.Lock()
+	soltar, err := travarArquivo(r.caminhoTrava) // travarArquivo vem do arquivo trava_unix.go
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.carregado = false
+	if err := r.carregar(); err != nil {
+		soltar()
+		r.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		soltar()
+		r.mu.Unlock()
+	}, nil
+}
 
-	for i := range produtos { // percorre a lista de produtos para encontrar o produto com o ID correspondente
-		if produtos[i].ID == produto.ID {
-			// atualiza o produto na lista
-			produtos[i] = produto
+// carregar lê o arquivo para a memória e refaz o índice, mas só se o arquivo mudou desde a última leitura
+// Um arquivo que não pode ser lido ou decodificado devolve o erro (ErrArquivoIlegivel) e mantém a cópia anterior
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioArquivo) carregar() error {
+	info, err := os.Stat(r.caminho)
+	if errors.Is(err, os.ErrNotExist) {
+		// arquivo ainda não existe -> estoque vazio
+		r.definirProdutos([]Produto{})
+		r.carregado = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.carregado && info.ModTime().Equal(r.modificado) && info.Size() == r.tamanho {
+		return nil // nada mudou no disco, a cópia em memória continua valendo
+	}
+
+	dados, err := os.ReadFile(r.caminho) // lê o conteúdo do arquivo
+	if err != nil {
+		return err
+	}
+	var produtos []Produto // cria uma variável para armazenar os produtos lidos do arquivo
+	if err := json.Unmarshal(dados, &produtos); err != nil { // decodifica os dados JSON para a variável produtos
+		return fmt.Errorf("%w: %s: %v", ErrArquivoIlegivel, r.caminho, err)
+	}
 
-			dados, _ := json.MarshalIndent(produtos, "", " ") // codifica a lista atualizada de produtos em JSON com indentação
-			os.WriteFile(r.caminho, dados, 0644) // grava os dados no arquivo especificado pelo caminho
-			
-			return nil // retorna nil se a atualização for bem-sucedida}
+	r.definirProdutos(produtos)
+	r.modificado, r.tamanho, r.carregado = info.ModTime(), info.Size(), true
+	return nil
+}
+
+// gravar escreve a lista no arquivo e, se deu certo, passa a usá-la como cópia em memória
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioArquivo) gravar(produtos []Produto) error {
+	dados, _ := json.MarshalIndent(produtos, "", " ") // codifica a lista de produtos em JSON com indentação
+	if err := gravarArquivoInteiro(r.caminho, dados); err != nil {
+		return err
+	}
+
+	r.definirProdutos(produtos)
+	if info, err := os.Stat(r.caminho); err == nil {
+		r.modificado, r.tamanho, r.carregado = info.ModTime(), info.Size(), true
+	}
+	return nil
+}
+
+// gravarArquivoInteiro grava os dados em um arquivo temporário ao lado, faz o fsync e troca o nome dele pelo do
+// arquivo final: quem lê (até outro processo) vê o conteúdo antigo ou o novo, nunca um arquivo pela metade
+func gravarArquivoInteiro(caminho string, dados []byte) error {
+	temporario := caminho + ".tmp"
+	arquivo, err := os.OpenFile(temporario, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644) // 0644 significa as permissões do arquivo
+	if err != nil {
+		return err
+	}
+	if _, err := arquivo.Write(dados); err != nil {
+		arquivo.Close()
+		return err
+	}
+	if err := arquivo.Sync(); err != nil { // os dados precisam estar no disco antes da troca de nome
+		arquivo.Close()
+		return err
+	}
+	if err := arquivo.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporario, caminho); err != nil {
+		return err
+	}
+	if diretorio, err := os.Open(filepath.Dir(caminho)); err == nil { // a troca de nome só sobrevive a uma queda depois do fsync do diretório
+		diretorio.Sync()
+		diretorio.Close()
+	}
+	return nil
+}
+
+// definirProdutos troca a lista em memória e refaz o índice por ID
+func (r *RepositorioArquivo) definirProdutos(produtos []Produto) {
+	r.produtos = produtos
+	r.indice = make(map[string]int, len(produtos))
+	for i, produto := range produtos {
+		if _, existe := r.indice[produto.ID]; !existe { // com IDs repetidos, vale o primeiro
+			r.indice[produto.ID] = i
 		}
 	}
+}
+
+// RegistrarMovimento acrescenta um movimento ao arquivo de movimentações
+// O mutex e a trava do arquivo evitam que duas gravações simultâneas, mesmo de processos diferentes (no Unix), leiam o mesmo arquivo e uma apague o movimento da outra
+func (r *RepositorioArquivo) RegistrarMovimento(movimento Movimento) {
+	destravar, err := r.travarParaGravar()
+	if err != nil {
+		return
+	}
+	defer destravar()
+	r.acrescentarMovimentos([]Movimento{movimento}) // sem retorno de erro na interface, como em Adicionar
+}
+
+// acrescentarMovimentos reescreve o arquivo de movimentações com os novos no final
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioArquivo) acrescentarMovimentos(novos []Movimento) error {
+	if len(novos) == 0 {
+		return nil
+	}
+	movimentos, err := r.lerMovimentos()
+	if err != nil {
+		return err // um livro ilegível não é sobrescrito só com os movimentos novos
+	}
+	dados, _ := json.MarshalIndent(append(movimentos, novos...), "", " ")
+	return gravarArquivoInteiro(r.caminhoMovimentos, dados)
+}
+
+// ListarMovimentos lê o arquivo de movimentações (arquivo inexistente ou ilegível -> livro vazio)
+func (r *RepositorioArquivo) ListarMovimentos() []Movimento {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	movimentos, err := r.lerMovimentos()
+	if err != nil {
+		return []Movimento{} // sem retorno de erro na interface; as gravações devolvem o ErrArquivoIlegivel
+	}
+	return movimentos
+}
 
-	return errors.New("produto não encontrado") // retorna erro se o produto não for encontrado
+// lerMovimentos decodifica o arquivo de movimentações
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioArquivo) lerMovimentos() ([]Movimento, error) {
+	dados, err := os.ReadFile(r.caminhoMovimentos)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Movimento{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var movimentos []Movimento
+	if err := json.Unmarshal(dados, &movimentos); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrArquivoIlegivel, r.caminhoMovimentos, err)
+	}
+	return movimentos, nil
 }
@@ -0,0 +1,324 @@
+package estoque
+
+import (
+	"bufio"         // pacote para ler o arquivo de auditoria linha por linha
+	"bytes"         // pacote para achar a última linha do arquivo de auditoria
+	"context"       // pacote para levar o ator (usuário, sistema) até o serviço
+	"crypto/sha256" // pacote para encadear os registros e perceber adulterações
+	"encoding/hex"  // pacote para guardar o hash como texto
+	"encoding/json" // pacote para gravar os registros
+	"errors"        // pacote para manipulação de erros
+	"fmt"           // pacote para detalhar o registro adulterado
+	"os"            // pacote para o arquivo de auditoria
+	"path/filepath" // pacote para trocar a extensão do arquivo
+	"strings"       // pacote para manipular o caminho do arquivo
+	"sync"          // pacote para proteger a gravação
+	"time"          // pacote para as datas dos registros
+)
+
+// Erro para indicar que um registro de auditoria foi alterado ou removido depois de gravado
+var ErrAuditoriaAdulterada = errors.New("registro de auditoria adulterado")
+
+// Erro para indicar que a operação foi gravada no estoque, mas o registro de auditoria dela não
+// Não é devolvido pela operação (que deu certo), e sim entregue ao AvisoAuditoria, com o erro da trilha junto
+var ErrAuditoriaNaoGravada = errors.New("operação gravada sem registro de auditoria")
+
+// Operações registradas na auditoria
+const (
+	OperacaoCadastro      = "cadastro"
+	OperacaoVenda         = "venda"
+	OperacaoTransferencia = "transferencia"
+	OperacaoLote          = "lote"
+	OperacaoEntrada       = "entrada"
+	OperacaoImportacao    = "importacao"
+	OperacaoContagem      = "contagem"
+	OperacaoEstrutura     = "estrutura"
+	OperacaoProducao      = "producao"
+	OperacaoReposicao     = "reposicao"
+	OperacaoCodigoBarras  = "codigo_barras"
+	OperacaoEstorno       = "estorno"
+)
+
+// Ator é quem fez a alteração: o usuário e o sistema de origem (cli, loja, integração...)
+type Ator struct {
+	Usuario string
+	Sistema string `json:",omitempty"`
+}
+
+// AtorDesconhecido é usado quando a operação chega sem ator no contexto
+var AtorDesconhecido = Ator{Usuario: "desconhecido"}
+
+// chaveAtor é a chave do ator no context.Context (tipo próprio para não colidir com outros pacotes)
+type chaveAtor struct{}
+
+// ComAtor devolve um contexto que leva o ator até as operações do ServicoEstoque
+func ComAtor(ctx context.Context, ator Ator) context.Context {
+	return context.WithValue(ctx, chaveAtor{}, ator)
+}
+
+// AtorDoContexto retorna o ator guardado no contexto, ou AtorDesconhecido
+func AtorDoContexto(ctx context.Context) Ator {
+	if ator, ok := ctx.Value(chaveAtor{}).(Ator); ok && ator.Usuario != "" {
+		return ator
+	}
+	return AtorDesconhecido
+}
+
+// RegistroAuditoria é uma linha imutável da trilha de auditoria: quem mudou o quê, quando, e como o produto ficou
+// Cada registro guarda o hash do anterior, então alterar ou apagar uma linha quebra a cadeia (VerificarAuditoria)
+type RegistroAuditoria struct {
+	ID           string
+	Data         time.Time
+	Ator         Ator
+	Operacao     string
+	ProdutoID    string
+	Antes        *Produto `json:",omitempty"` // nil no cadastro
+	Depois       *Produto
+	Movimentos   []string `json:",omitempty"` // IDs dos movimentos gerados pela operação
+	HashAnterior string
+	Hash         string
+}
+
+// FiltroAuditoria seleciona registros por produto, usuário e período (campos vazios não filtram)
+type FiltroAuditoria struct {
+	ProdutoID string
+	Usuario   string
+	Inicio    time.Time
+	Fim       time.Time
+}
+
+// RepositorioAuditoria guarda a trilha de auditoria; só permite acrescentar, nunca alterar
+type RepositorioAuditoria interface {
+	Registrar(registro RegistroAuditoria) error // encadeia o registro ao último e o grava no final
+	ListarAuditoria() []RegistroAuditoria       // em ordem de gravação
+}
+
+// AvisoAuditoria recebe a falha de gravação da trilha (ErrAuditoriaNaoGravada) de uma operação
+// A trilha só é gravada depois do estoque: a operação já valeu e devolveu sucesso, então quem recebe
+// o aviso não deve repeti-la (uma venda repetida sairia duas vezes do estoque)
+type AvisoAuditoria func(ctx context.Context, operacao string, err error)
+
+// DefinirAuditoria troca onde a trilha de auditoria é gravada (por padrão fica em memória)
+func (s *ServicoEstoque) DefinirAuditoria(auditoria RepositorioAuditoria) {
+	s.auditoria = auditoria
+}
+
+// DefinirAvisoAuditoria troca quem recebe as falhas de gravação da trilha (por padrão, a saída de erros)
+func (s *ServicoEstoque) DefinirAvisoAuditoria(aviso AvisoAuditoria) {
+	s.avisoAuditoria = aviso
+}
+
+// avisarNaSaidaDeErros é o AvisoAuditoria padrão
+func avisarNaSaidaDeErros(_ context.Context, operacao string, err error) {
+	fmt.Fprintf(os.Stderr, "aviso: %s: %v\n", operacao, err)
+}
+
+// ConsultarAuditoria lista os registros da trilha que atendem ao filtro
+func (s *ServicoEstoque) ConsultarAuditoria(filtro FiltroAuditoria) []RegistroAuditoria {
+	var registros []RegistroAuditoria
+	for _, registro := range s.auditoria.ListarAuditoria() {
+		if filtro.ProdutoID != "" && registro.ProdutoID != filtro.ProdutoID {
+			continue
+		}
+		if filtro.Usuario != "" && !strings.EqualFold(registro.Ator.Usuario, filtro.Usuario) {
+			continue
+		}
+		if !filtro.Inicio.IsZero() && registro.Data.Before(filtro.Inicio) {
+			continue
+		}
+		if !filtro.Fim.IsZero() && registro.Data.After(filtro.Fim) {
+			continue
+		}
+		registros = append(registros, registro)
+	}
+	return registros
+}
+
+// VerificarAuditoria confere a cadeia de hashes da trilha completa
+func (s *ServicoEstoque) VerificarAuditoria() error {
+	anterior := ""
+	for _, registro := range s.auditoria.ListarAuditoria() {
+		if registro.HashAnterior != anterior || registro.Hash != hashRegistro(registro) {
+			return fmt.Errorf("%w: registro %s", ErrAuditoriaAdulterada, registro.ID)
+		}
+		anterior = registro.Hash
+	}
+	return nil
+}
+
+// auditar grava um registro para cada produto alterado pela operação
+// antes traz o estado lido antes da operação (produtos novos não aparecem nele)
+// É chamado depois de o estoque ser gravado: uma falha vai para o AvisoAuditoria, não para quem chamou a operação
+func (s *ServicoEstoque) auditar(ctx context.Context, operacao string, antes map[string]Produto, depois []Produto, movimentos []Movimento) {
+	ator := AtorDoContexto(ctx)
+	agora := s.agora()
+	for _, produto := range depois {
+		registro := RegistroAuditoria{
+			ID:        gerarIDMovimento(), // gerarIDMovimento vem do arquivo movimento.go
+			Data:      agora,
+			Ator:      ator,
+			Operacao:  operacao,
+			ProdutoID: produto.ID,
+		}
+		if anterior, existe := antes[produto.ID]; existe {
+			anterior = anterior.clonar()
+			registro.Antes = &anterior
+		}
+		gravado := produto.clonar()
+		registro.Depois = &gravado
+		for _, movimento := range movimentos {
+			if movimento.ProdutoID == produto.ID {
+				registro.Movimentos = append(registro.Movimentos, movimento.ID)
+			}
+		}
+		if err := s.auditoria.Registrar(registro); err != nil {
+			s.avisoAuditoria(ctx, operacao, fmt.Errorf("%w: %w", ErrAuditoriaNaoGravada, err))
+			return // os próximos registros falhariam do mesmo jeito
+		}
+	}
+}
+
+// encadear liga o registro ao anterior e calcula o seu hash
+func encadear(registro RegistroAuditoria, hashAnterior string) RegistroAuditoria {
+	registro.HashAnterior = hashAnterior
+	registro.Hash = hashRegistro(registro)
+	return registro
+}
+
+// hashRegistro calcula o SHA-256 do registro sem o próprio hash
+func hashRegistro(registro RegistroAuditoria) string {
+	registro.Hash = ""
+	dados, _ := json.Marshal(registro)
+	soma := sha256.Sum256(dados)
+	return hex.EncodeToString(soma[:])
+}
+
+// RepositorioAuditoriaMemoria guarda a trilha de auditoria em memória
+type RepositorioAuditoriaMemoria struct {
+	registros []RegistroAuditoria
+	mu        sync.Mutex
+}
+
+// NovoRepositorioAuditoriaMemoria cria uma trilha de auditoria em memória
+func NovoRepositorioAuditoriaMemoria() *RepositorioAuditoriaMemoria {
+	return &RepositorioAuditoriaMemoria{}
+}
+
+// Registrar acrescenta o registro no final da trilha
+func (r *RepositorioAuditoriaMemoria) Registrar(registro RegistroAuditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	anterior := ""
+	if len(r.registros) > 0 {
+		anterior = r.registros[len(r.registros)-1].Hash
+	}
+	r.registros = append(r.registros, encadear(registro, anterior))
+	return nil
+}
+
+// ListarAuditoria devolve uma cópia da trilha
+func (r *RepositorioAuditoriaMemoria) ListarAuditoria() []RegistroAuditoria {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RegistroAuditoria(nil), r.registros...)
+}
+
+// RepositorioAuditoriaArquivo grava a trilha em um arquivo ao lado do estoque, um registro por linha
+// estoque.json -> estoque.auditoria.jsonl; o arquivo só recebe linhas novas no final
+type RepositorioAuditoriaArquivo struct {
+	caminho string
+	mu      sync.Mutex
+}
+
+// NovoRepositorioAuditoriaArquivo cria a trilha de auditoria ao lado do arquivo de estoque informado
+func NovoRepositorioAuditoriaArquivo(caminhoEstoque string) *RepositorioAuditoriaArquivo {
+	return &RepositorioAuditoriaArquivo{
+		caminho: strings.TrimSuffix(caminhoEstoque, filepath.Ext(caminhoEstoque)) + ".auditoria.jsonl",
+	}
+}
+
+// Registrar encadeia o registro ao último do arquivo e o acrescenta no final, em uma linha JSON com fsync
+// Só a última linha é lida para o encadeamento, então gravar não fica mais lento conforme a trilha cresce
+func (r *RepositorioAuditoriaArquivo) Registrar(registro RegistroAuditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	arquivo, err := os.OpenFile(r.caminho, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+
+	anterior, err := ultimoHash(arquivo)
+	if err != nil {
+		return err
+	}
+	linha, err := json.Marshal(encadear(registro, anterior))
+	if err != nil {
+		return err
+	}
+	if _, err := arquivo.Write(append(linha, '\n')); err != nil {
+		return err
+	}
+	return arquivo.Sync() // o registro só vale depois de estar no disco
+}
+
+// ultimoHash lê a trilha de trás para frente até achar a última linha e devolve o hash dela ("" se vazia)
+func ultimoHash(arquivo *os.File) (string, error) {
+	info, err := arquivo.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var cauda, linha []byte
+	for posicao := info.Size(); posicao > 0; {
+		tamanho := min(posicao, 4096)
+		posicao -= tamanho
+		bloco := make([]byte, tamanho)
+		if _, err := arquivo.ReadAt(bloco, posicao); err != nil {
+			return "", err
+		}
+		cauda = append(bloco, cauda...)
+		linha = bytes.TrimRight(cauda, "\n")
+		if i := bytes.LastIndexByte(linha, '\n'); i >= 0 {
+			linha = linha[i+1:]
+			break
+		}
+	}
+	if len(linha) == 0 {
+		return "", nil
+	}
+
+	var ultimo RegistroAuditoria
+	if err := json.Unmarshal(linha, &ultimo); err != nil {
+		return "", fmt.Errorf("%w: última linha ilegível", ErrAuditoriaAdulterada)
+	}
+	return ultimo.Hash, nil
+}
+
+// ListarAuditoria lê a trilha do arquivo (arquivo inexistente -> trilha vazia)
+func (r *RepositorioAuditoriaArquivo) ListarAuditoria() []RegistroAuditoria {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ler()
+}
+
+// ler decodifica o arquivo; deve ser chamado com o mutex bloqueado
+func (r *RepositorioAuditoriaArquivo) ler() []RegistroAuditoria {
+	arquivo, err := os.Open(r.caminho)
+	if err != nil {
+		return nil
+	}
+	defer arquivo.Close()
+
+	var registros []RegistroAuditoria
+	leitor := bufio.NewScanner(arquivo)
+	leitor.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for leitor.Scan() {
+		var registro RegistroAuditoria
+		if json.Unmarshal(leitor.Bytes(), &registro) == nil {
+			registros = append(registros, registro)
+		}
+	}
+	return registros
+}
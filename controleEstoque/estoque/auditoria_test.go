@@ -0,0 +1,184 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"os"            // pacote padrão para adulterar o arquivo de auditoria
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"strings"       // pacote padrão para adulterar uma linha do arquivo
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para as datas dos registros
+)
+
+func TestAuditoriaRegistraAtorAntesEDepois(t *testing.T) {
+	agora, adiantar := relogio(time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)) // relogio vem do arquivo eventos_test.go
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	servico.agora = agora
+	maria := ComAtor(context.Background(), Ator{Usuario: "maria", Sistema: "loja"})
+	joao := ComAtor(context.Background(), Ator{Usuario: "joao", Sistema: "cli"})
+
+	viga := NovoProduto("viga", 20)
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(joao, viga)
+	servico.CadastrarProduto(joao, coluna)
+	adiantar(24 * time.Hour)
+	if err := servico.VenderProduto(maria, viga.ID, 5); err != nil {
+		t.Fatalf("Não esperava erro na venda, mas recebi %v", err)
+	}
+	adiantar(24 * time.Hour)
+	if err := servico.Transferir(context.Background(), coluna.ID, LocalPatio, LocalLoja, 4); err != nil {
+		t.Fatalf("Não esperava erro na transferência, mas recebi %v", err)
+	}
+
+	vendas := servico.ConsultarAuditoria(FiltroAuditoria{Usuario: "maria"})
+	if len(vendas) != 1 {
+		t.Fatalf("Esperava 1 registro da maria, mas encontrei %d", len(vendas))
+	}
+	venda := vendas[0]
+	if venda.Operacao != OperacaoVenda || venda.ProdutoID != viga.ID || venda.Ator.Sistema != "loja" {
+		t.Errorf("Registro da venda inesperado: %+v", venda)
+	}
+	if venda.Antes == nil || venda.Antes.Quantidade != 20 || venda.Depois.Quantidade != 15 {
+		t.Errorf("Esperava quantidade 20 -> 15 na venda, mas encontrei %+v -> %+v", venda.Antes, venda.Depois)
+	}
+	if venda.Depois.Versao != venda.Antes.Versao+1 {
+		t.Errorf("Esperava a versão gravada depois da venda, mas encontrei %d -> %d", venda.Antes.Versao, venda.Depois.Versao)
+	}
+	if len(venda.Movimentos) != 1 {
+		t.Errorf("Esperava o movimento da venda ligado ao registro, mas encontrei %v", venda.Movimentos)
+	}
+
+	if cadastros := servico.ConsultarAuditoria(FiltroAuditoria{ProdutoID: viga.ID, Usuario: "JOAO"}); len(cadastros) != 1 || cadastros[0].Antes != nil {
+		t.Errorf("Esperava o cadastro da viga pelo joao, sem estado anterior, mas encontrei %+v", cadastros)
+	}
+	semAtor := servico.ConsultarAuditoria(FiltroAuditoria{Inicio: agora().Add(-time.Hour), Fim: agora()})
+	if len(semAtor) != 1 || semAtor[0].Ator != AtorDesconhecido {
+		t.Errorf("Esperava só a transferência no período, feita por ator desconhecido, mas encontrei %+v", semAtor)
+	}
+	if total := len(servico.ConsultarAuditoria(FiltroAuditoria{})); total != 4 {
+		t.Errorf("Esperava 4 registros sem filtro, mas encontrei %d", total)
+	}
+}
+
+func TestAuditoriaProducaoRegistraTodosOsProdutos(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := ComAtor(context.Background(), Ator{Usuario: "ana"})
+	cimento := NovoProduto("cimento", 10)
+	viga := NovoProduto("viga", 0)
+	servico.CadastrarProduto(ctx, cimento)
+	servico.CadastrarProduto(ctx, viga)
+	servico.DefinirEstrutura(ctx, viga.ID, []Componente{{ProdutoID: cimento.ID, Quantidade: 2}})
+
+	if _, err := servico.Produzir(ctx, OrdemProducao{ProdutoID: viga.ID, Quantidade: 3}); err != nil {
+		t.Fatalf("Não esperava erro na produção, mas recebi %v", err)
+	}
+	for _, id := range []string{cimento.ID, viga.ID} {
+		registros := servico.ConsultarAuditoria(FiltroAuditoria{ProdutoID: id})
+		ultimo := registros[len(registros)-1]
+		if ultimo.Operacao != OperacaoProducao || ultimo.Ator.Usuario != "ana" {
+			t.Errorf("Esperava o registro da produção para %s, mas encontrei %+v", id, ultimo)
+		}
+	}
+}
+
+func TestAuditoriaOperacaoCanceladaNaoGrava(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	viga := NovoProduto("viga", 20)
+	servico.CadastrarProduto(context.Background(), viga)
+
+	ctx, cancelar := context.WithCancel(context.Background())
+	cancelar()
+	if err := servico.VenderProduto(ctx, viga.ID, 5); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Esperava context.Canceled, mas recebi %v", err)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.Quantidade != 20 {
+		t.Errorf("A venda cancelada não deveria alterar o estoque, mas ficou %d", produto.Quantidade)
+	}
+	if total := len(servico.ConsultarAuditoria(FiltroAuditoria{})); total != 1 {
+		t.Errorf("Esperava só o registro do cadastro, mas encontrei %d", total)
+	}
+}
+
+func TestAuditoriaArquivoDetectaAdulteracao(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	servico := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	servico.DefinirAuditoria(NovoRepositorioAuditoriaArquivo(caminho))
+	ctx := ComAtor(context.Background(), Ator{Usuario: "maria"})
+
+	viga := NovoProduto("viga", 20)
+	servico.CadastrarProduto(ctx, viga)
+	servico.VenderProduto(ctx, viga.ID, 5)
+	if err := servico.VerificarAuditoria(); err != nil {
+		t.Fatalf("Não esperava erro na trilha íntegra, mas recebi %v", err)
+	}
+
+	// a trilha sobrevive a um novo serviço sobre o mesmo arquivo
+	reaberto := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	reaberto.DefinirAuditoria(NovoRepositorioAuditoriaArquivo(caminho))
+	if total := len(reaberto.ConsultarAuditoria(FiltroAuditoria{Usuario: "maria"})); total != 2 {
+		t.Fatalf("Esperava 2 registros gravados em arquivo, mas encontrei %d", total)
+	}
+
+	// trocar o usuário de uma linha quebra a cadeia de hashes
+	arquivo := filepath.Join(filepath.Dir(caminho), "estoque.auditoria.jsonl")
+	dados, err := os.ReadFile(arquivo)
+	if err != nil {
+		t.Fatalf("Não esperava erro ao ler a trilha, mas recebi %v", err)
+	}
+	adulterado := strings.Replace(string(dados), `"Usuario":"maria"`, `"Usuario":"joao"`, 1)
+	if err := os.WriteFile(arquivo, []byte(adulterado), 0644); err != nil {
+		t.Fatalf("Não esperava erro ao adulterar a trilha, mas recebi %v", err)
+	}
+	if err := reaberto.VerificarAuditoria(); !errors.Is(err, ErrAuditoriaAdulterada) {
+		t.Errorf("Esperava ErrAuditoriaAdulterada, mas recebi %v", err)
+	}
+}
+
+func TestAuditoriaArquivoEncadeiaELevaErros(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	servico.DefinirAuditoria(NovoRepositorioAuditoriaArquivo(caminho))
+	ctx := ComAtor(context.Background(), Ator{Usuario: "maria"})
+
+	// muitas gravações: cada uma lê só a última linha para encadear
+	viga := NovoProduto("viga", 500)
+	if err := servico.CadastrarProduto(ctx, viga); err != nil {
+		t.Fatalf("Não esperava erro no cadastro, mas recebi %v", err)
+	}
+	for range 200 {
+		if err := servico.VenderProduto(ctx, viga.ID, 1); err != nil {
+			t.Fatalf("Não esperava erro na venda, mas recebi %v", err)
+		}
+	}
+	if err := servico.VerificarAuditoria(); err != nil {
+		t.Fatalf("Não esperava erro na trilha íntegra, mas recebi %v", err)
+	}
+	if total := len(servico.ConsultarAuditoria(FiltroAuditoria{})); total != 201 {
+		t.Errorf("Esperava 201 registros, mas encontrei %d", total)
+	}
+
+	// uma trilha que não pode ser gravada vira aviso: a operação já foi feita e devolve sucesso
+	var avisos []error
+	servico.DefinirAvisoAuditoria(func(_ context.Context, operacao string, err error) {
+		if operacao != OperacaoVenda {
+			t.Errorf("Esperava o aviso da venda, mas recebi o de %s", operacao)
+		}
+		avisos = append(avisos, err)
+	})
+	arquivo := filepath.Join(filepath.Dir(caminho), "estoque.auditoria.jsonl")
+	if err := os.Remove(arquivo); err != nil {
+		t.Fatalf("Não esperava erro ao remover a trilha, mas recebi %v", err)
+	}
+	if err := os.Mkdir(arquivo, 0755); err != nil {
+		t.Fatalf("Não esperava erro ao criar o diretório, mas recebi %v", err)
+	}
+	if err := servico.VenderProduto(ctx, viga.ID, 1); err != nil {
+		t.Fatalf("A venda foi gravada e não deveria devolver erro (quem repete a venda venderia duas vezes), mas recebi %v", err)
+	}
+	if len(avisos) != 1 || !errors.Is(avisos[0], ErrAuditoriaNaoGravada) {
+		t.Fatalf("Esperava um aviso com ErrAuditoriaNaoGravada, mas recebi %v", avisos)
+	}
+	if produto, _ := servico.BuscarProduto(viga.ID); produto.Quantidade != 299 {
+		t.Errorf("A venda já estava gravada quando a auditoria falhou: esperava 299, mas encontrei %d", produto.Quantidade)
+	}
+}
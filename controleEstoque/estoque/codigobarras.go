@@ -0,0 +1,122 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para detalhar o código recusado
+	"strings" // pacote para limpar o código lido
+)
+
+// Erro para indicar que o código de barras não tem o tamanho de um GTIN ou o dígito verificador não confere
+var ErrGTINInvalido = errors.New("código de barras GTIN inválido")
+
+// Erro para indicar que o código de barras já pertence a outro produto
+var ErrGTINDuplicado = errors.New("código de barras já usado por outro produto")
+
+// NormalizarGTIN tira espaços, hífens e quebras de linha que leitores e planilhas costumam deixar no código
+func NormalizarGTIN(codigo string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, codigo)
+}
+
+// ValidarGTIN confere se o código é um GTIN-8, GTIN-12 (UPC-A), GTIN-13 (EAN-13) ou GTIN-14
+// com o dígito verificador correto
+func ValidarGTIN(codigo string) error {
+	codigo = NormalizarGTIN(codigo)
+	switch len(codigo) {
+	case 8, 12, 13, 14:
+	default:
+		return fmt.Errorf("%w: %q deve ter 8, 12, 13 ou 14 dígitos", ErrGTINInvalido, codigo)
+	}
+
+	digito, err := DigitoVerificadorGTIN(codigo[:len(codigo)-1])
+	if err != nil {
+		return err
+	}
+	if int(codigo[len(codigo)-1]-'0') != digito {
+		return fmt.Errorf("%w: %q deveria terminar em %d", ErrGTINInvalido, codigo, digito)
+	}
+	return nil
+}
+
+// DigitoVerificadorGTIN calcula o dígito verificador para os dígitos informados (sem o verificador)
+// Da direita para a esquerda os dígitos têm peso 3 e 1 alternados; o verificador completa a soma até o múltiplo de 10
+func DigitoVerificadorGTIN(semDigito string) (int, error) {
+	soma := 0
+	for i := len(semDigito) - 1; i >= 0; i-- {
+		c := semDigito[i]
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: %q tem caracteres que não são dígitos", ErrGTINInvalido, semDigito)
+		}
+		peso := 1
+		if (len(semDigito)-1-i)%2 == 0 {
+			peso = 3
+		}
+		soma += int(c-'0') * peso
+	}
+	return (10 - soma%10) % 10, nil
+}
+
+// mesmoGTIN compara códigos de tamanhos diferentes completando com zeros à esquerda
+// Assim o UPC-A 012345678905 encontra o EAN-13 0012345678905
+func mesmoGTIN(a, b string) bool {
+	return a != "" && b != "" && gtin14(a) == gtin14(b)
+}
+
+// gtin14 completa o código com zeros à esquerda até 14 dígitos
+func gtin14(codigo string) string {
+	if len(codigo) >= 14 {
+		return codigo
+	}
+	return strings.Repeat("0", 14-len(codigo)) + codigo
+}
+
+// DefinirGTIN troca o código de barras do produto; código vazio remove o código
+// Retorna ErrGTINInvalido se o dígito verificador não conferir e ErrGTINDuplicado se outro produto já usa o código
+func (s *ServicoEstoque) DefinirGTIN(ctx context.Context, id, gtin string) error {
+	gtin = NormalizarGTIN(gtin)
+	if gtin != "" {
+		if err := ValidarGTIN(gtin); err != nil {
+			return err
+		}
+		if outro, err := s.BuscarPorGTIN(gtin); err == nil && outro.ID != id {
+			return fmt.Errorf("%w: %s", ErrGTINDuplicado, outro.Nome)
+		}
+	}
+
+	return s.alterarProduto(ctx, OperacaoCodigoBarras, id, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		produto.GTIN = gtin
+		return nil, nil // mudar o código não movimenta o estoque
+	})
+}
+
+// BuscarPorGTIN encontra o produto pelo código de barras lido (GTIN-12 e GTIN-13 do mesmo produto se equivalem)
+func (s *ServicoEstoque) BuscarPorGTIN(gtin string) (Produto, error) {
+	gtin = NormalizarGTIN(gtin)
+	if err := ValidarGTIN(gtin); err != nil {
+		return Produto{}, err
+	}
+	for _, produto := range s.repositorio.Listar() {
+		if mesmoGTIN(produto.GTIN, gtin) {
+			return produto, nil
+		}
+	}
+	return Produto{}, ErrProdutoNaoEncontrado
+}
+
+// VenderPorGTIN vende o produto do código de barras lido pelo leitor, no local informado ("" = todos os locais)
+// Retorna o produto com o saldo depois da venda
+func (s *ServicoEstoque) VenderPorGTIN(ctx context.Context, gtin, local string, quantidade int) (Produto, error) {
+	produto, err := s.BuscarPorGTIN(gtin)
+	if err != nil {
+		return Produto{}, err
+	}
+	if err := s.VenderProdutoNoLocal(ctx, produto.ID, local, quantidade); err != nil {
+		return produto, err
+	}
+	return s.buscarProduto(produto.ID)
+}
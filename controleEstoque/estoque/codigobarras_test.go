@@ -0,0 +1,154 @@
+package estoque
+
+import (
+	"bytes"   // pacote padrão para guardar as etiquetas geradas em memória
+	"context" // pacote padrão para passar o ator das operações
+	"errors"  // pacote padrão para comparar erros com errors.Is
+	"strings" // pacote padrão para procurar textos nas etiquetas
+	"testing" // pacote padrão do Go para testes
+	"time"    // pacote padrão para as datas dos lotes
+)
+
+func TestValidarGTIN(t *testing.T) {
+	validos := []string{"4006381333931", "7891234567895", "036000291452", "96385074", "17891234567892", " 789-1234567895\r\n"}
+	for _, codigo := range validos {
+		if err := ValidarGTIN(codigo); err != nil {
+			t.Errorf("Esperava %q válido, mas recebi %v", codigo, err)
+		}
+	}
+
+	invalidos := []string{"", "4006381333932", "123", "78912345678A5", "789123456789512"}
+	for _, codigo := range invalidos {
+		if err := ValidarGTIN(codigo); !errors.Is(err, ErrGTINInvalido) {
+			t.Errorf("Esperava ErrGTINInvalido para %q, mas recebi %v", codigo, err)
+		}
+	}
+
+	if digito, _ := DigitoVerificadorGTIN("400638133393"); digito != 1 {
+		t.Errorf("Esperava dígito verificador 1, mas recebi %d", digito)
+	}
+}
+
+func TestVenderPorGTIN(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 10)
+	coluna := NovoProduto("coluna", 5)
+	servico.CadastrarProduto(ctx, viga)
+	servico.CadastrarProduto(ctx, coluna)
+
+	if err := servico.DefinirGTIN(ctx, viga.ID, "7891234567894"); !errors.Is(err, ErrGTINInvalido) {
+		t.Errorf("Esperava ErrGTINInvalido com o dígito errado, mas recebi %v", err)
+	}
+	if err := servico.DefinirGTIN(ctx, viga.ID, "036000291452"); err != nil {
+		t.Fatalf("Não esperava erro ao gravar o código, mas recebi %v", err)
+	}
+	if err := servico.DefinirGTIN(ctx, coluna.ID, "0036000291452"); !errors.Is(err, ErrGTINDuplicado) {
+		t.Errorf("Esperava ErrGTINDuplicado para o mesmo código com 13 dígitos, mas recebi %v", err)
+	}
+
+	// o leitor devolve o UPC-A como EAN-13, com zero à esquerda
+	produto, err := servico.VenderPorGTIN(ctx, "0036000291452\r\n", "", 3)
+	if err != nil {
+		t.Fatalf("Não esperava erro na venda pelo código, mas recebi %v", err)
+	}
+	if produto.ID != viga.ID || produto.Quantidade != 7 {
+		t.Errorf("Esperava a viga com 7 unidades, mas encontrei %+v", produto)
+	}
+	if _, err := servico.VenderPorGTIN(ctx, "7891234567895", "", 1); !errors.Is(err, ErrProdutoNaoEncontrado) {
+		t.Errorf("Esperava ErrProdutoNaoEncontrado para código sem produto, mas recebi %v", err)
+	}
+	if _, err := servico.VenderPorGTIN(ctx, "036000291452", "", 50); !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Errorf("Esperava ErrEstoqueInsuficiente, mas recebi %v", err)
+	}
+}
+
+func TestModulosEAN13(t *testing.T) {
+	modulos, err := modulosEAN13("4006381333931")
+	if err != nil {
+		t.Fatalf("Não esperava erro, mas recebi %v", err)
+	}
+	esperado := "101" + "0001101" + "0100111" + "0101111" + "0111101" + "0001001" + "0110011" + // 006381 em LGLLGG (paridade do 4)
+		"01010" + "1000010" + "1000010" + "1000010" + "1110100" + "1000010" + "1100110" + "101" // 333931
+	if modulos != esperado {
+		t.Errorf("Módulos do EAN-13 inesperados:\n%s\n%s", modulos, esperado)
+	}
+	if _, err := modulosEAN13("96385074"); !errors.Is(err, ErrGTINInvalido) {
+		t.Errorf("Esperava ErrGTINInvalido para GTIN-8 na etiqueta, mas recebi %v", err)
+	}
+}
+
+func TestEscreverEtiquetas(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga <protendida>", 0)
+	servico.CadastrarProduto(ctx, viga)
+	servico.DefinirGTIN(ctx, viga.ID, "7891234567895")
+	servico.RegistrarLote(ctx, viga.ID, NovoLote("V-0603", time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), 28, 20))
+	servico.CadastrarProduto(ctx, NovoProduto("coluna", 5)) // sem código: fica fora das etiquetas
+
+	etiquetas, err := servico.EtiquetasLotes()
+	if err != nil || len(etiquetas) != 1 || !strings.Contains(etiquetas[0].Detalhe, "V-0603") || !strings.Contains(etiquetas[0].Detalhe, "01/07/2024") {
+		t.Fatalf("Esperava uma etiqueta do lote V-0603, mas encontrei %+v (erro %v)", etiquetas, err)
+	}
+	if _, err := servico.EtiquetasProdutos(gerarID("coluna")); !errors.Is(err, ErrGTINInvalido) {
+		t.Errorf("Esperava ErrGTINInvalido para produto sem código, mas recebi %v", err)
+	}
+
+	var svg bytes.Buffer
+	if err := EscreverEtiquetas(&svg, EtiquetaSVG, etiquetas); err != nil {
+		t.Fatalf("Não esperava erro no SVG, mas recebi %v", err)
+	}
+	if !strings.Contains(svg.String(), "viga &lt;protendida&gt;") || !strings.Contains(svg.String(), ">7891234567895<") {
+		t.Errorf("SVG sem o nome escapado ou sem os dígitos:\n%s", svg.String())
+	}
+	if barras := strings.Count(svg.String(), "<rect x="); barras != len(barrasDosModulos(modulosOuFalha(t, "7891234567895"))) {
+		t.Errorf("Número de barras inesperado no SVG: %d", barras)
+	}
+
+	var pdf bytes.Buffer
+	if err := EscreverEtiquetas(&pdf, EtiquetaPDF, etiquetas); err != nil {
+		t.Fatalf("Não esperava erro no PDF, mas recebi %v", err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf.Bytes(), []byte("%%EOF\n")) || !bytes.Contains(pdf.Bytes(), []byte("/Count 1")) {
+		t.Errorf("PDF inesperado:\n%s", pdf.String())
+	}
+
+	if err := EscreverEtiquetas(&pdf, FormatoEtiqueta("png"), etiquetas); !errors.Is(err, ErrFormatoInvalido) {
+		t.Errorf("Esperava ErrFormatoInvalido, mas recebi %v", err)
+	}
+}
+
+// modulosOuFalha devolve os módulos do código ou encerra o teste
+func modulosOuFalha(t *testing.T, gtin string) string {
+	t.Helper()
+	modulos, err := modulosEAN13(gtin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modulos
+}
+
+func TestImportarGTIN(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+
+	invalida := "sku,nome,quantidade,gtin\nA1,viga,10,7891234567894\n"
+	relatorio, err := servico.Importar(ctx, strings.NewReader(invalida), OpcoesImportacao{Formato: FormatoCSV})
+	if !errors.Is(err, ErrImportacaoInvalida) || len(relatorio.Erros) != 1 || relatorio.Erros[0].Campo != CampoGTIN {
+		t.Fatalf("Esperava erro no campo gtin, mas recebi %+v (erro %v)", relatorio.Erros, err)
+	}
+
+	repetida := "sku,nome,quantidade,gtin\nA1,viga,10,7891234567895\nA2,coluna,5,7891234567895\n"
+	if _, err := servico.Importar(ctx, strings.NewReader(repetida), OpcoesImportacao{Formato: FormatoCSV}); !errors.Is(err, ErrImportacaoInvalida) {
+		t.Fatalf("Esperava ErrImportacaoInvalida com código repetido, mas recebi %v", err)
+	}
+
+	valida := "sku,nome,quantidade,gtin\nA1,viga,10,789 1234 56789 5\n"
+	if _, err := servico.Importar(ctx, strings.NewReader(valida), OpcoesImportacao{Formato: FormatoCSV}); err != nil {
+		t.Fatalf("Não esperava erro na importação, mas recebi %v", err)
+	}
+	if produto, err := servico.BuscarPorGTIN("7891234567895"); err != nil || produto.Nome != "viga" {
+		t.Errorf("Esperava encontrar a viga pelo código importado, mas encontrei %+v (erro %v)", produto, err)
+	}
+}
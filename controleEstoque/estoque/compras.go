@@ -0,0 +1,397 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para formatação de strings
+	"sort"    // pacote para ordenar os pedidos gerados
+	"sync"    // pacote para não receber o mesmo pedido duas vezes ao mesmo tempo
+	"time"    // pacote para as datas do pedido
+)
+
+// Erro para indicar que o pedido de compra não existe
+var ErrPedidoNaoEncontrado = errors.New("pedido de compra não encontrado")
+
+// Erro para indicar que a operação não é permitida no status atual do pedido
+var ErrStatusPedidoInvalido = errors.New("operação inválida para o status do pedido")
+
+// Erro para indicar que o recebimento é maior do que o que falta receber do item
+var ErrRecebimentoExcedido = errors.New("quantidade recebida maior que a pendente no pedido")
+
+// StatusPedido é a etapa em que o pedido de compra está
+type StatusPedido string
+
+const (
+	PedidoRascunho             StatusPedido = "rascunho"              // ainda pode ser conferido, não foi enviado ao fornecedor
+	PedidoEnviado              StatusPedido = "enviado"               // enviado ao fornecedor, aguardando a entrega
+	PedidoParcialmenteRecebido StatusPedido = "parcialmente_recebido" // parte dos itens já chegou
+	PedidoRecebido             StatusPedido = "recebido"              // todos os itens chegaram
+)
+
+// ItemPedido é um produto dentro do pedido de compra
+type ItemPedido struct {
+	ProdutoID     string
+	Produto       string
+	Quantidade    int
+	Recebido      int
+	CustoUnitario float64
+}
+
+// Pendente retorna quantas unidades do item ainda não chegaram
+func (i ItemPedido) Pendente() int {
+	return max(i.Quantidade-i.Recebido, 0)
+}
+
+// PedidoCompra é um pedido feito a um fornecedor
+type PedidoCompra struct {
+	ID              string
+	FornecedorID    string
+	Status          StatusPedido
+	Criacao         time.Time
+	Envio           *time.Time `json:",omitempty"` // nil enquanto for rascunho
+	PrevisaoEntrega *time.Time `json:",omitempty"` // envio + prazo de entrega do fornecedor
+	Itens           []ItemPedido
+}
+
+// aberto indica se o pedido ainda espera mercadoria (conta como "em pedido" na reposição)
+func (p PedidoCompra) aberto() bool {
+	return p.Status == PedidoRascunho || p.Status == PedidoEnviado || p.Status == PedidoParcialmenteRecebido
+}
+
+// ItemRecebido informa quanto chegou de um produto em um recebimento
+type ItemRecebido struct {
+	ProdutoID  string
+	Quantidade int
+}
+
+// SugestaoReposicao é uma linha do relatório de reposição
+type SugestaoReposicao struct {
+	ProdutoID     string
+	Produto       string
+	FornecedorID  string // vazio quando o produto ainda não tem fornecedor
+	Quantidade    int    // saldo atual
+	EmPedido      int    // unidades em pedidos de compra ainda abertos
+	EstoqueMinimo int
+	EstoqueMaximo int
+	Sugerido      int // quanto pedir para chegar ao estoque máximo
+}
+
+// RepositorioCompras define o contrato de armazenamento de fornecedores e pedidos de compra
+type RepositorioCompras interface {
+	SalvarFornecedor(fornecedor Fornecedor)         // inclui ou substitui o fornecedor com o mesmo ID
+	BuscarFornecedor(id string) (Fornecedor, error) // ErrFornecedorNaoEncontrado se não existir
+	ListarFornecedores() []Fornecedor               // em ordem de cadastro
+	SalvarPedido(pedido PedidoCompra)               // inclui ou substitui o pedido com o mesmo ID
+	BuscarPedido(id string) (PedidoCompra, error)   // ErrPedidoNaoEncontrado se não existir
+	ListarPedidos() []PedidoCompra                  // em ordem de criação
+}
+
+// ServicoCompras cuida dos fornecedores e do ciclo dos pedidos de compra
+// As entradas no estoque são feitas pelo ServicoEstoque, assim ficam no livro de movimentações com custo
+type ServicoCompras struct {
+	estoque     *ServicoEstoque
+	repositorio RepositorioCompras
+	mu          sync.Mutex // um pedido não pode ser recebido duas vezes ao mesmo tempo
+}
+
+// NovoServicoCompras cria o serviço de compras sobre o serviço de estoque
+func NovoServicoCompras(estoque *ServicoEstoque, repositorio RepositorioCompras) *ServicoCompras {
+	return &ServicoCompras{estoque: estoque, repositorio: repositorio}
+}
+
+// CadastrarFornecedor grava um fornecedor novo ou atualiza um já cadastrado
+func (c *ServicoCompras) CadastrarFornecedor(fornecedor Fornecedor) error {
+	if fornecedor.ID == "" || fornecedor.Nome == "" || fornecedor.PrazoEntregaDias < 0 {
+		return ErrValorInvalido
+	}
+	c.repositorio.SalvarFornecedor(fornecedor)
+	return nil
+}
+
+// ListarFornecedores retorna os fornecedores cadastrados
+func (c *ServicoCompras) ListarFornecedores() []Fornecedor {
+	return c.repositorio.ListarFornecedores()
+}
+
+// DefinirReposicao liga o produto a um fornecedor e define o estoque mínimo (ponto de pedido) e máximo
+func (c *ServicoCompras) DefinirReposicao(ctx context.Context, produtoID, fornecedorID string, minimo, maximo int) error {
+	if minimo < 0 || maximo < 0 || (maximo > 0 && maximo <= minimo) {
+		return ErrValorInvalido
+	}
+	if _, err := c.repositorio.BuscarFornecedor(fornecedorID); err != nil {
+		return err
+	}
+
+	return c.estoque.alterarProduto(ctx, OperacaoReposicao, produtoID, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		produto.FornecedorID = fornecedorID
+		produto.EstoqueMinimo = minimo
+		produto.EstoqueMaximo = maximo
+		return nil, nil // mudar a reposição não movimenta o estoque
+	})
+}
+
+// CriarPedido cria um pedido em rascunho para o fornecedor
+// O nome do produto é preenchido a partir do estoque
+func (c *ServicoCompras) CriarPedido(fornecedorID string, itens []ItemPedido) (PedidoCompra, error) {
+	if _, err := c.repositorio.BuscarFornecedor(fornecedorID); err != nil {
+		return PedidoCompra{}, err
+	}
+	if len(itens) == 0 {
+		return PedidoCompra{}, ErrValorInvalido
+	}
+
+	pedido := PedidoCompra{
+		ID:           gerarIDMovimento(), // gerarIDMovimento vem do arquivo movimento.go
+		FornecedorID: fornecedorID,
+		Status:       PedidoRascunho,
+		Criacao:      c.estoque.agora(),
+	}
+	for _, item := range itens {
+		if item.Quantidade <= 0 || item.CustoUnitario < 0 {
+			return PedidoCompra{}, ErrValorInvalido
+		}
+		produto, err := c.estoque.buscarProduto(item.ProdutoID)
+		if err != nil {
+			return PedidoCompra{}, err
+		}
+		item.Produto = produto.Nome
+		item.Recebido = 0
+		pedido.Itens = append(pedido.Itens, item)
+	}
+
+	c.repositorio.SalvarPedido(pedido)
+	return pedido, nil
+}
+
+// BuscarPedido retorna o pedido de compra com o ID informado
+func (c *ServicoCompras) BuscarPedido(id string) (PedidoCompra, error) {
+	return c.repositorio.BuscarPedido(id)
+}
+
+// ListarPedidos retorna os pedidos de compra em ordem de criação
+func (c *ServicoCompras) ListarPedidos() []PedidoCompra {
+	return c.repositorio.ListarPedidos()
+}
+
+// EnviarPedido marca o rascunho como enviado ao fornecedor e calcula a previsão de entrega
+func (c *ServicoCompras) EnviarPedido(id string) (PedidoCompra, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pedido, err := c.repositorio.BuscarPedido(id)
+	if err != nil {
+		return PedidoCompra{}, err
+	}
+	if pedido.Status != PedidoRascunho {
+		return PedidoCompra{}, fmt.Errorf("%w: pedido %s está %s", ErrStatusPedidoInvalido, pedido.ID, pedido.Status)
+	}
+
+	envio := c.estoque.agora()
+	pedido.Status = PedidoEnviado
+	pedido.Envio = &envio
+	if fornecedor, err := c.repositorio.BuscarFornecedor(pedido.FornecedorID); err == nil {
+		previsao := envio.AddDate(0, 0, fornecedor.PrazoEntregaDias)
+		pedido.PrevisaoEntrega = &previsao
+	}
+
+	c.repositorio.SalvarPedido(pedido)
+	return pedido, nil
+}
+
+// ReceberPedido dá entrada no estoque do que chegou do pedido, no local informado
+// Cada item vira uma entrada com o custo do pedido (RegistrarEntrada) e o pedido passa para
+// parcialmente recebido ou recebido. Todo o recebimento é conferido antes de dar qualquer entrada
+// As entradas e o pedido são gravados em repositórios diferentes: antes de conferir, o recebido de cada item é
+// acertado pelas entradas do livro com o motivo do pedido, então um recebimento interrompido depois das entradas
+// (queda antes de salvar o pedido) não deixa receber as mesmas unidades de novo
+func (c *ServicoCompras) ReceberPedido(ctx context.Context, id, local string, recebidos []ItemRecebido) (PedidoCompra, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pedido, err := c.repositorio.BuscarPedido(id)
+	if err != nil {
+		return PedidoCompra{}, err
+	}
+	if c.conciliarComLivro(&pedido) {
+		c.repositorio.SalvarPedido(pedido) // o pedido volta a bater com o estoque mesmo se este recebimento for recusado
+	}
+	if pedido.Status != PedidoEnviado && pedido.Status != PedidoParcialmenteRecebido {
+		return PedidoCompra{}, fmt.Errorf("%w: pedido %s está %s", ErrStatusPedidoInvalido, pedido.ID, pedido.Status)
+	}
+	if local == "" {
+		local = LocalPadrao
+	}
+
+	// confere tudo antes de mexer no estoque
+	posicoes := make([]int, len(recebidos))
+	pendentes := map[int]int{}
+	for i, recebido := range recebidos {
+		if recebido.Quantidade <= 0 {
+			return PedidoCompra{}, ErrValorInvalido
+		}
+		posicao := -1
+		for j, item := range pedido.Itens {
+			if item.ProdutoID == recebido.ProdutoID {
+				posicao = j
+				break
+			}
+		}
+		if posicao < 0 {
+			return PedidoCompra{}, fmt.Errorf("%w: produto %s não está no pedido %s", ErrProdutoNaoEncontrado, recebido.ProdutoID, pedido.ID)
+		}
+		if _, visto := pendentes[posicao]; !visto {
+			pendentes[posicao] = pedido.Itens[posicao].Pendente()
+		}
+		pendentes[posicao] -= recebido.Quantidade
+		if pendentes[posicao] < 0 {
+			return PedidoCompra{}, fmt.Errorf("%w: %s", ErrRecebimentoExcedido, pedido.Itens[posicao].Produto)
+		}
+		posicoes[i] = posicao
+	}
+
+	motivo := motivoPedido(pedido.ID)
+	for i, recebido := range recebidos {
+		item := &pedido.Itens[posicoes[i]]
+		if err = c.estoque.registrarEntrada(ctx, item.ProdutoID, local, recebido.Quantidade, item.CustoUnitario, motivo); err != nil {
+			break // grava abaixo o que já entrou, para o pedido não ficar diferente do estoque
+		}
+		item.Recebido += recebido.Quantidade
+	}
+
+	pedido.atualizarStatus()
+	c.repositorio.SalvarPedido(pedido)
+	return pedido, err
+}
+
+// motivoPedido é o motivo das entradas de um pedido no livro; liga cada entrada ao pedido
+func motivoPedido(id string) string {
+	return "pedido de compra " + id
+}
+
+// conciliarComLivro soma as entradas do livro com o motivo do pedido e, se passarem do recebido gravado,
+// completa o recebido dos itens do produto na ordem do pedido. Retorna true se o pedido mudou
+// Só entradas originais contam: um estorno não muda o recebido, como quando o pedido foi salvo
+func (c *ServicoCompras) conciliarComLivro(pedido *PedidoCompra) bool {
+	noLivro := map[string]int{}
+	motivo := motivoPedido(pedido.ID)
+	for _, movimento := range c.estoque.repositorio.ListarMovimentos() {
+		if movimento.Tipo == MovimentoEntrada && movimento.Motivo == motivo && movimento.Estorno == "" {
+			noLivro[movimento.ProdutoID] += movimento.Quantidade
+		}
+	}
+
+	for _, item := range pedido.Itens {
+		noLivro[item.ProdutoID] -= item.Recebido // o que sobrar entrou no estoque sem chegar ao pedido
+	}
+	mudou := false
+	for i := range pedido.Itens {
+		item := &pedido.Itens[i]
+		faltando := min(noLivro[item.ProdutoID], item.Pendente())
+		if faltando <= 0 {
+			continue
+		}
+		item.Recebido += faltando
+		noLivro[item.ProdutoID] -= faltando
+		mudou = true
+	}
+	if mudou {
+		pedido.atualizarStatus()
+	}
+	return mudou
+}
+
+// atualizarStatus passa o pedido para recebido, parcialmente recebido ou de volta a enviado conforme os itens
+func (p *PedidoCompra) atualizarStatus() {
+	p.Status = PedidoRecebido
+	recebeuAlgo := false
+	for _, item := range p.Itens {
+		if item.Pendente() > 0 {
+			p.Status = PedidoParcialmenteRecebido
+		}
+		recebeuAlgo = recebeuAlgo || item.Recebido > 0
+	}
+	if !recebeuAlgo {
+		p.Status = PedidoEnviado // nada entrou (recebimento vazio ou falhou logo no primeiro item)
+	}
+}
+
+// RelatorioReposicao lista os produtos com saldo (mais o que já está em pedido) no estoque mínimo ou abaixo
+// A sugestão completa o estoque até o máximo do produto (ou o dobro do mínimo, se o máximo não foi definido)
+func (c *ServicoCompras) RelatorioReposicao() []SugestaoReposicao {
+	emPedido := map[string]int{}
+	for _, pedido := range c.repositorio.ListarPedidos() {
+		if !pedido.aberto() {
+			continue
+		}
+		for _, item := range pedido.Itens {
+			emPedido[item.ProdutoID] += item.Pendente()
+		}
+	}
+
+	var sugestoes []SugestaoReposicao
+	for _, produto := range c.estoque.produtosUnicos() { // produtosUnicos vem do arquivo relatorios.go
+		previsto := produto.Quantidade + emPedido[produto.ID]
+		if produto.EstoqueMinimo <= 0 || previsto > produto.EstoqueMinimo {
+			continue
+		}
+		alvo := produto.EstoqueMaximo
+		if alvo <= produto.EstoqueMinimo {
+			alvo = 2 * produto.EstoqueMinimo
+		}
+		sugestoes = append(sugestoes, SugestaoReposicao{
+			ProdutoID:     produto.ID,
+			Produto:       produto.Nome,
+			FornecedorID:  produto.FornecedorID,
+			Quantidade:    produto.Quantidade,
+			EmPedido:      emPedido[produto.ID],
+			EstoqueMinimo: produto.EstoqueMinimo,
+			EstoqueMaximo: produto.EstoqueMaximo,
+			Sugerido:      alvo - previsto,
+		})
+	}
+	return sugestoes
+}
+
+// GerarPedidosReposicao cria um pedido em rascunho por fornecedor com as sugestões do relatório de reposição
+// O custo de cada item é o da última entrada do produto; produtos sem fornecedor ficam de fora
+func (c *ServicoCompras) GerarPedidosReposicao() ([]PedidoCompra, error) {
+	movimentos := c.estoque.movimentosPorProduto() // movimentosPorProduto vem do arquivo relatorios.go
+	porFornecedor := map[string][]ItemPedido{}
+	for _, sugestao := range c.RelatorioReposicao() {
+		if sugestao.FornecedorID == "" {
+			continue
+		}
+		porFornecedor[sugestao.FornecedorID] = append(porFornecedor[sugestao.FornecedorID], ItemPedido{
+			ProdutoID:     sugestao.ProdutoID,
+			Quantidade:    sugestao.Sugerido,
+			CustoUnitario: ultimoCusto(movimentos[sugestao.ProdutoID]),
+		})
+	}
+
+	fornecedores := make([]string, 0, len(porFornecedor))
+	for fornecedorID := range porFornecedor {
+		fornecedores = append(fornecedores, fornecedorID)
+	}
+	sort.Strings(fornecedores) // ordem estável entre execuções
+
+	var pedidos []PedidoCompra
+	for _, fornecedorID := range fornecedores {
+		pedido, err := c.CriarPedido(fornecedorID, porFornecedor[fornecedorID])
+		if err != nil {
+			return pedidos, err
+		}
+		pedidos = append(pedidos, pedido)
+	}
+	return pedidos, nil
+}
+
+// ultimoCusto retorna o custo unitário da entrada mais recente entre os movimentos do produto (0 se não houver)
+func ultimoCusto(movimentos []Movimento) float64 {
+	custo := 0.0
+	for _, movimento := range movimentos {
+		if movimento.Tipo == MovimentoEntrada && movimento.CustoUnitario > 0 {
+			custo = movimento.CustoUnitario
+		}
+	}
+	return custo
+}
@@ -0,0 +1,92 @@
+package estoque
+
+import (
+	"encoding/json" // serve para codificar e decodificar o arquivo de compras
+	"os"            // serve para ler e escrever o arquivo
+	"path/filepath" // serve para trocar a extensão do arquivo
+	"strings"       // serve para manipular o caminho do arquivo
+	"sync"          // serve para não gravar o arquivo ao mesmo tempo
+)
+
+// RepositorioComprasArquivo implementa o RepositorioCompras em um arquivo JSON ao lado do estoque
+// estoque.json -> estoque.compras.json; o arquivo é lido a cada operação, como o de movimentos
+type RepositorioComprasArquivo struct {
+	caminho string
+	mu      sync.Mutex
+}
+
+// dadosCompras é o conteúdo do arquivo de compras
+type dadosCompras struct {
+	Fornecedores []Fornecedor
+	Pedidos      []PedidoCompra
+}
+
+// NovoRepositorioComprasArquivo cria o repositório de compras ao lado do arquivo de estoque informado
+func NovoRepositorioComprasArquivo(caminhoEstoque string) *RepositorioComprasArquivo {
+	return &RepositorioComprasArquivo{
+		caminho: strings.TrimSuffix(caminhoEstoque, filepath.Ext(caminhoEstoque)) + ".compras.json",
+	}
+}
+
+// SalvarFornecedor inclui o fornecedor ou substitui o que tem o mesmo ID
+func (r *RepositorioComprasArquivo) SalvarFornecedor(fornecedor Fornecedor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dados := r.ler()
+	dados.Fornecedores = salvarFornecedor(dados.Fornecedores, fornecedor) // salvarFornecedor vem do arquivo compras_memoria.go
+	r.gravar(dados)
+}
+
+// BuscarFornecedor devolve o fornecedor com o ID informado
+func (r *RepositorioComprasArquivo) BuscarFornecedor(id string) (Fornecedor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return buscarFornecedor(r.ler().Fornecedores, id)
+}
+
+// ListarFornecedores devolve os fornecedores gravados
+func (r *RepositorioComprasArquivo) ListarFornecedores() []Fornecedor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ler().Fornecedores
+}
+
+// SalvarPedido inclui o pedido ou substitui o que tem o mesmo ID
+func (r *RepositorioComprasArquivo) SalvarPedido(pedido PedidoCompra) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dados := r.ler()
+	dados.Pedidos = salvarPedido(dados.Pedidos, pedido)
+	r.gravar(dados)
+}
+
+// BuscarPedido devolve o pedido com o ID informado
+func (r *RepositorioComprasArquivo) BuscarPedido(id string) (PedidoCompra, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return buscarPedido(r.ler().Pedidos, id)
+}
+
+// ListarPedidos devolve os pedidos gravados
+func (r *RepositorioComprasArquivo) ListarPedidos() []PedidoCompra {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ler().Pedidos
+}
+
+// ler decodifica o arquivo de compras (arquivo inexistente -> sem fornecedores nem pedidos)
+func (r *RepositorioComprasArquivo) ler() dadosCompras {
+	var dados dadosCompras
+	conteudo, err := os.ReadFile(r.caminho)
+	if err != nil {
+		return dados
+	}
+	json.Unmarshal(conteudo, &dados)
+	return dados
+}
+
+// gravar reescreve o arquivo de compras
+func (r *RepositorioComprasArquivo) gravar(dados dadosCompras) {
+	conteudo, _ := json.MarshalIndent(dados, "", " ")
+	os.WriteFile(r.caminho, conteudo, 0644)
+}
@@ -0,0 +1,111 @@
+package estoque
+
+import "sync"
+
+// RepositorioComprasMemoria implementa o RepositorioCompras guardando fornecedores e pedidos em memória
+type RepositorioComprasMemoria struct {
+	fornecedores []Fornecedor
+	pedidos      []PedidoCompra
+	mu           sync.Mutex // protege as listas em caso de concorrência
+}
+
+// NovoRepositorioComprasMemoria cria um repositório de compras em memória
+func NovoRepositorioComprasMemoria() *RepositorioComprasMemoria {
+	return &RepositorioComprasMemoria{}
+}
+
+// SalvarFornecedor inclui o fornecedor ou substitui o que tem o mesmo ID
+func (r *RepositorioComprasMemoria) SalvarFornecedor(fornecedor Fornecedor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fornecedores = salvarFornecedor(r.fornecedores, fornecedor)
+}
+
+// BuscarFornecedor devolve o fornecedor com o ID informado
+func (r *RepositorioComprasMemoria) BuscarFornecedor(id string) (Fornecedor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return buscarFornecedor(r.fornecedores, id)
+}
+
+// ListarFornecedores devolve uma cópia da lista de fornecedores
+func (r *RepositorioComprasMemoria) ListarFornecedores() []Fornecedor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fornecedor(nil), r.fornecedores...)
+}
+
+// SalvarPedido inclui o pedido ou substitui o que tem o mesmo ID
+func (r *RepositorioComprasMemoria) SalvarPedido(pedido PedidoCompra) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pedidos = salvarPedido(r.pedidos, pedido)
+}
+
+// BuscarPedido devolve uma cópia do pedido com o ID informado
+func (r *RepositorioComprasMemoria) BuscarPedido(id string) (PedidoCompra, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return buscarPedido(r.pedidos, id)
+}
+
+// ListarPedidos devolve uma cópia da lista de pedidos
+func (r *RepositorioComprasMemoria) ListarPedidos() []PedidoCompra {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pedidos := make([]PedidoCompra, len(r.pedidos))
+	for i, pedido := range r.pedidos {
+		pedidos[i] = pedido.clonar()
+	}
+	return pedidos
+}
+
+// clonar copia o pedido com a sua própria lista de itens, para o chamador não alterar o repositório por fora
+func (p PedidoCompra) clonar() PedidoCompra {
+	p.Itens = append([]ItemPedido(nil), p.Itens...)
+	return p
+}
+
+// salvarFornecedor substitui o fornecedor com o mesmo ID ou o acrescenta no final
+// Usado pelos repositórios de compras em memória e em arquivo
+func salvarFornecedor(fornecedores []Fornecedor, fornecedor Fornecedor) []Fornecedor {
+	for i := range fornecedores {
+		if fornecedores[i].ID == fornecedor.ID {
+			fornecedores[i] = fornecedor
+			return fornecedores
+		}
+	}
+	return append(fornecedores, fornecedor)
+}
+
+// buscarFornecedor procura o fornecedor pelo ID
+func buscarFornecedor(fornecedores []Fornecedor, id string) (Fornecedor, error) {
+	for _, fornecedor := range fornecedores {
+		if fornecedor.ID == id {
+			return fornecedor, nil
+		}
+	}
+	return Fornecedor{}, ErrFornecedorNaoEncontrado
+}
+
+// salvarPedido substitui o pedido com o mesmo ID ou o acrescenta no final
+func salvarPedido(pedidos []PedidoCompra, pedido PedidoCompra) []PedidoCompra {
+	pedido = pedido.clonar()
+	for i := range pedidos {
+		if pedidos[i].ID == pedido.ID {
+			pedidos[i] = pedido
+			return pedidos
+		}
+	}
+	return append(pedidos, pedido)
+}
+
+// buscarPedido procura o pedido pelo ID e devolve uma cópia
+func buscarPedido(pedidos []PedidoCompra, id string) (PedidoCompra, error) {
+	for _, pedido := range pedidos {
+		if pedido.ID == id {
+			return pedido.clonar(), nil
+		}
+	}
+	return PedidoCompra{}, ErrPedidoNaoEncontrado
+}
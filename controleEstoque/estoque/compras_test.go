@@ -0,0 +1,166 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para fixar a data do serviço
+)
+
+func TestPedidoCompraDoRascunhoAoRecebido(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	estoque := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	estoque.agora = func() time.Time { return time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC) }
+	compras := NovoServicoCompras(estoque, NovoRepositorioComprasArquivo(caminho))
+
+	cimento := NovoProduto("cimento", 4)
+	aco := NovoProduto("aço", 0)
+	estoque.CadastrarProduto(context.Background(), cimento)
+	estoque.CadastrarProduto(context.Background(), aco)
+
+	votoran := NovoFornecedor("Votoran", "vendas@votoran.com")
+	votoran.PrazoEntregaDias = 3
+	if err := compras.CadastrarFornecedor(votoran); err != nil {
+		t.Fatalf("Não esperava erro ao cadastrar o fornecedor, mas recebi %v", err)
+	}
+
+	pedido, err := compras.CriarPedido(votoran.ID, []ItemPedido{
+		{ProdutoID: cimento.ID, Quantidade: 10, CustoUnitario: 32.5},
+		{ProdutoID: aco.ID, Quantidade: 5, CustoUnitario: 48},
+	})
+	if err != nil || pedido.Status != PedidoRascunho || pedido.Itens[0].Produto != "cimento" {
+		t.Fatalf("Esperava um rascunho com o nome dos produtos, mas recebi %+v (erro %v)", pedido, err)
+	}
+
+	if _, err := compras.ReceberPedido(context.Background(), pedido.ID, "", []ItemRecebido{{cimento.ID, 1}}); !errors.Is(err, ErrStatusPedidoInvalido) {
+		t.Errorf("Rascunho não pode ser recebido, mas recebi %v", err)
+	}
+
+	pedido, _ = compras.EnviarPedido(pedido.ID)
+	if pedido.Status != PedidoEnviado || pedido.PrevisaoEntrega == nil || pedido.PrevisaoEntrega.Day() != 13 {
+		t.Errorf("Esperava pedido enviado com entrega prevista para o dia 13, mas recebi %+v", pedido)
+	}
+
+	// recebimento maior que o pedido é recusado sem dar entrada em nada
+	_, err = compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 6}, {cimento.ID, 5}})
+	if !errors.Is(err, ErrRecebimentoExcedido) {
+		t.Errorf("Esperava ErrRecebimentoExcedido, mas recebi %v", err)
+	}
+
+	pedido, err = compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 6}})
+	if err != nil || pedido.Status != PedidoParcialmenteRecebido {
+		t.Fatalf("Esperava pedido parcialmente recebido, mas recebi %s (erro %v)", pedido.Status, err)
+	}
+	pedido, err = compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 4}, {aco.ID, 5}})
+	if err != nil || pedido.Status != PedidoRecebido {
+		t.Fatalf("Esperava pedido recebido, mas recebi %s (erro %v)", pedido.Status, err)
+	}
+
+	produto, _ := estoque.BuscarProduto(cimento.ID)
+	if produto.Quantidade != 14 {
+		t.Errorf("Esperava 14 sacos de cimento, mas encontrei %d", produto.Quantidade)
+	}
+	entradas := 0
+	for _, movimento := range estoque.ListarMovimentos() {
+		if movimento.Motivo == "pedido de compra "+pedido.ID {
+			entradas++
+			if movimento.Tipo != MovimentoEntrada || movimento.CustoUnitario == 0 {
+				t.Errorf("Entrada do pedido sem custo: %+v", movimento)
+			}
+		}
+	}
+	if entradas != 3 {
+		t.Errorf("Esperava 3 entradas ligadas ao pedido, mas encontrei %d", entradas)
+	}
+}
+
+// repositorioComprasQueCai perde a próxima gravação de pedido, como uma queda entre as entradas e o SalvarPedido
+type repositorioComprasQueCai struct {
+	RepositorioCompras
+	cair bool
+}
+
+func (r *repositorioComprasQueCai) SalvarPedido(pedido PedidoCompra) {
+	if r.cair {
+		r.cair = false
+		return
+	}
+	r.RepositorioCompras.SalvarPedido(pedido)
+}
+
+func TestReceberPedidoDepoisDeQuedaNaoDuplicaEntrada(t *testing.T) {
+	estoque := NovoServicoEstoque(NovoRepositorioMemoria())
+	repositorio := &repositorioComprasQueCai{RepositorioCompras: NovoRepositorioComprasMemoria()}
+	compras := NovoServicoCompras(estoque, repositorio)
+	cimento := NovoProduto("cimento", 0)
+	estoque.CadastrarProduto(context.Background(), cimento)
+	votoran := NovoFornecedor("Votoran", "vendas@votoran.com")
+	compras.CadastrarFornecedor(votoran)
+	pedido, _ := compras.CriarPedido(votoran.ID, []ItemPedido{{ProdutoID: cimento.ID, Quantidade: 10, CustoUnitario: 32.5}})
+	compras.EnviarPedido(pedido.ID)
+
+	repositorio.cair = true
+	compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 6}}) // as 6 entram, o pedido não é salvo
+	if gravado, _ := compras.BuscarPedido(pedido.ID); gravado.Itens[0].Recebido != 0 {
+		t.Fatalf("A queda simulada deveria deixar o pedido sem o recebimento, mas encontrei %+v", gravado)
+	}
+
+	// o operador repete o recebimento das 10: só as 4 que faltam podem entrar
+	if _, err := compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 10}}); !errors.Is(err, ErrRecebimentoExcedido) {
+		t.Errorf("Esperava ErrRecebimentoExcedido ao receber de novo as unidades que já entraram, mas recebi %v", err)
+	}
+	gravado, _ := compras.BuscarPedido(pedido.ID)
+	if gravado.Itens[0].Recebido != 6 || gravado.Status != PedidoParcialmenteRecebido {
+		t.Errorf("Esperava o pedido acertado pelo livro com 6 recebidos, mas encontrei %+v", gravado)
+	}
+
+	pedido, err := compras.ReceberPedido(context.Background(), pedido.ID, LocalPatio, []ItemRecebido{{cimento.ID, 4}})
+	if err != nil || pedido.Status != PedidoRecebido {
+		t.Fatalf("Esperava pedido recebido, mas recebi %s (erro %v)", pedido.Status, err)
+	}
+	if produto, _ := estoque.BuscarProduto(cimento.ID); produto.Quantidade != 10 {
+		t.Errorf("Esperava 10 sacos de cimento, sem entrada duplicada, mas encontrei %d", produto.Quantidade)
+	}
+}
+
+func TestGerarPedidosReposicaoPorFornecedor(t *testing.T) {
+	estoque := NovoServicoEstoque(NovoRepositorioMemoria())
+	compras := NovoServicoCompras(estoque, NovoRepositorioComprasMemoria())
+
+	cimento, areia, brita, tinta := NovoProduto("cimento", 3), NovoProduto("areia", 2), NovoProduto("brita", 50), NovoProduto("tinta", 0)
+	for _, produto := range []Produto{cimento, areia, brita, tinta} {
+		estoque.CadastrarProduto(context.Background(), produto)
+	}
+	estoque.RegistrarEntrada(context.Background(), cimento.ID, LocalPadrao, 1, 30) // último custo do cimento
+
+	votoran, mineradora := NovoFornecedor("Votoran", ""), NovoFornecedor("Mineradora", "")
+	compras.CadastrarFornecedor(votoran)
+	compras.CadastrarFornecedor(mineradora)
+	compras.DefinirReposicao(context.Background(), cimento.ID, votoran.ID, 5, 20)   // 4 <= 5: pede 16
+	compras.DefinirReposicao(context.Background(), areia.ID, mineradora.ID, 10, 0)  // sem máximo: pede até 20
+	compras.DefinirReposicao(context.Background(), brita.ID, mineradora.ID, 10, 30) // acima do mínimo: não pede
+
+	if err := compras.DefinirReposicao(context.Background(), tinta.ID, "nao-existe", 1, 2); !errors.Is(err, ErrFornecedorNaoEncontrado) {
+		t.Errorf("Esperava ErrFornecedorNaoEncontrado, mas recebi %v", err)
+	}
+
+	pedidos, err := compras.GerarPedidosReposicao()
+	if err != nil || len(pedidos) != 2 {
+		t.Fatalf("Esperava 2 pedidos (um por fornecedor), mas recebi %d (erro %v)", len(pedidos), err)
+	}
+	quantidades := map[string]ItemPedido{}
+	for _, pedido := range pedidos {
+		for _, item := range pedido.Itens {
+			quantidades[item.ProdutoID] = item
+		}
+	}
+	if quantidades[cimento.ID].Quantidade != 16 || quantidades[cimento.ID].CustoUnitario != 30 || quantidades[areia.ID].Quantidade != 18 {
+		t.Errorf("Quantidades sugeridas inesperadas: %+v", quantidades)
+	}
+
+	// o que já está em pedido aberto não é pedido de novo
+	if sugestoes := compras.RelatorioReposicao(); len(sugestoes) != 0 {
+		t.Errorf("Não esperava novas sugestões com os pedidos abertos, mas recebi %+v", sugestoes)
+	}
+}
@@ -0,0 +1,182 @@
+package estoque
+
+import (
+	"context" // pacote para o ator da auditoria e o cancelamento das operações
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para formatação de strings
+)
+
+// TentativasPadrao é quantas vezes o serviço tenta uma operação de estoque que sofreu conflito de versão
+const TentativasPadrao = 5
+
+// Erro para indicar que o produto foi alterado por outra operação entre a leitura e a gravação
+// Use errors.Is(err, ErrConflito) para reconhecer um ErroConflito
+var ErrConflito = errors.New("conflito de versão do produto")
+
+// ErroConflito traz os detalhes de um conflito de versão em Atualizar
+type ErroConflito struct {
+	ProdutoID     string
+	VersaoEnviada int // versão do produto lido por quem tentou gravar
+	VersaoAtual   int // versão gravada no repositório
+}
+
+func (e *ErroConflito) Error() string {
+	return fmt.Sprintf("%s: produto %s na versão %d, mas a gravação partiu da versão %d", ErrConflito, e.ProdutoID, e.VersaoAtual, e.VersaoEnviada)
+}
+
+// Is permite comparar com errors.Is(err, ErrConflito)
+func (e *ErroConflito) Is(alvo error) bool {
+	return alvo == ErrConflito
+}
+
+// compararEGravar faz a troca condicional (compare-and-swap) usada pelos repositórios em Atualizar:
+// só aceita o produto se ele partiu da versão gravada, e devolve o produto com a versão seguinte
+func compararEGravar(atual, novo Produto) (Produto, error) {
+	if atual.Versao != novo.Versao {
+		return Produto{}, &ErroConflito{ProdutoID: novo.ID, VersaoEnviada: novo.Versao, VersaoAtual: atual.Versao}
+	}
+	novo = novo.clonar() // clonar vem do arquivo local.go
+	novo.Versao++
+	return novo, nil
+}
+
+// compararEGravarVarios faz a troca condicional de vários produtos de uma vez, usada em AtualizarVarios
+// Devolve as novas versões por posição na lista do repositório; se um produto não existir ou estiver
+// em outra versão, devolve o erro e nenhum produto deve ser gravado
+func compararEGravarVarios(atuais []Produto, indice map[string]int, produtos []Produto) (map[int]Produto, error) {
+	novos := make(map[int]Produto, len(produtos))
+	for _, produto := range produtos {
+		i, existe := indice[produto.ID]
+		if !existe {
+			return nil, ErrProdutoNaoEncontrado
+		}
+		if _, repetido := novos[i]; repetido {
+			return nil, ErrValorInvalido // o mesmo produto duas vezes na mesma gravação
+		}
+		novo, err := compararEGravar(atuais[i], produto)
+		if err != nil {
+			return nil, err
+		}
+		novos[i] = novo
+	}
+	return novos, nil
+}
+
+// compararEIncluir confere os produtos novos de uma operação, usada em GravarOperacao
+// Nenhum pode existir ainda: se outra operação cadastrou o mesmo ID no meio do caminho, devolve um
+// ErroConflito para a operação ser refeita sobre o estoque atualizado
+func compararEIncluir(atuais []Produto, indice map[string]int, novos []Produto) ([]Produto, error) {
+	incluidos := make([]Produto, 0, len(novos))
+	vistos := make(map[string]bool, len(novos))
+	for _, novo := range novos {
+		if i, existe := indice[novo.ID]; existe {
+			return nil, &ErroConflito{ProdutoID: novo.ID, VersaoEnviada: novo.Versao, VersaoAtual: atuais[i].Versao}
+		}
+		if vistos[novo.ID] {
+			return nil, ErrValorInvalido // o mesmo produto duas vezes na mesma gravação
+		}
+		vistos[novo.ID] = true
+		incluidos = append(incluidos, novo.clonar())
+	}
+	return incluidos, nil
+}
+
+// DefinirTentativas define quantas vezes uma operação de estoque é tentada quando há conflito de versão
+func (s *ServicoEstoque) DefinirTentativas(tentativas int) {
+	s.tentativas = max(tentativas, 1)
+}
+
+// alterarProduto lê o produto, aplica a alteração e grava com controle de versão
+// Se outra operação gravou o produto no meio do caminho (ErrConflito), tudo é refeito
+// a partir do produto atualizado, até o limite de tentativas do serviço
+// A alteração gravada entra na trilha de auditoria com o ator do contexto; uma falha da trilha não vira erro (auditoria.go)
+// Uma alteração que lança movimentos é recusada com ErrContagemAberta durante uma contagem em modo bloquear
+func (s *ServicoEstoque) alterarProduto(ctx context.Context, operacao, id string, alterar func(produto *Produto) ([]Movimento, error)) error {
+	var err error
+	for tentativa := 0; tentativa < s.tentativas; tentativa++ {
+		if err := ctx.Err(); err != nil {
+			return err // operação cancelada pelo chamador
+		}
+
+		var produto Produto
+		produto, err = s.buscarProduto(id)
+		if err != nil {
+			return err
+		}
+		antes := produto.clonar()
+
+		var movimentos []Movimento
+		movimentos, err = alterar(&produto)
+		if err != nil {
+			return err // erro de regra de negócio (estoque insuficiente, valor inválido...) não adianta repetir
+		}
+		if err := s.conferirBloqueio(movimentos); err != nil { // conferirBloqueio vem do arquivo contagem.go
+			return err
+		}
+
+		err = s.salvar(produto, movimentos)
+		if err == nil {
+			produto.Versao++ // a versão que ficou gravada
+			s.auditar(ctx, operacao, map[string]Produto{id: antes}, []Produto{produto}, movimentos)
+			return nil
+		}
+		if !errors.Is(err, ErrConflito) {
+			return err
+		}
+	}
+	return err // esgotou as tentativas, devolve o último ErroConflito
+}
+
+// alterarProdutos é o alterarProduto para operações que mexem em vários produtos juntos (ex: ordem de produção)
+// Todos são gravados de uma vez, junto com os movimentos (GravarOperacao); se algum mudou no meio do caminho, tudo é refeito
+func (s *ServicoEstoque) alterarProdutos(ctx context.Context, operacao string, ids []string, alterar func(produtos map[string]*Produto) ([]Movimento, error)) error {
+	var err error
+	for tentativa := 0; tentativa < s.tentativas; tentativa++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		produtos := make(map[string]*Produto, len(ids))
+		antes := make(map[string]Produto, len(ids))
+		alterados := make([]Produto, 0, len(ids))
+		for _, id := range ids {
+			if _, lido := produtos[id]; lido {
+				continue
+			}
+			produto, err := s.buscarProduto(id)
+			if err != nil {
+				return err
+			}
+			produtos[id] = &produto
+			antes[id] = produto.clonar()
+		}
+
+		var movimentos []Movimento
+		movimentos, err = alterar(produtos)
+		if err != nil {
+			return err
+		}
+		if err := s.conferirBloqueio(movimentos); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if produto, existe := produtos[id]; existe {
+				alterados = append(alterados, *produto)
+				delete(produtos, id) // cada produto entra uma vez só na gravação
+			}
+		}
+		err = s.repositorio.GravarOperacao(nil, alterados, movimentos)
+		if err == nil {
+			for i := range alterados {
+				alterados[i].Versao++ // a versão que ficou gravada
+			}
+			s.auditar(ctx, operacao, antes, alterados, movimentos)
+			return nil
+		}
+		if !errors.Is(err, ErrConflito) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"os"            // pacote padrão para estragar o arquivo de estoque
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"sync"          // pacote padrão para disparar as vendas em paralelo
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para a data do movimento
+)
+
+func TestAtualizarRecusaVersaoAntiga(t *testing.T) {
+	repositorios := map[string]RepositorioEstoque{
+		"memoria": NovoRepositorioMemoria(),
+		"arquivo": NovoRepositorioArquivo(filepath.Join(t.TempDir(), "estoque.json")),
+	}
+
+	for nome, repo := range repositorios {
+		t.Run(nome, func(t *testing.T) {
+			repo.Adicionar(NovoProduto("telha", 10))
+			primeiro, _ := repo.Buscar(NovoProduto("telha", 0).ID)
+			segundo, _ := repo.Buscar(primeiro.ID) // duas leituras da mesma versão
+
+			primeiro.DiminuirQuantidade(3)
+			if err := repo.Atualizar(primeiro); err != nil {
+				t.Fatalf("Não esperava erro na primeira gravação, mas recebi %v", err)
+			}
+
+			segundo.DiminuirQuantidade(4)
+			err := repo.Atualizar(segundo)
+			var conflito *ErroConflito
+			if !errors.Is(err, ErrConflito) || !errors.As(err, &conflito) || conflito.VersaoAtual != 1 || conflito.VersaoEnviada != 0 {
+				t.Fatalf("Esperava ErroConflito da versão 0 contra a 1, mas recebi %v", err)
+			}
+
+			gravado, _ := repo.Buscar(primeiro.ID)
+			if gravado.Quantidade != 7 || gravado.Versao != 1 {
+				t.Errorf("Esperava 7 telhas na versão 1, mas encontrei %d na versão %d", gravado.Quantidade, gravado.Versao)
+			}
+
+			// gravação em grupo: um produto desatualizado impede a gravação de todos
+			repo.Adicionar(NovoProduto("cumeeira", 5))
+			cumeeira, _ := repo.Buscar(NovoProduto("cumeeira", 0).ID)
+			cumeeira.AumentarQuantidade(1)
+			if err := repo.AtualizarVarios([]Produto{cumeeira, segundo}); !errors.Is(err, ErrConflito) {
+				t.Fatalf("Esperava ErrConflito na gravação em grupo, mas recebi %v", err)
+			}
+			if naoGravada, _ := repo.Buscar(cumeeira.ID); naoGravada.Quantidade != 5 {
+				t.Errorf("A gravação em grupo recusada não deveria mudar a cumeeira, mas encontrei %d", naoGravada.Quantidade)
+			}
+		})
+	}
+}
+
+func TestVendasSimultaneasNaoPerdemAtualizacoes(t *testing.T) {
+	const vendas = 50
+
+	repositorios := map[string]RepositorioEstoque{
+		"memoria": NovoRepositorioMemoria(),
+		"arquivo": NovoRepositorioArquivo(filepath.Join(t.TempDir(), "estoque.json")),
+		"eventos": NovoRepositorioEventos(t.TempDir()),
+	}
+
+	for nome, repo := range repositorios {
+		t.Run(nome, func(t *testing.T) {
+			servico := NovoServicoEstoque(repo)
+			servico.DefinirTentativas(vendas) // no pior caso cada venda perde para todas as outras
+			tijolo := NovoProduto("tijolo", vendas+10)
+			servico.CadastrarProduto(context.Background(), tijolo)
+
+			var wg sync.WaitGroup
+			erros := make(chan error, vendas)
+			for range vendas {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					erros <- servico.VenderProduto(context.Background(), tijolo.ID, 1)
+				}()
+			}
+			wg.Wait()
+			close(erros)
+
+			for err := range erros {
+				if err != nil {
+					t.Errorf("Não esperava erro nas vendas simultâneas, mas recebi %v", err)
+				}
+			}
+
+			produto, _ := servico.BuscarProduto(tijolo.ID)
+			if produto.Quantidade != 10 {
+				t.Errorf("Esperava 10 tijolos após %d vendas, mas encontrei %d", vendas, produto.Quantidade)
+			}
+			saidas := 0
+			for _, movimento := range servico.ListarMovimentos() {
+				if movimento.Tipo == MovimentoSaida {
+					saidas++
+				}
+			}
+			if saidas != vendas {
+				t.Errorf("Esperava %d movimentos de saída, mas encontrei %d", vendas, saidas)
+			}
+		})
+	}
+}
+
+func TestVendasSimultaneasSemTentativasSuficientes(t *testing.T) {
+	const vendas = 50
+
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+	servico.DefinirTentativas(1) // sem repetir, parte das vendas pode perder para as outras
+	areia := NovoProduto("areia", vendas)
+	servico.CadastrarProduto(context.Background(), areia)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sucessos := 0
+	for range vendas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := servico.VenderProduto(context.Background(), areia.ID, 1)
+			if err != nil && !errors.Is(err, ErrConflito) {
+				t.Errorf("Esperava apenas ErrConflito, mas recebi %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				sucessos++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	produto, _ := servico.BuscarProduto(areia.ID)
+	if produto.Quantidade != vendas-sucessos { // nenhuma venda confirmada pode ter sido sobrescrita
+		t.Errorf("Esperava %d de areia após %d vendas confirmadas, mas encontrei %d", vendas-sucessos, sucessos, produto.Quantidade)
+	}
+	if len(servico.ListarMovimentos()) != 1+sucessos { // entrada do cadastro + uma saída por venda confirmada
+		t.Errorf("Esperava %d movimentos, mas encontrei %d", 1+sucessos, len(servico.ListarMovimentos()))
+	}
+}
+
+func TestArquivoIlegivelNaoViraEstoqueVazio(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	repo := NovoRepositorioArquivo(caminho)
+	telha := NovoProduto("telha", 10)
+	repo.Adicionar(telha)
+	if _, err := os.Stat(caminho + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("O arquivo temporário deveria ter trocado de nome com o estoque, mas encontrei %v", err)
+	}
+
+	os.WriteFile(caminho, []byte(`[{"ID":"telha","Quan`), 0644) // gravação interrompida de outra versão do programa
+	reaberto := NovoRepositorioArquivo(caminho)
+	if _, err := reaberto.Buscar(telha.ID); !errors.Is(err, ErrArquivoIlegivel) {
+		t.Errorf("Esperava ErrArquivoIlegivel ao buscar, mas recebi %v", err)
+	}
+	if err := reaberto.Atualizar(telha); !errors.Is(err, ErrArquivoIlegivel) {
+		t.Errorf("Esperava ErrArquivoIlegivel ao atualizar, mas recebi %v", err)
+	}
+	reaberto.Adicionar(NovoProduto("tijolo", 5))
+	if dados, _ := os.ReadFile(caminho); string(dados) != `[{"ID":"telha","Quan` {
+		t.Errorf("O arquivo ilegível não deveria ser sobrescrito, mas encontrei %s", dados)
+	}
+
+	os.Remove(caminho)
+	livro := filepath.Join(filepath.Dir(caminho), "estoque.movimentos.json")
+	os.WriteFile(livro, []byte("[{"), 0644)
+	tijolo := NovoProduto("tijolo", 5)
+	movimento := novoMovimento(tijolo.ID, MovimentoEntrada, LocalPatio, 5, time.Now())
+	if err := reaberto.GravarOperacao([]Produto{tijolo}, nil, []Movimento{movimento}); !errors.Is(err, ErrArquivoIlegivel) {
+		t.Errorf("Esperava ErrArquivoIlegivel com o livro de movimentos estragado, mas recebi %v", err)
+	}
+	if dados, _ := os.ReadFile(livro); string(dados) != "[{" {
+		t.Errorf("O livro ilegível não deveria ser sobrescrito, mas encontrei %s", dados)
+	}
+	if _, err := os.Stat(caminho); !os.IsNotExist(err) {
+		t.Errorf("Com o livro ilegível os produtos não deveriam ser gravados, mas encontrei %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package estoque
+
+import (
+	"cmp"             // pacote para comparar textos e números na ordenação
+	"encoding/base64" // pacote para transformar o cursor em um texto seguro para URLs
+	"encoding/json"   // pacote para guardar a posição dentro do cursor
+	"errors"          // pacote para manipulação de erros
+	"sort"            // pacote para ordenar o resultado
+	"strings"         // pacote para comparar nomes
+)
+
+// TamanhoPaginaPadrao é o número de produtos por página quando a consulta não informa o limite
+const TamanhoPaginaPadrao = 50
+
+// Erro para indicar que o cursor de paginação não é válido para a consulta
+var ErrCursorInvalido = errors.New("cursor de paginação inválido")
+
+// Erro para indicar que o campo de ordenação não existe
+var ErrOrdenacaoInvalida = errors.New("ordenação inválida")
+
+// CampoOrdenacao define por qual campo o resultado da consulta é ordenado
+type CampoOrdenacao string
+
+const (
+	OrdenarPorNome       CampoOrdenacao = "nome"
+	OrdenarPorQuantidade CampoOrdenacao = "quantidade"
+	OrdenarPorCategoria  CampoOrdenacao = "categoria"
+)
+
+// Consulta descreve a busca de produtos: filtros, ordenação e paginação
+type Consulta struct {
+	Nome             string // parte do nome, sem diferenciar maiúsculas nem acentos ("cobogo" encontra "cobogó")
+	Categoria        string // categoria exata, sem diferenciar maiúsculas nem acentos
+	QuantidadeMinima *int   // nil = sem limite
+	QuantidadeMaxima *int   // nil = sem limite
+	Ordenacao        CampoOrdenacao
+	Decrescente      bool
+	Limite           int    // produtos por página (0 = TamanhoPaginaPadrao)
+	Cursor           string // ProximoCursor da página anterior ("" = primeira página)
+}
+
+// PaginaProdutos é uma página do resultado de uma consulta
+type PaginaProdutos struct {
+	Produtos      []Produto
+	ProximoCursor string // vazio quando não há mais páginas
+}
+
+// posicaoCursor é o conteúdo do cursor: a ordenação usada e o último produto da página
+type posicaoCursor struct {
+	Ordenacao   CampoOrdenacao
+	Decrescente bool
+	Nome        string
+	Categoria   string
+	Quantidade  int
+	ID          string
+}
+
+// aplicarConsulta filtra, ordena e pagina uma lista de produtos
+// É usada pelos repositórios em memória e em arquivo para que os dois se comportem igual
+func aplicarConsulta(produtos []Produto, consulta Consulta) (PaginaProdutos, error) {
+	if consulta.Ordenacao == "" {
+		consulta.Ordenacao = OrdenarPorNome
+	}
+	if consulta.Ordenacao != OrdenarPorNome && consulta.Ordenacao != OrdenarPorQuantidade && consulta.Ordenacao != OrdenarPorCategoria {
+		return PaginaProdutos{}, ErrOrdenacaoInvalida
+	}
+	if consulta.Limite <= 0 {
+		consulta.Limite = TamanhoPaginaPadrao
+	}
+
+	nome := normalizarBusca(consulta.Nome)
+	categoria := normalizarBusca(consulta.Categoria)
+	vistos := map[string]bool{}
+	var filtrados []Produto
+	for _, produto := range produtos {
+		if vistos[produto.ID] {
+			continue // estoques antigos podem ter o mesmo produto repetido; vale o primeiro
+		}
+		vistos[produto.ID] = true
+
+		if nome != "" && !strings.Contains(normalizarBusca(produto.Nome), nome) {
+			continue
+		}
+		if categoria != "" && normalizarBusca(produto.Categoria) != categoria {
+			continue
+		}
+		if consulta.QuantidadeMinima != nil && produto.Quantidade < *consulta.QuantidadeMinima {
+			continue
+		}
+		if consulta.QuantidadeMaxima != nil && produto.Quantidade > *consulta.QuantidadeMaxima {
+			continue
+		}
+		filtrados = append(filtrados, produto)
+	}
+
+	comparar := func(a, b Produto) int {
+		var resultado int
+		switch consulta.Ordenacao {
+		case OrdenarPorQuantidade:
+			resultado = cmp.Compare(a.Quantidade, b.Quantidade)
+		case OrdenarPorCategoria:
+			resultado = cmp.Compare(normalizarBusca(a.Categoria), normalizarBusca(b.Categoria))
+		}
+		if resultado == 0 {
+			resultado = cmp.Compare(normalizarBusca(a.Nome), normalizarBusca(b.Nome))
+		}
+		if resultado == 0 {
+			resultado = cmp.Compare(a.ID, b.ID) // o ID desempata, assim o cursor aponta sempre para uma posição única
+		}
+		if consulta.Decrescente {
+			return -resultado
+		}
+		return resultado
+	}
+	sort.Slice(filtrados, func(i, j int) bool { return comparar(filtrados[i], filtrados[j]) < 0 })
+
+	inicio := 0
+	if consulta.Cursor != "" {
+		posicao, err := lerCursor(consulta.Cursor)
+		if err != nil || posicao.Ordenacao != consulta.Ordenacao || posicao.Decrescente != consulta.Decrescente {
+			return PaginaProdutos{}, ErrCursorInvalido
+		}
+		ultimo := Produto{ID: posicao.ID, Nome: posicao.Nome, Categoria: posicao.Categoria, Quantidade: posicao.Quantidade}
+		inicio = sort.Search(len(filtrados), func(i int) bool { return comparar(filtrados[i], ultimo) > 0 }) // primeiro depois do último visto
+	}
+
+	fim := min(inicio+consulta.Limite, len(filtrados))
+	pagina := PaginaProdutos{Produtos: make([]Produto, 0, fim-inicio)}
+	for _, produto := range filtrados[inicio:fim] {
+		pagina.Produtos = append(pagina.Produtos, produto.clonar()) // clonar vem do arquivo local.go
+	}
+	if fim < len(filtrados) {
+		ultimo := filtrados[fim-1]
+		pagina.ProximoCursor = gerarCursor(posicaoCursor{
+			Ordenacao:   consulta.Ordenacao,
+			Decrescente: consulta.Decrescente,
+			Nome:        ultimo.Nome,
+			Categoria:   ultimo.Categoria,
+			Quantidade:  ultimo.Quantidade,
+			ID:          ultimo.ID,
+		})
+	}
+	return pagina, nil
+}
+
+// gerarCursor codifica a posição em um texto opaco para o chamador
+func gerarCursor(posicao posicaoCursor) string {
+	dados, _ := json.Marshal(posicao)
+	return base64.RawURLEncoding.EncodeToString(dados)
+}
+
+// lerCursor decodifica a posição guardada no cursor
+func lerCursor(cursor string) (posicaoCursor, error) {
+	var posicao posicaoCursor
+	dados, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return posicao, err
+	}
+	err = json.Unmarshal(dados, &posicao)
+	return posicao, err
+}
+
+// removedorAcentos troca letras acentuadas pela letra sem acento (português e espanhol)
+var removedorAcentos = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// normalizarBusca deixa o texto em minúsculas, sem acentos e sem espaços nas pontas
+func normalizarBusca(texto string) string {
+	return removedorAcentos.Replace(strings.ToLower(strings.TrimSpace(texto)))
+}
@@ -0,0 +1,87 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"testing"       // pacote padrão do Go para testes
+)
+
+// novoProdutoComCategoria cria um produto já com categoria para os testes de consulta
+func novoProdutoComCategoria(nome, categoria string, quantidade int) Produto {
+	produto := NovoProduto(nome, quantidade)
+	produto.Categoria = categoria
+	return produto
+}
+
+func TestConsultarEstoqueNosDoisRepositorios(t *testing.T) {
+	repositorios := map[string]RepositorioEstoque{
+		"memoria": NovoRepositorioMemoria(),
+		"arquivo": NovoRepositorioArquivo(filepath.Join(t.TempDir(), "estoque.json")),
+	}
+
+	for nome, repo := range repositorios {
+		t.Run(nome, func(t *testing.T) {
+			servico := NovoServicoEstoque(repo)
+			servico.CadastrarProduto(context.Background(), novoProdutoComCategoria("Cobogó Flor", "cobogó", 55))
+			servico.CadastrarProduto(context.Background(), novoProdutoComCategoria("cobogo árabe", "Cobogo", 38))
+			servico.CadastrarProduto(context.Background(), novoProdutoComCategoria("Cobogó Colmeia", "cobogó", 3))
+			servico.CadastrarProduto(context.Background(), novoProdutoComCategoria("viga", "estrutura", 17))
+
+			// busca sem acento encontra nomes com acento
+			pagina, err := servico.ConsultarEstoque(Consulta{Nome: "cobogo"}) // ConsultarEstoque vem do arquivo servico.go
+			if err != nil || len(pagina.Produtos) != 3 {
+				t.Fatalf("Esperava 3 cobogós, mas encontrei %d (erro %v)", len(pagina.Produtos), err)
+			}
+
+			// filtro por categoria e faixa de estoque, ordenado por quantidade decrescente
+			minimo := 10
+			pagina, _ = servico.ConsultarEstoque(Consulta{Categoria: "COBOGO", QuantidadeMinima: &minimo, Ordenacao: OrdenarPorQuantidade, Decrescente: true})
+			if len(pagina.Produtos) != 2 || pagina.Produtos[0].Nome != "Cobogó Flor" || pagina.Produtos[1].Nome != "cobogo árabe" {
+				t.Errorf("Resultado do filtro inesperado: %+v", pagina.Produtos)
+			}
+
+			// paginação por cursor, de 2 em 2
+			var nomes []string
+			consulta := Consulta{Limite: 2}
+			for {
+				pagina, err := servico.ConsultarEstoque(consulta)
+				if err != nil {
+					t.Fatalf("Não esperava erro na paginação, mas recebi %v", err)
+				}
+				for _, produto := range pagina.Produtos {
+					nomes = append(nomes, produto.Nome)
+				}
+				if pagina.ProximoCursor == "" {
+					break
+				}
+				consulta.Cursor = pagina.ProximoCursor
+			}
+			esperado := []string{"cobogo árabe", "Cobogó Colmeia", "Cobogó Flor", "viga"}
+			if len(nomes) != len(esperado) {
+				t.Fatalf("Esperava %v, mas encontrei %v", esperado, nomes)
+			}
+			for i := range esperado {
+				if nomes[i] != esperado[i] {
+					t.Errorf("Esperava %v, mas encontrei %v", esperado, nomes)
+					break
+				}
+			}
+
+			// cursor gerado para outra ordenação é recusado
+			_, err = servico.ConsultarEstoque(Consulta{Limite: 2, Cursor: consulta.Cursor, Ordenacao: OrdenarPorQuantidade})
+			if !errors.Is(err, ErrCursorInvalido) {
+				t.Errorf("Esperava ErrCursorInvalido, mas recebi %v", err)
+			}
+
+			// busca pelo ID usa o índice do repositório
+			viga, err := servico.BuscarProduto(NovoProduto("viga", 0).ID)
+			if err != nil || viga.Quantidade != 17 {
+				t.Errorf("Esperava encontrar a viga pelo ID, mas recebi %+v (erro %v)", viga, err)
+			}
+			if _, err := servico.BuscarProduto("nao-existe"); !errors.Is(err, ErrProdutoNaoEncontrado) {
+				t.Errorf("Esperava ErrProdutoNaoEncontrado, mas recebi %v", err)
+			}
+		})
+	}
+}
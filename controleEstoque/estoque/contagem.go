@@ -0,0 +1,279 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"time"    // pacote para registrar a abertura da contagem
+)
+
+// Erro para indicar que já existe uma contagem aberta (ou que as vendas estão bloqueadas por ela)
+var ErrContagemAberta = errors.New("contagem de estoque em andamento")
+
+// Erro para indicar que a operação precisa de uma contagem aberta
+var ErrSemContagemAberta = errors.New("nenhuma contagem de estoque aberta")
+
+// ModoContagem define o que acontece com as vendas enquanto a contagem está aberta
+type ModoContagem string
+
+const (
+	ContagemBloqueiaVendas   ModoContagem = "bloquear"    // vendas e demais operações que lançam movimentos são recusadas até a contagem ser aprovada ou cancelada
+	ContagemReconciliaVendas ModoContagem = "reconciliar" // vendas continuam; a diferença entre o contado e o saldo na hora da contagem é aplicada sobre o saldo atual
+)
+
+// ItemContagem é o saldo congelado de um produto em um local e a quantidade contada
+type ItemContagem struct {
+	ProdutoID string
+	Produto   string
+	Local     string
+	Esperado  int  // saldo no momento em que a contagem foi aberta
+	Contado   *int // nil enquanto o item não foi contado
+	NoSistema *int `json:",omitempty"` // saldo do sistema quando o item foi contado; é a base da diferença
+}
+
+// Contagem é uma sessão de inventário físico com a fotografia do estoque na abertura
+type Contagem struct {
+	ID       string
+	Abertura time.Time
+	Modo     ModoContagem
+	Itens    []ItemContagem
+}
+
+// DiferencaContagem mostra a diferença entre o saldo esperado e o contado
+type DiferencaContagem struct {
+	ProdutoID string
+	Produto   string
+	Local     string
+	Esperado  int // saldo do sistema quando o item foi contado
+	Contado   int
+	Diferenca int // positiva = sobra, negativa = falta
+}
+
+// AbrirContagem congela o saldo atual de todos os produtos em cada local e abre a sessão de contagem
+func (s *ServicoEstoque) AbrirContagem(modo ModoContagem) (Contagem, error) {
+	if modo != ContagemBloqueiaVendas && modo != ContagemReconciliaVendas {
+		return Contagem{}, ErrValorInvalido
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem != nil {
+		return Contagem{}, ErrContagemAberta
+	}
+
+	contagem := Contagem{ID: gerarIDMovimento(), Abertura: s.agora(), Modo: modo} // gerarIDMovimento vem do arquivo movimento.go
+	for _, produto := range s.produtosUnicos() { // produtosUnicos vem do arquivo relatorios.go
+		locais := produto.LocaisOrdenados()
+		if len(locais) == 0 {
+			locais = []string{LocalPadrao} // produto zerado também entra na contagem
+		}
+		for _, local := range locais {
+			contagem.Itens = append(contagem.Itens, ItemContagem{
+				ProdutoID: produto.ID,
+				Produto:   produto.Nome,
+				Local:     local,
+				Esperado:  produto.QuantidadeNoLocal(local),
+			})
+		}
+	}
+
+	s.contagem = &contagem
+	return contagem, nil
+}
+
+// RetomarContagem volta a usar uma contagem aberta anteriormente (ex: salva em arquivo pela linha de comando)
+func (s *ServicoEstoque) RetomarContagem(contagem Contagem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem != nil {
+		return ErrContagemAberta
+	}
+	s.contagem = &contagem
+	return nil
+}
+
+// ContagemAberta retorna a contagem em andamento, se houver
+func (s *ServicoEstoque) ContagemAberta() (Contagem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem == nil {
+		return Contagem{}, false
+	}
+	return *s.contagem, true
+}
+
+// RegistrarContagem informa quantas unidades foram contadas de um produto em um local
+// Contar de novo o mesmo item substitui a contagem anterior
+// Um local que não estava na fotografia (ex: peças achadas na loja) entra com saldo esperado zero
+// O saldo do sistema nessa hora fica guardado no item: vendas feitas entre a abertura e a contagem
+// já estão nele e não entram na diferença
+func (s *ServicoEstoque) RegistrarContagem(id, local string, quantidade int) error {
+	if local == "" {
+		return ErrLocalInvalido
+	}
+	if quantidade < 0 {
+		return ErrValorInvalido
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem == nil {
+		return ErrSemContagemAberta
+	}
+
+	produto, err := s.buscarProduto(id)
+	if err != nil {
+		return err
+	}
+	noSistema := produto.QuantidadeNoLocal(local)
+
+	nome := ""
+	for i := range s.contagem.Itens {
+		item := &s.contagem.Itens[i]
+		if item.ProdutoID != id {
+			continue
+		}
+		nome = item.Produto
+		if item.Local == local {
+			item.Contado, item.NoSistema = &quantidade, &noSistema
+			return nil
+		}
+	}
+
+	if nome == "" { // produto cadastrado depois da abertura
+		nome = produto.Nome
+	}
+	s.contagem.Itens = append(s.contagem.Itens, ItemContagem{ProdutoID: id, Produto: nome, Local: local, Contado: &quantidade, NoSistema: &noSistema})
+	return nil
+}
+
+// DiferencasContagem lista os itens já contados cujo saldo contado é diferente do esperado
+func (s *ServicoEstoque) DiferencasContagem() ([]DiferencaContagem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem == nil {
+		return nil, ErrSemContagemAberta
+	}
+	return s.contagem.diferencas(), nil
+}
+
+// AprovarContagem lança um movimento de ajuste para cada diferença e encerra a contagem
+// A diferença (contado - saldo do sistema quando o item foi contado) é aplicada sobre o saldo atual,
+// assim as vendas feitas durante uma contagem em modo reconciliar, antes ou depois de o item ser contado,
+// não são perdidas nem contadas duas vezes
+// Itens não contados não são ajustados
+func (s *ServicoEstoque) AprovarContagem(ctx context.Context, motivo string) ([]Movimento, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem == nil {
+		return nil, ErrSemContagemAberta
+	}
+	if motivo == "" {
+		motivo = "inventário " + s.contagem.ID
+	}
+
+	// aplica todas as diferenças em cópias dos produtos antes de gravar, para não gravar pela metade
+	produtos := map[string]*Produto{}
+	antes := map[string]Produto{} // estado lido, para a auditoria
+	var ordem []string
+	var movimentos []Movimento
+	for _, diferenca := range s.contagem.diferencas() {
+		produto, existe := produtos[diferenca.ProdutoID]
+		if !existe {
+			encontrado, err := s.buscarProduto(diferenca.ProdutoID)
+			if err != nil {
+				return nil, err
+			}
+			antes[diferenca.ProdutoID] = encontrado.clonar()
+			produto = &encontrado
+			produtos[diferenca.ProdutoID] = produto
+			ordem = append(ordem, diferenca.ProdutoID)
+		}
+
+		if diferenca.Diferenca > 0 {
+			produto.AumentarQuantidadeNoLocal(diferenca.Local, diferenca.Diferenca)
+		} else if _, err := produto.retirar(diferenca.Local, -diferenca.Diferenca, nil, PoliticaFIFO); err != nil {
+			// a falta contada é física: sai também de lotes em cura, que a venda e a produção não podem usar
+			return nil, err // o saldo atual já é menor que a falta contada; nada foi gravado
+		}
+
+		movimento := novoMovimento(diferenca.ProdutoID, MovimentoAjuste, diferenca.Local, diferenca.Diferenca, s.agora())
+		movimento.Motivo = motivo
+		movimentos = append(movimentos, movimento)
+	}
+
+	alterados := make([]Produto, 0, len(ordem))
+	for _, id := range ordem {
+		alterados = append(alterados, *produtos[id])
+	}
+	if err := s.repositorio.GravarOperacao(nil, alterados, movimentos); err != nil {
+		return nil, err // ErrConflito se uma venda mudou algum produto durante a aprovação; a contagem continua aberta
+	}
+	for i := range alterados {
+		alterados[i].Versao++ // a versão que ficou gravada
+	}
+	s.contagem = nil // os ajustes já foram gravados: a contagem se encerra mesmo se a auditoria falhar
+	s.auditar(ctx, OperacaoContagem, antes, alterados, movimentos) // auditar vem do arquivo auditoria.go
+	return movimentos, nil
+}
+
+// CancelarContagem encerra a contagem sem ajustar nenhum saldo
+func (s *ServicoEstoque) CancelarContagem() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contagem == nil {
+		return ErrSemContagemAberta
+	}
+	s.contagem = nil
+	return nil
+}
+
+// AcompanharContagem troca a contagem do serviço pela informada (nil = nenhuma aberta)
+// Serve a um processo que fica no ar enquanto outro conduz a contagem (ex: o servidor, com a contagem da linha de comando)
+func (s *ServicoEstoque) AcompanharContagem(contagem *Contagem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contagem = contagem
+}
+
+// vendasBloqueadas indica se uma contagem aberta em modo bloquear congela os saldos
+func (s *ServicoEstoque) vendasBloqueadas() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.contagem != nil && s.contagem.Modo == ContagemBloqueiaVendas
+}
+
+// conferirBloqueio recusa com ErrContagemAberta uma operação que lança movimentos (venda, transferência,
+// entrada, produção, estorno...) enquanto uma contagem em modo bloquear está aberta
+// Operações sem movimento (ex: trocar o código de barras) continuam liberadas
+func (s *ServicoEstoque) conferirBloqueio(movimentos []Movimento) error {
+	if len(movimentos) > 0 && s.vendasBloqueadas() {
+		return ErrContagemAberta
+	}
+	return nil
+}
+
+// diferencas compara o saldo do sistema com o contado dos itens já contados
+func (c *Contagem) diferencas() []DiferencaContagem {
+	var diferencas []DiferencaContagem
+	for _, item := range c.Itens {
+		if item.Contado == nil {
+			continue
+		}
+		noSistema := item.Esperado // contagens gravadas antes de NoSistema existir
+		if item.NoSistema != nil {
+			noSistema = *item.NoSistema
+		}
+		if *item.Contado == noSistema {
+			continue
+		}
+		diferencas = append(diferencas, DiferencaContagem{
+			ProdutoID: item.ProdutoID,
+			Produto:   item.Produto,
+			Local:     item.Local,
+			Esperado:  noSistema,
+			Contado:   *item.Contado,
+			Diferenca: *item.Contado - noSistema,
+		})
+	}
+	return diferencas
+}
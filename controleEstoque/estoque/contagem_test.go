@@ -0,0 +1,130 @@
+package estoque
+
+import (
+	"context" // pacote padrão para passar o ator das operações
+	"errors"  // pacote padrão para comparar erros com errors.Is
+	"testing" // pacote padrão do Go para testes
+)
+
+func TestContagemBloqueiaVendasEAprovaAjustes(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+
+	viga := NovoProduto("viga", 20)
+	servico.CadastrarProduto(context.Background(), viga)
+
+	if _, err := servico.AbrirContagem(ContagemBloqueiaVendas); err != nil { // AbrirContagem vem do arquivo contagem.go
+		t.Fatalf("Não esperava erro ao abrir a contagem, mas recebi %v", err)
+	}
+	if _, err := servico.AbrirContagem(ContagemBloqueiaVendas); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava ErrContagemAberta ao abrir duas contagens, mas recebi %v", err)
+	}
+	if err := servico.VenderProduto(context.Background(), viga.ID, 1); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava venda bloqueada durante a contagem, mas recebi %v", err)
+	}
+
+	servico.RegistrarContagem(viga.ID, LocalPatio, 18) // faltam 2 vigas no pátio
+	servico.RegistrarContagem(viga.ID, LocalLoja, 1)   // 1 viga achada na loja
+
+	diferencas, _ := servico.DiferencasContagem()
+	if len(diferencas) != 2 || diferencas[0].Diferenca != -2 || diferencas[1].Diferenca != 1 {
+		t.Fatalf("Diferenças inesperadas: %+v", diferencas)
+	}
+
+	movimentos, err := servico.AprovarContagem(context.Background(), "inventário de maio")
+	if err != nil {
+		t.Fatalf("Não esperava erro ao aprovar a contagem, mas recebi %v", err)
+	}
+	if len(movimentos) != 2 || movimentos[0].Tipo != MovimentoAjuste || movimentos[0].Motivo != "inventário de maio" {
+		t.Errorf("Movimentos de ajuste inesperados: %+v", movimentos)
+	}
+
+	produto := repo.Listar()[0]
+	if produto.QuantidadeNoLocal(LocalPatio) != 18 || produto.QuantidadeNoLocal(LocalLoja) != 1 {
+		t.Errorf("Esperava 18 no pátio e 1 na loja, mas encontrei %v", produto.Locais)
+	}
+	if err := servico.VenderProduto(context.Background(), viga.ID, 1); err != nil {
+		t.Errorf("As vendas deveriam voltar após a aprovação, mas recebi %v", err)
+	}
+}
+
+func TestContagemReconciliaVendas(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(context.Background(), coluna)
+
+	servico.AbrirContagem(ContagemReconciliaVendas)
+	servico.RegistrarContagem(coluna.ID, LocalPatio, 9) // contou 9 onde o sistema esperava 10
+
+	if err := servico.VenderProduto(context.Background(), coluna.ID, 3); err != nil { // venda durante a contagem
+		t.Fatalf("No modo reconciliar a venda deveria passar, mas recebi %v", err)
+	}
+
+	if _, err := servico.AprovarContagem(context.Background(), ""); err != nil {
+		t.Fatalf("Não esperava erro ao aprovar a contagem, mas recebi %v", err)
+	}
+
+	if repo.Listar()[0].Quantidade != 6 { // 10 - 3 vendidas - 1 de falta na contagem
+		t.Errorf("Esperava 6 colunas após reconciliar, mas encontrei %d", repo.Listar()[0].Quantidade)
+	}
+}
+
+func TestContagemReconciliaVendaAntesDaContagem(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(context.Background(), coluna)
+
+	servico.AbrirContagem(ContagemReconciliaVendas)
+	servico.VenderProduto(context.Background(), coluna.ID, 3) // venda antes de o item ser contado: saldo 7
+	servico.RegistrarContagem(coluna.ID, LocalPatio, 6)       // contou 6 onde o sistema tinha 7
+
+	diferencas, _ := servico.DiferencasContagem()
+	if len(diferencas) != 1 || diferencas[0].Esperado != 7 || diferencas[0].Diferenca != -1 {
+		t.Fatalf("Diferenças inesperadas: %+v", diferencas)
+	}
+	if _, err := servico.AprovarContagem(context.Background(), ""); err != nil {
+		t.Fatalf("Não esperava erro ao aprovar a contagem, mas recebi %v", err)
+	}
+	if quantidade := repo.Listar()[0].Quantidade; quantidade != 6 { // o que foi contado, sem descontar a venda duas vezes
+		t.Errorf("Esperava 6 colunas após reconciliar, mas encontrei %d", quantidade)
+	}
+}
+
+func TestContagemBloqueiaTodosOsMovimentos(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 20)
+	servico.CadastrarProduto(ctx, viga)
+	servico.AbrirContagem(ContagemBloqueiaVendas)
+
+	if err := servico.Transferir(ctx, viga.ID, LocalPatio, LocalLoja, 2); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava transferência bloqueada durante a contagem, mas recebi %v", err)
+	}
+	if err := servico.RegistrarEntrada(ctx, viga.ID, LocalPatio, 5, 10); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava entrada bloqueada durante a contagem, mas recebi %v", err)
+	}
+	if err := servico.CadastrarProduto(ctx, NovoProduto("coluna", 4)); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava cadastro com saldo bloqueado durante a contagem, mas recebi %v", err)
+	}
+	if err := servico.DefinirEstrutura(ctx, viga.ID, nil); err != nil { // não lança movimento
+		t.Errorf("Alterações sem movimento deveriam continuar liberadas, mas recebi %v", err)
+	}
+	if produto, _ := servico.BuscarProduto(viga.ID); produto.Quantidade != 20 || produto.QuantidadeNoLocal(LocalLoja) != 0 {
+		t.Errorf("Os saldos deveriam continuar congelados, mas encontrei %v", produto.Locais)
+	}
+
+	// quem só acompanha a contagem (ex: o servidor) passa a bloquear e a liberar junto com ela
+	outro := NovoServicoEstoque(servico.repositorio)
+	contagem, _ := servico.ContagemAberta()
+	outro.AcompanharContagem(&contagem)
+	if err := outro.VenderProduto(ctx, viga.ID, 1); !errors.Is(err, ErrContagemAberta) {
+		t.Errorf("Esperava venda bloqueada no serviço que acompanha a contagem, mas recebi %v", err)
+	}
+	outro.AcompanharContagem(nil)
+	if err := outro.VenderProduto(ctx, viga.ID, 1); err != nil {
+		t.Errorf("Esperava a venda liberada depois da contagem, mas recebi %v", err)
+	}
+}
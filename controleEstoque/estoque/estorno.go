@@ -0,0 +1,133 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para detalhar o estorno recusado
+)
+
+// Erro para indicar que o movimento informado não está no livro de movimentações
+var ErrMovimentoNaoEncontrado = errors.New("movimento não encontrado")
+
+// Erro para indicar que o movimento já foi estornado
+var ErrMovimentoEstornado = errors.New("movimento já estornado")
+
+// Erro para indicar que o movimento não pode ser estornado (ex: ele mesmo é um estorno)
+var ErrEstornoInvalido = errors.New("estorno inválido")
+
+// Estornar desfaz um movimento lançado por engano com um movimento de compensação, sem apagar o histórico
+// O estorno tem o mesmo tipo do original com a quantidade invertida (a transferência volta do destino para a origem)
+// e guarda o ID do original em Estorno, então os relatórios de vendas e de valorização já descontam o engano
+// Estornos que deixariam o saldo negativo são recusados com ErrEstoqueInsuficiente
+// Unidades de uma venda estornada voltam sem lote; o estorno de uma entrada em lote retira as unidades desse lote
+// O ID do original fica marcado no produto (Estornados) na mesma gravação com controle de versão: dois estornos
+// simultâneos não passam juntos; o segundo recebe ErrMovimentoEstornado
+// Entre processos diferentes da CLI isso só vale no Unix, pela trava do RepositorioArquivo (trava_unix.go)
+func (s *ServicoEstoque) Estornar(ctx context.Context, movimentoID, motivo string) (Movimento, error) {
+	original, err := s.buscarMovimento(movimentoID)
+	if err != nil {
+		return Movimento{}, err
+	}
+	if original.Estorno != "" {
+		return Movimento{}, fmt.Errorf("%w: %s já é o estorno de %s", ErrEstornoInvalido, original.ID, original.Estorno)
+	}
+	if original.Quantidade == 0 {
+		return Movimento{}, fmt.Errorf("%w: movimento sem quantidade", ErrEstornoInvalido)
+	}
+	if motivo == "" {
+		motivo = "estorno de " + original.ID
+	}
+
+	var estorno Movimento
+	err = s.alterarProduto(ctx, OperacaoEstorno, original.ProdutoID, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		// conferido a cada tentativa: se outro estorno gravou o produto antes, o ErrConflito traz a releitura até aqui
+		if err := s.conferirEstorno(*produto, original.ID); err != nil {
+			return nil, err
+		}
+		produto.Estornados = append(produto.Estornados, original.ID)
+
+		estorno = novoMovimento(produto.ID, original.Tipo, original.Local, -original.Quantidade, s.agora())
+		estorno.Lote = original.Lote
+		estorno.CustoUnitario = original.CustoUnitario
+		estorno.Motivo = motivo
+		estorno.Estorno = original.ID
+
+		switch {
+		case original.Tipo == MovimentoTransferencia:
+			// transferências guardam a quantidade positiva; o estorno leva as unidades de volta à origem
+			estorno.Local, estorno.Destino = original.Destino, original.Local
+			estorno.Quantidade = original.Quantidade
+			if err := produto.Transferir(estorno.Local, estorno.Destino, estorno.Quantidade); err != nil { // Transferir vem do arquivo local.go
+				return nil, err
+			}
+		case estorno.Quantidade > 0:
+			if err := produto.AumentarQuantidadeNoLocal(estorno.Local, estorno.Quantidade); err != nil {
+				return nil, err
+			}
+			estorno.Lote = "" // as unidades voltam sem lote
+		case original.Lote != "":
+			if err := produto.retirarDoLote(original.Local, original.Lote, -estorno.Quantidade); err != nil {
+				return nil, err
+			}
+		default:
+			if err := produto.diminuirNoLocal(estorno.Local, -estorno.Quantidade, estorno.Data); err != nil { // diminuirNoLocal vem do arquivo local.go
+				return nil, err
+			}
+		}
+		return []Movimento{estorno}, nil
+	})
+	if err != nil {
+		return Movimento{}, err
+	}
+	return estorno, nil
+}
+
+// conferirEstorno recusa o movimento já estornado: pela marca no produto ou, para estornos
+// gravados antes da marca existir, pelo livro de movimentações
+func (s *ServicoEstoque) conferirEstorno(produto Produto, movimentoID string) error {
+	for _, estornado := range produto.Estornados {
+		if estornado == movimentoID {
+			return fmt.Errorf("%w: %s", ErrMovimentoEstornado, movimentoID)
+		}
+	}
+	for _, movimento := range s.repositorio.ListarMovimentos() {
+		if movimento.Estorno == movimentoID {
+			return fmt.Errorf("%w: estorno %s", ErrMovimentoEstornado, movimento.ID)
+		}
+	}
+	return nil
+}
+
+// buscarMovimento procura o movimento pelo ID no livro de movimentações
+func (s *ServicoEstoque) buscarMovimento(id string) (Movimento, error) {
+	for _, movimento := range s.repositorio.ListarMovimentos() {
+		if movimento.ID == id {
+			return movimento, nil
+		}
+	}
+	return Movimento{}, ErrMovimentoNaoEncontrado
+}
+
+// retirarDoLote remove unidades de um lote específico no local, como no estorno da entrada desse lote
+// Se o lote já saiu (vendido ou transferido), retorna ErrEstoqueInsuficiente e nada é alterado
+func (p *Produto) retirarDoLote(local, codigo string, valor int) error {
+	for i := range p.Lotes {
+		lote := &p.Lotes[i]
+		if lote.Codigo != codigo || lote.Local != local {
+			continue
+		}
+		if lote.Quantidade < valor {
+			return ErrEstoqueInsuficiente
+		}
+		lote.Quantidade -= valor
+		p.removerLotesVazios() // removerLotesVazios vem do arquivo lote.go
+
+		p.Locais[local] -= valor
+		if p.Locais[local] == 0 {
+			delete(p.Locais, local)
+		}
+		p.Quantidade -= valor
+		return nil
+	}
+	return ErrEstoqueInsuficiente
+}
@@ -0,0 +1,189 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"sync"          // pacote padrão para disparar estornos em paralelo
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para as datas dos lotes e do período
+)
+
+// ultimoMovimento devolve o último movimento do livro
+func ultimoMovimento(s *ServicoEstoque) Movimento {
+	movimentos := s.ListarMovimentos()
+	return movimentos[len(movimentos)-1]
+}
+
+func TestEstornarEntradaEVenda(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 10)
+	servico.CadastrarProduto(ctx, viga)
+
+	// entrada digitada duas vezes
+	servico.RegistrarEntrada(ctx, viga.ID, LocalPadrao, 6, 50)
+	servico.RegistrarEntrada(ctx, viga.ID, LocalPadrao, 6, 50)
+	duplicada := ultimoMovimento(servico)
+
+	estorno, err := servico.Estornar(ctx, duplicada.ID, "")
+	if err != nil {
+		t.Fatalf("Não esperava erro no estorno, mas recebi %v", err)
+	}
+	if estorno.Estorno != duplicada.ID || estorno.Tipo != MovimentoEntrada || estorno.Quantidade != -6 || estorno.CustoUnitario != 50 {
+		t.Errorf("Movimento de estorno inesperado: %+v", estorno)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.Quantidade != 16 {
+		t.Errorf("Esperava 16 vigas depois do estorno, mas encontrei %d", produto.Quantidade)
+	}
+	if len(servico.ListarMovimentos()) != 4 {
+		t.Errorf("O estorno não pode apagar o histórico: esperava 4 movimentos, mas encontrei %d", len(servico.ListarMovimentos()))
+	}
+
+	if _, err := servico.Estornar(ctx, duplicada.ID, ""); !errors.Is(err, ErrMovimentoEstornado) {
+		t.Errorf("Esperava ErrMovimentoEstornado no segundo estorno, mas recebi %v", err)
+	}
+	if _, err := servico.Estornar(ctx, estorno.ID, ""); !errors.Is(err, ErrEstornoInvalido) {
+		t.Errorf("Esperava ErrEstornoInvalido ao estornar um estorno, mas recebi %v", err)
+	}
+	if _, err := servico.Estornar(ctx, "nao-existe", ""); !errors.Is(err, ErrMovimentoNaoEncontrado) {
+		t.Errorf("Esperava ErrMovimentoNaoEncontrado, mas recebi %v", err)
+	}
+
+	// venda estornada devolve as unidades e sai da curva ABC
+	servico.VenderProduto(ctx, viga.ID, 4)
+	venda := ultimoMovimento(servico)
+	if _, err := servico.Estornar(ctx, venda.ID, "venda cancelada"); err != nil {
+		t.Fatalf("Não esperava erro no estorno da venda, mas recebi %v", err)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.Quantidade != 16 {
+		t.Errorf("Esperava 16 vigas depois de estornar a venda, mas encontrei %d", produto.Quantidade)
+	}
+	if abc := servico.CurvaABC(UltimosDias(time.Now(), 30)); abc[0].QuantidadeVendida != 0 {
+		t.Errorf("A venda estornada não deveria contar na curva ABC, mas encontrei %+v", abc[0])
+	}
+
+	if registros := servico.ConsultarAuditoria(FiltroAuditoria{ProdutoID: viga.ID}); registros[len(registros)-1].Operacao != OperacaoEstorno {
+		t.Errorf("Esperava o estorno na auditoria, mas encontrei %+v", registros[len(registros)-1])
+	}
+}
+
+func TestEstornarRecusaSaldoNegativo(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 0)
+	servico.CadastrarProduto(ctx, viga)
+
+	servico.RegistrarEntrada(ctx, viga.ID, LocalPatio, 5, 0)
+	entrada := ultimoMovimento(servico)
+	servico.VenderProdutoNoLocal(ctx, viga.ID, LocalPatio, 3)
+
+	if _, err := servico.Estornar(ctx, entrada.ID, ""); !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Fatalf("Esperava ErrEstoqueInsuficiente, mas recebi %v", err)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.Quantidade != 2 {
+		t.Errorf("O estorno recusado não deveria alterar o saldo, mas ficou %d", produto.Quantidade)
+	}
+	if len(servico.ListarMovimentos()) != 2 {
+		t.Errorf("O estorno recusado não deveria gravar movimento, mas encontrei %d", len(servico.ListarMovimentos()))
+	}
+}
+
+func TestEstornarTransferenciaELote(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 10)
+	servico.CadastrarProduto(ctx, viga)
+
+	servico.Transferir(ctx, viga.ID, LocalPatio, LocalLoja, 4)
+	transferencia := ultimoMovimento(servico)
+	estorno, err := servico.Estornar(ctx, transferencia.ID, "")
+	if err != nil {
+		t.Fatalf("Não esperava erro no estorno da transferência, mas recebi %v", err)
+	}
+	if estorno.Local != LocalLoja || estorno.Destino != LocalPatio || estorno.Quantidade != 4 {
+		t.Errorf("Esperava a transferência de volta da loja para o pátio, mas encontrei %+v", estorno)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.QuantidadeNoLocal(LocalPatio) != 10 || produto.QuantidadeNoLocal(LocalLoja) != 0 {
+		t.Errorf("Esperava tudo de volta no pátio, mas encontrei %v", produto.Locais)
+	}
+
+	// o estorno da entrada do lote tira as unidades do próprio lote, não das unidades sem lote
+	servico.RegistrarLote(ctx, viga.ID, NovoLote("V-01", time.Now(), 28, 6))
+	lote := ultimoMovimento(servico)
+	if _, err := servico.Estornar(ctx, lote.ID, ""); err != nil {
+		t.Fatalf("Não esperava erro no estorno do lote, mas recebi %v", err)
+	}
+	produto, _ := servico.repositorio.Buscar(viga.ID)
+	if produto.Quantidade != 10 || len(produto.Lotes) != 0 {
+		t.Errorf("Esperava 10 vigas e nenhum lote, mas encontrei %d e %+v", produto.Quantidade, produto.Lotes)
+	}
+}
+
+func TestEstornosSimultaneosDoMesmoMovimento(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga", 10)
+	servico.CadastrarProduto(ctx, viga)
+	servico.VenderProduto(ctx, viga.ID, 2)
+	venda := ultimoMovimento(servico)
+
+	var grupo sync.WaitGroup
+	var mu sync.Mutex
+	sucessos := 0
+	for i := 0; i < 10; i++ {
+		grupo.Add(1)
+		go func() {
+			defer grupo.Done()
+			if _, err := servico.Estornar(ctx, venda.ID, ""); err == nil {
+				mu.Lock()
+				sucessos++
+				mu.Unlock()
+			}
+		}()
+	}
+	grupo.Wait()
+
+	if sucessos != 1 {
+		t.Errorf("Esperava exatamente 1 estorno aceito, mas foram %d", sucessos)
+	}
+	if produto, _ := servico.repositorio.Buscar(viga.ID); produto.Quantidade != 10 {
+		t.Errorf("Esperava 10 vigas, mas encontrei %d", produto.Quantidade)
+	}
+}
+
+func TestEstornosDoMesmoMovimentoEmProcessosDiferentes(t *testing.T) {
+	// dois processos da CLI: cada um com seu repositório e seu serviço sobre o mesmo arquivo
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	ctx := context.Background()
+	primeiro := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	viga := NovoProduto("viga", 10)
+	primeiro.CadastrarProduto(ctx, viga)
+	primeiro.VenderProduto(ctx, viga.ID, 2)
+	venda := ultimoMovimento(primeiro)
+
+	// o primeiro processo estorna depois que o segundo conferiu o livro e antes de ele gravar
+	repo := &repositorioComVendaNoMeio{RepositorioEstoque: NovoRepositorioArquivo(caminho)} // repositorioComVendaNoMeio vem de importacao_test.go
+	segundo := NovoServicoEstoque(repo)
+	repo.vender = func() {
+		if _, err := primeiro.Estornar(ctx, venda.ID, ""); err != nil {
+			t.Fatalf("Não esperava erro no primeiro estorno, mas recebi %v", err)
+		}
+	}
+	if _, err := segundo.Estornar(ctx, venda.ID, ""); !errors.Is(err, ErrMovimentoEstornado) {
+		t.Errorf("Esperava ErrMovimentoEstornado no segundo processo, mas recebi %v", err)
+	}
+
+	if produto, _ := segundo.BuscarProduto(viga.ID); produto.Quantidade != 10 {
+		t.Errorf("Esperava 10 vigas depois de um único estorno, mas encontrei %d", produto.Quantidade)
+	}
+	estornos := 0
+	for _, movimento := range segundo.ListarMovimentos() {
+		if movimento.Estorno == venda.ID {
+			estornos++
+		}
+	}
+	if estornos != 1 {
+		t.Errorf("Esperava 1 movimento de estorno no livro, mas encontrei %d", estornos)
+	}
+}
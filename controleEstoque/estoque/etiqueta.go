@@ -0,0 +1,335 @@
+package estoque
+
+import (
+	"bytes"        // pacote para montar o PDF em memória antes de calcular as posições
+	"encoding/xml" // pacote para escapar os textos do SVG
+	"fmt"          // pacote para formatação de strings
+	"io"           // pacote com a interface de escrita
+	"strings"      // pacote para montar os textos das etiquetas
+)
+
+// FormatoEtiqueta indica como a folha de etiquetas é gerada
+type FormatoEtiqueta string
+
+const (
+	EtiquetaSVG FormatoEtiqueta = "svg" // imagem vetorial, abre no navegador
+	EtiquetaPDF FormatoEtiqueta = "pdf" // folha A4 pronta para imprimir
+)
+
+// Medidas da folha de etiquetas em pontos (1/72 de polegada), iguais no SVG e no PDF
+const (
+	larguraFolha     = 595.0 // A4
+	alturaFolha      = 842.0
+	larguraEtiqueta  = 180.0
+	alturaEtiqueta   = 110.0
+	colunasEtiqueta  = 3
+	linhasEtiqueta   = 7 // etiquetas por coluna em cada página do PDF
+	larguraModulo    = 1.4
+	alturaBarras     = 55.0
+	alturaGuardas    = 61.0 // as barras de guarda descem um pouco mais, como no EAN-13 impresso
+	maximoCaracteres = 34   // títulos maiores são cortados para caber na etiqueta
+)
+
+// Etiqueta é o conteúdo de uma etiqueta impressa: o código de barras e os textos abaixo do nome
+type Etiqueta struct {
+	GTIN    string
+	Titulo  string // nome do produto
+	Detalhe string // lote e data de cura, nas etiquetas de lote
+}
+
+// EtiquetasProdutos monta uma etiqueta para cada produto informado; sem IDs, usa todos os produtos com código de barras
+func (s *ServicoEstoque) EtiquetasProdutos(ids ...string) ([]Etiqueta, error) {
+	produtos, err := s.produtosComGTIN(ids)
+	if err != nil {
+		return nil, err
+	}
+	var etiquetas []Etiqueta
+	for _, produto := range produtos {
+		etiquetas = append(etiquetas, Etiqueta{GTIN: produto.GTIN, Titulo: produto.Nome})
+	}
+	return etiquetas, nil
+}
+
+// EtiquetasLotes monta uma etiqueta para cada lote dos produtos informados (sem IDs, todos os produtos com código de barras)
+// A etiqueta leva o código de barras do produto e o código do lote com a data de cura
+func (s *ServicoEstoque) EtiquetasLotes(ids ...string) ([]Etiqueta, error) {
+	produtos, err := s.produtosComGTIN(ids)
+	if err != nil {
+		return nil, err
+	}
+	var etiquetas []Etiqueta
+	for _, produto := range produtos {
+		for _, lote := range produto.Lotes {
+			etiquetas = append(etiquetas, Etiqueta{
+				GTIN:    produto.GTIN,
+				Titulo:  produto.Nome,
+				Detalhe: fmt.Sprintf("Lote %s | cura %s", lote.Codigo, lote.DataCura.Format("02/01/2006")),
+			})
+		}
+	}
+	return etiquetas, nil
+}
+
+// produtosComGTIN busca os produtos das etiquetas; um produto pedido sem código de barras é um erro
+func (s *ServicoEstoque) produtosComGTIN(ids []string) ([]Produto, error) {
+	if len(ids) == 0 {
+		var produtos []Produto
+		for _, produto := range s.repositorio.Listar() {
+			if produto.GTIN != "" {
+				produtos = append(produtos, produto)
+			}
+		}
+		return produtos, nil
+	}
+
+	produtos := make([]Produto, 0, len(ids))
+	for _, id := range ids {
+		produto, err := s.buscarProduto(id)
+		if err != nil {
+			return nil, err
+		}
+		if produto.GTIN == "" {
+			return nil, fmt.Errorf("%w: %s não tem código de barras", ErrGTINInvalido, produto.Nome)
+		}
+		produtos = append(produtos, produto)
+	}
+	return produtos, nil
+}
+
+// EscreverEtiquetas gera a folha de etiquetas em SVG ou PDF, três etiquetas por linha
+// Retorna ErrGTINInvalido se algum código não puder ser impresso como EAN-13
+func EscreverEtiquetas(w io.Writer, formato FormatoEtiqueta, etiquetas []Etiqueta) error {
+	barras := make([]string, len(etiquetas))
+	for i, etiqueta := range etiquetas {
+		modulos, err := modulosEAN13(etiqueta.GTIN)
+		if err != nil {
+			return err
+		}
+		barras[i] = modulos
+	}
+
+	switch formato {
+	case EtiquetaSVG:
+		return escreverEtiquetasSVG(w, etiquetas, barras)
+	case EtiquetaPDF:
+		return escreverEtiquetasPDF(w, etiquetas, barras)
+	}
+	return ErrFormatoInvalido // ErrFormatoInvalido vem do arquivo planilha.go
+}
+
+// Padrões do EAN-13: L e G codificam os seis primeiros dígitos, R os seis últimos
+// O primeiro dígito não é desenhado; ele escolhe a sequência de L e G dos seis seguintes
+var (
+	padraoL  = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	padraoG  = [10]string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+	padraoR  = [10]string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+	paridade = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+// ean13 converte o GTIN em EAN-13: o GTIN-12 (UPC-A) ganha um zero à esquerda e o GTIN-14 que começa com zero perde
+func ean13(gtin string) (string, error) {
+	gtin = NormalizarGTIN(gtin)
+	if err := ValidarGTIN(gtin); err != nil {
+		return "", err
+	}
+	switch {
+	case len(gtin) == 13:
+		return gtin, nil
+	case len(gtin) == 12:
+		return "0" + gtin, nil
+	case len(gtin) == 14 && gtin[0] == '0':
+		return gtin[1:], nil
+	}
+	return "", fmt.Errorf("%w: %s não pode ser impresso como EAN-13", ErrGTINInvalido, gtin)
+}
+
+// modulosEAN13 devolve os 95 módulos do código de barras ('1' = barra, '0' = espaço)
+func modulosEAN13(gtin string) (string, error) {
+	codigo, err := ean13(gtin)
+	if err != nil {
+		return "", err
+	}
+
+	var modulos strings.Builder
+	modulos.WriteString("101") // guarda inicial
+	sequencia := paridade[codigo[0]-'0']
+	for i := 1; i <= 6; i++ {
+		if sequencia[i-1] == 'L' {
+			modulos.WriteString(padraoL[codigo[i]-'0'])
+		} else {
+			modulos.WriteString(padraoG[codigo[i]-'0'])
+		}
+	}
+	modulos.WriteString("01010") // guarda central
+	for i := 7; i <= 12; i++ {
+		modulos.WriteString(padraoR[codigo[i]-'0'])
+	}
+	modulos.WriteString("101") // guarda final
+	return modulos.String(), nil
+}
+
+// barra é um retângulo preto do código de barras, medido em módulos
+type barra struct {
+	inicio  int
+	largura int
+	guarda  bool
+}
+
+// barrasDosModulos junta os módulos pretos vizinhos em barras
+func barrasDosModulos(modulos string) []barra {
+	var barras []barra
+	for i := 0; i < len(modulos); i++ {
+		if modulos[i] != '1' {
+			continue
+		}
+		inicio := i
+		for i+1 < len(modulos) && modulos[i+1] == '1' {
+			i++
+		}
+		guarda := inicio < 3 || (inicio >= 45 && inicio < 50) || inicio >= 92
+		barras = append(barras, barra{inicio: inicio, largura: i - inicio + 1, guarda: guarda})
+	}
+	return barras
+}
+
+// posicaoEtiqueta retorna o canto superior esquerdo da etiqueta n dentro da página
+func posicaoEtiqueta(n int) (x, y float64) {
+	margemX := (larguraFolha - colunasEtiqueta*larguraEtiqueta) / 2
+	coluna, linha := n%colunasEtiqueta, n/colunasEtiqueta
+	return margemX + float64(coluna)*larguraEtiqueta, 36 + float64(linha)*alturaEtiqueta
+}
+
+// cortarTitulo limita o título ao espaço da etiqueta
+func cortarTitulo(titulo string) string {
+	letras := []rune(titulo)
+	if len(letras) <= maximoCaracteres {
+		return titulo
+	}
+	return string(letras[:maximoCaracteres-1]) + "…"
+}
+
+// escreverEtiquetasSVG desenha todas as etiquetas em uma única imagem, da altura necessária
+func escreverEtiquetasSVG(w io.Writer, etiquetas []Etiqueta, modulos []string) error {
+	linhas := (len(etiquetas) + colunasEtiqueta - 1) / colunasEtiqueta
+	altura := 72 + float64(linhas)*alturaEtiqueta
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n", larguraFolha, altura, larguraFolha, altura)
+	fmt.Fprintf(&svg, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for n, etiqueta := range etiquetas {
+		x, y := posicaoEtiqueta(n)
+		inicioBarras := x + (larguraEtiqueta-95*larguraModulo)/2
+		codigo, _ := ean13(etiqueta.GTIN) // já validado em EscreverEtiquetas
+
+		fmt.Fprintf(&svg, `<g font-family="Helvetica, Arial, sans-serif">`+"\n")
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="9">%s</text>`+"\n", inicioBarras, y+14, escaparXML(cortarTitulo(etiqueta.Titulo)))
+		if etiqueta.Detalhe != "" {
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="7">%s</text>`+"\n", inicioBarras, y+25, escaparXML(cortarTitulo(etiqueta.Detalhe)))
+		}
+		for _, b := range barrasDosModulos(modulos[n]) {
+			altura := alturaBarras
+			if b.guarda {
+				altura = alturaGuardas
+			}
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`+"\n", inicioBarras+float64(b.inicio)*larguraModulo, y+30, float64(b.largura)*larguraModulo, altura)
+		}
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="9" text-anchor="middle" letter-spacing="1">%s</text>`+"\n", x+larguraEtiqueta/2, y+102, codigo)
+		fmt.Fprintf(&svg, "</g>\n")
+	}
+	svg.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+// escaparXML troca &, < e > para o texto não quebrar o SVG
+func escaparXML(texto string) string {
+	var escapado strings.Builder
+	xml.EscapeText(&escapado, []byte(texto))
+	return escapado.String()
+}
+
+// escreverEtiquetasPDF gera um PDF A4 com 21 etiquetas por página, sem bibliotecas externas
+// Cada página tem um fluxo de desenho com os retângulos das barras e os textos em Helvetica
+func escreverEtiquetasPDF(w io.Writer, etiquetas []Etiqueta, modulos []string) error {
+	porPagina := colunasEtiqueta * linhasEtiqueta
+	var paginas []string
+	for inicio := 0; inicio < len(etiquetas) || inicio == 0; inicio += porPagina {
+		fim := min(inicio+porPagina, len(etiquetas))
+		var desenho strings.Builder
+		for n := inicio; n < fim; n++ {
+			desenharEtiquetaPDF(&desenho, n-inicio, etiquetas[n], modulos[n])
+		}
+		paginas = append(paginas, desenho.String())
+	}
+
+	// objetos: 1 catálogo, 2 árvore de páginas, 3 fonte, depois uma página e o seu desenho para cada página
+	objetos := []string{"<< /Type /Catalog /Pages 2 0 R >>", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"}
+	var filhas []string
+	for _, desenho := range paginas {
+		pagina := len(objetos) + 1
+		filhas = append(filhas, fmt.Sprintf("%d 0 R", pagina))
+		objetos = append(objetos,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", larguraFolha, alturaFolha, pagina+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(desenho), desenho))
+	}
+	objetos[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(filhas, " "), len(paginas))
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	posicoes := make([]int, len(objetos))
+	for i, objeto := range objetos {
+		posicoes[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, objeto)
+	}
+	tabela := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objetos)+1)
+	for _, posicao := range posicoes {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", posicao)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objetos)+1, tabela)
+
+	_, err := w.Write(pdf.Bytes())
+	return err
+}
+
+// desenharEtiquetaPDF escreve os comandos de desenho de uma etiqueta (o PDF mede y de baixo para cima)
+func desenharEtiquetaPDF(desenho *strings.Builder, n int, etiqueta Etiqueta, modulos string) {
+	x, topo := posicaoEtiqueta(n)
+	y := alturaFolha - topo
+	inicioBarras := x + (larguraEtiqueta-95*larguraModulo)/2
+	codigo, _ := ean13(etiqueta.GTIN)
+
+	fmt.Fprintf(desenho, "BT /F1 9 Tf %.1f %.1f Td (%s) Tj ET\n", inicioBarras, y-14, textoPDF(cortarTitulo(etiqueta.Titulo)))
+	if etiqueta.Detalhe != "" {
+		fmt.Fprintf(desenho, "BT /F1 7 Tf %.1f %.1f Td (%s) Tj ET\n", inicioBarras, y-25, textoPDF(cortarTitulo(etiqueta.Detalhe)))
+	}
+	for _, b := range barrasDosModulos(modulos) {
+		altura := alturaBarras
+		if b.guarda {
+			altura = alturaGuardas
+		}
+		fmt.Fprintf(desenho, "%.1f %.1f %.1f %.1f re f\n", inicioBarras+float64(b.inicio)*larguraModulo, y-30-altura, float64(b.largura)*larguraModulo, altura)
+	}
+	larguraCodigo := float64(len(codigo)) * 0.556 * 9 // na Helvetica cada dígito mede 556/1000 do tamanho da fonte
+	fmt.Fprintf(desenho, "BT /F1 9 Tf %.1f %.1f Td (%s) Tj ET\n", x+(larguraEtiqueta-larguraCodigo)/2, y-102, codigo)
+}
+
+// textoPDF converte o texto para WinAnsi (acentos do português) e escapa os caracteres especiais do PDF
+func textoPDF(texto string) string {
+	var convertido strings.Builder
+	for _, r := range texto {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			convertido.WriteByte('\\')
+			convertido.WriteRune(r)
+		case r == '…':
+			convertido.WriteByte(0x85) // reticências no WinAnsi
+		case r < 256:
+			convertido.WriteByte(byte(r))
+		default:
+			convertido.WriteByte('?')
+		}
+	}
+	return convertido.String()
+}
@@ -0,0 +1,520 @@
+package estoque
+
+import (
+	"bufio"         // serve para ler o log de eventos linha por linha
+	"bytes"         // serve para achar a última linha completa do log
+	"encoding/json" // serve para codificar os eventos e os snapshots
+	"errors"        // serve para criar os erros do histórico
+	"fmt"           // serve para montar o nome dos arquivos de snapshot
+	"io"            // serve para reconhecer o fim do log
+	"os"            // serve para ler e escrever os arquivos
+	"path/filepath" // serve para montar os caminhos dentro do diretório
+	"sort"          // serve para ordenar os snapshots
+	"sync"          // serve para proteger o estado e o log
+	"time"          // serve para a data de cada evento
+)
+
+// IntervaloSnapshotPadrao é a cada quantos eventos um snapshot do estado é gravado
+const IntervaloSnapshotPadrao = 100
+
+// Erro para indicar que o repositório não guarda histórico para consultas "na data"
+var ErrSemHistorico = errors.New("repositório sem histórico de eventos")
+
+// Erro para indicar uma linha ilegível no meio do log de eventos (só a última linha incompleta é descartada)
+var ErrLogCorrompido = errors.New("log de eventos corrompido")
+
+// TipoEvento classifica cada linha do log de eventos
+type TipoEvento string
+
+const (
+	EventoProdutoAdicionado   TipoEvento = "produto_adicionado"   // Produtos tem o produto cadastrado
+	EventoProdutosAtualizados TipoEvento = "produtos_atualizados" // Produtos tem o novo estado de cada produto alterado
+	EventoMovimentoRegistrado TipoEvento = "movimento_registrado" // Movimento tem a linha do livro de movimentações
+	EventoOperacaoGravada     TipoEvento = "operacao_gravada"     // Novos, Produtos (alterados) e Movimentos de uma operação inteira
+)
+
+// Evento é uma linha imutável do log; o estado do estoque é a aplicação de todos os eventos em ordem
+type Evento struct {
+	Sequencia  int
+	Tipo       TipoEvento
+	Data       time.Time
+	Produtos   []Produto   `json:",omitempty"`
+	Novos      []Produto   `json:",omitempty"`
+	Movimento  *Movimento  `json:",omitempty"`
+	Movimentos []Movimento `json:",omitempty"`
+}
+
+// snapshot é o estado depois de um evento, para não precisar reaplicar o log desde o início
+// Os produtos vão inteiros; o livro de movimentações não: cada snapshot só traz os movimentos desde o
+// anterior, para o espaço em disco crescer com o histórico e não com o quadrado dele
+type snapshot struct {
+	Sequencia  int // último evento aplicado
+	Anterior   int `json:",omitempty"` // sequência do snapshot anterior (0 = Movimentos traz o livro desde o início)
+	Data       time.Time
+	Produtos   []Produto
+	Movimentos []Movimento // só os movimentos depois do snapshot anterior
+}
+
+// RepositorioEventos implementa o RepositorioEstoque com event sourcing
+// Cada alteração vira um evento no final de eventos.jsonl (gravado com fsync) e, a cada
+// IntervaloSnapshotPadrao eventos, o estado completo é salvo em snapshot-NNNNNNNN.json
+// Ao abrir, o estado é o último snapshot mais os eventos seguintes; EstoqueEm refaz o estado de uma data passada
+// O estado atual fica em um RepositorioMemoria, então buscas e consultas não leem o disco
+type RepositorioEventos struct {
+	diretorio string
+	estado    *RepositorioMemoria // estado atual (memoria.go)
+	sequencia int                 // último evento gravado
+	intervalo int                 // eventos entre dois snapshots
+	agora     func() time.Time    // relógio dos eventos (substituível nos testes)
+	log       *os.File
+	erroAbrir error // erro ao abrir o log (ou escrita que não pôde ser desfeita), devolvido pelas operações que retornam erro
+	mu        sync.Mutex
+
+	snapshotAnterior     int // sequência do último snapshot gravado ou lido
+	movimentosAnteriores int // movimentos do livro que já estão na cadeia de snapshots
+}
+
+// NovoRepositorioEventos abre (ou cria) o log de eventos no diretório e reconstrói o estado atual
+func NovoRepositorioEventos(diretorio string) *RepositorioEventos {
+	r := &RepositorioEventos{
+		diretorio: diretorio,
+		estado:    NovoRepositorioMemoria(),
+		intervalo: IntervaloSnapshotPadrao,
+		agora:     time.Now,
+	}
+
+	if err := os.MkdirAll(diretorio, 0755); err != nil {
+		r.erroAbrir = err
+		return r
+	}
+	r.erroAbrir = r.repararLog()
+	if r.erroAbrir == nil {
+		var inicial *snapshot
+		r.estado, r.sequencia, inicial, r.erroAbrir = r.reconstruir(time.Time{})
+		if inicial != nil {
+			r.snapshotAnterior, r.movimentosAnteriores = inicial.Sequencia, len(inicial.Movimentos)
+		}
+	}
+	if r.erroAbrir == nil {
+		r.log, r.erroAbrir = os.OpenFile(r.caminhoLog(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	return r
+}
+
+// DefinirIntervaloSnapshot muda a cada quantos eventos o estado completo é salvo
+func (r *RepositorioEventos) DefinirIntervaloSnapshot(eventos int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intervalo = max(eventos, 1)
+}
+
+// Fechar fecha o arquivo do log
+func (r *RepositorioEventos) Fechar() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.log == nil {
+		return nil
+	}
+	err := r.log.Close()
+	r.log = nil
+	return err
+}
+
+// Adicionar grava o evento de cadastro do produto e o aplica ao estado
+func (r *RepositorioEventos) Adicionar(produto Produto) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registrar(Evento{Tipo: EventoProdutoAdicionado, Produtos: []Produto{produto.clonar()}}) // sem retorno de erro na interface, como no RepositorioArquivo
+}
+
+// Atualizar grava o novo estado do produto se ele estiver na versão atual (ErrConflito se não estiver)
+func (r *RepositorioEventos) Atualizar(produto Produto) error {
+	return r.AtualizarVarios([]Produto{produto})
+}
+
+// AtualizarVarios grava todos os produtos em um único evento: todos ou nenhum
+func (r *RepositorioEventos) AtualizarVarios(produtos []Produto) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.estado.mu.Lock()
+	novos, err := compararEGravarVarios(r.estado.produtos, r.estado.indice, produtos) // compararEGravarVarios vem do arquivo concorrencia.go
+	r.estado.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	evento := Evento{Tipo: EventoProdutosAtualizados}
+	for _, produto := range produtos { // mesma ordem recebida, para o log ficar legível
+		novo := novos[r.estado.indice[produto.ID]]
+		evento.Produtos = append(evento.Produtos, novo)
+	}
+	return r.registrar(evento)
+}
+
+// GravarOperacao grava a operação inteira em um único evento: produtos novos, alterados e movimentos
+func (r *RepositorioEventos) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.estado.mu.Lock()
+	atualizados, err := compararEGravarVarios(r.estado.produtos, r.estado.indice, alterados)
+	var incluidos []Produto
+	if err == nil {
+		incluidos, err = compararEIncluir(r.estado.produtos, r.estado.indice, novos) // compararEIncluir vem do arquivo concorrencia.go
+	}
+	r.estado.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	evento := Evento{Tipo: EventoOperacaoGravada, Novos: incluidos, Movimentos: movimentos}
+	for _, produto := range alterados {
+		evento.Produtos = append(evento.Produtos, atualizados[r.estado.indice[produto.ID]])
+	}
+	return r.registrar(evento)
+}
+
+// Buscar devolve o produto do estado atual
+func (r *RepositorioEventos) Buscar(id string) (Produto, error) {
+	return r.estado.Buscar(id)
+}
+
+// Listar devolve os produtos do estado atual
+func (r *RepositorioEventos) Listar() []Produto {
+	return r.estado.Listar()
+}
+
+// Consultar filtra, ordena e pagina os produtos do estado atual
+func (r *RepositorioEventos) Consultar(consulta Consulta) (PaginaProdutos, error) {
+	return r.estado.Consultar(consulta)
+}
+
+// RegistrarMovimento grava o evento do movimento e o acrescenta ao livro
+func (r *RepositorioEventos) RegistrarMovimento(movimento Movimento) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registrar(Evento{Tipo: EventoMovimentoRegistrado, Movimento: &movimento})
+}
+
+// ListarMovimentos devolve o livro de movimentações do estado atual
+func (r *RepositorioEventos) ListarMovimentos() []Movimento {
+	return r.estado.ListarMovimentos()
+}
+
+// ListarEventos lê o log completo, do primeiro ao último evento
+func (r *RepositorioEventos) ListarEventos() ([]Evento, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var eventos []Evento
+	err := r.lerEventos(0, func(evento Evento) bool {
+		eventos = append(eventos, evento)
+		return true
+	})
+	return eventos, err
+}
+
+// EstoqueEm refaz o estoque como estava na data informada (ex: fechamento do mês)
+// Parte do último snapshot até a data e aplica os eventos seguintes que aconteceram até ela
+func (r *RepositorioEventos) EstoqueEm(data time.Time) (RepositorioEstoque, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.erroAbrir != nil {
+		return nil, r.erroAbrir
+	}
+	estado, _, _, err := r.reconstruir(data)
+	return estado, err
+}
+
+// registrar grava o evento no final do log com fsync e só então o aplica ao estado
+// Se a gravação ou o fsync falhar, o log volta ao tamanho anterior: sem isso, a meia linha ficaria
+// no meio do log depois do próximo evento e a abertura seguinte daria ErrLogCorrompido
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioEventos) registrar(evento Evento) error {
+	if r.erroAbrir != nil {
+		return r.erroAbrir
+	}
+
+	evento.Sequencia = r.sequencia + 1
+	evento.Data = r.agora()
+	linha, err := json.Marshal(evento)
+	if err != nil {
+		return err
+	}
+	info, err := r.log.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = r.log.Write(append(linha, '\n'))
+	if err == nil {
+		err = r.log.Sync() // o evento só vale depois de estar no disco
+	}
+	if err != nil {
+		if errCorte := r.log.Truncate(info.Size()); errCorte != nil {
+			r.erroAbrir = fmt.Errorf("%w: gravação interrompida não desfeita: %v", ErrLogCorrompido, errCorte) // nada mais é gravado depois da meia linha
+		}
+		return err
+	}
+
+	r.sequencia = evento.Sequencia
+	aplicarEvento(r.estado, evento)
+	if r.sequencia%r.intervalo == 0 {
+		r.gravarSnapshot(evento.Data) // um snapshot perdido só deixa a próxima abertura mais lenta
+	}
+	return nil
+}
+
+// reconstruir monta o estado a partir do último snapshot e dos eventos seguintes
+// Com data zero, usa todos os eventos; senão, só os que aconteceram até a data
+// Devolve também o snapshot usado (nil se partiu do início do log), já com o livro inteiro da cadeia
+func (r *RepositorioEventos) reconstruir(data time.Time) (*RepositorioMemoria, int, *snapshot, error) {
+	estado := NovoRepositorioMemoria()
+	sequencia := 0
+
+	inicial, err := r.ultimoSnapshot(data)
+	if err != nil {
+		return estado, 0, nil, err // estado vazio: as operações devolvem o erro, mas as consultas não quebram
+	}
+	if inicial != nil {
+		for _, produto := range inicial.Produtos {
+			estado.Adicionar(produto)
+		}
+		estado.movimentos = append([]Movimento(nil), inicial.Movimentos...) // os próximos movimentos não podem mexer no livro do snapshot
+		sequencia = inicial.Sequencia
+	}
+
+	err = r.lerEventos(sequencia, func(evento Evento) bool {
+		if !data.IsZero() && evento.Data.After(data) {
+			return false // o log está em ordem, os próximos também são depois da data
+		}
+		aplicarEvento(estado, evento)
+		sequencia = evento.Sequencia
+		return true
+	})
+	return estado, sequencia, inicial, err
+}
+
+// aplicarEvento muda o estado conforme o evento, sem conferir versões (o evento já foi aceito ao ser gravado)
+func aplicarEvento(estado *RepositorioMemoria, evento Evento) {
+	switch evento.Tipo {
+	case EventoProdutoAdicionado:
+		for _, produto := range evento.Produtos {
+			estado.Adicionar(produto)
+		}
+	case EventoProdutosAtualizados:
+		substituirProdutos(estado, evento.Produtos)
+	case EventoMovimentoRegistrado:
+		if evento.Movimento != nil {
+			estado.RegistrarMovimento(*evento.Movimento)
+		}
+	case EventoOperacaoGravada:
+		for _, produto := range evento.Novos {
+			estado.Adicionar(produto)
+		}
+		substituirProdutos(estado, evento.Produtos)
+		for _, movimento := range evento.Movimentos {
+			estado.RegistrarMovimento(movimento)
+		}
+	}
+}
+
+// substituirProdutos troca cada produto do estado pelo novo estado gravado no evento
+func substituirProdutos(estado *RepositorioMemoria, produtos []Produto) {
+	estado.mu.Lock()
+	defer estado.mu.Unlock()
+	for _, produto := range produtos {
+		if i, existe := estado.indice[produto.ID]; existe {
+			estado.produtos[i] = produto.clonar()
+		}
+	}
+}
+
+// lerEventos percorre o log em ordem, a partir do evento seguinte a "depois", até o visitar devolver false
+// Uma última linha incompleta (queda no meio da gravação, sem o \n final) é ignorada; uma linha ilegível
+// em qualquer outro ponto devolve ErrLogCorrompido, em vez de esconder os eventos seguintes
+func (r *RepositorioEventos) lerEventos(depois int, visitar func(Evento) bool) error {
+	arquivo, err := os.Open(r.caminhoLog())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil // log ainda não existe -> nenhum evento
+	}
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+
+	leitor := bufio.NewReaderSize(arquivo, 64*1024) // produtos com muitos lotes geram linhas longas
+	for numero := 1; ; numero++ {
+		linha, err := leitor.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil // o que sobrou sem \n é a gravação interrompida (ou nada)
+		}
+		if err != nil {
+			return err
+		}
+
+		var evento Evento
+		if err := json.Unmarshal(linha, &evento); err != nil {
+			return fmt.Errorf("%w: linha %d: %v", ErrLogCorrompido, numero, err)
+		}
+		if evento.Sequencia <= depois {
+			continue
+		}
+		if !visitar(evento) {
+			return nil
+		}
+	}
+}
+
+// gravarSnapshot salva os produtos atuais e os movimentos desde o snapshot anterior; o nome leva a sequência para ordenar os arquivos
+// Deve ser chamado com o mutex bloqueado
+func (r *RepositorioEventos) gravarSnapshot(data time.Time) error {
+	r.estado.mu.Lock()
+	movimentos := len(r.estado.movimentos)
+	conteudo := snapshot{
+		Sequencia:  r.sequencia,
+		Anterior:   r.snapshotAnterior,
+		Data:       data,
+		Produtos:   r.estado.produtos,
+		Movimentos: r.estado.movimentos[r.movimentosAnteriores:],
+	}
+	dados, err := json.Marshal(conteudo)
+	r.estado.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	caminho := r.caminhoSnapshot(r.sequencia)
+	temporario := caminho + ".tmp"
+	if err := os.WriteFile(temporario, dados, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temporario, caminho); err != nil { // o snapshot aparece inteiro ou não aparece
+		return err
+	}
+	r.snapshotAnterior, r.movimentosAnteriores = r.sequencia, movimentos // o próximo continua a cadeia a partir deste
+	return nil
+}
+
+// ultimoSnapshot procura o snapshot mais recente até a data (data zero = o mais recente de todos)
+func (r *RepositorioEventos) ultimoSnapshot(data time.Time) (*snapshot, error) {
+	caminhos, err := filepath.Glob(filepath.Join(r.diretorio, "snapshot-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(caminhos))) // a sequência com zeros à esquerda ordena pelo nome
+
+	for _, caminho := range caminhos {
+		lido, err := lerSnapshot(caminho)
+		if snapshotPerdido(err) {
+			continue // snapshot corrompido: tenta o anterior
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !data.IsZero() && lido.Data.After(data) {
+			continue
+		}
+		if completo, err := r.completarLivro(lido); err != nil {
+			return nil, err
+		} else if completo {
+			return lido, nil
+		}
+		// algum snapshot da cadeia sumiu ou está corrompido: tenta um anterior (no limite, o log desde o início)
+	}
+	return nil, nil
+}
+
+// completarLivro junta ao snapshot os movimentos dos snapshots anteriores da cadeia, do mais antigo ao mais novo
+// Devolve false se algum elo estiver faltando ou ilegível
+func (r *RepositorioEventos) completarLivro(s *snapshot) (bool, error) {
+	partes := [][]Movimento{s.Movimentos}
+	for anterior := s.Anterior; anterior > 0; {
+		elo, err := lerSnapshot(r.caminhoSnapshot(anterior))
+		if snapshotPerdido(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		partes = append(partes, elo.Movimentos)
+		anterior = elo.Anterior
+	}
+
+	var livro []Movimento
+	for i := len(partes) - 1; i >= 0; i-- {
+		livro = append(livro, partes[i]...)
+	}
+	s.Movimentos = livro
+	return true, nil
+}
+
+// errSnapshotIlegivel indica um arquivo de snapshot que não é JSON válido (ex: gravação antiga interrompida)
+var errSnapshotIlegivel = errors.New("snapshot ilegível")
+
+// lerSnapshot lê um arquivo de snapshot
+func lerSnapshot(caminho string) (*snapshot, error) {
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, err
+	}
+	var lido snapshot
+	if err := json.Unmarshal(dados, &lido); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errSnapshotIlegivel, caminho, err)
+	}
+	return &lido, nil
+}
+
+// snapshotPerdido diz se o erro de lerSnapshot só obriga a usar um snapshot anterior (ou o log)
+func snapshotPerdido(err error) bool {
+	return errors.Is(err, errSnapshotIlegivel) || errors.Is(err, os.ErrNotExist)
+}
+
+// repararLog corta uma última linha incompleta (queda no meio da gravação) para que
+// os próximos eventos não sejam escritos grudados nela
+func (r *RepositorioEventos) repararLog() error {
+	dados, err := os.ReadFile(r.caminhoLog())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(dados) == 0 || dados[len(dados)-1] == '\n' {
+		return nil
+	}
+	return os.Truncate(r.caminhoLog(), int64(bytes.LastIndexByte(dados, '\n')+1))
+}
+
+// caminhoSnapshot retorna o arquivo do snapshot gravado depois do evento da sequência
+func (r *RepositorioEventos) caminhoSnapshot(sequencia int) string {
+	return filepath.Join(r.diretorio, fmt.Sprintf("snapshot-%08d.json", sequencia))
+}
+
+// caminhoLog retorna o arquivo do log de eventos dentro do diretório
+func (r *RepositorioEventos) caminhoLog() string {
+	return filepath.Join(r.diretorio, "eventos.jsonl")
+}
+
+// RepositorioHistorico é implementado pelos repositórios que sabem refazer o estoque de uma data passada
+type RepositorioHistorico interface {
+	EstoqueEm(data time.Time) (RepositorioEstoque, error)
+}
+
+// EstoqueEm devolve um serviço sobre o estoque como estava na data, para auditorias e fechamento do mês
+// Os relatórios (ValorizarEstoque, TotaisPorLocal, EstoquePorLote...) do serviço devolvido usam essa data como "agora"
+// Retorna ErrSemHistorico se o repositório não guarda eventos
+func (s *ServicoEstoque) EstoqueEm(data time.Time) (*ServicoEstoque, error) {
+	historico, ok := s.repositorio.(RepositorioHistorico)
+	if !ok {
+		return nil, ErrSemHistorico
+	}
+	passado, err := historico.EstoqueEm(data)
+	if err != nil {
+		return nil, err
+	}
+
+	servico := NovoServicoEstoque(passado)
+	servico.politicaLotes = s.politicaLotes
+	servico.agora = func() time.Time { return data }
+	return servico, nil
+}
@@ -0,0 +1,234 @@
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"os"            // pacote padrão para simular uma gravação interrompida
+	"path/filepath" // pacote padrão para montar o caminho dos arquivos temporários
+	"strings"       // pacote padrão para estragar uma linha do log
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para as datas dos eventos
+)
+
+// relogio devolve um relógio que pode ser adiantado pelo teste
+func relogio(inicio time.Time) (func() time.Time, func(time.Duration)) {
+	agora := inicio
+	return func() time.Time { return agora }, func(d time.Duration) { agora = agora.Add(d) }
+}
+
+func TestRepositorioEventosReconstroiEConsultaNaData(t *testing.T) {
+	diretorio := t.TempDir()
+	agora, adiantar := relogio(time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC))
+
+	repo := NovoRepositorioEventos(diretorio)
+	repo.agora = agora
+	repo.DefinirIntervaloSnapshot(3) // snapshots frequentes para o teste passar por eles
+	servico := NovoServicoEstoque(repo)
+	servico.agora = agora
+
+	viga := NovoProduto("viga", 20)
+	servico.CadastrarProduto(context.Background(), viga)
+	servico.VenderProduto(context.Background(), viga.ID, 5) // 30/05: fica com 15
+	adiantar(48 * time.Hour)
+	servico.Transferir(context.Background(), viga.ID, LocalPatio, LocalLoja, 4) // 01/06
+	servico.VenderProdutoNoLocal(context.Background(), viga.ID, LocalLoja, 2)   // 01/06: fica com 13
+	repo.Fechar()
+
+	if snapshots, _ := filepath.Glob(filepath.Join(diretorio, "snapshot-*.json")); len(snapshots) == 0 {
+		t.Fatalf("Esperava pelo menos um snapshot gravado")
+	}
+
+	// reabrir o diretório refaz o estado a partir do snapshot e dos eventos seguintes
+	reaberto := NovoRepositorioEventos(diretorio)
+	defer reaberto.Fechar()
+	produto, err := reaberto.Buscar(viga.ID)
+	if err != nil || produto.Quantidade != 13 || produto.QuantidadeNoLocal(LocalLoja) != 2 {
+		t.Fatalf("Estado reconstruído inesperado: %+v (erro %v)", produto, err)
+	}
+	if len(reaberto.ListarMovimentos()) != len(servico.ListarMovimentos()) {
+		t.Errorf("Esperava %d movimentos após reabrir, mas encontrei %d", len(servico.ListarMovimentos()), len(reaberto.ListarMovimentos()))
+	}
+
+	// fechamento de maio: o estoque como estava no fim do dia 31
+	fechamento, err := NovoServicoEstoque(reaberto).EstoqueEm(time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Não esperava erro na consulta por data, mas recebi %v", err)
+	}
+	if total := fechamento.TotalGeral(); total != 15 {
+		t.Errorf("Esperava 15 vigas no fechamento de maio, mas encontrei %d", total)
+	}
+	if antes, _ := reaberto.EstoqueEm(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); len(antes.Listar()) != 0 {
+		t.Errorf("Antes do primeiro evento o estoque deveria estar vazio, mas encontrei %+v", antes.Listar())
+	}
+
+	// repositórios sem eventos não sabem responder pelo passado
+	if _, err := NovoServicoEstoque(NovoRepositorioMemoria()).EstoqueEm(time.Now()); !errors.Is(err, ErrSemHistorico) {
+		t.Errorf("Esperava ErrSemHistorico, mas recebi %v", err)
+	}
+}
+
+func TestRepositorioEventosIgnoraLinhaIncompleta(t *testing.T) {
+	diretorio := t.TempDir()
+	repo := NovoRepositorioEventos(diretorio)
+	servico := NovoServicoEstoque(repo)
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(context.Background(), coluna)
+	repo.Fechar()
+
+	// simula uma queda no meio da gravação de um evento
+	log, _ := os.OpenFile(filepath.Join(diretorio, "eventos.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	log.WriteString(`{"Sequencia":99,"Tipo":"produtos_atua`)
+	log.Close()
+
+	repo = NovoRepositorioEventos(diretorio)
+	if err := NovoServicoEstoque(repo).VenderProduto(context.Background(), coluna.ID, 3); err != nil {
+		t.Fatalf("Não esperava erro ao vender após a queda, mas recebi %v", err)
+	}
+	repo.Fechar()
+
+	reaberto := NovoRepositorioEventos(diretorio)
+	defer reaberto.Fechar()
+	if produto, _ := reaberto.Buscar(coluna.ID); produto.Quantidade != 7 {
+		t.Errorf("Esperava 7 colunas, mas encontrei %d", produto.Quantidade)
+	}
+	eventos, _ := reaberto.ListarEventos()
+	for i, evento := range eventos {
+		if evento.Sequencia != i+1 {
+			t.Fatalf("Sequência dos eventos quebrada: %+v", eventos)
+		}
+	}
+}
+
+func TestRepositorioEventosGravaUmEventoPorOperacao(t *testing.T) {
+	repo := NovoRepositorioEventos(t.TempDir())
+	defer repo.Fechar()
+	servico := NovoServicoEstoque(repo)
+
+	telha := NovoProduto("telha", 10)
+	telha.Locais = map[string]int{LocalPatio: 6, LocalLoja: 4}
+	if err := servico.CadastrarProduto(context.Background(), telha); err != nil {
+		t.Fatalf("Não esperava erro no cadastro, mas recebi %v", err)
+	}
+	if err := servico.VenderProduto(context.Background(), telha.ID, 8); err != nil { // sai dos dois locais
+		t.Fatalf("Não esperava erro na venda, mas recebi %v", err)
+	}
+
+	eventos, err := repo.ListarEventos()
+	if err != nil {
+		t.Fatalf("Não esperava erro ao ler o log, mas recebi %v", err)
+	}
+	if len(eventos) != 2 {
+		t.Fatalf("Esperava um evento por operação (2), mas encontrei %d: %+v", len(eventos), eventos)
+	}
+	cadastro, venda := eventos[0], eventos[1]
+	if cadastro.Tipo != EventoOperacaoGravada || len(cadastro.Novos) != 1 || len(cadastro.Movimentos) != 2 {
+		t.Errorf("Evento de cadastro inesperado: %+v", cadastro)
+	}
+	if venda.Tipo != EventoOperacaoGravada || len(venda.Produtos) != 1 || len(venda.Movimentos) != 2 {
+		t.Errorf("Evento de venda inesperado: %+v", venda)
+	}
+}
+
+func TestRepositorioEventosRecusaLogCorrompidoNoMeio(t *testing.T) {
+	diretorio := t.TempDir()
+	repo := NovoRepositorioEventos(diretorio)
+	servico := NovoServicoEstoque(repo)
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(context.Background(), coluna)
+	servico.VenderProduto(context.Background(), coluna.ID, 3)
+	servico.VenderProduto(context.Background(), coluna.ID, 2)
+	repo.Fechar()
+
+	// estraga o segundo evento: os seguintes não podem sumir em silêncio
+	caminho := filepath.Join(diretorio, "eventos.jsonl")
+	dados, _ := os.ReadFile(caminho)
+	linhas := strings.SplitAfter(string(dados), "\n")
+	linhas[1] = "{\"Sequencia\":2,\"Tipo\":\n"
+	os.WriteFile(caminho, []byte(strings.Join(linhas, "")), 0644)
+
+	reaberto := NovoRepositorioEventos(diretorio)
+	defer reaberto.Fechar()
+	if _, err := reaberto.ListarEventos(); !errors.Is(err, ErrLogCorrompido) {
+		t.Errorf("Esperava ErrLogCorrompido ao listar, mas recebi %v", err)
+	}
+	if err := NovoServicoEstoque(reaberto).VenderProduto(context.Background(), coluna.ID, 1); !errors.Is(err, ErrLogCorrompido) {
+		t.Errorf("Esperava ErrLogCorrompido ao vender, mas recebi %v", err)
+	}
+}
+
+func TestRepositorioEventosSnapshotGuardaSoOsMovimentosNovos(t *testing.T) {
+	diretorio := t.TempDir()
+	repo := NovoRepositorioEventos(diretorio)
+	repo.DefinirIntervaloSnapshot(2)
+	servico := NovoServicoEstoque(repo)
+	coluna := NovoProduto("coluna", 20)
+	servico.CadastrarProduto(context.Background(), coluna)
+	for i := 0; i < 7; i++ {
+		servico.VenderProduto(context.Background(), coluna.ID, 1)
+	}
+	repo.Fechar()
+
+	// 8 eventos, 8 movimentos: cada snapshot traz só os 2 movimentos desde o anterior
+	caminhos, _ := filepath.Glob(filepath.Join(diretorio, "snapshot-*.json"))
+	if len(caminhos) != 4 {
+		t.Fatalf("Esperava 4 snapshots, mas encontrei %d", len(caminhos))
+	}
+	for _, caminho := range caminhos {
+		if lido, err := lerSnapshot(caminho); err != nil || len(lido.Movimentos) != 2 {
+			t.Errorf("Snapshot %s inesperado: %+v (erro %v)", filepath.Base(caminho), lido, err)
+		}
+	}
+
+	reaberto := NovoRepositorioEventos(diretorio)
+	reaberto.DefinirIntervaloSnapshot(2)
+	if movimentos := reaberto.ListarMovimentos(); len(movimentos) != 8 {
+		t.Errorf("Esperava o livro inteiro (8 movimentos) após reabrir, mas encontrei %d", len(movimentos))
+	}
+	NovoServicoEstoque(reaberto).VenderProduto(context.Background(), coluna.ID, 1)
+	NovoServicoEstoque(reaberto).VenderProduto(context.Background(), coluna.ID, 1) // evento 10: novo snapshot continua a cadeia
+	reaberto.Fechar()
+	if lido, err := lerSnapshot(filepath.Join(diretorio, "snapshot-00000010.json")); err != nil || lido.Anterior != 8 || len(lido.Movimentos) != 2 {
+		t.Errorf("Snapshot depois de reabrir inesperado: %+v (erro %v)", lido, err)
+	}
+
+	// sem um elo da cadeia, o livro vem do log e nada se perde
+	os.Remove(filepath.Join(diretorio, "snapshot-00000004.json"))
+	reaberto = NovoRepositorioEventos(diretorio)
+	defer reaberto.Fechar()
+	if movimentos := reaberto.ListarMovimentos(); len(movimentos) != 10 {
+		t.Errorf("Esperava 10 movimentos sem um snapshot do meio, mas encontrei %d", len(movimentos))
+	}
+	if produto, _ := reaberto.Buscar(coluna.ID); produto.Quantidade != 11 {
+		t.Errorf("Esperava 11 colunas, mas encontrei %d", produto.Quantidade)
+	}
+}
+
+func TestRepositorioEventosParaDeGravarSeNaoDesfazAGravacao(t *testing.T) {
+	diretorio := t.TempDir()
+	repo := NovoRepositorioEventos(diretorio)
+	servico := NovoServicoEstoque(repo)
+	coluna := NovoProduto("coluna", 10)
+	servico.CadastrarProduto(context.Background(), coluna)
+
+	// log somente leitura: a gravação falha e o corte também
+	log := repo.log
+	somenteLeitura, _ := os.Open(filepath.Join(diretorio, "eventos.jsonl"))
+	defer somenteLeitura.Close()
+	repo.log = somenteLeitura
+	if err := servico.VenderProduto(context.Background(), coluna.ID, 3); err == nil {
+		t.Fatalf("Esperava erro na venda com o log somente leitura")
+	}
+
+	// pode ter sobrado meia linha: nenhum evento é gravado depois dela
+	repo.log = log
+	if err := servico.VenderProduto(context.Background(), coluna.ID, 2); !errors.Is(err, ErrLogCorrompido) {
+		t.Errorf("Esperava ErrLogCorrompido depois da gravação não desfeita, mas recebi %v", err)
+	}
+	repo.Fechar()
+
+	reaberto := NovoRepositorioEventos(diretorio)
+	defer reaberto.Fechar()
+	if produto, _ := reaberto.Buscar(coluna.ID); produto.Quantidade != 10 {
+		t.Errorf("Esperava 10 colunas, mas encontrei %d", produto.Quantidade)
+	}
+}
@@ -0,0 +1,56 @@
+package estoque
+
+import (
+	"io"      // pacote com a interface de escrita
+	"strconv" // pacote para converter números em texto
+	"time"    // pacote para formatar as datas dos movimentos
+)
+
+// ExportarEstoque grava o estoque atual de qualquer RepositorioEstoque em uma planilha
+// Cada linha é o saldo de um produto em um local, com as mesmas colunas aceitas por Importar
+func ExportarEstoque(repo RepositorioEstoque, w io.Writer, formato FormatoPlanilha) error {
+	linhas := [][]string{{"id", CampoSKU, CampoGTIN, CampoNome, CampoCategoria, CampoLocal, CampoQuantidade}}
+
+	for _, produto := range repo.Listar() {
+		locais := produto.LocaisOrdenados() // LocaisOrdenados vem do arquivo local.go
+		if len(locais) == 0 {
+			locais = []string{LocalPadrao} // produto sem saldo aparece com quantidade zero
+		}
+		for _, local := range locais {
+			linhas = append(linhas, []string{
+				produto.ID,
+				produto.SKU,
+				produto.GTIN,
+				produto.Nome,
+				produto.Categoria,
+				local,
+				strconv.Itoa(produto.QuantidadeNoLocal(local)),
+			})
+		}
+	}
+
+	return EscreverPlanilha(w, formato, linhas) // EscreverPlanilha vem do arquivo planilha.go
+}
+
+// ExportarMovimentos grava o livro de movimentações de qualquer RepositorioEstoque em uma planilha
+func ExportarMovimentos(repo RepositorioEstoque, w io.Writer, formato FormatoPlanilha) error {
+	linhas := [][]string{{"id", "data", "produto_id", "tipo", "local", "destino", "lote", CampoQuantidade, "custo_unitario", "motivo", "estorno"}}
+
+	for _, movimento := range repo.ListarMovimentos() {
+		linhas = append(linhas, []string{
+			movimento.ID,
+			movimento.Data.Format(time.RFC3339),
+			movimento.ProdutoID,
+			string(movimento.Tipo),
+			movimento.Local,
+			movimento.Destino,
+			movimento.Lote,
+			strconv.Itoa(movimento.Quantidade),
+			strconv.FormatFloat(movimento.CustoUnitario, 'f', 2, 64),
+			movimento.Motivo,
+			movimento.Estorno,
+		})
+	}
+
+	return EscreverPlanilha(w, formato, linhas)
+}
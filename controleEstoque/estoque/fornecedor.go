@@ -0,0 +1,23 @@
+package estoque
+
+import "errors" // pacote para manipulação de erros
+
+// Erro para indicar que o fornecedor não está cadastrado
+var ErrFornecedorNaoEncontrado = errors.New("fornecedor não encontrado")
+
+// Fornecedor é quem vende matéria-prima ou produtos prontos para a empresa
+type Fornecedor struct {
+	ID               string
+	Nome             string
+	Contato          string `json:",omitempty"` // telefone ou e-mail para enviar os pedidos
+	PrazoEntregaDias int    `json:",omitempty"` // prazo médio entre o envio do pedido e a entrega
+}
+
+// NovoFornecedor cria um fornecedor com o ID gerado a partir do nome, como em NovoProduto
+func NovoFornecedor(nome, contato string) Fornecedor {
+	return Fornecedor{
+		ID:      gerarID(nome), // gerarID vem do arquivo produto.go
+		Nome:    nome,
+		Contato: contato,
+	}
+}
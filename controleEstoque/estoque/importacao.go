@@ -0,0 +1,352 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para formatação de strings
+	"io"      // pacote com a interface de leitura
+	"strconv" // pacote para converter quantidades e custos
+	"strings" // pacote para manipular textos
+)
+
+// Campos que podem ser lidos da planilha de importação
+const (
+	CampoSKU        = "sku"        // código do produto, usado para encontrar o produto já cadastrado
+	CampoNome       = "nome"       // obrigatório
+	CampoQuantidade = "quantidade" // saldo de abertura no local
+	CampoLocal      = "local"      // sem local, o saldo vai para o local padrão
+	CampoCusto      = "custo"      // custo unitário do saldo de abertura
+	CampoCategoria  = "categoria"  // categoria usada nas consultas (consulta.go)
+	CampoGTIN       = "gtin"       // código de barras EAN-13/GTIN, com o dígito verificador conferido (codigobarras.go)
+)
+
+// Erro para indicar que a planilha não tem uma coluna obrigatória
+var ErrColunaObrigatoria = errors.New("coluna obrigatória não encontrada")
+
+// Erro para indicar que a importação encontrou linhas inválidas e nada foi gravado
+var ErrImportacaoInvalida = errors.New("importação com linhas inválidas")
+
+// OpcoesImportacao configura a leitura da planilha
+type OpcoesImportacao struct {
+	Formato   FormatoPlanilha
+	Colunas   map[string]string // campo -> título da coluna na planilha (ex: "nome" -> "Descrição"); sem mapeamento, o título é o próprio campo
+	Simulacao bool              // dry-run: valida e mostra o resultado sem gravar nada
+}
+
+// ErroImportacao descreve um problema em uma linha da planilha
+type ErroImportacao struct {
+	Linha    int // número da linha na planilha (a linha 1 é a de títulos)
+	Campo    string
+	Mensagem string
+}
+
+func (e ErroImportacao) Error() string {
+	return fmt.Sprintf("linha %d, campo %s: %s", e.Linha, e.Campo, e.Mensagem)
+}
+
+// RelatorioImportacao resume o resultado da importação
+type RelatorioImportacao struct {
+	Linhas      int // linhas de dados lidas (sem contar os títulos)
+	Criados     int // produtos novos
+	Atualizados int // produtos que já existiam
+	Movimentos  int // movimentos de saldo gerados
+	Simulacao   bool
+	Erros       []ErroImportacao
+}
+
+// linhaImportacao guarda os valores já convertidos de uma linha da planilha
+type linhaImportacao struct {
+	numero     int
+	sku        string
+	gtin       string
+	nome       string
+	local      string
+	categoria  string
+	quantidade int
+	custo      float64
+}
+
+// Importar lê produtos e saldos de abertura de uma planilha e grava no estoque
+// Produtos são encontrados pelo SKU (ou pelo nome, se o SKU estiver vazio) e atualizados; os demais são criados
+// O saldo de cada linha passa a ser o saldo do local, e a diferença é registrada como movimento
+// A importação é tudo ou nada: se alguma linha for inválida, nada é gravado e os erros vêm no relatório;
+// produtos novos, alterados e movimentos vão juntos em um só GravarOperacao, e se outra operação mexeu
+// em algum produto no meio do caminho (ErrConflito), a importação é recalculada sobre o estoque atualizado
+func (s *ServicoEstoque) Importar(ctx context.Context, r io.Reader, opcoes OpcoesImportacao) (RelatorioImportacao, error) {
+	relatorio := RelatorioImportacao{Simulacao: opcoes.Simulacao}
+
+	planilha, err := LerPlanilha(r, opcoes.Formato) // LerPlanilha vem do arquivo planilha.go
+	if err != nil {
+		return relatorio, err
+	}
+	if len(planilha) == 0 {
+		return relatorio, fmt.Errorf("%w: %s", ErrColunaObrigatoria, nomeDaColuna(opcoes, CampoNome))
+	}
+
+	indices, err := indicesDasColunas(planilha[0], opcoes)
+	if err != nil {
+		return relatorio, err
+	}
+
+	linhas := lerLinhasImportacao(planilha[1:], indices, &relatorio)
+	if len(relatorio.Erros) > 0 {
+		return relatorio, ErrImportacaoInvalida
+	}
+
+	for tentativa := 0; tentativa < s.tentativas; tentativa++ {
+		if err := ctx.Err(); err != nil {
+			return relatorio, err
+		}
+
+		relatorio.Criados, relatorio.Atualizados, relatorio.Movimentos = 0, 0, 0
+		plano := s.planejarImportacao(linhas, &relatorio)
+		if len(relatorio.Erros) > 0 {
+			return relatorio, ErrImportacaoInvalida
+		}
+		relatorio.Criados = len(plano.novos)
+		relatorio.Atualizados = len(plano.alterados)
+		relatorio.Movimentos = len(plano.movimentos)
+		if opcoes.Simulacao {
+			return relatorio, nil // dry-run: nada é gravado
+		}
+		if err := s.conferirBloqueio(plano.movimentos); err != nil { // conferirBloqueio vem do arquivo contagem.go
+			return relatorio, err
+		}
+
+		err = s.repositorio.GravarOperacao(plano.novos, plano.alterados, plano.movimentos)
+		if err == nil {
+			s.auditar(ctx, OperacaoImportacao, plano.originais, plano.gravados(), plano.movimentos) // auditar vem do arquivo auditoria.go
+			return relatorio, nil
+		}
+		if !errors.Is(err, ErrConflito) {
+			return relatorio, err
+		}
+	}
+	return relatorio, err // esgotou as tentativas, devolve o último ErroConflito
+}
+
+// planoImportacao é o resultado de aplicar as linhas da planilha sobre uma cópia do estoque
+type planoImportacao struct {
+	novos      []Produto          // produtos que a planilha cria
+	alterados  []Produto          // produtos que já existiam, na versão lida
+	originais  map[string]Produto // estado antes da importação, para a auditoria
+	movimentos []Movimento
+}
+
+// gravados devolve os produtos como ficaram gravados: os alterados vão para a versão seguinte
+func (p planoImportacao) gravados() []Produto {
+	gravados := make([]Produto, 0, len(p.novos)+len(p.alterados))
+	for _, produto := range p.alterados {
+		produto.Versao++
+		gravados = append(gravados, produto)
+	}
+	return append(gravados, p.novos...)
+}
+
+// planejarImportacao aplica as linhas sobre uma cópia do estoque atual, sem gravar nada
+// Linhas que não podem ser aplicadas (GTIN repetido, saldo insuficiente...) entram nos erros do relatório
+func (s *ServicoEstoque) planejarImportacao(linhas []linhaImportacao, relatorio *RelatorioImportacao) planoImportacao {
+	existentes := map[string]bool{}
+	originais := map[string]Produto{}
+	porSKU := map[string]string{}  // sku -> ID do produto
+	porGTIN := map[string]string{} // código de barras (14 dígitos) -> ID do produto
+	produtos := map[string]*Produto{}
+	var ordem []string // ordem em que os produtos foram tocados, para gravar de forma previsível
+	for _, produto := range s.repositorio.Listar() {
+		if _, repetido := produtos[produto.ID]; repetido {
+			continue // estoques antigos podem ter o mesmo produto repetido; vale o primeiro, como em Atualizar
+		}
+		p := produto.clonar() // cópia: a simulação não pode alterar o repositório
+		produtos[p.ID] = &p
+		existentes[p.ID] = true
+		originais[p.ID] = produto
+		if p.SKU != "" {
+			porSKU[p.SKU] = p.ID
+		}
+		if p.GTIN != "" {
+			porGTIN[gtin14(p.GTIN)] = p.ID // gtin14 vem do arquivo codigobarras.go
+		}
+	}
+
+	tocados := map[string]bool{}
+	var movimentos []Movimento
+	agora := s.agora()
+	for _, linha := range linhas {
+		id, encontrado := porSKU[linha.sku]
+		if linha.sku == "" || !encontrado {
+			id = gerarID(linha.nome) // gerarID vem do arquivo produto.go
+		}
+
+		produto, existe := produtos[id]
+		if !existe {
+			novo := NovoProduto(linha.nome, 0)
+			novo.Locais = map[string]int{}
+			produto = &novo
+			produtos[id] = produto
+		}
+		produto.Nome = linha.nome
+		if linha.categoria != "" {
+			produto.Categoria = linha.categoria
+		}
+		if linha.sku != "" {
+			produto.SKU = linha.sku
+			porSKU[linha.sku] = id
+		}
+		if linha.gtin != "" {
+			if outro, usado := porGTIN[gtin14(linha.gtin)]; usado && outro != id {
+				relatorio.Erros = append(relatorio.Erros, ErroImportacao{Linha: linha.numero, Campo: CampoGTIN, Mensagem: fmt.Sprintf("%v: %s", ErrGTINDuplicado, produtos[outro].Nome)})
+				continue
+			}
+			produto.GTIN = linha.gtin
+			porGTIN[gtin14(linha.gtin)] = id
+		}
+		if !tocados[id] {
+			tocados[id] = true
+			ordem = append(ordem, id)
+		}
+
+		tipo := MovimentoAjuste
+		if !existentes[id] {
+			tipo = MovimentoEntrada // saldo de abertura de um produto novo
+		}
+
+		delta := linha.quantidade - produto.QuantidadeNoLocal(linha.local)
+		switch {
+		case delta > 0:
+			produto.AumentarQuantidadeNoLocal(linha.local, delta)
+		case delta < 0:
+			if err := produto.diminuirNoLocal(linha.local, -delta, agora); err != nil {
+				relatorio.Erros = append(relatorio.Erros, ErroImportacao{Linha: linha.numero, Campo: CampoQuantidade, Mensagem: err.Error()})
+				continue
+			}
+		default:
+			continue // saldo já está correto, nada a registrar
+		}
+
+		movimento := novoMovimento(id, tipo, linha.local, delta, agora)
+		movimento.Motivo = "importação de planilha"
+		if delta > 0 {
+			movimento.CustoUnitario = linha.custo
+		}
+		movimentos = append(movimentos, movimento)
+	}
+
+	plano := planoImportacao{originais: originais, movimentos: movimentos}
+	for _, id := range ordem {
+		if existentes[id] {
+			plano.alterados = append(plano.alterados, *produtos[id])
+		} else {
+			plano.novos = append(plano.novos, *produtos[id])
+		}
+	}
+	return plano
+}
+
+// indicesDasColunas encontra a posição de cada campo na linha de títulos
+func indicesDasColunas(titulos []string, opcoes OpcoesImportacao) (map[string]int, error) {
+	posicoes := map[string]int{}
+	for i, titulo := range titulos {
+		posicoes[normalizarTitulo(titulo)] = i
+	}
+
+	indices := map[string]int{}
+	for _, campo := range []string{CampoSKU, CampoNome, CampoQuantidade, CampoLocal, CampoCusto, CampoCategoria, CampoGTIN} {
+		if i, existe := posicoes[normalizarTitulo(nomeDaColuna(opcoes, campo))]; existe {
+			indices[campo] = i
+		}
+	}
+	for _, obrigatorio := range []string{CampoNome, CampoQuantidade} {
+		if _, existe := indices[obrigatorio]; !existe {
+			return nil, fmt.Errorf("%w: %s", ErrColunaObrigatoria, nomeDaColuna(opcoes, obrigatorio))
+		}
+	}
+	return indices, nil
+}
+
+// lerLinhasImportacao converte e valida as linhas de dados, acumulando os erros no relatório
+func lerLinhasImportacao(planilha [][]string, indices map[string]int, relatorio *RelatorioImportacao) []linhaImportacao {
+	var linhas []linhaImportacao
+	vistos := map[string]int{} // produto + local -> linha onde apareceu primeiro
+
+	for i, valores := range planilha {
+		numero := i + 2 // +1 pela linha de títulos e +1 porque planilhas começam na linha 1
+		celula := func(campo string) string {
+			indice, existe := indices[campo]
+			if !existe || indice >= len(valores) {
+				return ""
+			}
+			return strings.TrimSpace(valores[indice])
+		}
+		erro := func(campo, mensagem string) {
+			relatorio.Erros = append(relatorio.Erros, ErroImportacao{Linha: numero, Campo: campo, Mensagem: mensagem})
+		}
+
+		if strings.TrimSpace(strings.Join(valores, "")) == "" {
+			continue // linhas em branco são ignoradas
+		}
+		relatorio.Linhas++
+
+		linha := linhaImportacao{numero: numero, sku: celula(CampoSKU), nome: celula(CampoNome), local: celula(CampoLocal), categoria: celula(CampoCategoria)}
+		if linha.nome == "" {
+			erro(CampoNome, "nome vazio")
+		}
+		if linha.local == "" {
+			linha.local = LocalPadrao
+		}
+		if linha.gtin = NormalizarGTIN(celula(CampoGTIN)); linha.gtin != "" {
+			if err := ValidarGTIN(linha.gtin); err != nil {
+				erro(CampoGTIN, err.Error())
+			}
+		}
+
+		quantidade, err := strconv.Atoi(celula(CampoQuantidade))
+		if err != nil || quantidade < 0 {
+			erro(CampoQuantidade, fmt.Sprintf("quantidade inválida %q", celula(CampoQuantidade)))
+		}
+		linha.quantidade = quantidade
+
+		if texto := celula(CampoCusto); texto != "" {
+			custo, err := converterDecimal(texto)
+			if err != nil || custo < 0 {
+				erro(CampoCusto, fmt.Sprintf("custo inválido %q", texto))
+			}
+			linha.custo = custo
+		}
+
+		chave := linha.sku
+		if chave == "" {
+			chave = gerarID(linha.nome)
+		}
+		chave += "|" + linha.local
+		if anterior, repetida := vistos[chave]; repetida {
+			erro(CampoLocal, fmt.Sprintf("produto e local repetidos (já informados na linha %d)", anterior))
+		} else {
+			vistos[chave] = numero
+		}
+
+		linhas = append(linhas, linha)
+	}
+	return linhas
+}
+
+// nomeDaColuna retorna o título esperado na planilha para o campo
+func nomeDaColuna(opcoes OpcoesImportacao, campo string) string {
+	if titulo, existe := opcoes.Colunas[campo]; existe {
+		return titulo
+	}
+	return campo
+}
+
+// normalizarTitulo compara títulos sem diferenciar maiúsculas e espaços nas pontas
+func normalizarTitulo(titulo string) string {
+	return strings.ToLower(strings.TrimSpace(titulo))
+}
+
+// converterDecimal aceita números com vírgula ("12,50") ou ponto ("12.50") como separador decimal
+func converterDecimal(texto string) (float64, error) {
+	if strings.Contains(texto, ",") {
+		texto = strings.ReplaceAll(texto, ".", "")  // remove o separador de milhar: 1.234,50 -> 1234,50
+		texto = strings.ReplaceAll(texto, ",", ".") // troca a vírgula decimal: 1234,50 -> 1234.50
+	}
+	return strconv.ParseFloat(texto, 64)
+}
@@ -0,0 +1,237 @@
+package estoque
+
+import (
+	"archive/zip"   // pacote padrão para montar um xlsx com as abas fora de ordem
+	"bytes"         // pacote padrão para guardar a planilha exportada em memória
+	"context"       // pacote padrão para passar o ator das operações
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"path/filepath" // pacote padrão para montar o caminho dos arquivos temporários
+	"strings"       // pacote padrão para montar o CSV de entrada
+	"testing"       // pacote padrão do Go para testes
+)
+
+func TestImportarCSVComMapeamentoDeColunas(t *testing.T) {
+	repo := NovoRepositorioMemoria() // NovoRepositorioMemoria vem do arquivo memoria.go
+	servico := NovoServicoEstoque(repo)
+
+	viga := NovoProduto("viga", 10)
+	viga.SKU = "VG-01"
+	repo.Adicionar(viga)
+
+	// CSV no padrão do Excel em português: ponto e vírgula e vírgula decimal
+	csv := "Código;Descrição;Saldo;Depósito;Custo\n" +
+		"VG-01;Viga 3m;4;patio;\n" +
+		"CB-07;cobogo flor;30;loja;12,50\n"
+	opcoes := OpcoesImportacao{
+		Formato: FormatoCSV,
+		Colunas: map[string]string{CampoSKU: "Código", CampoNome: "Descrição", CampoQuantidade: "Saldo", CampoLocal: "Depósito", CampoCusto: "Custo"},
+	}
+
+	// dry-run: o relatório é calculado, mas nada é gravado
+	opcoes.Simulacao = true
+	relatorio, err := servico.Importar(context.Background(), strings.NewReader(csv), opcoes) // Importar vem do arquivo importacao.go
+	if err != nil {
+		t.Fatalf("Não esperava erro na simulação, mas recebi %v", err)
+	}
+	if relatorio.Criados != 1 || relatorio.Atualizados != 1 {
+		t.Errorf("Esperava 1 criado e 1 atualizado, mas o relatório foi %+v", relatorio)
+	}
+	if len(repo.Listar()) != 1 || repo.Listar()[0].Quantidade != 10 || len(repo.ListarMovimentos()) != 0 {
+		t.Fatalf("A simulação alterou o repositório: %+v", repo.Listar())
+	}
+
+	opcoes.Simulacao = false
+	if _, err := servico.Importar(context.Background(), strings.NewReader(csv), opcoes); err != nil {
+		t.Fatalf("Não esperava erro na importação, mas recebi %v", err)
+	}
+
+	produtos := repo.Listar()
+	if len(produtos) != 2 {
+		t.Fatalf("Esperava 2 produtos após a importação, mas encontrei %d", len(produtos))
+	}
+	if produtos[0].Nome != "Viga 3m" || produtos[0].Quantidade != 4 { // upsert pelo SKU
+		t.Errorf("Esperava a viga renomeada com saldo 4, mas encontrei %+v", produtos[0])
+	}
+	if produtos[1].SKU != "CB-07" || produtos[1].QuantidadeNoLocal(LocalLoja) != 30 {
+		t.Errorf("Esperava o cobogó com 30 unidades na loja, mas encontrei %+v", produtos[1])
+	}
+
+	movimentos := repo.ListarMovimentos()
+	if len(movimentos) != 2 || movimentos[0].Quantidade != -6 || movimentos[1].CustoUnitario != 12.5 {
+		t.Errorf("Movimentos de importação inesperados: %+v", movimentos)
+	}
+}
+
+func TestImportarRelatorioDeValidacao(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+
+	csv := "sku,nome,quantidade,local\n" +
+		"A1,areia,10,patio\n" +
+		"A2,,5,patio\n" + // nome vazio
+		"A3,brita,muito,patio\n" + // quantidade inválida
+		"A1,areia,3,patio\n" // produto e local repetidos
+
+	relatorio, err := servico.Importar(context.Background(), strings.NewReader(csv), OpcoesImportacao{Formato: FormatoCSV})
+	if !errors.Is(err, ErrImportacaoInvalida) {
+		t.Fatalf("Esperava ErrImportacaoInvalida, mas recebi %v", err)
+	}
+	if len(relatorio.Erros) != 3 {
+		t.Errorf("Esperava 3 erros no relatório, mas encontrei %v", relatorio.Erros)
+	}
+	if len(repo.Listar()) != 0 { // tudo ou nada
+		t.Errorf("Nenhum produto deveria ser gravado, mas encontrei %d", len(repo.Listar()))
+	}
+}
+
+func TestExportarEImportarXLSX(t *testing.T) {
+	origem := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(origem)
+
+	coluna := NovoProduto("coluna", 8)
+	coluna.SKU = "007" // zeros à esquerda precisam sobreviver à planilha
+	servico.CadastrarProduto(context.Background(), coluna)
+	servico.Transferir(context.Background(), coluna.ID, LocalPatio, LocalLoja, 3)
+
+	var planilha bytes.Buffer
+	if err := ExportarEstoque(origem, &planilha, FormatoXLSX); err != nil { // ExportarEstoque vem do arquivo exportacao.go
+		t.Fatalf("Não esperava erro ao exportar, mas recebi %v", err)
+	}
+
+	destino := NovoRepositorioMemoria()
+	if _, err := NovoServicoEstoque(destino).Importar(context.Background(), &planilha, OpcoesImportacao{Formato: FormatoXLSX}); err != nil {
+		t.Fatalf("Não esperava erro ao importar o xlsx exportado, mas recebi %v", err)
+	}
+
+	importado := destino.Listar()
+	if len(importado) != 1 || importado[0].SKU != "007" || importado[0].QuantidadeNoLocal(LocalPatio) != 5 || importado[0].QuantidadeNoLocal(LocalLoja) != 3 {
+		t.Errorf("O xlsx não preservou o estoque: %+v", importado)
+	}
+
+	var movimentos bytes.Buffer
+	if err := ExportarMovimentos(origem, &movimentos, FormatoCSV); err != nil {
+		t.Fatalf("Não esperava erro ao exportar movimentos, mas recebi %v", err)
+	}
+	if linhas := strings.Count(movimentos.String(), "\n"); linhas != 3 { // títulos + entrada + transferência
+		t.Errorf("Esperava 3 linhas no CSV de movimentos, mas encontrei %d:\n%s", linhas, movimentos.String())
+	}
+}
+
+func TestImportarXLSXLeAPrimeiraAbaDoWorkbook(t *testing.T) {
+	// aba "Produtos" arrastada para a frente no Excel: ela é a primeira, mas o arquivo dela é o sheet2.xml
+	arquivos := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Produtos" sheetId="2" r:id="rId2"/><sheet name="Instruções" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>preencha a outra aba</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="A1" t="inlineStr"><is><t>nome</t></is></c><c r="B1" t="inlineStr"><is><t>quantidade</t></is></c></row>` +
+			`<row r="2"><c r="A2" t="inlineStr"><is><t>telha</t></is></c><c r="B2"><v>12</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	var planilha bytes.Buffer
+	compactador := zip.NewWriter(&planilha)
+	for nome, conteudo := range arquivos {
+		f, _ := compactador.Create(nome)
+		f.Write([]byte(conteudo))
+	}
+	compactador.Close()
+
+	repo := NovoRepositorioMemoria()
+	if _, err := NovoServicoEstoque(repo).Importar(context.Background(), &planilha, OpcoesImportacao{Formato: FormatoXLSX}); err != nil {
+		t.Fatalf("Não esperava erro ao importar o xlsx, mas recebi %v", err)
+	}
+	if produtos := repo.Listar(); len(produtos) != 1 || produtos[0].Nome != "telha" || produtos[0].Quantidade != 12 {
+		t.Errorf("Esperava a telha da aba Produtos, mas encontrei %+v", produtos)
+	}
+}
+
+// repositorioComVendaNoMeio dispara uma operação concorrente antes da primeira gravação da importação
+type repositorioComVendaNoMeio struct {
+	RepositorioEstoque
+	vender func()
+}
+
+func (r *repositorioComVendaNoMeio) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	if r.vender != nil {
+		vender := r.vender
+		r.vender = nil
+		vender()
+	}
+	return r.RepositorioEstoque.GravarOperacao(novos, alterados, movimentos)
+}
+
+func TestImportarRefazComConflitoNoMeio(t *testing.T) {
+	repositorios := map[string]func() RepositorioEstoque{
+		"memoria": func() RepositorioEstoque { return NovoRepositorioMemoria() },
+		"arquivo": func() RepositorioEstoque {
+			return NovoRepositorioArquivo(filepath.Join(t.TempDir(), "estoque.json"))
+		},
+		"eventos": func() RepositorioEstoque { return NovoRepositorioEventos(t.TempDir()) },
+	}
+	csv := "sku,nome,quantidade,local\n" +
+		"VG-01,viga,4,patio\n" +
+		"CB-07,cobogo,30,loja\n"
+
+	for nome, abrir := range repositorios {
+		t.Run(nome, func(t *testing.T) {
+			repo := &repositorioComVendaNoMeio{RepositorioEstoque: abrir()}
+			servico := NovoServicoEstoque(repo)
+			viga := NovoProduto("viga", 10)
+			viga.SKU = "VG-01"
+			servico.CadastrarProduto(context.Background(), viga)
+
+			// a venda grava a viga depois que a importação leu o estoque e antes de ela gravar
+			repo.vender = func() {
+				if err := servico.VenderProduto(context.Background(), viga.ID, 3); err != nil {
+					t.Fatalf("Não esperava erro na venda, mas recebi %v", err)
+				}
+			}
+			relatorio, err := servico.Importar(context.Background(), strings.NewReader(csv), OpcoesImportacao{Formato: FormatoCSV})
+			if err != nil {
+				t.Fatalf("Não esperava erro na importação refeita, mas recebi %v", err)
+			}
+			if relatorio.Criados != 1 || relatorio.Atualizados != 1 || relatorio.Movimentos != 2 {
+				t.Errorf("Relatório inesperado: %+v", relatorio)
+			}
+
+			if produto, _ := servico.BuscarProduto(viga.ID); produto.Quantidade != 4 {
+				t.Errorf("Esperava 4 vigas, mas encontrei %d", produto.Quantidade)
+			}
+			if len(servico.ListarEstoque()) != 2 {
+				t.Errorf("Esperava 2 produtos, mas encontrei %+v", servico.ListarEstoque())
+			}
+			var ajustes []int
+			for _, movimento := range servico.ListarMovimentos() {
+				if movimento.Tipo == MovimentoAjuste {
+					ajustes = append(ajustes, movimento.Quantidade)
+				}
+			}
+			if len(ajustes) != 1 || ajustes[0] != -3 { // calculado sobre as 7 vigas que sobraram da venda
+				t.Errorf("Esperava um ajuste de -3 vigas, mas encontrei %v", ajustes)
+			}
+		})
+	}
+}
+
+func TestImportarComConflitoSemTentativasNaoGravaNada(t *testing.T) {
+	repo := &repositorioComVendaNoMeio{RepositorioEstoque: NovoRepositorioMemoria()}
+	servico := NovoServicoEstoque(repo)
+	servico.DefinirTentativas(1)
+	viga := NovoProduto("viga", 10)
+	viga.SKU = "VG-01"
+	servico.CadastrarProduto(context.Background(), viga)
+	repo.vender = func() { servico.VenderProduto(context.Background(), viga.ID, 3) }
+
+	csv := "sku,nome,quantidade\nVG-01,viga,4\nCB-07,cobogo,30\n"
+	if _, err := servico.Importar(context.Background(), strings.NewReader(csv), OpcoesImportacao{Formato: FormatoCSV}); !errors.Is(err, ErrConflito) {
+		t.Fatalf("Esperava ErrConflito, mas recebi %v", err)
+	}
+	if produtos := servico.ListarEstoque(); len(produtos) != 1 || produtos[0].Quantidade != 7 {
+		t.Errorf("A importação recusada não deveria gravar nada, mas encontrei %+v", produtos)
+	}
+	if movimentos := servico.ListarMovimentos(); len(movimentos) != 2 { // cadastro + venda
+		t.Errorf("Esperava só os movimentos do cadastro e da venda, mas encontrei %+v", movimentos)
+	}
+}
@@ -5,5 +5,11 @@ package estoque
 type RepositorioEstoque interface { 
 	Adicionar(produto Produto) // se conter esse método, pode ser usado como repositório
 	Atualizar(produto Produto) error // se conter esse método, pode ser usado como repositório
+	AtualizarVarios(produtos []Produto) error // grava vários produtos de uma vez: todos ou nenhum (ErrConflito se algum mudou)
+	GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error // grava uma operação inteira: produtos novos, alterados e os movimentos; tudo ou nada (ErrConflito se algum mudou ou já existe)
 	Listar() []Produto // se conter esse método, pode ser usado como repositório
+	Buscar(id string) (Produto, error) // busca um produto pelo ID sem percorrer a lista (ErrProdutoNaoEncontrado se não existir)
+	Consultar(consulta Consulta) (PaginaProdutos, error) // filtra, ordena e pagina os produtos (consulta.go)
+	RegistrarMovimento(movimento Movimento) // grava uma linha no livro de movimentações (movimento.go)
+	ListarMovimentos() []Movimento // devolve o livro de movimentações na ordem em que foi gravado
 }
\ No newline at end of file
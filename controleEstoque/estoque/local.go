@@ -0,0 +1,126 @@
+package estoque
+
+import (
+	"errors" // pacote para manipulação de erros
+	"sort"   // pacote para ordenar os locais de forma previsível
+	"time"   // pacote para conferir a cura dos lotes nas saídas
+)
+
+// Locais de armazenamento conhecidos pela fábrica
+const (
+	LocalPatio = "patio" // pátio da fábrica, onde as peças são produzidas e curadas
+	LocalLoja  = "loja"  // loja, onde os produtos ficam expostos para venda
+)
+
+// LocalPadrao é o local usado quando nenhum local é informado (ex: AumentarQuantidade)
+const LocalPadrao = LocalPatio
+
+// Erro para indicar que o local informado é inválido (vazio ou origem igual ao destino)
+var ErrLocalInvalido = errors.New("local inválido")
+
+// SaldoLocal representa a quantidade total guardada em um local
+type SaldoLocal struct {
+	Local      string
+	Quantidade int
+}
+
+// QuantidadeNoLocal retorna quantas unidades do produto estão guardadas no local informado
+func (p *Produto) QuantidadeNoLocal(local string) int {
+	p.normalizarLocais() // garante que produtos antigos (sem locais) tenham o saldo no local padrão
+	return p.Locais[local]
+}
+
+// AumentarQuantidadeNoLocal adiciona unidades ao produto em um local específico
+func (p *Produto) AumentarQuantidadeNoLocal(local string, valor int) error {
+	if local == "" { // o local precisa ser informado
+		return ErrLocalInvalido
+	}
+	if valor <= 0 { // valida se o valor é positivo
+		return ErrValorInvalido
+	}
+
+	p.normalizarLocais()
+	p.Locais[local] += valor // soma no local
+	p.Quantidade += valor    // mantém o total sempre igual à soma dos locais
+	return nil
+}
+
+// DiminuirQuantidadeNoLocal remove unidades do produto de um local específico
+// Unidades sem lote saem primeiro; depois os lotes já curados do local são consumidos do mais antigo para o mais novo
+// Como na venda, lotes que ainda não curaram não saem: sem saldo curado suficiente, retorna ErrEstoqueInsuficiente
+func (p *Produto) DiminuirQuantidadeNoLocal(local string, valor int) error {
+	return p.diminuirNoLocal(local, valor, time.Now())
+}
+
+// diminuirNoLocal é o DiminuirQuantidadeNoLocal com a data que decide se um lote já curou (o relógio do serviço)
+func (p *Produto) diminuirNoLocal(local string, valor int, agora time.Time) error {
+	curado := func(lote Lote) bool { return lote.Curado(agora) }
+	_, err := p.retirar(local, valor, curado, PoliticaFIFO) // retirar vem do arquivo lote.go
+	return err
+}
+
+// Transferir move unidades de um local para outro sem alterar o total do produto
+// Os lotes transferidos mantêm código e datas no destino
+// A operação é tudo ou nada: se a origem não tiver saldo suficiente, nada é alterado
+func (p *Produto) Transferir(origem, destino string, valor int) error {
+	if origem == "" || destino == "" || origem == destino {
+		return ErrLocalInvalido
+	}
+	partes, err := p.retirar(origem, valor, nil, PoliticaFIFO)
+	if err != nil {
+		return err // nada foi alterado, pois retirar valida antes de mudar o estado
+	}
+	p.AumentarQuantidadeNoLocal(destino, valor) // não falha: destino e valor já foram validados
+	p.receberLotes(destino, partes)             // receberLotes vem do arquivo lote.go
+	return nil
+}
+
+// clonar cria uma cópia independente do produto, para que o mapa de locais
+// e as listas de lotes e componentes não sejam compartilhados entre o repositório e quem o chamou
+func (p Produto) clonar() Produto {
+	if p.Locais != nil {
+		locais := make(map[string]int, len(p.Locais))
+		for local, quantidade := range p.Locais {
+			locais[local] = quantidade
+		}
+		p.Locais = locais
+	}
+	if p.Lotes != nil {
+		p.Lotes = append([]Lote(nil), p.Lotes...)
+	}
+	if p.Componentes != nil {
+		p.Componentes = append([]Componente(nil), p.Componentes...)
+	}
+	if p.Estornados != nil {
+		p.Estornados = append([]string(nil), p.Estornados...)
+	}
+	return p
+}
+
+// LocaisOrdenados retorna os locais do produto em ordem, começando pelo local padrão
+func (p *Produto) LocaisOrdenados() []string {
+	p.normalizarLocais()
+
+	locais := make([]string, 0, len(p.Locais))
+	for local := range p.Locais {
+		locais = append(locais, local)
+	}
+	sort.Slice(locais, func(i, j int) bool { // o local padrão vem primeiro, depois ordem alfabética
+		if locais[i] == LocalPadrao || locais[j] == LocalPadrao {
+			return locais[i] == LocalPadrao
+		}
+		return locais[i] < locais[j]
+	})
+	return locais
+}
+
+// normalizarLocais converte produtos antigos (gravados antes dos locais existirem)
+// colocando toda a quantidade no local padrão
+func (p *Produto) normalizarLocais() {
+	if p.Locais == nil {
+		p.Locais = map[string]int{}
+		if p.Quantidade > 0 {
+			p.Locais[LocalPadrao] = p.Quantidade
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package estoque
+
+import (
+	"errors" // pacote para manipulação de erros
+	"sort"   // pacote para ordenar os lotes pela política escolhida
+	"time"   // pacote para datas de produção e de cura
+)
+
+// Erro para indicar que o lote informado é inválido (sem código, sem quantidade ou datas trocadas)
+var ErrLoteInvalido = errors.New("lote inválido")
+
+// Erro para indicar que já existe um lote com o mesmo código no produto
+var ErrLoteDuplicado = errors.New("lote já cadastrado")
+
+// PoliticaLote define a ordem em que os lotes são consumidos nas saídas
+type PoliticaLote int
+
+const (
+	PoliticaFIFO PoliticaLote = iota // primeiro a ser produzido, primeiro a sair
+	PoliticaFEFO                     // primeiro a ficar pronto (curado), primeiro a sair
+)
+
+// Lote representa uma fornada de peças pré-moldadas (vigas, colunas, estacas...)
+// As peças só podem ser vendidas depois da data de cura
+type Lote struct {
+	Codigo       string
+	DataProducao time.Time
+	DataCura     time.Time // data a partir da qual o lote pode ser vendido
+	Quantidade   int
+	Local        string // local onde o lote está guardado
+}
+
+// SaldoLote é uma linha do relatório de estoque por lote
+type SaldoLote struct {
+	ProdutoID    string
+	Produto      string
+	Codigo       string
+	Local        string
+	DataProducao time.Time
+	DataCura     time.Time
+	Quantidade   int
+	Curado       bool // indica se o lote já pode ser vendido
+}
+
+// NovoLote cria um lote no local padrão, calculando a data de cura a partir dos dias de cura
+func NovoLote(codigo string, producao time.Time, diasCura, quantidade int) Lote {
+	return Lote{
+		Codigo:       codigo,
+		DataProducao: producao,
+		DataCura:     producao.AddDate(0, 0, diasCura),
+		Quantidade:   quantidade,
+		Local:        LocalPadrao,
+	}
+}
+
+// Curado indica se o lote já terminou a cura na data informada
+func (l Lote) Curado(agora time.Time) bool {
+	return !agora.Before(l.DataCura)
+}
+
+// AdicionarLote registra a entrada de um lote no produto, somando a quantidade no local do lote
+func (p *Produto) AdicionarLote(lote Lote) error {
+	if lote.Local == "" {
+		lote.Local = LocalPadrao // sem local informado, o lote vai para o local padrão
+	}
+	if lote.Codigo == "" || lote.Quantidade <= 0 || lote.DataCura.Before(lote.DataProducao) {
+		return ErrLoteInvalido
+	}
+	for _, existente := range p.Lotes {
+		if existente.Codigo == lote.Codigo {
+			return ErrLoteDuplicado
+		}
+	}
+
+	if err := p.AumentarQuantidadeNoLocal(lote.Local, lote.Quantidade); err != nil { // AumentarQuantidadeNoLocal vem do arquivo local.go
+		return err
+	}
+	p.Lotes = append(p.Lotes, lote)
+	return nil
+}
+
+// DisponivelParaVenda retorna quantas unidades podem ser vendidas no local na data informada
+// Local vazio considera todos os locais. Unidades sem lote são sempre consideradas disponíveis
+func (p *Produto) DisponivelParaVenda(local string, agora time.Time) int {
+	total := 0
+	for _, l := range p.locaisDaSaida(local) {
+		total += p.Locais[l] - p.quantidadeEmLotes(l)
+		for _, lote := range p.Lotes {
+			if lote.Local == l && lote.Curado(agora) {
+				total += lote.Quantidade
+			}
+		}
+	}
+	return total
+}
+
+// Vender retira unidades já curadas do produto, seguindo a política de lotes
+// Local vazio vende de todos os locais, começando pelo local padrão
+// Lotes que ainda não curaram são ignorados; se não houver saldo curado suficiente nada é alterado
+func (p *Produto) Vender(local string, valor int, agora time.Time, politica PoliticaLote) error {
+	if valor <= 0 {
+		return ErrValorInvalido
+	}
+	if p.DisponivelParaVenda(local, agora) < valor { // regra de negócio: lote em cura não pode ser vendido
+		return ErrEstoqueInsuficiente
+	}
+
+	curado := func(lote Lote) bool { return lote.Curado(agora) }
+	restante := valor
+	for _, l := range p.locaisDaSaida(local) {
+		retirar := min(restante, p.disponivelNoLocal(l, curado))
+		if retirar > 0 {
+			p.retirar(l, retirar, curado, politica) // não falha: o saldo já foi conferido
+			restante -= retirar
+		}
+	}
+	return nil
+}
+
+// locaisDaSaida retorna os locais de onde uma saída pode retirar unidades
+func (p *Produto) locaisDaSaida(local string) []string {
+	if local != "" {
+		return []string{local}
+	}
+	return p.LocaisOrdenados() // LocaisOrdenados vem do arquivo local.go
+}
+
+// quantidadeEmLotes soma as unidades controladas por lote em um local
+func (p *Produto) quantidadeEmLotes(local string) int {
+	total := 0
+	for _, lote := range p.Lotes {
+		if lote.Local == local {
+			total += lote.Quantidade
+		}
+	}
+	return total
+}
+
+// disponivelNoLocal soma as unidades sem lote e as dos lotes aceitos pelo filtro (filtro nil aceita todos)
+func (p *Produto) disponivelNoLocal(local string, filtro func(Lote) bool) int {
+	total := p.Locais[local] - p.quantidadeEmLotes(local)
+	for _, lote := range p.Lotes {
+		if lote.Local == local && (filtro == nil || filtro(lote)) {
+			total += lote.Quantidade
+		}
+	}
+	return total
+}
+
+// retirar remove unidades de um local, consumindo primeiro as unidades sem lote
+// e depois os lotes aceitos pelo filtro, na ordem da política
+// Retorna as partes dos lotes consumidos, para que uma transferência possa recriá-las no destino
+func (p *Produto) retirar(local string, valor int, filtro func(Lote) bool, politica PoliticaLote) ([]Lote, error) {
+	if local == "" {
+		return nil, ErrLocalInvalido
+	}
+	if valor <= 0 {
+		return nil, ErrValorInvalido
+	}
+
+	p.normalizarLocais() // normalizarLocais vem do arquivo local.go
+	if p.disponivelNoLocal(local, filtro) < valor {
+		return nil, ErrEstoqueInsuficiente
+	}
+
+	restante := valor - min(valor, p.Locais[local]-p.quantidadeEmLotes(local)) // unidades sem lote saem primeiro
+
+	var consumidos []Lote
+	for _, i := range p.ordemDosLotes(politica) {
+		lote := &p.Lotes[i]
+		if restante == 0 {
+			break
+		}
+		if lote.Local != local || (filtro != nil && !filtro(*lote)) {
+			continue
+		}
+		parte := min(restante, lote.Quantidade)
+		lote.Quantidade -= parte
+		restante -= parte
+
+		consumido := *lote
+		consumido.Quantidade = parte
+		consumidos = append(consumidos, consumido)
+	}
+	p.removerLotesVazios()
+
+	p.Locais[local] -= valor
+	if p.Locais[local] == 0 {
+		delete(p.Locais, local) // remove locais zerados para o JSON ficar limpo
+	}
+	p.Quantidade -= valor
+	return consumidos, nil
+}
+
+// receberLotes recoloca no local de destino as partes de lotes vindas de uma transferência
+func (p *Produto) receberLotes(destino string, partes []Lote) {
+	for _, parte := range partes {
+		juntou := false
+		for i := range p.Lotes { // se o lote já existe no destino, apenas soma a quantidade
+			if p.Lotes[i].Codigo == parte.Codigo && p.Lotes[i].Local == destino {
+				p.Lotes[i].Quantidade += parte.Quantidade
+				juntou = true
+				break
+			}
+		}
+		if !juntou {
+			parte.Local = destino
+			p.Lotes = append(p.Lotes, parte)
+		}
+	}
+}
+
+// ordemDosLotes retorna os índices dos lotes na ordem de consumo da política
+func (p *Produto) ordemDosLotes(politica PoliticaLote) []int {
+	indices := make([]int, len(p.Lotes))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		la, lb := p.Lotes[indices[a]], p.Lotes[indices[b]]
+		if politica == PoliticaFEFO {
+			return la.DataCura.Before(lb.DataCura)
+		}
+		return la.DataProducao.Before(lb.DataProducao)
+	})
+	return indices
+}
+
+// removerLotesVazios apaga os lotes que já foram totalmente consumidos
+func (p *Produto) removerLotesVazios() {
+	lotes := p.Lotes[:0]
+	for _, lote := range p.Lotes {
+		if lote.Quantidade > 0 {
+			lotes = append(lotes, lote)
+		}
+	}
+	if len(lotes) == 0 {
+		lotes = nil // sem lotes o campo some do JSON
+	}
+	p.Lotes = lotes
+}
@@ -1,13 +1,14 @@
 package estoque
 
 import (
-	"errors"
 	"sync"
 )
 
 // RepositorioMemoria implementa o RepositorioEstoque armazenando produtos em memória
 type RepositorioMemoria struct {
 	produtos []Produto
+	indice map[string]int // ID -> posição em produtos, para buscar sem percorrer a lista
+	movimentos []Movimento // livro de movimentações do estoque
 	mu sync.Mutex // mutex para garantir acesso seguro ao repositório em caso de concorrência
 }
 
@@ -15,6 +16,7 @@ type RepositorioMemoria struct {
 func NovoRepositorioMemoria() *RepositorioMemoria {
 	return &RepositorioMemoria {
 		produtos: []Produto{},
+		indice: map[string]int{},
 	}
 }
 
@@ -22,22 +24,88 @@ func NovoRepositorioMemoria() *RepositorioMemoria {
 func (r *RepositorioMemoria) Adicionar(produto Produto) {
 	r.mu.Lock() // bloqueia o mutex para garantir que apenas uma goroutine possa acessar o repositório ao mesmo tempo
 	defer r.mu.Unlock() // desbloqueia o mutex após a função ser executada, garantindo que outros goroutines possam acessar o repositório
-	r.produtos = append(r.produtos, produto) // adiciona o produto à lista de produtos do repositório
+	if _, existe := r.indice[produto.ID]; !existe { // com IDs repetidos, o índice aponta para o primeiro, como em Atualizar
+		r.indice[produto.ID] = len(r.produtos)
+	}
+	r.produtos = append(r.produtos, produto.clonar()) // adiciona o produto à lista de produtos do repositório
 }
 
 // Atualizar modifica um produto existente no estoque em memória.
-func (r *RepositorioMemoria) Atualizar(produto Produto) error { // percorre a lista de produtos para encontrar o produto com o ID correspondente
+// O produto precisa estar na mesma versão que está gravada, senão retorna ErrConflito.
+func (r *RepositorioMemoria) Atualizar(produto Produto) error { // usa o índice para encontrar o produto com o ID correspondente
 	r.mu.Lock() // bloqueia o mutex para garantir que apenas uma goroutine possa acessar o repositório ao mesmo tempo
 	defer r.mu.Unlock() // desbloqueia o mutex após a função ser executada, garantindo que outros goroutines possam acessar o repositório
 
-	for i:= range r.produtos { // itera sobre os produtos do repositório
-		if r.produtos[i].ID == produto.ID { // se encontrar o produto com o ID correspondente
-			// atualiza o produto no repositório
-			r.produtos[i] = produto
-			return nil // retorna nil se a atualização for bem-sucedida
-		}
+	i, existe := r.indice[produto.ID] // busca a posição do produto no índice
+	if !existe {
+		return ErrProdutoNaoEncontrado // retorna erro se o produto não for encontrado
+	}
+	novo, err := compararEGravar(r.produtos[i], produto) // só grava se ninguém alterou o produto desde a leitura (concorrencia.go)
+	if err != nil {
+		return err // ErroConflito: outra goroutine gravou antes
+	}
+	r.produtos[i] = novo // compararEGravar já devolve uma cópia, então o chamador não altera o repositório por fora
+	return nil // retorna nil se a atualização for bem-sucedida
+}
+
+// AtualizarVarios grava vários produtos de uma vez: confere todos antes e, se algum não existir
+// ou estiver em outra versão, não grava nenhum.
+func (r *RepositorioMemoria) AtualizarVarios(produtos []Produto) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	novos, err := compararEGravarVarios(r.produtos, r.indice, produtos) // compararEGravarVarios vem do arquivo concorrencia.go
+	if err != nil {
+		return err
 	}
-	return errors.New("produto não encontrado") // retorna erro se o produto não for encontrado
+	for i, novo := range novos {
+		r.produtos[i] = novo
+	}
+	return nil
+}
+
+// GravarOperacao grava uma operação inteira de uma vez: inclui os produtos novos, atualiza os alterados
+// e registra os movimentos; se algum produto não conferir, nada é gravado.
+func (r *RepositorioMemoria) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	atualizados, err := compararEGravarVarios(r.produtos, r.indice, alterados) // compararEGravarVarios vem do arquivo concorrencia.go
+	if err != nil {
+		return err
+	}
+	incluidos, err := compararEIncluir(r.produtos, r.indice, novos)
+	if err != nil {
+		return err
+	}
+	for i, novo := range atualizados {
+		r.produtos[i] = novo
+	}
+	for _, novo := range incluidos {
+		r.indice[novo.ID] = len(r.produtos)
+		r.produtos = append(r.produtos, novo)
+	}
+	r.movimentos = append(r.movimentos, movimentos...)
+	return nil
+}
+
+// Buscar devolve o produto com o ID informado usando o índice, sem percorrer a lista.
+func (r *RepositorioMemoria) Buscar(id string) (Produto, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, existe := r.indice[id]
+	if !existe {
+		return Produto{}, ErrProdutoNaoEncontrado
+	}
+	return r.produtos[i].clonar(), nil
+}
+
+// Consultar filtra, ordena e pagina os produtos em memória (aplicarConsulta vem do arquivo consulta.go).
+func (r *RepositorioMemoria) Consultar(consulta Consulta) (PaginaProdutos, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return aplicarConsulta(r.produtos, consulta)
 }
 // Listar devolve todos os produtos armazenados no estoque em memória.
 func (r *RepositorioMemoria) Listar() []Produto {
@@ -45,9 +113,23 @@ func (r *RepositorioMemoria) Listar() []Produto {
 	defer r.mu.Unlock() // desbloqueia o mutex após a função ser executada, garantindo que outros goroutines possam acessar o repositório
 
 	copia := make([]Produto, len(r.produtos)) // cria uma cópia da lista de produtos para evitar que o chamador modifique diretamente a lista interna do repositório
-	copy(copia, r.produtos) // copia os produtos para a nova lista
+	for i, produto := range r.produtos { // copia os produtos para a nova lista
+		copia[i] = produto.clonar() // clonar vem do arquivo local.go e também copia locais e lotes
+	}
 
 	return copia // retorna a cópia da lista de produtos
 }
 
+// RegistrarMovimento grava um movimento no livro de movimentações em memória.
+func (r *RepositorioMemoria) RegistrarMovimento(movimento Movimento) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.movimentos = append(r.movimentos, movimento)
+}
 
+// ListarMovimentos devolve uma cópia do livro de movimentações em memória.
+func (r *RepositorioMemoria) ListarMovimentos() []Movimento {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Movimento(nil), r.movimentos...) // cópia para o chamador não alterar o histórico
+}
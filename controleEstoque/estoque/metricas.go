@@ -0,0 +1,214 @@
+package estoque
+
+import (
+	"context" // pacote para reconhecer operações canceladas
+	"errors"  // pacote para classificar os erros
+	"fmt"     // pacote para escrever as métricas no formato texto do Prometheus
+	"io"      // pacote com a interface de escrita
+	"sort"    // pacote para escrever as séries sempre na mesma ordem
+	"strings" // pacote para escapar os valores dos rótulos
+	"sync"    // pacote para proteger os contadores
+	"time"    // pacote para medir a duração das operações
+)
+
+// Tipos de erro usados no rótulo "tipo" das métricas de erro
+const (
+	ErroTipoEstoqueInsuficiente = "estoque_insuficiente"
+	ErroTipoNaoEncontrado       = "nao_encontrado"
+	ErroTipoConflito            = "conflito"
+	ErroTipoValorInvalido       = "valor_invalido"
+	ErroTipoContagemAberta      = "contagem_aberta"
+	ErroTipoCancelado           = "cancelado"
+	ErroTipoOutro               = "outro"
+)
+
+// limitesLatencia são os limites (em segundos) dos baldes do histograma de duração
+var limitesLatencia = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// ClassificarErro devolve o tipo do erro para as métricas e os logs ("" quando não há erro)
+func ClassificarErro(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrEstoqueInsuficiente):
+		return ErroTipoEstoqueInsuficiente
+	case errors.Is(err, ErrProdutoNaoEncontrado), errors.Is(err, ErrMovimentoNaoEncontrado),
+		errors.Is(err, ErrPedidoNaoEncontrado), errors.Is(err, ErrFornecedorNaoEncontrado):
+		return ErroTipoNaoEncontrado
+	case errors.Is(err, ErrConflito):
+		return ErroTipoConflito
+	case errors.Is(err, ErrValorInvalido), errors.Is(err, ErrLocalInvalido), errors.Is(err, ErrLoteInvalido),
+		errors.Is(err, ErrGTINInvalido), errors.Is(err, ErrEstruturaInvalida):
+		return ErroTipoValorInvalido
+	case errors.Is(err, ErrContagemAberta):
+		return ErroTipoContagemAberta
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErroTipoCancelado
+	}
+	return ErroTipoOutro
+}
+
+// serieOperacao identifica uma operação nas métricas: a camada (repositorio ou servico) e o nome do método
+type serieOperacao struct {
+	camada   string
+	operacao string
+}
+
+// serieErro identifica os erros de uma operação por tipo
+type serieErro struct {
+	serieOperacao
+	tipo string
+}
+
+// histograma acumula as durações de uma operação nos baldes de limitesLatencia
+type histograma struct {
+	baldes []int // baldes[i] conta as durações <= limitesLatencia[i]
+	soma   float64
+	total  int
+}
+
+// Metricas guarda os contadores de operações e erros e a latência de cada operação
+// e escreve tudo no formato texto do Prometheus (EscreverPrometheus), sem bibliotecas externas
+type Metricas struct {
+	operacoes map[serieOperacao]int
+	erros     map[serieErro]int
+	duracoes  map[serieOperacao]*histograma
+	estoque   RepositorioEstoque // de onde vem o saldo atual de cada produto (AcompanharEstoque)
+	mu        sync.Mutex
+}
+
+// NovasMetricas cria um conjunto de métricas vazio
+func NovasMetricas() *Metricas {
+	return &Metricas{
+		operacoes: map[serieOperacao]int{},
+		erros:     map[serieErro]int{},
+		duracoes:  map[serieOperacao]*histograma{},
+	}
+}
+
+// AcompanharEstoque faz as métricas publicarem o saldo atual de cada produto do repositório
+// Use o repositório original, não o RepositorioObservado, para a leitura das métricas não contar como operação
+func (m *Metricas) AcompanharEstoque(repo RepositorioEstoque) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.estoque = repo
+}
+
+// Registrar conta uma operação, o erro (se houver) e a sua duração
+func (m *Metricas) Registrar(camada, operacao string, duracao time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	serie := serieOperacao{camada: camada, operacao: operacao}
+	m.operacoes[serie]++
+	if tipo := ClassificarErro(err); tipo != "" {
+		m.erros[serieErro{serieOperacao: serie, tipo: tipo}]++
+	}
+
+	h, existe := m.duracoes[serie]
+	if !existe {
+		h = &histograma{baldes: make([]int, len(limitesLatencia))}
+		m.duracoes[serie] = h
+	}
+	segundos := duracao.Seconds()
+	for i, limite := range limitesLatencia {
+		if segundos <= limite {
+			h.baldes[i]++
+		}
+	}
+	h.soma += segundos
+	h.total++
+}
+
+// Operacoes retorna quantas vezes a operação foi registrada
+func (m *Metricas) Operacoes(camada, operacao string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.operacoes[serieOperacao{camada: camada, operacao: operacao}]
+}
+
+// Erros retorna quantos erros do tipo a operação teve
+func (m *Metricas) Erros(camada, operacao, tipo string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.erros[serieErro{serieOperacao: serieOperacao{camada: camada, operacao: operacao}, tipo: tipo}]
+}
+
+// EscreverPrometheus escreve as métricas no formato texto do Prometheus (versão 0.0.4), pronto para o endpoint /metrics
+func (m *Metricas) EscreverPrometheus(w io.Writer) error {
+	m.mu.Lock()
+	var texto strings.Builder
+
+	texto.WriteString("# HELP estoque_operacoes_total Operações executadas por camada.\n# TYPE estoque_operacoes_total counter\n")
+	for _, serie := range seriesOrdenadas(m.operacoes) {
+		fmt.Fprintf(&texto, "estoque_operacoes_total{camada=%q,operacao=%q} %d\n", serie.camada, serie.operacao, m.operacoes[serie])
+	}
+
+	texto.WriteString("# HELP estoque_erros_total Operações que terminaram com erro, por tipo de erro.\n# TYPE estoque_erros_total counter\n")
+	erros := make([]serieErro, 0, len(m.erros))
+	for serie := range m.erros {
+		erros = append(erros, serie)
+	}
+	sort.Slice(erros, func(i, j int) bool {
+		if erros[i].serieOperacao != erros[j].serieOperacao {
+			return menorSerie(erros[i].serieOperacao, erros[j].serieOperacao)
+		}
+		return erros[i].tipo < erros[j].tipo
+	})
+	for _, serie := range erros {
+		fmt.Fprintf(&texto, "estoque_erros_total{camada=%q,operacao=%q,tipo=%q} %d\n", serie.camada, serie.operacao, serie.tipo, m.erros[serie])
+	}
+
+	texto.WriteString("# HELP estoque_operacao_duracao_segundos Duração das operações.\n# TYPE estoque_operacao_duracao_segundos histogram\n")
+	for _, serie := range seriesOrdenadas(m.duracoes) {
+		h := m.duracoes[serie]
+		rotulos := fmt.Sprintf("camada=%q,operacao=%q", serie.camada, serie.operacao)
+		for i, limite := range limitesLatencia {
+			fmt.Fprintf(&texto, "estoque_operacao_duracao_segundos_bucket{%s,le=\"%g\"} %d\n", rotulos, limite, h.baldes[i])
+		}
+		fmt.Fprintf(&texto, "estoque_operacao_duracao_segundos_bucket{%s,le=\"+Inf\"} %d\n", rotulos, h.total)
+		fmt.Fprintf(&texto, "estoque_operacao_duracao_segundos_sum{%s} %g\n", rotulos, h.soma)
+		fmt.Fprintf(&texto, "estoque_operacao_duracao_segundos_count{%s} %d\n", rotulos, h.total)
+	}
+	estoque := m.estoque
+	m.mu.Unlock() // o repositório é lido fora do mutex das métricas
+
+	if estoque != nil {
+		texto.WriteString("# HELP estoque_quantidade Saldo atual do produto somando todos os locais.\n# TYPE estoque_quantidade gauge\n")
+		vistos := map[string]bool{}
+		for _, produto := range estoque.Listar() {
+			if vistos[produto.ID] {
+				continue // estoques antigos podem ter o mesmo produto repetido; vale o primeiro
+			}
+			vistos[produto.ID] = true
+			fmt.Fprintf(&texto, "estoque_quantidade{produto_id=\"%s\",produto=\"%s\"} %d\n", escaparRotulo(produto.ID), escaparRotulo(produto.Nome), produto.Quantidade)
+		}
+	}
+
+	_, err := io.WriteString(w, texto.String())
+	return err
+}
+
+// seriesOrdenadas devolve as séries do mapa em ordem de camada e operação
+func seriesOrdenadas[V any](mapa map[serieOperacao]V) []serieOperacao {
+	series := make([]serieOperacao, 0, len(mapa))
+	for serie := range mapa {
+		series = append(series, serie)
+	}
+	sort.Slice(series, func(i, j int) bool { return menorSerie(series[i], series[j]) })
+	return series
+}
+
+// menorSerie ordena as séries por camada e depois por operação
+func menorSerie(a, b serieOperacao) bool {
+	if a.camada != b.camada {
+		return a.camada < b.camada
+	}
+	return a.operacao < b.operacao
+}
+
+// escaparRotulo escapa barra invertida, aspas e quebra de linha, como pede o formato do Prometheus
+// (o formato só aceita os escapes \\, \" e \n; o %q do Go geraria outros, como \t ou \x.., para caracteres de controle)
+func escaparRotulo(valor string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(valor)
+}
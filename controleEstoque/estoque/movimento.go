@@ -0,0 +1,79 @@
+package estoque
+
+import (
+	"crypto/rand"  // pacote para gerar bytes aleatórios para os IDs dos movimentos
+	"encoding/hex" // pacote para codificar em hexadecimal
+	"time"         // pacote para registrar a data de cada movimento
+)
+
+// TipoMovimento classifica cada linha do livro de movimentações do estoque
+type TipoMovimento string
+
+const (
+	MovimentoEntrada       TipoMovimento = "entrada"       // cadastro, produção ou recebimento de mercadoria
+	MovimentoSaida         TipoMovimento = "saida"         // venda
+	MovimentoConsumo       TipoMovimento = "consumo"       // matéria-prima usada em uma ordem de produção (não conta como venda)
+	MovimentoTransferencia TipoMovimento = "transferencia" // mudança de local sem alterar o total
+	MovimentoAjuste        TipoMovimento = "ajuste"        // correção de saldo (importação, inventário...)
+)
+
+// Movimento é um registro imutável de uma alteração de estoque
+// Quantidade é positiva quando o saldo do local aumenta e negativa quando diminui
+// Nas transferências a quantidade é positiva e sai de Local para Destino
+type Movimento struct {
+	ID            string
+	ProdutoID     string
+	Tipo          TipoMovimento
+	Quantidade    int
+	Local         string
+	Destino       string  `json:",omitempty"`
+	Lote          string  `json:",omitempty"`
+	CustoUnitario float64 `json:",omitempty"` // custo de cada unidade nas entradas
+	Motivo        string  `json:",omitempty"`
+	Estorno       string  `json:",omitempty"` // ID do movimento desfeito por este estorno (estorno.go)
+	Data          time.Time
+}
+
+// novoMovimento cria um movimento com ID único e a data informada
+func novoMovimento(produtoID string, tipo TipoMovimento, local string, quantidade int, data time.Time) Movimento {
+	return Movimento{
+		ID:         gerarIDMovimento(),
+		ProdutoID:  produtoID,
+		Tipo:       tipo,
+		Quantidade: quantidade,
+		Local:      local,
+		Data:       data,
+	}
+}
+
+// gerarIDMovimento gera um ID aleatório de 16 caracteres hexadecimais
+// Diferente do ID do produto, dois movimentos iguais precisam ter IDs diferentes
+func gerarIDMovimento() string {
+	b := make([]byte, 8)
+	rand.Read(b) // crypto/rand não falha nas plataformas suportadas
+	return hex.EncodeToString(b)
+}
+
+// movimentosDaDiferenca compara o produto antes e depois de uma operação
+// e gera um movimento para cada local cujo saldo mudou
+func movimentosDaDiferenca(antes, depois Produto, tipo TipoMovimento, data time.Time) []Movimento {
+	var movimentos []Movimento
+	for _, local := range depois.unirLocais(antes) {
+		delta := depois.QuantidadeNoLocal(local) - antes.QuantidadeNoLocal(local)
+		if delta != 0 {
+			movimentos = append(movimentos, novoMovimento(depois.ID, tipo, local, delta, data))
+		}
+	}
+	return movimentos
+}
+
+// unirLocais retorna os locais presentes em qualquer um dos dois produtos, em ordem
+func (p *Produto) unirLocais(outro Produto) []string {
+	locais := p.LocaisOrdenados() // LocaisOrdenados vem do arquivo local.go
+	for _, local := range outro.LocaisOrdenados() {
+		if _, existe := p.Locais[local]; !existe {
+			locais = append(locais, local)
+		}
+	}
+	return locais
+}
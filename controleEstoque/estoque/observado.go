@@ -0,0 +1,253 @@
+package estoque
+
+import (
+	"context"  // pacote para o ator e o cancelamento das operações
+	"io"       // pacote com a interface de leitura da importação
+	"log/slog" // pacote de logs estruturados da biblioteca padrão
+	"time"     // pacote para medir a duração das operações
+)
+
+// Camadas usadas no rótulo "camada" das métricas e no atributo "camada" dos logs
+const (
+	CamadaRepositorio = "repositorio"
+	CamadaServico     = "servico"
+)
+
+// observar registra a operação nas métricas e escreve o log estruturado com a duração e o erro
+// Sucesso vai em Debug no repositório e em Info no serviço; erros de regra de negócio em Warn e os demais em Error
+func observar(logger *slog.Logger, metricas *Metricas, camada, operacao string, inicio time.Time, err error, atributos ...any) {
+	duracao := time.Since(inicio)
+	if metricas != nil {
+		metricas.Registrar(camada, operacao, duracao, err)
+	}
+	if logger == nil {
+		return
+	}
+
+	atributos = append(atributos, slog.String("camada", camada), slog.String("operacao", operacao), slog.Duration("duracao", duracao))
+	nivel := slog.LevelInfo
+	if camada == CamadaRepositorio {
+		nivel = slog.LevelDebug
+	}
+	if tipo := ClassificarErro(err); tipo != "" {
+		atributos = append(atributos, slog.String("erro", err.Error()), slog.String("tipo_erro", tipo))
+		nivel = slog.LevelWarn
+		if tipo == ErroTipoOutro {
+			nivel = slog.LevelError
+		}
+	}
+	logger.Log(context.Background(), nivel, "operação de estoque", atributos...)
+}
+
+// RepositorioObservado é um decorador de RepositorioEstoque: repassa cada chamada ao repositório original
+// e registra logs e métricas de cada uma
+type RepositorioObservado struct {
+	repositorio RepositorioEstoque
+	logger      *slog.Logger
+	metricas    *Metricas
+}
+
+// NovoRepositorioObservado envolve o repositório; logger ou metricas nil desligam a parte correspondente
+func NovoRepositorioObservado(repo RepositorioEstoque, logger *slog.Logger, metricas *Metricas) *RepositorioObservado {
+	return &RepositorioObservado{repositorio: repo, logger: logger, metricas: metricas}
+}
+
+func (r *RepositorioObservado) Adicionar(produto Produto) {
+	inicio := time.Now()
+	r.repositorio.Adicionar(produto)
+	observar(r.logger, r.metricas, CamadaRepositorio, "adicionar", inicio, nil, slog.String("produto_id", produto.ID))
+}
+
+func (r *RepositorioObservado) Atualizar(produto Produto) error {
+	inicio := time.Now()
+	err := r.repositorio.Atualizar(produto)
+	observar(r.logger, r.metricas, CamadaRepositorio, "atualizar", inicio, err, slog.String("produto_id", produto.ID), slog.Int("versao", produto.Versao))
+	return err
+}
+
+func (r *RepositorioObservado) AtualizarVarios(produtos []Produto) error {
+	inicio := time.Now()
+	err := r.repositorio.AtualizarVarios(produtos)
+	observar(r.logger, r.metricas, CamadaRepositorio, "atualizar_varios", inicio, err, slog.Int("produtos", len(produtos)))
+	return err
+}
+
+func (r *RepositorioObservado) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	inicio := time.Now()
+	err := r.repositorio.GravarOperacao(novos, alterados, movimentos)
+	observar(r.logger, r.metricas, CamadaRepositorio, "gravar_operacao", inicio, err,
+		slog.Int("novos", len(novos)), slog.Int("alterados", len(alterados)), slog.Int("movimentos", len(movimentos)))
+	return err
+}
+
+func (r *RepositorioObservado) Buscar(id string) (Produto, error) {
+	inicio := time.Now()
+	produto, err := r.repositorio.Buscar(id)
+	observar(r.logger, r.metricas, CamadaRepositorio, "buscar", inicio, err, slog.String("produto_id", id))
+	return produto, err
+}
+
+func (r *RepositorioObservado) Listar() []Produto {
+	inicio := time.Now()
+	produtos := r.repositorio.Listar()
+	observar(r.logger, r.metricas, CamadaRepositorio, "listar", inicio, nil, slog.Int("produtos", len(produtos)))
+	return produtos
+}
+
+func (r *RepositorioObservado) Consultar(consulta Consulta) (PaginaProdutos, error) {
+	inicio := time.Now()
+	pagina, err := r.repositorio.Consultar(consulta)
+	observar(r.logger, r.metricas, CamadaRepositorio, "consultar", inicio, err, slog.Int("produtos", len(pagina.Produtos)))
+	return pagina, err
+}
+
+func (r *RepositorioObservado) RegistrarMovimento(movimento Movimento) {
+	inicio := time.Now()
+	r.repositorio.RegistrarMovimento(movimento)
+	observar(r.logger, r.metricas, CamadaRepositorio, "registrar_movimento", inicio, nil,
+		slog.String("produto_id", movimento.ProdutoID), slog.String("tipo", string(movimento.Tipo)), slog.Int("quantidade", movimento.Quantidade))
+}
+
+func (r *RepositorioObservado) ListarMovimentos() []Movimento {
+	inicio := time.Now()
+	movimentos := r.repositorio.ListarMovimentos()
+	observar(r.logger, r.metricas, CamadaRepositorio, "listar_movimentos", inicio, nil, slog.Int("movimentos", len(movimentos)))
+	return movimentos
+}
+
+// EstoqueEm repassa a consulta por data quando o repositório original guarda eventos (eventos.go)
+// Assim o decorador não esconde o histórico do ServicoEstoque.EstoqueEm
+func (r *RepositorioObservado) EstoqueEm(data time.Time) (RepositorioEstoque, error) {
+	historico, ok := r.repositorio.(RepositorioHistorico)
+	if !ok {
+		return nil, ErrSemHistorico
+	}
+	inicio := time.Now()
+	passado, err := historico.EstoqueEm(data)
+	observar(r.logger, r.metricas, CamadaRepositorio, "estoque_em", inicio, err, slog.Time("data", data))
+	return passado, err
+}
+
+// ServicoObservado é um decorador do ServicoEstoque: as operações que alteram o estoque e as buscas
+// passam a escrever logs estruturados (com o usuário do contexto) e métricas; os demais métodos vêm do serviço original
+type ServicoObservado struct {
+	*ServicoEstoque
+	logger   *slog.Logger
+	metricas *Metricas
+}
+
+// NovoServicoObservado envolve o serviço; logger ou metricas nil desligam a parte correspondente
+func NovoServicoObservado(servico *ServicoEstoque, logger *slog.Logger, metricas *Metricas) *ServicoObservado {
+	return &ServicoObservado{ServicoEstoque: servico, logger: logger, metricas: metricas}
+}
+
+// observar registra a operação do serviço com o usuário do contexto
+func (s *ServicoObservado) observar(ctx context.Context, operacao string, inicio time.Time, err error, atributos ...any) {
+	atributos = append(atributos, slog.String("usuario", AtorDoContexto(ctx).Usuario))
+	observar(s.logger, s.metricas, CamadaServico, operacao, inicio, err, atributos...)
+}
+
+func (s *ServicoObservado) CadastrarProduto(ctx context.Context, produto Produto) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.CadastrarProduto(ctx, produto)
+	s.observar(ctx, "cadastrar_produto", inicio, err, slog.String("produto_id", produto.ID), slog.Int("quantidade", produto.Quantidade))
+	return err
+}
+
+func (s *ServicoObservado) BuscarProduto(id string) (Produto, error) {
+	inicio := time.Now()
+	produto, err := s.ServicoEstoque.BuscarProduto(id)
+	s.observar(context.Background(), "buscar_produto", inicio, err, slog.String("produto_id", id))
+	return produto, err
+}
+
+func (s *ServicoObservado) ConsultarEstoque(consulta Consulta) (PaginaProdutos, error) {
+	inicio := time.Now()
+	pagina, err := s.ServicoEstoque.ConsultarEstoque(consulta)
+	s.observar(context.Background(), "consultar_estoque", inicio, err, slog.Int("produtos", len(pagina.Produtos)))
+	return pagina, err
+}
+
+func (s *ServicoObservado) VenderProduto(ctx context.Context, id string, quantidade int) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.VenderProduto(ctx, id, quantidade)
+	s.observar(ctx, "vender", inicio, err, slog.String("produto_id", id), slog.Int("quantidade", quantidade))
+	return err
+}
+
+func (s *ServicoObservado) VenderProdutoNoLocal(ctx context.Context, id, local string, quantidade int) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.VenderProdutoNoLocal(ctx, id, local, quantidade)
+	s.observar(ctx, "vender", inicio, err, slog.String("produto_id", id), slog.String("local", local), slog.Int("quantidade", quantidade))
+	return err
+}
+
+func (s *ServicoObservado) VenderPorGTIN(ctx context.Context, gtin, local string, quantidade int) (Produto, error) {
+	inicio := time.Now()
+	produto, err := s.ServicoEstoque.VenderPorGTIN(ctx, gtin, local, quantidade)
+	s.observar(ctx, "vender_por_gtin", inicio, err, slog.String("gtin", gtin), slog.String("local", local), slog.Int("quantidade", quantidade))
+	return produto, err
+}
+
+func (s *ServicoObservado) Transferir(ctx context.Context, id, origem, destino string, quantidade int) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.Transferir(ctx, id, origem, destino, quantidade)
+	s.observar(ctx, "transferir", inicio, err, slog.String("produto_id", id), slog.String("origem", origem), slog.String("destino", destino), slog.Int("quantidade", quantidade))
+	return err
+}
+
+func (s *ServicoObservado) RegistrarLote(ctx context.Context, id string, lote Lote) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.RegistrarLote(ctx, id, lote)
+	s.observar(ctx, "registrar_lote", inicio, err, slog.String("produto_id", id), slog.String("lote", lote.Codigo), slog.Int("quantidade", lote.Quantidade))
+	return err
+}
+
+func (s *ServicoObservado) RegistrarEntrada(ctx context.Context, id, local string, quantidade int, custoUnitario float64) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.RegistrarEntrada(ctx, id, local, quantidade, custoUnitario)
+	s.observar(ctx, "registrar_entrada", inicio, err, slog.String("produto_id", id), slog.String("local", local), slog.Int("quantidade", quantidade))
+	return err
+}
+
+func (s *ServicoObservado) Importar(ctx context.Context, r io.Reader, opcoes OpcoesImportacao) (RelatorioImportacao, error) {
+	inicio := time.Now()
+	relatorio, err := s.ServicoEstoque.Importar(ctx, r, opcoes)
+	s.observar(ctx, "importar", inicio, err, slog.Int("linhas", relatorio.Linhas), slog.Int("movimentos", relatorio.Movimentos), slog.Bool("simulacao", relatorio.Simulacao))
+	return relatorio, err
+}
+
+func (s *ServicoObservado) AprovarContagem(ctx context.Context, motivo string) ([]Movimento, error) {
+	inicio := time.Now()
+	movimentos, err := s.ServicoEstoque.AprovarContagem(ctx, motivo)
+	s.observar(ctx, "aprovar_contagem", inicio, err, slog.Int("ajustes", len(movimentos)))
+	return movimentos, err
+}
+
+func (s *ServicoObservado) DefinirEstrutura(ctx context.Context, id string, componentes []Componente) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.DefinirEstrutura(ctx, id, componentes)
+	s.observar(ctx, "definir_estrutura", inicio, err, slog.String("produto_id", id), slog.Int("componentes", len(componentes)))
+	return err
+}
+
+func (s *ServicoObservado) Produzir(ctx context.Context, ordem OrdemProducao) (OrdemProducao, error) {
+	inicio := time.Now()
+	produzida, err := s.ServicoEstoque.Produzir(ctx, ordem)
+	s.observar(ctx, "produzir", inicio, err, slog.String("produto_id", ordem.ProdutoID), slog.Int("quantidade", ordem.Quantidade))
+	return produzida, err
+}
+
+func (s *ServicoObservado) DefinirGTIN(ctx context.Context, id, gtin string) error {
+	inicio := time.Now()
+	err := s.ServicoEstoque.DefinirGTIN(ctx, id, gtin)
+	s.observar(ctx, "definir_gtin", inicio, err, slog.String("produto_id", id), slog.String("gtin", gtin))
+	return err
+}
+
+func (s *ServicoObservado) Estornar(ctx context.Context, movimentoID, motivo string) (Movimento, error) {
+	inicio := time.Now()
+	estorno, err := s.ServicoEstoque.Estornar(ctx, movimentoID, motivo)
+	s.observar(ctx, "estornar", inicio, err, slog.String("movimento_id", movimentoID))
+	return estorno, err
+}
@@ -0,0 +1,145 @@
+package estoque
+
+import (
+	"bytes"         // pacote padrão para capturar os logs e as métricas escritas
+	"context"       // pacote padrão para passar o ator das operações
+	"encoding/json" // pacote padrão para ler os logs em JSON
+	"errors"        // pacote padrão para comparar erros com errors.Is
+	"fmt"           // pacote padrão para embrulhar erros no teste de classificação
+	"log/slog"      // pacote padrão de logs estruturados
+	"strings"       // pacote padrão para procurar as linhas das métricas
+	"testing"       // pacote padrão do Go para testes
+	"time"          // pacote padrão para a consulta por data
+)
+
+// servicoObservadoParaTeste monta o serviço com os dois decoradores gravando os logs em JSON no buffer
+func servicoObservadoParaTeste(repo RepositorioEstoque) (*ServicoObservado, *Metricas, *bytes.Buffer) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	metricas := NovasMetricas()
+	metricas.AcompanharEstoque(repo)
+	servico := NovoServicoObservado(NovoServicoEstoque(NovoRepositorioObservado(repo, logger, metricas)), logger, metricas)
+	return servico, metricas, &logs
+}
+
+func TestClassificarErro(t *testing.T) {
+	casos := map[error]string{
+		nil:                    "",
+		ErrEstoqueInsuficiente: ErroTipoEstoqueInsuficiente,
+		fmt.Errorf("%w: viga", ErrProdutoNaoEncontrado): ErroTipoNaoEncontrado,
+		ErrMovimentoNaoEncontrado:                       ErroTipoNaoEncontrado,
+		ErrConflito:                                     ErroTipoConflito,
+		ErrGTINInvalido:                                 ErroTipoValorInvalido,
+		context.Canceled:                                ErroTipoCancelado,
+		errors.New("disco cheio"):                       ErroTipoOutro,
+	}
+	for err, esperado := range casos {
+		if tipo := ClassificarErro(err); tipo != esperado {
+			t.Errorf("ClassificarErro(%v): esperava %q, mas recebi %q", err, esperado, tipo)
+		}
+	}
+}
+
+func TestServicoObservadoContaOperacoesEErros(t *testing.T) {
+	servico, metricas, logs := servicoObservadoParaTeste(NovoRepositorioMemoria())
+	ctx := ComAtor(context.Background(), Ator{Usuario: "ana", Sistema: "teste"})
+	viga := NovoProduto("viga", 10)
+	servico.CadastrarProduto(ctx, viga)
+
+	if err := servico.VenderProduto(ctx, viga.ID, 3); err != nil {
+		t.Fatalf("Não esperava erro na venda, mas recebi %v", err)
+	}
+	if err := servico.VenderProduto(ctx, viga.ID, 50); !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Fatalf("O decorador deve devolver o erro original, mas recebi %v", err)
+	}
+	if err := servico.VenderProduto(ctx, "nao-existe", 1); !errors.Is(err, ErrProdutoNaoEncontrado) {
+		t.Fatalf("Esperava ErrProdutoNaoEncontrado, mas recebi %v", err)
+	}
+
+	if total := metricas.Operacoes(CamadaServico, "vender"); total != 3 {
+		t.Errorf("Esperava 3 vendas contadas, mas encontrei %d", total)
+	}
+	if erros := metricas.Erros(CamadaServico, "vender", ErroTipoEstoqueInsuficiente); erros != 1 {
+		t.Errorf("Esperava 1 erro de estoque insuficiente, mas encontrei %d", erros)
+	}
+	if erros := metricas.Erros(CamadaServico, "vender", ErroTipoNaoEncontrado); erros != 1 {
+		t.Errorf("Esperava 1 erro de produto não encontrado, mas encontrei %d", erros)
+	}
+	if metricas.Operacoes(CamadaRepositorio, "buscar") == 0 {
+		t.Errorf("Esperava as chamadas ao repositório também contadas")
+	}
+
+	// cada linha do log é um JSON com a operação, o usuário e o tipo do erro
+	var avisos []map[string]any
+	for _, linha := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var registro map[string]any
+		if err := json.Unmarshal([]byte(linha), &registro); err != nil {
+			t.Fatalf("Linha de log não é JSON: %s", linha)
+		}
+		if registro["level"] == "WARN" {
+			avisos = append(avisos, registro)
+		}
+	}
+	var venda map[string]any
+	for _, aviso := range avisos {
+		if aviso["camada"] == CamadaServico && aviso["tipo_erro"] == ErroTipoEstoqueInsuficiente {
+			venda = aviso
+		}
+	}
+	if venda == nil || venda["usuario"] != "ana" || venda["operacao"] != "vender" || venda["produto_id"] != viga.ID {
+		t.Errorf("Esperava o aviso da venda recusada com o usuário ana, mas encontrei %v", avisos)
+	}
+}
+
+func TestEscreverPrometheus(t *testing.T) {
+	servico, metricas, _ := servicoObservadoParaTeste(NovoRepositorioMemoria())
+	ctx := context.Background()
+	viga := NovoProduto("viga \"I\"", 10)
+	servico.CadastrarProduto(ctx, viga)
+	servico.VenderProduto(ctx, viga.ID, 4)
+	servico.VenderProduto(ctx, viga.ID, 40)
+
+	var saida bytes.Buffer
+	if err := metricas.EscreverPrometheus(&saida); err != nil {
+		t.Fatalf("Não esperava erro ao escrever as métricas, mas recebi %v", err)
+	}
+	texto := saida.String()
+	esperadas := []string{
+		"# TYPE estoque_operacoes_total counter",
+		`estoque_operacoes_total{camada="servico",operacao="vender"} 2`,
+		`estoque_erros_total{camada="servico",operacao="vender",tipo="estoque_insuficiente"} 1`,
+		"# TYPE estoque_operacao_duracao_segundos histogram",
+		`estoque_operacao_duracao_segundos_bucket{camada="servico",operacao="vender",le="+Inf"} 2`,
+		`estoque_operacao_duracao_segundos_count{camada="servico",operacao="vender"} 2`,
+		"# TYPE estoque_quantidade gauge",
+		`estoque_quantidade{produto_id="` + viga.ID + `",produto="viga \"I\""} 6`,
+	}
+	for _, linha := range esperadas {
+		if !strings.Contains(texto, linha+"\n") {
+			t.Errorf("Esperava a linha %q nas métricas:\n%s", linha, texto)
+		}
+	}
+}
+
+func TestRepositorioObservadoMantemHistorico(t *testing.T) {
+	repo := NovoRepositorioEventos(t.TempDir())
+	defer repo.Fechar()
+	servico, metricas, _ := servicoObservadoParaTeste(repo)
+	viga := NovoProduto("viga", 8)
+	servico.CadastrarProduto(context.Background(), viga)
+
+	passado, err := servico.EstoqueEm(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("O decorador não deveria esconder o histórico, mas recebi %v", err)
+	}
+	if total := passado.TotalGeral(); total != 8 {
+		t.Errorf("Esperava 8 vigas na consulta por data, mas encontrei %d", total)
+	}
+	if metricas.Operacoes(CamadaRepositorio, "estoque_em") != 1 {
+		t.Errorf("Esperava a consulta por data contada nas métricas")
+	}
+
+	if _, err := NovoServicoEstoque(NovoRepositorioObservado(NovoRepositorioMemoria(), nil, nil)).EstoqueEm(time.Now()); !errors.Is(err, ErrSemHistorico) {
+		t.Errorf("Esperava ErrSemHistorico para o repositório em memória, mas recebi %v", err)
+	}
+}
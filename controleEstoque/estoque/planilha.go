@@ -0,0 +1,76 @@
+package estoque
+
+import (
+	"bytes"         // pacote para trabalhar com o conteúdo lido em memória
+	"encoding/csv"  // pacote padrão para ler e escrever CSV
+	"errors"        // pacote para manipulação de erros
+	"io"            // pacote com as interfaces de leitura e escrita
+	"path/filepath" // pacote para descobrir a extensão do arquivo
+	"strings"       // pacote para manipular textos
+)
+
+// FormatoPlanilha indica como as linhas de uma planilha são codificadas
+type FormatoPlanilha string
+
+const (
+	FormatoCSV  FormatoPlanilha = "csv"  // texto separado por vírgula ou ponto e vírgula
+	FormatoXLSX FormatoPlanilha = "xlsx" // planilha do Excel/LibreOffice (xlsx.go)
+)
+
+// Erro para indicar que o formato da planilha não é suportado
+var ErrFormatoInvalido = errors.New("formato de planilha inválido")
+
+// FormatoPorArquivo descobre o formato da planilha pela extensão do arquivo
+func FormatoPorArquivo(caminho string) (FormatoPlanilha, error) {
+	switch strings.ToLower(filepath.Ext(caminho)) {
+	case ".csv", ".txt":
+		return FormatoCSV, nil
+	case ".xlsx":
+		return FormatoXLSX, nil
+	}
+	return "", ErrFormatoInvalido
+}
+
+// LerPlanilha lê todas as linhas da planilha, incluindo a linha de títulos
+func LerPlanilha(r io.Reader, formato FormatoPlanilha) ([][]string, error) {
+	dados, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch formato {
+	case FormatoCSV:
+		return lerCSV(dados)
+	case FormatoXLSX:
+		return lerXLSX(dados) // lerXLSX vem do arquivo xlsx.go
+	}
+	return nil, ErrFormatoInvalido
+}
+
+// EscreverPlanilha grava as linhas na planilha, a primeira linha deve conter os títulos
+func EscreverPlanilha(w io.Writer, formato FormatoPlanilha, linhas [][]string) error {
+	switch formato {
+	case FormatoCSV:
+		escritor := csv.NewWriter(w)
+		escritor.WriteAll(linhas) // WriteAll já chama Flush
+		return escritor.Error()
+	case FormatoXLSX:
+		return escreverXLSX(w, linhas) // escreverXLSX vem do arquivo xlsx.go
+	}
+	return ErrFormatoInvalido
+}
+
+// lerCSV lê um CSV separado por vírgula ou ponto e vírgula (padrão do Excel em português)
+func lerCSV(dados []byte) ([][]string, error) {
+	dados = bytes.TrimPrefix(dados, []byte("\xef\xbb\xbf")) // remove o BOM que o Excel coloca no início do arquivo
+
+	primeiraLinha, _, _ := bytes.Cut(dados, []byte("\n"))
+	leitor := csv.NewReader(bytes.NewReader(dados))
+	if bytes.Count(primeiraLinha, []byte(";")) > bytes.Count(primeiraLinha, []byte(",")) {
+		leitor.Comma = ';'
+	}
+	leitor.FieldsPerRecord = -1 // aceita linhas com quantidades diferentes de colunas
+	leitor.TrimLeadingSpace = true
+
+	return leitor.ReadAll()
+}
@@ -0,0 +1,164 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"fmt"     // pacote para detalhar qual componente está em falta
+	"time"    // pacote para a data da ordem de produção
+)
+
+// Erro para indicar que a estrutura do produto é inválida (vazia, com o próprio produto ou em ciclo)
+var ErrEstruturaInvalida = errors.New("estrutura de produto inválida")
+
+// Componente é uma matéria-prima da estrutura do produto e quanto dela vai em cada unidade produzida
+type Componente struct {
+	ProdutoID  string
+	Quantidade int
+}
+
+// OrdemProducao consome os componentes da estrutura e dá entrada no produto acabado
+type OrdemProducao struct {
+	ID            string
+	ProdutoID     string
+	Quantidade    int
+	Local         string // onde os componentes são consumidos e o produto entra ("" = consome de qualquer local e entra no padrão)
+	Lote          string // código do lote produzido ("" = entrada sem lote)
+	DiasCura      int    // dias de cura do lote produzido
+	Data          time.Time
+	Consumos      []Componente // quanto foi consumido de cada componente
+	CustoUnitario float64      // soma do custo médio dos componentes de uma unidade
+}
+
+// DefinirEstrutura troca a lista de componentes (estrutura) do produto
+// Componentes repetidos são somados; lista vazia remove a estrutura
+func (s *ServicoEstoque) DefinirEstrutura(ctx context.Context, id string, componentes []Componente) error {
+	var estrutura []Componente
+	posicoes := map[string]int{}
+	for _, componente := range componentes {
+		if componente.Quantidade <= 0 {
+			return ErrValorInvalido
+		}
+		if componente.ProdutoID == id {
+			return fmt.Errorf("%w: o produto não pode ser componente dele mesmo", ErrEstruturaInvalida)
+		}
+		if _, err := s.buscarProduto(componente.ProdutoID); err != nil {
+			return err
+		}
+		if s.usaComponente(componente.ProdutoID, id, map[string]bool{}) {
+			return fmt.Errorf("%w: %s já usa o produto na sua estrutura", ErrEstruturaInvalida, componente.ProdutoID)
+		}
+		if i, repetido := posicoes[componente.ProdutoID]; repetido {
+			estrutura[i].Quantidade += componente.Quantidade
+			continue
+		}
+		posicoes[componente.ProdutoID] = len(estrutura)
+		estrutura = append(estrutura, componente)
+	}
+
+	return s.alterarProduto(ctx, OperacaoEstrutura, id, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		produto.Componentes = estrutura
+		return nil, nil // mudar a estrutura não movimenta o estoque
+	})
+}
+
+// usaComponente indica se o produto usa o componente procurado em algum nível da sua estrutura
+func (s *ServicoEstoque) usaComponente(produtoID, procurado string, visitados map[string]bool) bool {
+	if visitados[produtoID] {
+		return false
+	}
+	visitados[produtoID] = true
+
+	produto, err := s.buscarProduto(produtoID)
+	if err != nil {
+		return false
+	}
+	for _, componente := range produto.Componentes {
+		if componente.ProdutoID == procurado || s.usaComponente(componente.ProdutoID, procurado, visitados) {
+			return true
+		}
+	}
+	return false
+}
+
+// Produzir executa a ordem de produção: consome os componentes e dá entrada no produto acabado
+// Tudo é gravado de uma vez (GravarOperacao); se faltar qualquer componente, nada muda e o erro
+// é ErrEstoqueInsuficiente com o nome do componente em falta
+func (s *ServicoEstoque) Produzir(ctx context.Context, ordem OrdemProducao) (OrdemProducao, error) {
+	if ordem.Quantidade <= 0 || ordem.DiasCura < 0 {
+		return OrdemProducao{}, ErrValorInvalido
+	}
+
+	acabado, err := s.buscarProduto(ordem.ProdutoID)
+	if err != nil {
+		return OrdemProducao{}, err
+	}
+	if len(acabado.Componentes) == 0 {
+		return OrdemProducao{}, fmt.Errorf("%w: %s não tem componentes", ErrEstruturaInvalida, acabado.Nome)
+	}
+
+	ordem.ID = gerarIDMovimento() // gerarIDMovimento vem do arquivo movimento.go
+	ordem.Data = s.agora()
+	motivo := "ordem de produção " + ordem.ID
+	custos := s.movimentosPorProduto() // movimentosPorProduto vem do arquivo relatorios.go
+
+	ids := []string{ordem.ProdutoID}
+	for _, componente := range acabado.Componentes {
+		ids = append(ids, componente.ProdutoID)
+	}
+
+	err = s.alterarProdutos(ctx, OperacaoProducao, ids, func(produtos map[string]*Produto) ([]Movimento, error) { // alterarProdutos vem do arquivo concorrencia.go
+		produto := produtos[ordem.ProdutoID]
+		ordem.Consumos, ordem.CustoUnitario = nil, 0
+		var movimentos []Movimento
+
+		for _, componente := range produto.Componentes { // estrutura relida a cada tentativa
+			materia, lido := produtos[componente.ProdutoID]
+			if !lido {
+				return nil, fmt.Errorf("%w: a estrutura de %s mudou durante a produção", ErrEstruturaInvalida, produto.Nome)
+			}
+			necessario := componente.Quantidade * ordem.Quantidade
+			if materia.Quantidade > 0 {
+				ordem.CustoUnitario += float64(componente.Quantidade) * valorDoProduto(custos[materia.ID], materia.Quantidade, CustoMedio) / float64(materia.Quantidade)
+			}
+
+			antes := materia.clonar()
+			err := materia.Vender(ordem.Local, necessario, ordem.Data, PoliticaFIFO) // lote em cura não pode ser consumido, como na venda (lote.go)
+			if errors.Is(err, ErrEstoqueInsuficiente) {
+				return nil, fmt.Errorf("%w: %s precisa de %d, há %d", ErrEstoqueInsuficiente, materia.Nome, necessario, antes.DisponivelParaVenda(ordem.Local, ordem.Data))
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			for _, movimento := range movimentosDaDiferenca(antes, *materia, MovimentoConsumo, ordem.Data) {
+				movimento.Motivo = motivo
+				movimentos = append(movimentos, movimento)
+			}
+			ordem.Consumos = append(ordem.Consumos, Componente{ProdutoID: materia.ID, Quantidade: necessario})
+		}
+
+		local := ordem.Local
+		if local == "" {
+			local = LocalPadrao
+		}
+		if ordem.Lote != "" {
+			lote := NovoLote(ordem.Lote, ordem.Data, ordem.DiasCura, ordem.Quantidade) // NovoLote vem do arquivo lote.go
+			lote.Local = local
+			if err := produto.AdicionarLote(lote); err != nil {
+				return nil, err
+			}
+		} else if err := produto.AumentarQuantidadeNoLocal(local, ordem.Quantidade); err != nil {
+			return nil, err
+		}
+
+		entrada := novoMovimento(produto.ID, MovimentoEntrada, local, ordem.Quantidade, ordem.Data)
+		entrada.Lote = ordem.Lote
+		entrada.CustoUnitario = ordem.CustoUnitario
+		entrada.Motivo = motivo
+		return append(movimentos, entrada), nil
+	})
+	if err != nil {
+		return OrdemProducao{}, err
+	}
+	return ordem, nil
+}
@@ -0,0 +1,107 @@
+package estoque
+
+import (
+	"context" // pacote padrão para passar o ator das operações
+	"errors"  // pacote padrão para comparar erros com errors.Is
+	"testing" // pacote padrão do Go para testes
+	"time"    // pacote padrão para fixar a data do serviço
+)
+
+func TestOrdemProducaoConsomeComponentes(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+	servico.agora = func() time.Time { return time.Date(2024, 6, 3, 7, 0, 0, 0, time.UTC) }
+
+	cimento, aco, areia, viga := NovoProduto("cimento", 0), NovoProduto("aço", 0), NovoProduto("areia", 0), NovoProduto("viga", 0)
+	for _, produto := range []Produto{cimento, aco, areia, viga} {
+		servico.CadastrarProduto(context.Background(), produto)
+	}
+	servico.RegistrarEntrada(context.Background(), cimento.ID, LocalPatio, 10, 30) // R$ 30 o saco
+	servico.RegistrarEntrada(context.Background(), aco.ID, LocalPatio, 20, 5)      // R$ 5 a barra
+	servico.RegistrarEntrada(context.Background(), areia.ID, LocalPatio, 5, 10)    // só 5 m³ de areia
+
+	err := servico.DefinirEstrutura(context.Background(), viga.ID, []Componente{{cimento.ID, 2}, {aco.ID, 4}, {areia.ID, 1}})
+	if err != nil {
+		t.Fatalf("Não esperava erro ao definir a estrutura, mas recebi %v", err)
+	}
+	if err := servico.DefinirEstrutura(context.Background(), cimento.ID, []Componente{{viga.ID, 1}}); !errors.Is(err, ErrEstruturaInvalida) {
+		t.Errorf("Esperava ErrEstruturaInvalida para estrutura em ciclo, mas recebi %v", err)
+	}
+
+	// faltam 1 m³ de areia para 6 vigas: nada pode ser consumido
+	movimentosAntes := len(servico.ListarMovimentos())
+	if _, err := servico.Produzir(context.Background(), OrdemProducao{ProdutoID: viga.ID, Quantidade: 6}); !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Fatalf("Esperava ErrEstoqueInsuficiente, mas recebi %v", err)
+	}
+	if produto, _ := servico.BuscarProduto(cimento.ID); produto.Quantidade != 10 || len(servico.ListarMovimentos()) != movimentosAntes {
+		t.Fatalf("A ordem recusada não deveria consumir nada, mas o cimento ficou com %d", produto.Quantidade)
+	}
+
+	ordem, err := servico.Produzir(context.Background(), OrdemProducao{ProdutoID: viga.ID, Quantidade: 5, Local: LocalPatio, Lote: "V-0603", DiasCura: 28})
+	if err != nil {
+		t.Fatalf("Não esperava erro na produção, mas recebi %v", err)
+	}
+	if ordem.CustoUnitario != 2*30+4*5+10 {
+		t.Errorf("Esperava custo unitário de 90, mas recebi %.2f", ordem.CustoUnitario)
+	}
+
+	saldos := map[string]int{}
+	for _, produto := range repo.Listar() {
+		saldos[produto.Nome] = produto.Quantidade
+	}
+	if saldos["cimento"] != 0 || saldos["aço"] != 0 || saldos["areia"] != 0 || saldos["viga"] != 5 {
+		t.Errorf("Saldos inesperados após a produção: %v", saldos)
+	}
+	produzida, _ := servico.BuscarProduto(viga.ID)
+	if len(produzida.Lotes) != 1 || produzida.DisponivelParaVenda("", servico.agora()) != 0 {
+		t.Errorf("Esperava as vigas em um lote ainda em cura, mas encontrei %+v", produzida.Lotes)
+	}
+
+	consumos := 0
+	for _, movimento := range servico.ListarMovimentos() {
+		if movimento.Motivo == "ordem de produção "+ordem.ID && movimento.Tipo == MovimentoConsumo {
+			consumos++
+		}
+	}
+	if consumos != 3 {
+		t.Errorf("Esperava 3 movimentos de consumo ligados à ordem, mas encontrei %d", consumos)
+	}
+	if abc := servico.CurvaABC(UltimosDias(servico.agora(), 30)); abc[0].QuantidadeVendida != 0 {
+		t.Errorf("Consumo de matéria-prima não deveria contar como venda: %+v", abc)
+	}
+}
+
+func TestProducaoNaoConsomeComponenteEmCura(t *testing.T) {
+	servico := NovoServicoEstoque(NovoRepositorioMemoria())
+	hoje := time.Date(2024, 6, 3, 7, 0, 0, 0, time.UTC)
+	servico.agora = func() time.Time { return hoje }
+
+	// a laje é feita com vigas, mas as únicas vigas ainda estão curando
+	viga, laje := NovoProduto("viga", 0), NovoProduto("laje", 0)
+	servico.CadastrarProduto(context.Background(), viga)
+	servico.CadastrarProduto(context.Background(), laje)
+	lote := NovoLote("V-0601", hoje.AddDate(0, 0, -2), 28, 10)
+	lote.Local = LocalPatio
+	if err := servico.RegistrarLote(context.Background(), viga.ID, lote); err != nil {
+		t.Fatalf("Não esperava erro ao registrar o lote, mas recebi %v", err)
+	}
+	if err := servico.DefinirEstrutura(context.Background(), laje.ID, []Componente{{viga.ID, 2}}); err != nil {
+		t.Fatalf("Não esperava erro ao definir a estrutura, mas recebi %v", err)
+	}
+
+	for _, local := range []string{"", LocalPatio} {
+		_, err := servico.Produzir(context.Background(), OrdemProducao{ProdutoID: laje.ID, Quantidade: 1, Local: local})
+		if !errors.Is(err, ErrEstoqueInsuficiente) {
+			t.Errorf("Esperava ErrEstoqueInsuficiente com as vigas em cura (local %q), mas recebi %v", local, err)
+		}
+	}
+	if produto, _ := servico.BuscarProduto(viga.ID); produto.Quantidade != 10 {
+		t.Errorf("As vigas em cura não deveriam ser consumidas, mas sobraram %d", produto.Quantidade)
+	}
+
+	// depois da cura, a mesma ordem consome o lote
+	hoje = hoje.AddDate(0, 0, 30)
+	if _, err := servico.Produzir(context.Background(), OrdemProducao{ProdutoID: laje.ID, Quantidade: 1, Local: LocalPatio}); err != nil {
+		t.Errorf("Não esperava erro depois da cura, mas recebi %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"  // pacote para codificar em hexadecimal
 	"errors"        // pacote para manipulação de erros
 	"fmt"           // pacote para formatação de strings
+	"time"          // pacote para conferir a cura dos lotes nas saídas
 )
 
 func NovoProduto(nome string, quantidade int) Produto {
@@ -12,6 +13,7 @@ func NovoProduto(nome string, quantidade int) Produto {
 		ID: gerarID(nome),
 		Nome: nome,
 		Quantidade: quantidade,
+		Locais: map[string]int{LocalPadrao: quantidade}, // o saldo inicial fica no local padrão
 	}
 }
 
@@ -29,16 +31,32 @@ var ErrEstoqueInsuficiente = errors.New("estoque insuficiente")
 // Erro para indicar que o valor fornecido é inválido
 var ErrValorInvalido = errors.New("valor inválido")
 
+// Erro para indicar que o produto não existe no repositório
+var ErrProdutoNaoEncontrado = errors.New("produto não encontrado")
+
 type Produto struct {
 
 	ID string
+	SKU string `json:",omitempty"` // código do produto usado nas planilhas (importacao.go)
+	GTIN string `json:",omitempty"` // código de barras EAN-13/GTIN lido pelo leitor no caixa (codigobarras.go)
 	Nome string
-	Quantidade int
+	Categoria string `json:",omitempty"` // ex: "cobogó", "estaca" (usada nas consultas)
+	Quantidade int // total somando todos os locais
+	Locais map[string]int `json:",omitempty"` // quantidade guardada em cada local (pátio, loja...)
+	Lotes []Lote `json:",omitempty"` // lotes de produção com data de cura (lote.go)
+	Componentes []Componente `json:",omitempty"` // estrutura do produto: matérias-primas usadas em cada unidade (producao.go)
+	FornecedorID string `json:",omitempty"` // fornecedor usado para repor o produto (compras.go)
+	EstoqueMinimo int `json:",omitempty"` // ponto de pedido: com esse saldo ou menos o produto entra na reposição
+	EstoqueMaximo int `json:",omitempty"` // saldo desejado depois da reposição (0 = o dobro do mínimo)
+	Estornados []string `json:",omitempty"` // IDs dos movimentos do produto já estornados; gravados com a versão, valem entre processos (estorno.go)
+	Versao int // aumenta a cada Atualizar; gravar a partir de uma versão antiga retorna ErrConflito (concorrencia.go)
 
 }
 
 // Métodos para aumentar e diminuir a quantidade do produto
 func (p *Produto) AumentarQuantidade(valor int) { 
+	p.normalizarLocais()
+	p.Locais[LocalPadrao] += valor // sem local informado, a entrada vai para o local padrão
 	p.Quantidade += valor
 }
 
@@ -47,14 +65,10 @@ func (p *Produto) DiminuirQuantidade(valor int) error {
 	if valor <= 0 { // valida se o valor é positivo
 		return ErrValorInvalido // retorna erro se o valor for inválido
 	}
-	// verifica se há estoque suficiente (regra de negócio)
-	if p.Quantidade < valor {
-		return ErrEstoqueInsuficiente // retorna erro se o estoque for insuficiente
-	}
-
 	// serve para diminuir a quantidade do produto - altera segura do estado do objeto
-	p.Quantidade -= valor // diminui a quantidade do produto
-	return nil // se não houver erro, retorna nil
+	// retira primeiro do local padrão e depois dos demais locais, em ordem alfabética,
+	// com a regra da venda: lotes que ainda não curaram não saem (ErrEstoqueInsuficiente se faltar saldo curado)
+	return p.Vender("", valor, time.Now(), PoliticaFIFO) // Vender vem do arquivo lote.go
 }
 
 func (p *Produto) Exibir() {
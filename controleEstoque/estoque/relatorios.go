@@ -0,0 +1,300 @@
+package estoque
+
+import (
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"errors"  // pacote para manipulação de erros
+	"sort"    // pacote para ordenar os produtos nos relatórios
+	"time"    // pacote para o período dos relatórios
+)
+
+// Erro para indicar que o método de custo não existe
+var ErrMetodoCustoInvalido = errors.New("método de custo inválido")
+
+// MetodoCusto define como o custo das unidades em estoque é calculado
+type MetodoCusto string
+
+const (
+	CustoMedio MetodoCusto = "medio" // custo médio ponderado das entradas
+	CustoFIFO  MetodoCusto = "fifo"  // as unidades que sobram são as das entradas mais recentes
+)
+
+// Limites da curva ABC (percentual acumulado das vendas)
+const (
+	limiteClasseA = 0.80 // os produtos que somam até 80% das vendas são classe A
+	limiteClasseB = 0.95 // até 95% são classe B, o restante é classe C
+)
+
+// Periodo delimita os movimentos considerados em um relatório
+type Periodo struct {
+	Inicio time.Time
+	Fim    time.Time
+}
+
+// UltimosDias cria um período que termina agora e começa alguns dias antes
+func UltimosDias(agora time.Time, dias int) Periodo {
+	return Periodo{Inicio: agora.AddDate(0, 0, -dias), Fim: agora}
+}
+
+// dias retorna a duração do período em dias (no mínimo 1, para evitar divisão por zero)
+func (p Periodo) dias() float64 {
+	return max(p.Fim.Sub(p.Inicio).Hours()/24, 1)
+}
+
+// contem indica se a data está dentro do período (início incluído, fim incluído)
+func (p Periodo) contem(data time.Time) bool {
+	return !data.Before(p.Inicio) && !data.After(p.Fim)
+}
+
+// ValorEstoque é uma linha do relatório de valorização do estoque
+type ValorEstoque struct {
+	ProdutoID     string
+	Produto       string
+	Quantidade    int
+	CustoUnitario float64
+	Valor         float64
+}
+
+// ClasseABC é uma linha da curva ABC por volume de vendas
+type ClasseABC struct {
+	ProdutoID           string
+	Produto             string
+	QuantidadeVendida   int
+	Percentual          float64 // participação do produto nas vendas (0 a 1)
+	PercentualAcumulado float64
+	Classe              string // A, B ou C
+}
+
+// GiroEstoque é uma linha do relatório de giro e dias de cobertura
+type GiroEstoque struct {
+	ProdutoID         string
+	Produto           string
+	QuantidadeVendida int
+	EstoqueAtual      int
+	EstoqueMedio      float64
+	Giro              float64  // quantas vezes o estoque médio foi vendido no período
+	DiasCobertura     *float64 // quantos dias o estoque atual dura no ritmo de vendas do período (nil = sem vendas)
+}
+
+// RegistrarEntrada registra a compra ou produção de unidades com o custo unitário
+// O custo é usado na valorização do estoque (custo médio e FIFO)
+func (s *ServicoEstoque) RegistrarEntrada(ctx context.Context, id, local string, quantidade int, custoUnitario float64) error {
+	return s.registrarEntrada(ctx, id, local, quantidade, custoUnitario, "")
+}
+
+// registrarEntrada é o RegistrarEntrada com o motivo gravado no movimento (ex: o pedido de compra recebido)
+func (s *ServicoEstoque) registrarEntrada(ctx context.Context, id, local string, quantidade int, custoUnitario float64, motivo string) error {
+	if custoUnitario < 0 {
+		return ErrValorInvalido
+	}
+	return s.alterarProduto(ctx, OperacaoEntrada, id, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		if err := produto.AumentarQuantidadeNoLocal(local, quantidade); err != nil { // AumentarQuantidadeNoLocal vem do arquivo local.go
+			return nil, err
+		}
+
+		movimento := novoMovimento(produto.ID, MovimentoEntrada, local, quantidade, s.agora())
+		movimento.CustoUnitario = custoUnitario
+		movimento.Motivo = motivo
+		return []Movimento{movimento}, nil
+	})
+}
+
+// ValorizarEstoque calcula quanto vale o estoque atual de cada produto pelo método escolhido
+// O custo vem das entradas do livro de movimentações; unidades sem custo conhecido usam o último custo
+func (s *ServicoEstoque) ValorizarEstoque(metodo MetodoCusto) ([]ValorEstoque, error) {
+	if metodo != CustoMedio && metodo != CustoFIFO {
+		return nil, ErrMetodoCustoInvalido
+	}
+
+	movimentos := s.movimentosPorProduto()
+	var valores []ValorEstoque
+	for _, produto := range s.produtosUnicos() {
+		valor := valorDoProduto(movimentos[produto.ID], produto.Quantidade, metodo)
+		custo := 0.0
+		if produto.Quantidade > 0 {
+			custo = valor / float64(produto.Quantidade)
+		}
+		valores = append(valores, ValorEstoque{
+			ProdutoID:     produto.ID,
+			Produto:       produto.Nome,
+			Quantidade:    produto.Quantidade,
+			CustoUnitario: custo,
+			Valor:         valor,
+		})
+	}
+	return valores, nil
+}
+
+// CurvaABC classifica os produtos pelo volume vendido no período
+// Classe A: produtos que somam até 80% das vendas, B: até 95%, C: o restante (incluindo os sem venda)
+func (s *ServicoEstoque) CurvaABC(periodo Periodo) []ClasseABC {
+	vendas := s.vendasNoPeriodo(periodo)
+	total := 0
+	var classes []ClasseABC
+	for _, produto := range s.produtosUnicos() {
+		total += vendas[produto.ID]
+		classes = append(classes, ClasseABC{ProdutoID: produto.ID, Produto: produto.Nome, QuantidadeVendida: vendas[produto.ID]})
+	}
+
+	sort.SliceStable(classes, func(i, j int) bool { return classes[i].QuantidadeVendida > classes[j].QuantidadeVendida })
+
+	acumulado := 0.0
+	for i := range classes {
+		if total > 0 {
+			classes[i].Percentual = float64(classes[i].QuantidadeVendida) / float64(total)
+		}
+		anterior := acumulado // a classe é decidida pelo acumulado antes do produto, assim o maior vendedor é sempre A
+		acumulado += classes[i].Percentual
+		classes[i].PercentualAcumulado = acumulado
+
+		switch {
+		case classes[i].QuantidadeVendida == 0:
+			classes[i].Classe = "C"
+		case anterior < limiteClasseA:
+			classes[i].Classe = "A"
+		case anterior < limiteClasseB:
+			classes[i].Classe = "B"
+		default:
+			classes[i].Classe = "C"
+		}
+	}
+	return classes
+}
+
+// GiroEstoque calcula o giro e os dias de cobertura de cada produto no período
+// O estoque médio é a média entre o saldo no início do período e o saldo atual
+func (s *ServicoEstoque) GiroEstoque(periodo Periodo) []GiroEstoque {
+	vendas := s.vendasNoPeriodo(periodo)
+	movimentos := s.movimentosPorProduto()
+
+	var giros []GiroEstoque
+	for _, produto := range s.produtosUnicos() {
+		inicial := produto.Quantidade // volta o saldo atual até o início do período desfazendo os movimentos
+		for _, movimento := range movimentos[produto.ID] {
+			if !movimento.Data.Before(periodo.Inicio) && movimento.Tipo != MovimentoTransferencia {
+				inicial -= movimento.Quantidade
+			}
+		}
+
+		giro := GiroEstoque{
+			ProdutoID:         produto.ID,
+			Produto:           produto.Nome,
+			QuantidadeVendida: vendas[produto.ID],
+			EstoqueAtual:      produto.Quantidade,
+			EstoqueMedio:      float64(max(inicial, 0)+produto.Quantidade) / 2,
+		}
+		if giro.EstoqueMedio > 0 {
+			giro.Giro = float64(giro.QuantidadeVendida) / giro.EstoqueMedio
+		}
+		if giro.QuantidadeVendida > 0 {
+			dias := float64(produto.Quantidade) / (float64(giro.QuantidadeVendida) / periodo.dias())
+			giro.DiasCobertura = &dias
+		}
+		giros = append(giros, giro)
+	}
+	return giros
+}
+
+// produtosUnicos lista os produtos sem repetir o mesmo ID (estoques antigos podem ter produtos duplicados)
+func (s *ServicoEstoque) produtosUnicos() []Produto {
+	vistos := map[string]bool{}
+	var produtos []Produto
+	for _, produto := range s.repositorio.Listar() {
+		if !vistos[produto.ID] {
+			vistos[produto.ID] = true
+			produtos = append(produtos, produto)
+		}
+	}
+	return produtos
+}
+
+// movimentosPorProduto agrupa o livro de movimentações por produto, em ordem de data
+func (s *ServicoEstoque) movimentosPorProduto() map[string][]Movimento {
+	movimentos := append([]Movimento(nil), s.repositorio.ListarMovimentos()...) // cópia para não reordenar o livro do repositório
+	sort.SliceStable(movimentos, func(i, j int) bool { return movimentos[i].Data.Before(movimentos[j].Data) })
+
+	porProduto := map[string][]Movimento{}
+	for _, movimento := range movimentos {
+		porProduto[movimento.ProdutoID] = append(porProduto[movimento.ProdutoID], movimento)
+	}
+	return porProduto
+}
+
+// vendasNoPeriodo soma as unidades vendidas de cada produto no período
+func (s *ServicoEstoque) vendasNoPeriodo(periodo Periodo) map[string]int {
+	vendas := map[string]int{}
+	for _, movimento := range s.repositorio.ListarMovimentos() {
+		if movimento.Tipo == MovimentoSaida && periodo.contem(movimento.Data) {
+			vendas[movimento.ProdutoID] -= movimento.Quantidade // saídas têm quantidade negativa
+		}
+	}
+	return vendas
+}
+
+// camadaCusto é um grupo de unidades que entraram com o mesmo custo (usado no FIFO)
+type camadaCusto struct {
+	quantidade int
+	custo      float64
+}
+
+// valorDoProduto refaz as entradas e saídas do produto para calcular o valor do saldo atual
+func valorDoProduto(movimentos []Movimento, quantidadeAtual int, metodo MetodoCusto) float64 {
+	var camadas []camadaCusto
+	saldo, medio, ultimo := 0, 0.0, 0.0
+
+	for _, movimento := range movimentos {
+		if movimento.Tipo == MovimentoTransferencia {
+			continue // mudar de local não muda o custo
+		}
+		if movimento.Quantidade > 0 {
+			custo := movimento.CustoUnitario
+			if custo == 0 {
+				custo = ultimo // entrada sem custo (cadastro, ajuste) usa o último custo conhecido
+			} else {
+				ultimo = custo
+			}
+			medio = (float64(saldo)*medio + float64(movimento.Quantidade)*custo) / float64(saldo+movimento.Quantidade)
+			saldo += movimento.Quantidade
+			camadas = append(camadas, camadaCusto{quantidade: movimento.Quantidade, custo: custo})
+			continue
+		}
+		saldo = max(saldo+movimento.Quantidade, 0)
+		camadas = consumirCamadas(camadas, -movimento.Quantidade)
+	}
+
+	if metodo == CustoMedio {
+		if medio == 0 {
+			medio = ultimo
+		}
+		return float64(quantidadeAtual) * medio
+	}
+
+	// ajusta as camadas ao saldo atual do produto (produtos antigos podem ter saldo fora do livro)
+	emCamadas := 0
+	for _, camada := range camadas {
+		emCamadas += camada.quantidade
+	}
+	if quantidadeAtual < emCamadas {
+		camadas = consumirCamadas(camadas, emCamadas-quantidadeAtual)
+	} else if quantidadeAtual > emCamadas {
+		camadas = append(camadas, camadaCusto{quantidade: quantidadeAtual - emCamadas, custo: ultimo})
+	}
+
+	valor := 0.0
+	for _, camada := range camadas {
+		valor += float64(camada.quantidade) * camada.custo
+	}
+	return valor
+}
+
+// consumirCamadas retira unidades das camadas mais antigas primeiro
+func consumirCamadas(camadas []camadaCusto, quantidade int) []camadaCusto {
+	for quantidade > 0 && len(camadas) > 0 {
+		parte := min(quantidade, camadas[0].quantidade)
+		camadas[0].quantidade -= parte
+		quantidade -= parte
+		if camadas[0].quantidade == 0 {
+			camadas = camadas[1:]
+		}
+	}
+	return camadas
+}
@@ -0,0 +1,82 @@
+package estoque
+
+import (
+	"bytes"   // pacote padrão para capturar o relatório renderizado
+	"context" // pacote padrão para passar o ator das operações
+	"math"    // pacote padrão para comparar números decimais
+	"strings" // pacote padrão para procurar textos no relatório
+	"testing" // pacote padrão do Go para testes
+	"time"    // pacote padrão para controlar as datas dos movimentos
+)
+
+func TestValorizarEstoqueCustoMedioEFIFO(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+	servico.agora = func() time.Time { return time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	cimento := NovoProduto("cimento", 0)
+	servico.CadastrarProduto(context.Background(), cimento)
+	servico.RegistrarEntrada(context.Background(), cimento.ID, LocalPatio, 10, 30) // 10 sacos a R$ 30
+	servico.RegistrarEntrada(context.Background(), cimento.ID, LocalPatio, 10, 40) // 10 sacos a R$ 40
+	servico.VenderProduto(context.Background(), cimento.ID, 15)                    // sobram 5 sacos
+
+	medio, _ := servico.ValorizarEstoque(CustoMedio)
+	if math.Abs(medio[0].Valor-175) > 0.001 { // 5 x R$ 35 (média ponderada)
+		t.Errorf("Esperava valor 175 pelo custo médio, mas encontrei %.2f", medio[0].Valor)
+	}
+
+	fifo, _ := servico.ValorizarEstoque(CustoFIFO)
+	if math.Abs(fifo[0].Valor-200) > 0.001 { // os 5 que sobram são da última entrada, 5 x R$ 40
+		t.Errorf("Esperava valor 200 pelo FIFO, mas encontrei %.2f", fifo[0].Valor)
+	}
+
+	if _, err := servico.ValorizarEstoque("lifo"); err != ErrMetodoCustoInvalido {
+		t.Errorf("Esperava ErrMetodoCustoInvalido, mas recebi %v", err)
+	}
+}
+
+func TestCurvaABCEGiro(t *testing.T) {
+	repo := NovoRepositorioMemoria()
+	servico := NovoServicoEstoque(repo)
+	hoje := time.Date(2026, 5, 31, 0, 0, 0, 0, time.UTC)
+	servico.agora = func() time.Time { return hoje }
+
+	vendas := map[string]int{"viga": 80, "coluna": 15, "estaca": 5, "cobogo": 0}
+	for _, nome := range []string{"viga", "coluna", "estaca", "cobogo"} {
+		produto := NovoProduto(nome, 100)
+		servico.CadastrarProduto(context.Background(), produto)
+		if vendas[nome] > 0 {
+			servico.VenderProduto(context.Background(), produto.ID, vendas[nome])
+		}
+	}
+
+	periodo := UltimosDias(hoje, 30)
+	classes := servico.CurvaABC(periodo)
+	esperado := map[string]string{"viga": "A", "coluna": "B", "estaca": "C", "cobogo": "C"}
+	for _, c := range classes {
+		if c.Classe != esperado[c.Produto] {
+			t.Errorf("Esperava %s na classe %s, mas encontrei %s", c.Produto, esperado[c.Produto], c.Classe)
+		}
+	}
+
+	for _, g := range servico.GiroEstoque(periodo) {
+		if g.Produto == "viga" && (g.DiasCobertura == nil || math.Abs(*g.DiasCobertura-7.5) > 0.001) { // 20 vigas / (80 vigas / 30 dias)
+			t.Errorf("Esperava 7,5 dias de cobertura para a viga, mas encontrei %+v", g)
+		}
+		if g.Produto == "cobogo" && g.DiasCobertura != nil {
+			t.Errorf("Produto sem vendas não deveria ter dias de cobertura, mas encontrei %v", *g.DiasCobertura)
+		}
+	}
+
+	var saida bytes.Buffer
+	TabelaCurvaABC(classes).Escrever(&saida, RelatorioTexto) // TabelaCurvaABC vem do arquivo tabela.go
+	if !strings.Contains(saida.String(), "Curva ABC") || !strings.Contains(saida.String(), "viga") {
+		t.Errorf("Relatório em texto inesperado:\n%s", saida.String())
+	}
+
+	saida.Reset()
+	TabelaCurvaABC(classes).Escrever(&saida, RelatorioJSON)
+	if !strings.Contains(saida.String(), `"QuantidadeVendida": 80`) {
+		t.Errorf("Relatório em JSON inesperado:\n%s", saida.String())
+	}
+}
@@ -1,12 +1,22 @@
 package estoque
 
 import (
-	"errors"
+	"context" // pacote para receber o ator das operações (auditoria.go)
+	"sort" // pacote para ordenar os saldos por local
+	"sync" // pacote para proteger a contagem aberta
+	"time" // pacote para saber se os lotes já curaram
 )
 
 // ServicoEstoque chama a interface RepositorioEstoque para gerenciar produtos no estoque
 type ServicoEstoque struct {
 	repositorio RepositorioEstoque // campo que armazena o repositório de estoque que esta implementa a interface RepositorioEstoque
+	politicaLotes PoliticaLote // ordem de consumo dos lotes nas vendas (FIFO por padrão)
+	agora func() time.Time // relógio usado para saber se um lote já curou (substituível nos testes)
+	tentativas int // quantas vezes uma operação é tentada quando há conflito de versão (concorrencia.go)
+	auditoria RepositorioAuditoria // trilha de auditoria das alterações (auditoria.go)
+	avisoAuditoria AvisoAuditoria // quem recebe as falhas de gravação da trilha (auditoria.go)
+	contagem *Contagem // contagem de estoque (inventário) aberta, ou nil (contagem.go)
+	mu sync.Mutex // protege a contagem aberta
 }
 
 
@@ -14,12 +24,28 @@ type ServicoEstoque struct {
 func NovoServicoEstoque(repo RepositorioEstoque) *ServicoEstoque {
 	return &ServicoEstoque { // retorna um ponteiro para ServicoEstoque
 		repositorio: repo, // repo significa o repositório passado como argumento que é atribuído ao campo repositorio
+		politicaLotes: PoliticaFIFO,
+		agora: time.Now,
+		tentativas: TentativasPadrao,
+		auditoria: NovoRepositorioAuditoriaMemoria(),
+		avisoAuditoria: avisarNaSaidaDeErros,
 	}
 }
 
 // CadastrarProduto adiciona um novo produto ao estoque usando o repositório substituindo o método Adicionar da interface
-func (s *ServicoEstoque) CadastrarProduto(produto Produto) {
-	s.repositorio.Adicionar(produto) // chama o método Adicionar do repositório para adicionar o produto que esta no arquivo main.go
+// O saldo inicial de cada local é registrado como movimento de entrada
+// Produto e movimentos são gravados juntos (GravarOperacao); um produto com o mesmo ID já cadastrado dá ErrConflito
+// O ator do contexto (ComAtor) fica registrado na trilha de auditoria
+func (s *ServicoEstoque) CadastrarProduto(ctx context.Context, produto Produto) error {
+	movimentos := movimentosDaDiferenca(Produto{}, produto, MovimentoEntrada, s.agora()) // movimentosDaDiferenca vem do arquivo movimento.go
+	if err := s.conferirBloqueio(movimentos); err != nil { // saldo inicial durante uma contagem em modo bloquear
+		return err
+	}
+	if err := s.repositorio.GravarOperacao([]Produto{produto}, nil, movimentos); err != nil {
+		return err
+	}
+	s.auditar(ctx, OperacaoCadastro, nil, []Produto{produto}, movimentos) // auditar vem do arquivo auditoria.go
+	return nil
 }
 
 // ListarEstoque retorna a lista de produtos no estoque usando o repositório substituindo o método Listar da interface
@@ -27,15 +53,147 @@ func (s *ServicoEstoque) ListarEstoque() []Produto {
 	return s.repositorio.Listar() // chama o método Listar do repositório para listar os produtos que estão no estoque que estar no aruivo main.go
 }
 
+// BuscarProduto retorna o produto com o ID informado
+func (s *ServicoEstoque) BuscarProduto(id string) (Produto, error) {
+	return s.buscarProduto(id)
+}
+
+// ConsultarEstoque busca produtos por nome (sem diferenciar acentos), categoria e faixa de quantidade,
+// com ordenação e paginação por cursor
+func (s *ServicoEstoque) ConsultarEstoque(consulta Consulta) (PaginaProdutos, error) {
+	return s.repositorio.Consultar(consulta) // Consultar vem do arquivo interface.go
+}
+
+// ListarMovimentos retorna o livro de movimentações do estoque
+func (s *ServicoEstoque) ListarMovimentos() []Movimento {
+	return s.repositorio.ListarMovimentos()
+}
+
+// DefinirPoliticaLotes escolhe a ordem em que os lotes são consumidos nas vendas (FIFO ou FEFO)
+func (s *ServicoEstoque) DefinirPoliticaLotes(politica PoliticaLote) {
+	s.politicaLotes = politica
+}
+
 // VenderProduto diminui a quantidade de um produto no estoque
-func (s *ServicoEstoque) VenderProduto(id string, quantidade int) error {
-	produtos := s.repositorio.Listar() // obtém a lista de produtos do repositório
+// A saída é feita primeiro do local padrão e depois dos demais locais
+// Lotes que ainda não curaram são ignorados
+// Retorna ErrContagemAberta se houver uma contagem de estoque bloqueando as vendas (também as transferências,
+// entradas e demais operações que lançam movimentos, conferidas em alterarProduto)
+func (s *ServicoEstoque) VenderProduto(ctx context.Context, id string, quantidade int) error {
+	return s.VenderProdutoNoLocal(ctx, id, "", quantidade) // local vazio vende de todos os locais
+}
+
+// VenderProdutoNoLocal diminui a quantidade de um produto em um local específico (ex: loja)
+// Com local vazio, vende de todos os locais como VenderProduto
+// Vendas simultâneas do mesmo produto são repetidas em caso de conflito de versão (concorrencia.go)
+func (s *ServicoEstoque) VenderProdutoNoLocal(ctx context.Context, id, local string, quantidade int) error {
+	if s.vendasBloqueadas() { // vendasBloqueadas vem do arquivo contagem.go
+		return ErrContagemAberta
+	}
+
+	return s.alterarProduto(ctx, OperacaoVenda, id, func(produto *Produto) ([]Movimento, error) { // alterarProduto vem do arquivo concorrencia.go
+		antes := produto.clonar() // guarda o estado anterior para registrar os movimentos
+		if err := produto.Vender(local, quantidade, s.agora(), s.politicaLotes); err != nil { // Vender vem do arquivo lote.go
+			return nil, err // propaga ErrValorInvalido ou ErrEstoqueInsuficiente
+		}
+		return movimentosDaDiferenca(antes, *produto, MovimentoSaida, s.agora()), nil
+	})
+}
+
+// Transferir move unidades de um produto entre dois locais (ex: do pátio para a loja)
+// A transferência é atômica: origem e destino são gravados juntos em uma única atualização do produto
+func (s *ServicoEstoque) Transferir(ctx context.Context, id, origem, destino string, quantidade int) error {
+	return s.alterarProduto(ctx, OperacaoTransferencia, id, func(produto *Produto) ([]Movimento, error) {
+		if err := produto.Transferir(origem, destino, quantidade); err != nil { // Transferir vem do arquivo local.go
+			return nil, err // nada é gravado se a transferência for inválida
+		}
+
+		movimento := novoMovimento(produto.ID, MovimentoTransferencia, origem, quantidade, s.agora())
+		movimento.Destino = destino
+		return []Movimento{movimento}, nil
+	})
+}
+
+// RegistrarLote registra a entrada de um lote de produção em um produto já cadastrado
+func (s *ServicoEstoque) RegistrarLote(ctx context.Context, id string, lote Lote) error {
+	return s.alterarProduto(ctx, OperacaoLote, id, func(produto *Produto) ([]Movimento, error) {
+		if err := produto.AdicionarLote(lote); err != nil { // AdicionarLote vem do arquivo lote.go
+			return nil, err
+		}
 
-	for i := range produtos {  // faz um loop pelos os produtos
-		if produtos[i].ID == id { // se o nome do produto for igual ao nome passado como argumento
-			return produtos[i].DiminuirQuantidade(quantidade) // retorna o resultado da chamada do método DiminuirQuantidade do produto, onde podutos[i] significa o produto encontrado no estoque .DiminuirQuantidade(quantidade) vem do arquivo produto.go
+		local := lote.Local
+		if local == "" {
+			local = LocalPadrao // mesmo padrão usado em AdicionarLote
+		}
+		movimento := novoMovimento(produto.ID, MovimentoEntrada, local, lote.Quantidade, s.agora())
+		movimento.Lote = lote.Codigo
+		return []Movimento{movimento}, nil
+	})
+}
+
+// EstoquePorLote retorna o saldo de cada lote, ordenado por produto e data de produção
+func (s *ServicoEstoque) EstoquePorLote() []SaldoLote {
+	agora := s.agora()
+	var saldos []SaldoLote
+
+	for _, produto := range s.repositorio.Listar() {
+		for _, lote := range produto.Lotes {
+			saldos = append(saldos, SaldoLote{
+				ProdutoID:    produto.ID,
+				Produto:      produto.Nome,
+				Codigo:       lote.Codigo,
+				Local:        lote.Local,
+				DataProducao: lote.DataProducao,
+				DataCura:     lote.DataCura,
+				Quantidade:   lote.Quantidade,
+				Curado:       lote.Curado(agora),
+			})
+		}
+	}
+	sort.SliceStable(saldos, func(i, j int) bool {
+		if saldos[i].Produto != saldos[j].Produto {
+			return saldos[i].Produto < saldos[j].Produto
+		}
+		return saldos[i].DataProducao.Before(saldos[j].DataProducao)
+	})
+
+	return saldos
+}
+
+// TotaisPorLocal retorna a quantidade total de produtos guardada em cada local, em ordem alfabética
+func (s *ServicoEstoque) TotaisPorLocal() []SaldoLocal {
+	totais := map[string]int{} // soma das quantidades por local
+
+	for _, produto := range s.repositorio.Listar() {
+		for _, local := range produto.LocaisOrdenados() {
+			totais[local] += produto.Locais[local]
 		}
 	}
 
-	return errors.New("produto não encontrado") // retorna um erro se o produto não for encontrado
-}
\ No newline at end of file
+	saldos := make([]SaldoLocal, 0, len(totais))
+	for local, quantidade := range totais {
+		saldos = append(saldos, SaldoLocal{Local: local, Quantidade: quantidade})
+	}
+	sort.Slice(saldos, func(i, j int) bool { return saldos[i].Local < saldos[j].Local })
+
+	return saldos
+}
+
+// TotalGeral retorna a quantidade total de produtos somando todos os locais
+func (s *ServicoEstoque) TotalGeral() int {
+	total := 0
+	for _, produto := range s.repositorio.Listar() {
+		total += produto.Quantidade
+	}
+	return total
+}
+
+// salvar grava o produto e os movimentos da operação juntos; sem atualização, nenhum movimento é registrado
+func (s *ServicoEstoque) salvar(produto Produto, movimentos []Movimento) error {
+	return s.repositorio.GravarOperacao(nil, []Produto{produto}, movimentos)
+}
+
+// buscarProduto procura um produto pelo ID usando o índice do repositório
+func (s *ServicoEstoque) buscarProduto(id string) (Produto, error) {
+	return s.repositorio.Buscar(id) // retorna ErrProdutoNaoEncontrado se o produto não existir
+}
@@ -1,12 +1,18 @@
 package estoque
 
-import "testing" // pacote padrão do Go para testes
+import (
+	"context" // pacote padrão para passar o ator das operações
+	"errors"  // pacote padrão para comparar erros com errors.Is
+	"testing" // pacote padrão do Go para testes
+	"time"    // pacote padrão para datas dos lotes
+)
 
 // mockRepositorioEstoque é uma implementação falsa do RepositorioEstoque para testes
 // serve para apenas testar a lógica do serviço de estoque sem depender de um banco de dados real
 // implementa a interface RepositorioEstoque do arquivo interface.go
 type mockRepositorioEstoque struct {
 	produtos []Produto // usa o tipo Produto do arquivo produto.go
+	movimentos []Movimento // usa o tipo Movimento do arquivo movimento.go
 }
 
 // Adicionar implementa o método da interface RepositorioEstoque do arquivo interface.go
@@ -30,6 +36,47 @@ func (m *mockRepositorioEstoque) Atualizar(produto Produto) error {
 	return nil // para testes simples, retorna nil se não encontrar
 }
 
+// AtualizarVarios implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) AtualizarVarios(produtos []Produto) error {
+	for _, produto := range produtos {
+		m.Atualizar(produto)
+	}
+	return nil
+}
+
+// GravarOperacao implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) GravarOperacao(novos, alterados []Produto, movimentos []Movimento) error {
+	m.produtos = append(m.produtos, novos...)
+	m.AtualizarVarios(alterados)
+	m.movimentos = append(m.movimentos, movimentos...)
+	return nil
+}
+
+// Buscar implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) Buscar(id string) (Produto, error) {
+	for _, produto := range m.produtos {
+		if produto.ID == id {
+			return produto, nil
+		}
+	}
+	return Produto{}, ErrProdutoNaoEncontrado
+}
+
+// Consultar implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) Consultar(consulta Consulta) (PaginaProdutos, error) {
+	return aplicarConsulta(m.produtos, consulta) // aplicarConsulta vem do arquivo consulta.go
+}
+
+// RegistrarMovimento implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) RegistrarMovimento(movimento Movimento) {
+	m.movimentos = append(m.movimentos, movimento)
+}
+
+// ListarMovimentos implementa o método da interface RepositorioEstoque do arquivo interface.go
+func (m *mockRepositorioEstoque) ListarMovimentos() []Movimento {
+	return m.movimentos
+}
+
 func TestCadastrarProduto( t *testing.T) { // t *testing.T vem do pacote padrão "testing"
 
 	// cria o mock do repositório para os testes
@@ -42,7 +89,7 @@ func TestCadastrarProduto( t *testing.T) { // t *testing.T vem do pacote padrão
 	produto := NovoProduto("viga", 12)
 
 	// CadastrarProduto vem do arquivo servico.go
-	servico.CadastrarProduto(produto)
+	servico.CadastrarProduto(context.Background(), produto)
 
 	// t.Errorf vem do pacote padrão "testing"
 	if len(mockRepo.produtos) != 1 {
@@ -74,13 +121,131 @@ func TestVenderProdutoComEstoqueInsuficiente(t *testing.T) {
 	mockRepo := &mockRepositorioEstoque{}
 	servico := NovoServicoEstoque(mockRepo)
 
-	mockRepo.Adicionar(NovoProduto("areia", 5))
+	areia := NovoProduto("areia", 5)
+	mockRepo.Adicionar(areia)
 
-	err := servico.VenderProduto("areia", 10) // VenderProduto vem do arquivo servico.go
+	err := servico.VenderProduto(context.Background(), areia.ID, 10) // VenderProduto vem do arquivo servico.go, e busca o produto pelo ID
 
-	if err != nil {
-		t.Errorf("Esperava erro de estoque insuficiente, mas não recebi erro")
+	if !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Errorf("Esperava erro de estoque insuficiente, mas recebi %v", err)
 	}
 }
 
+func TestVenderProdutoAtualizaRepositorio(t *testing.T) {
+	mockRepo := &mockRepositorioEstoque{}
+	servico := NovoServicoEstoque(mockRepo)
+
+	viga := NovoProduto("viga", 10)
+	mockRepo.Adicionar(viga)
 
+	if err := servico.VenderProduto(context.Background(), viga.ID, 4); err != nil {
+		t.Fatalf("Não esperava erro ao vender, mas recebi %v", err)
+	}
+
+	if mockRepo.produtos[0].Quantidade != 6 { // a venda precisa ser gravada no repositório
+		t.Errorf("Esperava 6 vigas após a venda, mas encontrei %d", mockRepo.produtos[0].Quantidade)
+	}
+}
+
+func TestTransferirEntreLocais(t *testing.T) {
+	mockRepo := &mockRepositorioEstoque{}
+	servico := NovoServicoEstoque(mockRepo)
+
+	coluna := NovoProduto("coluna", 10) // NovoProduto coloca o saldo inicial no LocalPadrao (pátio)
+	mockRepo.Adicionar(coluna)
+
+	if err := servico.Transferir(context.Background(), coluna.ID, LocalPatio, LocalLoja, 3); err != nil { // Transferir vem do arquivo servico.go
+		t.Fatalf("Não esperava erro ao transferir, mas recebi %v", err)
+	}
+
+	atualizado := mockRepo.produtos[0]
+	if atualizado.QuantidadeNoLocal(LocalPatio) != 7 || atualizado.QuantidadeNoLocal(LocalLoja) != 3 {
+		t.Errorf("Esperava 7 no pátio e 3 na loja, mas encontrei %v", atualizado.Locais)
+	}
+	if atualizado.Quantidade != 10 { // transferência não muda o total
+		t.Errorf("Esperava total 10, mas encontrei %d", atualizado.Quantidade)
+	}
+
+	// transferir mais do que existe na loja não pode alterar nada
+	err := servico.Transferir(context.Background(), coluna.ID, LocalLoja, LocalPatio, 5)
+	if !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Errorf("Esperava erro de estoque insuficiente, mas recebi %v", err)
+	}
+	if mockRepo.produtos[0].QuantidadeNoLocal(LocalLoja) != 3 {
+		t.Errorf("A transferência inválida alterou o estoque da loja: %v", mockRepo.produtos[0].Locais)
+	}
+}
+
+func TestTotaisPorLocal(t *testing.T) {
+	mockRepo := &mockRepositorioEstoque{}
+	servico := NovoServicoEstoque(mockRepo)
+
+	viga := NovoProduto("viga", 10)
+	viga.Transferir(LocalPatio, LocalLoja, 4) // Transferir vem do arquivo local.go
+	mockRepo.Adicionar(viga)
+	mockRepo.Adicionar(NovoProduto("cobogo flor", 5))
+
+	totais := servico.TotaisPorLocal()
+	esperado := []SaldoLocal{{Local: LocalLoja, Quantidade: 4}, {Local: LocalPatio, Quantidade: 11}}
+	if len(totais) != len(esperado) || totais[0] != esperado[0] || totais[1] != esperado[1] {
+		t.Errorf("Esperava %v, mas encontrei %v", esperado, totais)
+	}
+
+	if servico.TotalGeral() != 15 {
+		t.Errorf("Esperava total geral 15, mas encontrei %d", servico.TotalGeral())
+	}
+}
+
+
+
+func TestVenderProdutoIgnoraLotesEmCura(t *testing.T) {
+	mockRepo := &mockRepositorioEstoque{}
+	servico := NovoServicoEstoque(mockRepo)
+	hoje := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	servico.agora = func() time.Time { return hoje } // relógio fixo para o teste
+
+	viga := NovoProduto("viga", 0)
+	mockRepo.Adicionar(viga)
+
+	// lote antigo já curado e lote novo ainda em cura (NovoLote vem do arquivo lote.go)
+	servico.RegistrarLote(context.Background(), viga.ID, NovoLote("L1", hoje.AddDate(0, 0, -30), 28, 5))
+	servico.RegistrarLote(context.Background(), viga.ID, NovoLote("L2", hoje.AddDate(0, 0, -3), 28, 10))
+
+	if err := servico.VenderProduto(context.Background(), viga.ID, 6); !errors.Is(err, ErrEstoqueInsuficiente) {
+		t.Errorf("Esperava erro de estoque insuficiente (só 5 curadas), mas recebi %v", err)
+	}
+
+	if err := servico.VenderProduto(context.Background(), viga.ID, 5); err != nil {
+		t.Fatalf("Não esperava erro ao vender o lote curado, mas recebi %v", err)
+	}
+
+	lotes := servico.EstoquePorLote()
+	if len(lotes) != 1 || lotes[0].Codigo != "L2" || lotes[0].Quantidade != 10 || lotes[0].Curado {
+		t.Errorf("Esperava apenas o lote L2 em cura com 10 unidades, mas encontrei %+v", lotes)
+	}
+}
+
+func TestVenderProdutoComPoliticaFEFO(t *testing.T) {
+	mockRepo := &mockRepositorioEstoque{}
+	servico := NovoServicoEstoque(mockRepo)
+	hoje := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	servico.agora = func() time.Time { return hoje }
+	servico.DefinirPoliticaLotes(PoliticaFEFO)
+
+	estaca := NovoProduto("estaca curvada", 0)
+	mockRepo.Adicionar(estaca)
+
+	// L1 foi produzido antes, mas L2 tem cura mais curta e ficou pronto primeiro
+	servico.RegistrarLote(context.Background(), estaca.ID, NovoLote("L1", hoje.AddDate(0, 0, -20), 14, 4))
+	servico.RegistrarLote(context.Background(), estaca.ID, NovoLote("L2", hoje.AddDate(0, 0, -15), 7, 4))
+
+	if err := servico.VenderProduto(context.Background(), estaca.ID, 3); err != nil {
+		t.Fatalf("Não esperava erro ao vender, mas recebi %v", err)
+	}
+
+	for _, lote := range mockRepo.produtos[0].Lotes {
+		if lote.Codigo == "L2" && lote.Quantidade != 1 {
+			t.Errorf("Esperava que a venda saísse do lote L2 (FEFO), mas L2 ficou com %d", lote.Quantidade)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package estoque
+
+import (
+	"encoding/json"  // pacote para gerar o relatório em JSON
+	"fmt"            // pacote para formatação de strings
+	"io"             // pacote com a interface de escrita
+	"strconv"        // pacote para converter números em texto
+	"strings"        // pacote para montar a linha separadora
+	"text/tabwriter" // pacote para alinhar as colunas do relatório em texto
+)
+
+// FormatoRelatorio indica como um relatório é apresentado
+type FormatoRelatorio string
+
+const (
+	RelatorioTexto FormatoRelatorio = "texto" // tabela alinhada para o terminal
+	RelatorioCSV   FormatoRelatorio = "csv"   // para abrir em planilhas
+	RelatorioJSON  FormatoRelatorio = "json"  // para outros sistemas
+)
+
+// Tabela é um relatório pronto para ser apresentado em texto, CSV ou JSON
+type Tabela struct {
+	Titulo  string
+	Colunas []string
+	Linhas  [][]string
+	Dados   any // dados originais do relatório, usados no JSON para manter os números como números
+}
+
+// Escrever apresenta a tabela no formato escolhido
+func (t Tabela) Escrever(w io.Writer, formato FormatoRelatorio) error {
+	switch formato {
+	case RelatorioTexto:
+		return t.escreverTexto(w)
+	case RelatorioCSV:
+		return EscreverPlanilha(w, FormatoCSV, append([][]string{t.Colunas}, t.Linhas...)) // EscreverPlanilha vem do arquivo planilha.go
+	case RelatorioJSON:
+		codificador := json.NewEncoder(w)
+		codificador.SetIndent("", " ")
+		return codificador.Encode(t.Dados)
+	}
+	return ErrFormatoInvalido
+}
+
+// escreverTexto alinha as colunas com tabwriter, como uma tabela no terminal
+func (t Tabela) escreverTexto(w io.Writer) error {
+	if t.Titulo != "" {
+		fmt.Fprintf(w, "%s\n\n", t.Titulo)
+	}
+
+	tabela := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabela, strings.Join(t.Colunas, "\t"))
+	separadores := make([]string, len(t.Colunas))
+	for i, coluna := range t.Colunas {
+		separadores[i] = strings.Repeat("-", len([]rune(coluna)))
+	}
+	fmt.Fprintln(tabela, strings.Join(separadores, "\t"))
+	for _, linha := range t.Linhas {
+		fmt.Fprintln(tabela, strings.Join(linha, "\t"))
+	}
+	return tabela.Flush()
+}
+
+// TabelaValorizacao monta o relatório de valorização com uma linha de total no final
+func TabelaValorizacao(valores []ValorEstoque, metodo MetodoCusto) Tabela {
+	tabela := Tabela{
+		Titulo:  "Valorização do estoque (" + string(metodo) + ")",
+		Colunas: []string{"produto", "quantidade", "custo_unitario", "valor"},
+		Dados:   valores,
+	}
+	total := 0.0
+	for _, v := range valores {
+		tabela.Linhas = append(tabela.Linhas, []string{v.Produto, strconv.Itoa(v.Quantidade), formatarDecimal(v.CustoUnitario), formatarDecimal(v.Valor)})
+		total += v.Valor
+	}
+	tabela.Linhas = append(tabela.Linhas, []string{"TOTAL", "", "", formatarDecimal(total)})
+	return tabela
+}
+
+// TabelaCurvaABC monta o relatório da curva ABC
+func TabelaCurvaABC(classes []ClasseABC) Tabela {
+	tabela := Tabela{
+		Titulo:  "Curva ABC por volume de vendas",
+		Colunas: []string{"produto", "vendido", "percentual", "acumulado", "classe"},
+		Dados:   classes,
+	}
+	for _, c := range classes {
+		tabela.Linhas = append(tabela.Linhas, []string{c.Produto, strconv.Itoa(c.QuantidadeVendida), formatarPercentual(c.Percentual), formatarPercentual(c.PercentualAcumulado), c.Classe})
+	}
+	return tabela
+}
+
+// TabelaGiro monta o relatório de giro e dias de cobertura
+func TabelaGiro(giros []GiroEstoque) Tabela {
+	tabela := Tabela{
+		Titulo:  "Giro e dias de cobertura",
+		Colunas: []string{"produto", "vendido", "estoque_atual", "estoque_medio", "giro", "dias_cobertura"},
+		Dados:   giros,
+	}
+	for _, g := range giros {
+		cobertura := "sem vendas"
+		if g.DiasCobertura != nil {
+			cobertura = strconv.FormatFloat(*g.DiasCobertura, 'f', 1, 64)
+		}
+		tabela.Linhas = append(tabela.Linhas, []string{g.Produto, strconv.Itoa(g.QuantidadeVendida), strconv.Itoa(g.EstoqueAtual), strconv.FormatFloat(g.EstoqueMedio, 'f', 1, 64), formatarDecimal(g.Giro), cobertura})
+	}
+	return tabela
+}
+
+// TabelaReposicao monta o relatório de reposição com a quantidade sugerida para cada produto
+func TabelaReposicao(sugestoes []SugestaoReposicao) Tabela {
+	tabela := Tabela{
+		Titulo:  "Reposição de estoque",
+		Colunas: []string{"produto", "fornecedor", "estoque_atual", "em_pedido", "minimo", "maximo", "sugerido"},
+		Dados:   sugestoes,
+	}
+	for _, r := range sugestoes {
+		fornecedor := r.FornecedorID
+		if fornecedor == "" {
+			fornecedor = "sem fornecedor"
+		}
+		tabela.Linhas = append(tabela.Linhas, []string{r.Produto, fornecedor, strconv.Itoa(r.Quantidade), strconv.Itoa(r.EmPedido), strconv.Itoa(r.EstoqueMinimo), strconv.Itoa(r.EstoqueMaximo), strconv.Itoa(r.Sugerido)})
+	}
+	return tabela
+}
+
+// formatarDecimal formata números com duas casas decimais
+func formatarDecimal(valor float64) string {
+	return strconv.FormatFloat(valor, 'f', 2, 64)
+}
+
+// formatarPercentual formata uma fração (0 a 1) como percentual
+func formatarPercentual(fracao float64) string {
+	return strconv.FormatFloat(fracao*100, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package estoque
+
+import (
+	"os"      // serve para abrir o arquivo da trava
+	"syscall" // serve para a trava exclusiva (flock) entre processos
+)
+
+// travarArquivo pega a trava exclusiva do arquivo informado, esperando se outro processo estiver com ela
+// A trava é do sistema operacional: some sozinha se o processo cair, sem deixar arquivo preso
+func travarArquivo(caminho string) (func(), error) {
+	arquivo, err := os.OpenFile(caminho, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(arquivo.Fd()), syscall.LOCK_EX); err != nil {
+		arquivo.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(arquivo.Fd()), syscall.LOCK_UN)
+		arquivo.Close()
+	}, nil
+}
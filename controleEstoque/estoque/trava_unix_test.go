@@ -0,0 +1,43 @@
+//go:build unix
+
+package estoque
+
+import (
+	"context"       // pacote padrão para passar o ator das operações
+	"path/filepath" // pacote padrão para montar o caminho do arquivo temporário
+	"sync"          // pacote padrão para disparar as vendas em paralelo
+	"testing"       // pacote padrão do Go para testes
+)
+
+func TestArquivoCompartilhadoPorDoisProcessos(t *testing.T) {
+	// dois processos da CLI: cada um com seu repositório (e seu mutex) sobre o mesmo arquivo
+	caminho := filepath.Join(t.TempDir(), "estoque.json")
+	primeiro := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	segundo := NovoServicoEstoque(NovoRepositorioArquivo(caminho))
+	primeiro.DefinirTentativas(1000) // as vendas que perdem a corrida são refeitas até passar
+	segundo.DefinirTentativas(1000)
+	telha := NovoProduto("telha", 100)
+	primeiro.CadastrarProduto(context.Background(), telha)
+
+	var grupo sync.WaitGroup
+	for _, servico := range []*ServicoEstoque{primeiro, segundo} {
+		for i := 0; i < 20; i++ {
+			grupo.Add(1)
+			go func() {
+				defer grupo.Done()
+				if err := servico.VenderProduto(context.Background(), telha.ID, 1); err != nil {
+					t.Errorf("Não esperava erro na venda, mas recebi %v", err)
+				}
+			}()
+		}
+	}
+	grupo.Wait()
+
+	// sem a trava entre processos, os dois aceitariam a mesma versão e uma venda apagaria a outra
+	if produto, _ := NovoRepositorioArquivo(caminho).Buscar(telha.ID); produto.Quantidade != 60 {
+		t.Errorf("Esperava 60 telhas depois de 40 vendas, mas encontrei %d", produto.Quantidade)
+	}
+	if movimentos := primeiro.ListarMovimentos(); len(movimentos) != 41 {
+		t.Errorf("Esperava 41 movimentos (cadastro e vendas), mas encontrei %d", len(movimentos))
+	}
+}
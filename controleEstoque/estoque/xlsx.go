@@ -0,0 +1,250 @@
+package estoque
+
+import (
+	"archive/zip"  // um arquivo xlsx é um zip com vários arquivos XML dentro
+	"bytes"        // pacote para ler o zip a partir da memória
+	"encoding/xml" // pacote para ler e escrever XML
+	"errors"       // pacote para manipulação de erros
+	"io"           // pacote com as interfaces de leitura e escrita
+	"path"         // pacote para resolver o caminho da aba dentro do zip
+	"sort"         // pacote para escolher a primeira aba de um xlsx sem workbook.xml
+	"strconv"      // pacote para converter o número da linha
+	"strings"      // pacote para manipular textos
+)
+
+// Erro para indicar que o arquivo xlsx não tem nenhuma aba de planilha
+var ErrPlanilhaVazia = errors.New("planilha xlsx sem abas")
+
+// Estruturas mínimas do formato SpreadsheetML usadas para ler um xlsx
+type xlsxTexto struct {
+	Texto   string `xml:"t"`
+	Trechos []struct {
+		Texto string `xml:"t"`
+	} `xml:"r"` // textos com formatação ficam divididos em trechos
+}
+
+type xlsxCelula struct {
+	Referencia string    `xml:"r,attr"` // ex: B3
+	Tipo       string    `xml:"t,attr"` // s = texto compartilhado, inlineStr = texto na própria célula
+	Valor      string    `xml:"v"`
+	Inline     xlsxTexto `xml:"is"`
+}
+
+type xlsxAba struct {
+	Linhas []struct {
+		Celulas []xlsxCelula `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxTextosCompartilhados struct {
+	Itens []xlsxTexto `xml:"si"`
+}
+
+// xlsxPastaDeTrabalho é o xl/workbook.xml: as abas na ordem em que aparecem no Excel
+type xlsxPastaDeTrabalho struct {
+	Abas []struct {
+		Nome    string `xml:"name,attr"`
+		Relacao string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"` // r:id
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelacoes é o xl/_rels/workbook.xml.rels: o arquivo de cada r:id do workbook.xml
+type xlsxRelacoes struct {
+	Relacoes []struct {
+		ID   string `xml:"Id,attr"`
+		Alvo string `xml:"Target,attr"` // relativo a xl/ (ex: worksheets/sheet1.xml) ou absoluto (/xl/...)
+	} `xml:"Relationship"`
+}
+
+// texto junta o texto simples com os trechos formatados
+func (t xlsxTexto) texto() string {
+	var b strings.Builder
+	b.WriteString(t.Texto)
+	for _, trecho := range t.Trechos {
+		b.WriteString(trecho.Texto)
+	}
+	return b.String()
+}
+
+// lerXLSX lê a primeira aba de um arquivo xlsx (a primeira da esquerda no Excel, não a de menor nome no zip)
+func lerXLSX(dados []byte) ([][]string, error) {
+	arquivo, err := zip.NewReader(bytes.NewReader(dados), int64(len(dados)))
+	if err != nil {
+		return nil, err
+	}
+
+	var compartilhados xlsxTextosCompartilhados
+	arquivos := map[string]*zip.File{}
+	for _, f := range arquivo.File {
+		arquivos[f.Name] = f
+		if f.Name == "xl/sharedStrings.xml" {
+			if err := lerXMLDoZip(f, &compartilhados); err != nil {
+				return nil, err
+			}
+		}
+	}
+	primeira, err := primeiraAba(arquivo.File, arquivos)
+	if err != nil {
+		return nil, err
+	}
+
+	var aba xlsxAba
+	if err := lerXMLDoZip(primeira, &aba); err != nil {
+		return nil, err
+	}
+
+	linhas := make([][]string, 0, len(aba.Linhas))
+	for _, linha := range aba.Linhas {
+		var valores []string
+		for i, celula := range linha.Celulas {
+			coluna := i
+			if celula.Referencia != "" {
+				coluna = colunaDaReferencia(celula.Referencia) // células vazias não aparecem no XML
+			}
+			for len(valores) < coluna {
+				valores = append(valores, "")
+			}
+
+			valor := celula.Valor
+			switch celula.Tipo {
+			case "s":
+				indice, _ := strconv.Atoi(celula.Valor)
+				if indice >= 0 && indice < len(compartilhados.Itens) {
+					valor = compartilhados.Itens[indice].texto()
+				}
+			case "inlineStr":
+				valor = celula.Inline.texto()
+			}
+			valores = append(valores, valor)
+		}
+		linhas = append(linhas, valores)
+	}
+	return linhas, nil
+}
+
+// escreverXLSX grava as linhas em um xlsx com uma única aba, todas as células como texto
+// Células de texto preservam zeros à esquerda de SKUs e códigos de lote
+func escreverXLSX(w io.Writer, linhas [][]string) error {
+	var aba bytes.Buffer
+	aba.WriteString(xml.Header)
+	aba.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, linha := range linhas {
+		aba.WriteString(`<row r="` + strconv.Itoa(i+1) + `">`)
+		for j, valor := range linha {
+			aba.WriteString(`<c r="` + referenciaDaColuna(j) + strconv.Itoa(i+1) + `" t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(&aba, []byte(valor))
+			aba.WriteString(`</t></is></c>`)
+		}
+		aba.WriteString(`</row>`)
+	}
+	aba.WriteString(`</sheetData></worksheet>`)
+
+	arquivos := []struct {
+		nome     string
+		conteudo string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Planilha1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", aba.String()},
+	}
+
+	compactador := zip.NewWriter(w)
+	for _, arquivo := range arquivos {
+		f, err := compactador.Create(arquivo.nome)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, arquivo.conteudo); err != nil {
+			return err
+		}
+	}
+	return compactador.Close()
+}
+
+// primeiraAba acha o arquivo da primeira aba pelo xl/workbook.xml e pelas relações dele
+// Abas reordenadas no Excel mantêm o nome do arquivo (ex: sheet3.xml pode ser a primeira)
+// Um xlsx sem workbook.xml (gerado por ferramentas simples) usa o arquivo de menor nome em xl/worksheets/
+func primeiraAba(todos []*zip.File, arquivos map[string]*zip.File) (*zip.File, error) {
+	pasta, relacoes := arquivos["xl/workbook.xml"], arquivos["xl/_rels/workbook.xml.rels"]
+	if pasta != nil && relacoes != nil {
+		var conteudo xlsxPastaDeTrabalho
+		if err := lerXMLDoZip(pasta, &conteudo); err != nil {
+			return nil, err
+		}
+		if len(conteudo.Abas) == 0 {
+			return nil, ErrPlanilhaVazia
+		}
+		var alvos xlsxRelacoes
+		if err := lerXMLDoZip(relacoes, &alvos); err != nil {
+			return nil, err
+		}
+		for _, relacao := range alvos.Relacoes {
+			if relacao.ID != conteudo.Abas[0].Relacao {
+				continue
+			}
+			nome := path.Join("xl", relacao.Alvo)
+			if strings.HasPrefix(relacao.Alvo, "/") {
+				nome = strings.TrimPrefix(path.Clean(relacao.Alvo), "/")
+			}
+			if aba := arquivos[nome]; aba != nil {
+				return aba, nil
+			}
+		}
+		return nil, ErrPlanilhaVazia // o workbook.xml aponta para uma aba que não está no zip
+	}
+
+	var abas []*zip.File
+	for _, f := range todos {
+		if strings.HasPrefix(f.Name, "xl/worksheets/") && strings.HasSuffix(f.Name, ".xml") {
+			abas = append(abas, f)
+		}
+	}
+	if len(abas) == 0 {
+		return nil, ErrPlanilhaVazia
+	}
+	sort.Slice(abas, func(i, j int) bool { return abas[i].Name < abas[j].Name }) // sheet1.xml vem primeiro
+	return abas[0], nil
+}
+
+// lerXMLDoZip decodifica um arquivo XML de dentro do zip
+func lerXMLDoZip(f *zip.File, destino any) error {
+	leitor, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer leitor.Close()
+	return xml.NewDecoder(leitor).Decode(destino)
+}
+
+// colunaDaReferencia converte a referência da célula (ex: "AB12") no índice da coluna (ex: 27)
+func colunaDaReferencia(referencia string) int {
+	coluna := 0
+	for _, r := range referencia {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		coluna = coluna*26 + int(r-'A'+1)
+	}
+	return coluna - 1
+}
+
+// referenciaDaColuna converte o índice da coluna (ex: 27) nas letras do Excel (ex: "AB")
+func referenciaDaColuna(coluna int) string {
+	letras := ""
+	for coluna++; coluna > 0; coluna = (coluna - 1) / 26 {
+		letras = string(rune('A'+(coluna-1)%26)) + letras
+	}
+	return letras
+}
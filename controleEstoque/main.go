@@ -2,14 +2,28 @@ package main
 
 import (
 	"controleEstoque/estoque"
+	"fmt"
+	"os"
+	"time"
 )
 
 // Função principal do programa
 func main() {
 
+	// Com argumentos, o programa funciona como linha de comando (comandos.go), ex: go run . importar -arquivo produtos.csv
+	if len(os.Args) > 1 {
+		if err := executarComando(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Println("Erro:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	repo := estoque.NovoRepositorioArquivo("estoque.json") // cria um novo repositório de estoque em arquivo chamando a função NovoRepositorioArquivo do pacote arquivo.go, passando o nome do arquivo onde os dados serão armazenados
 	
 	servico := estoque.NovoServicoEstoque(repo) // cria um novo serviço de estoque passando o repositório como parâmetro
+	servico.DefinirAuditoria(estoque.NovoRepositorioAuditoriaArquivo("estoque.json")) // grava quem alterou o estoque em estoque.auditoria.jsonl
+	ctx := contextoDoUsuario() // contextoDoUsuario vem do arquivo comandos.go
 
 	// Criando alguns produtos usando a função NovoProduto
 	viga := estoque.NovoProduto("viga", 11) // cria um novo produto chamando a função NovoProduto
@@ -26,13 +40,24 @@ func main() {
 	cobogoArabe.AumentarQuantidade(3) 
 
 		// Adicionando os produtos ao estoque
-		servico.CadastrarProduto(viga) // Chama o método CadastrarProduto do serviço para adicionar o produto viga
-		servico.CadastrarProduto(coluna)
-		servico.CadastrarProduto(estacaTipoMourao)
-		servico.CadastrarProduto(estacaCurvada)
-		servico.CadastrarProduto(cobogoFlor)
-		servico.CadastrarProduto(cobogoArabe)
+		servico.CadastrarProduto(ctx, viga) // Chama o método CadastrarProduto do serviço para adicionar o produto viga
+		servico.CadastrarProduto(ctx, coluna)
+		servico.CadastrarProduto(ctx, estacaTipoMourao)
+		servico.CadastrarProduto(ctx, estacaCurvada)
+		servico.CadastrarProduto(ctx, cobogoFlor)
+		servico.CadastrarProduto(ctx, cobogoArabe)
+
+
+	// Transferindo produtos do pátio da fábrica para a loja
+	if err := servico.Transferir(ctx, cobogoFlor.ID, estoque.LocalPatio, estoque.LocalLoja, 10); err != nil {
+		println("Erro ao transferir:", err.Error())
+	}
 
+	// Registrando um lote de vigas produzido hoje, que só pode ser vendido após 28 dias de cura
+	loteViga := estoque.NovoLote("VIGA-"+time.Now().Format("20060102"), time.Now(), 28, 20)
+	if err := servico.RegistrarLote(ctx, viga.ID, loteViga); err != nil {
+		println("Erro ao registrar lote:", err.Error())
+	}
 
 	// Listando os produtos no estoque
 	produtos := servico.ListarEstoque() // Chama o método ListarProdutos do serviço para obter a lista de produtos
@@ -42,5 +67,17 @@ func main() {
 		println("Produto:", produto.Nome, "Quantidade:", produto.Quantidade)
 	}
 
+	// Imprimindo os totais por local e o total geral
+	for _, saldo := range servico.TotaisPorLocal() {
+		println("Local:", saldo.Local, "Quantidade:", saldo.Quantidade)
+	}
+	println("Total geral:", servico.TotalGeral())
+
+	// Imprimindo o estoque por lote
+	for _, lote := range servico.EstoquePorLote() {
+		fmt.Printf("Lote: %s | Produto: %s | Local: %s | Quantidade: %d | Cura: %s | Curado: %t\n",
+			lote.Codigo, lote.Produto, lote.Local, lote.Quantidade, lote.DataCura.Format("02/01/2006"), lote.Curado)
+	}
+
 }
 
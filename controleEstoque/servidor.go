@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"controleEstoque/estoque"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// comandoServidor sobe o modo servidor: vendas e consulta do estoque por HTTP, com logs estruturados e métricas
+// Endpoints: GET /produtos, POST /vendas e GET /metrics (formato do Prometheus)
+// Exemplo: go run . servidor -endereco :8080 -log json
+func comandoServidor(argumentos []string) error {
+	flags := flag.NewFlagSet("servidor", flag.ContinueOnError)
+	caminhoEstoque := flags.String("estoque", "estoque.json", "arquivo JSON do estoque ou diretório de eventos")
+	endereco := flags.String("endereco", ":8080", "endereço em que o servidor escuta")
+	formatoLog := flags.String("log", "texto", "formato dos logs: texto ou json")
+	detalhado := flags.Bool("detalhado", false, "também registra cada chamada ao repositório (nível debug)")
+	if err := flags.Parse(argumentos); err != nil {
+		return err
+	}
+
+	opcoes := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if *detalhado {
+		opcoes.Level = slog.LevelDebug
+	}
+	var logger *slog.Logger
+	switch *formatoLog {
+	case "texto":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opcoes))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opcoes))
+	default:
+		return fmt.Errorf("formato de log inválido: %s", *formatoLog)
+	}
+
+	// o repositório e o serviço são envolvidos pelos decoradores de observabilidade (observado.go)
+	repo := abrirRepositorio(*caminhoEstoque)
+	metricas := estoque.NovasMetricas()
+	metricas.AcompanharEstoque(repo) // saldo por produto lido direto do repositório original
+	base, err := montarServico(estoque.NovoRepositorioObservado(repo, logger, metricas), *caminhoEstoque) // retoma a contagem aberta (comandos.go)
+	if err != nil {
+		return err
+	}
+	base.DefinirAvisoAuditoria(func(ctx context.Context, operacao string, err error) {
+		logger.ErrorContext(ctx, "trilha de auditoria não gravada", slog.String("operacao", operacao), slog.String("erro", err.Error())) // a operação já valeu: o cliente recebe sucesso
+	})
+	servico := estoque.NovoServicoObservado(base, logger, metricas)
+
+	rotas := http.NewServeMux()
+	rotas.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metricas.EscreverPrometheus(w)
+	})
+	rotas.HandleFunc("/produtos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		responderJSON(w, http.StatusOK, servico.ListarEstoque())
+	})
+	rotas.HandleFunc("/vendas", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		// a contagem pode ter sido aberta ou encerrada pela linha de comando depois que o servidor subiu
+		contagem, err := lerContagem(*caminhoEstoque) // lerContagem vem do arquivo comandos.go
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		base.AcompanharContagem(contagem)
+		venderPorHTTP(servico, w, r)
+	})
+
+	servidor := &http.Server{Addr: *endereco, Handler: rotas, ReadHeaderTimeout: 10 * time.Second}
+	ctx, parar := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer parar()
+	go func() {
+		<-ctx.Done() // Ctrl+C encerra o servidor terminando as requisições em andamento
+		desligar, cancelar := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelar()
+		servidor.Shutdown(desligar)
+	}()
+
+	logger.Info("servidor iniciado", slog.String("endereco", *endereco), slog.String("estoque", *caminhoEstoque))
+	if err := servidor.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// pedidoVenda é o corpo do POST /vendas: o produto pelo ID ou pelo código de barras
+type pedidoVenda struct {
+	ProdutoID  string `json:"produto_id"`
+	GTIN       string `json:"gtin"`
+	Local      string `json:"local"`
+	Quantidade int    `json:"quantidade"`
+}
+
+// venderPorHTTP registra a venda do pedido; o usuário vem do cabeçalho X-Usuario para a auditoria
+func venderPorHTTP(servico *estoque.ServicoObservado, w http.ResponseWriter, r *http.Request) {
+	var pedido pedidoVenda
+	if err := json.NewDecoder(r.Body).Decode(&pedido); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := estoque.ComAtor(r.Context(), estoque.Ator{Usuario: r.Header.Get("X-Usuario"), Sistema: "http"})
+
+	if pedido.GTIN != "" {
+		produto, err := servico.VenderPorGTIN(ctx, pedido.GTIN, pedido.Local, pedido.Quantidade) // já devolve o produto depois da venda
+		if err != nil {
+			http.Error(w, err.Error(), statusDoErro(err))
+			return
+		}
+		responderJSON(w, http.StatusOK, produto)
+		return
+	}
+
+	if err := servico.VenderProdutoNoLocal(ctx, pedido.ProdutoID, pedido.Local, pedido.Quantidade); err != nil {
+		http.Error(w, err.Error(), statusDoErro(err))
+		return
+	}
+	produto, err := servico.BuscarProduto(pedido.ProdutoID)
+	if err != nil {
+		http.Error(w, err.Error(), statusDoErro(err))
+		return
+	}
+	responderJSON(w, http.StatusOK, produto)
+}
+
+// statusDoErro converte o tipo do erro (o mesmo usado nas métricas) no status HTTP
+func statusDoErro(err error) int {
+	switch estoque.ClassificarErro(err) {
+	case estoque.ErroTipoNaoEncontrado:
+		return http.StatusNotFound
+	case estoque.ErroTipoValorInvalido:
+		return http.StatusBadRequest
+	case estoque.ErroTipoEstoqueInsuficiente, estoque.ErroTipoContagemAberta, estoque.ErroTipoConflito:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// responderJSON escreve a resposta em JSON com o status informado
+func responderJSON(w http.ResponseWriter, status int, dados any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dados)
+}
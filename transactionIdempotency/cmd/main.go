@@ -1,31 +1,198 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"transactionIdempotency/internal/transaction"
 )
 
 // Ponto de entrada do aplicativo | Application entry point
 func main() {
-	repo := transaction.NewRepository()
-	service := transaction.NewService(repo)
+	store := flag.String("store", "file", "armazenamento: memory, file ou sqlite | store: memory, file or sqlite")
+	path := flag.String("path", "transactions.wal", "arquivo do armazenamento file/sqlite | file/sqlite store path")
+	retention := flag.Duration("retention", 0, "retenção das chaves, ex: 24h ou 720h; 0 guarda para sempre | key retention, e.g. 24h or 720h; 0 keeps forever")
+	addr := flag.String("http", "", "endereço para servir POST /transacoes com Idempotency-Key, ex: :8080 | address to serve POST /transacoes with Idempotency-Key, e.g. :8080")
+	flag.Parse()
+
+	repo, err := openRepository(*store, *path)
+	if err != nil {
+		fmt.Printf("❌ Erro ao abrir o armazenamento: %s\n", err)
+		return
+	}
+	defer repo.Close()
+
+	ledgerStore, err := openLedgerStore(*store, *path, repo)
+	if err != nil {
+		fmt.Printf("❌ Erro ao abrir o razão: %s\n", err)
+		return
+	}
+	defer ledgerStore.Close()
+	ledger := transaction.NewLedger(ledgerStore, nil)
+
+	// a limpeza das chaves vencidas roda uma vez na partida; no modo -http continua a cada hora | the expired key cleanup runs once at startup; in -http mode it keeps running every hour
+	janitor := transaction.NewJanitor(repo, time.Hour, nil)
+	if _, err := janitor.RunOnce(); err != nil {
+		fmt.Printf("❌ Erro ao remover chaves vencidas: %s\n", err)
+		return
+	}
+
+	service := transaction.NewService(repo,
+		transaction.WithRetention(transaction.KeepFor(*retention)),
+		transaction.WithHandler(post(ledger)),
+	)
+	relay := transaction.NewOutboxRelay(repo, transaction.PublisherFunc(printMessage), nil)
+
+	if *addr != "" {
+		serve(*addr, service, relay, janitor)
+		return
+	}
 
 	t := transaction.Transaction{
 		ID: "tx84",
 		Customer: "Duarte",
-		Amount: 1000000.00,
+		Amount: transaction.BRL(1000000_00),
 	}
 
-	err := service.Process(t)
+	result, err := service.Process(t)
+	var invalid *transaction.ValidationError
+	if errors.As(err, &invalid) {
+		fmt.Printf("❌ Transação inválida: %s\n", err)
+		return
+	}
+	if errors.Is(err, transaction.ErrIdempotencyKeyMismatch) {
+		fmt.Printf("❌ Erro: %s\n", err)
+		fmt.Printf("   O ID '%s' já foi usado por uma transação com outro conteúdo.\n", t.ID)
+		return
+	}
 	if err != nil {
 		fmt.Printf("❌ Erro: %s\n", err)
-		fmt.Printf("   A transação com ID '%s' já foi processada anteriormente.\n", t.ID)
 		return
 	}
 
-	fmt.Println("✅ Transação processada com sucesso!")
+	if result.Replayed {
+		fmt.Println("♻️  Transação já processada: resultado original devolvido.")
+		fmt.Printf("   Processada em: %s\n", result.ProcessedAt.Local().Format("02/01/2006 15:04:05"))
+	} else if result.Status == transaction.StatusSucceeded {
+		fmt.Println("✅ Transação processada com sucesso!")
+	} else {
+		fmt.Printf("❌ Transação recusada: %s\n", result.Err())
+	}
+	fmt.Printf("   Situação: %s\n", result.Status)
 	fmt.Printf("   ID: %s\n", t.ID)
 	fmt.Printf("   Cliente: %s\n", t.Customer)
-	fmt.Printf("   Valor: R$ %.2f\n", t.Amount)
+	fmt.Printf("   Valor: %s (%s)\n", t.Amount, t.Kind())
+	if balance, err := ledger.Balance(t.Customer, t.Amount.Currency); err == nil {
+		fmt.Printf("   Saldo: %s\n", balance)
+	}
+	if len(result.Result) > 0 {
+		fmt.Printf("   Resposta: %s\n", result.Result)
+	}
+
+	// publica também o que ficou pendente de execuções que caíram | also publishes what was left pending by runs that crashed
+	if _, err := relay.DeliverPending(context.Background(), 100); err != nil {
+		fmt.Printf("❌ Erro ao publicar o outbox: %s\n", err)
+	}
+}
+
+// post é o handler de exemplo: lança a transação no razão e avisa o banco pelo outbox | post is the sample handler: it posts the transaction to the ledger and notifies the bank through the outbox
+func post(ledger *transaction.Ledger) transaction.Handler {
+	return transaction.HandlerFunc(func(ctx context.Context, t transaction.Transaction) (transaction.Effects, error) {
+		effects, err := ledger.Handle(ctx, t)
+		if err != nil {
+			return effects, err
+		}
+		effects.Messages = append(effects.Messages, transaction.Message{
+			Topic:   "banco." + string(t.Kind()),
+			Payload: []byte(fmt.Sprintf(`{"cliente":%q,"centavos":%d,"moeda":%q}`, t.Customer, t.Amount.Cents, t.Amount.Currency)),
+		})
+		return effects, nil
+	})
+}
+
+// printMessage é o publisher de exemplo: mostra a mensagem no terminal | printMessage is the sample publisher: it shows the message on the terminal
+func printMessage(ctx context.Context, m transaction.Message) error {
+	fmt.Printf("📨 Mensagem %s publicada em %s: %s\n", m.ID, m.Topic, m.Payload)
+	return nil
+}
+
+// openRepository escolhe o armazenamento; com file ou sqlite os IDs sobrevivem a reinícios | openRepository picks the store; with file or sqlite the IDs survive restarts
+func openRepository(store, path string) (transaction.Repository, error) {
+	switch store {
+	case "memory":
+		return transaction.NewMemoryRepository(), nil
+	case "file":
+		return transaction.OpenFileRepository(path)
+	case "sqlite":
+		return transaction.OpenSQLiteRepository(path)
+	}
+	return nil, fmt.Errorf("armazenamento desconhecido | unknown store: %s", store)
+}
+
+// openLedgerStore abre o razão no mesmo armazenamento das chaves | openLedgerStore opens the ledger on the same store as the keys
+func openLedgerStore(store, path string, repo transaction.Repository) (transaction.LedgerStore, error) {
+	switch store {
+	case "file":
+		return transaction.OpenFileLedgerStore(path + ".ledger")
+	case "sqlite":
+		return transaction.NewSQLiteLedgerStore(repo.(*transaction.SQLiteRepository)), nil
+	}
+	return transaction.NewMemoryLedgerStore(), nil
 }
 
+// serve expõe POST /transacoes atrás do middleware de Idempotency-Key | serve exposes POST /transacoes behind the Idempotency-Key middleware
+// O faxineiro roda enquanto o servidor estiver no ar; Ctrl+C ou SIGTERM encerram os dois | The janitor runs while the server is up; Ctrl+C or SIGTERM stop both
+func serve(addr string, service *transaction.Service, relay *transaction.OutboxRelay, janitor *transaction.Janitor) {
+	mux := http.NewServeMux()
+	mux.Handle("POST /transacoes", transaction.HTTPMiddleware(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var t transaction.Transaction
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := service.ProcessContext(r.Context(), t)
+		var invalid *transaction.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, transaction.ErrIdempotencyKeyMismatch):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, transaction.ErrTransactionInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		relay.Deliver(r.Context(), t.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})))
+
+	janitor.Start()
+	defer janitor.Stop() // espera a limpeza em andamento antes de fechar o armazenamento | waits for the running cleanup before the store closes
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("🌐 Servindo em %s\n", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("❌ Erro no servidor: %s\n", err)
+	}
+}
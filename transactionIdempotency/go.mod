@@ -1,3 +1,5 @@
 module transactionIdempotency
 
 go 1.22.2
+
+require github.com/mattn/go-sqlite3 v1.14.22
@@ -3,6 +3,73 @@ package transaction
 import "errors"
 
 // Erro de transação já processada | Error for already processed transaction
+//
+// Deprecated: Process agora devolve o resultado gravado com Replayed=true | Deprecated: Process now returns the stored result with Replayed=true
 var ErrTransactionAlreadyProcessed = errors.New(
 	"transaction already processed",
-)
\ No newline at end of file
+)
+
+// Erro de arquivo de log corrompido no meio (não é só a última linha interrompida) | Error for a log file corrupted in the middle (not just a torn last line)
+var ErrCorruptLog = errors.New(
+	"corrupt transaction log",
+)
+
+// Erro de log com uma escrita pela metade que não pôde ser desfeita; o armazenamento recusa novos acréscimos | Error for a log with a partial write that couldn't be undone; the store refuses further appends
+var ErrLogUnwritable = errors.New(
+	"transaction log has an unrecoverable partial write",
+)
+
+// Erro de uso do repositório depois de fechado | Error for using the repository after it was closed
+var ErrRepositoryClosed = errors.New(
+	"repository closed",
+)
+
+// Erro de chave de idempotência reutilizada com outro conteúdo | Error for an idempotency key reused with different contents
+var ErrIdempotencyKeyMismatch = errors.New(
+	"idempotency key reused with a different payload",
+)
+
+// Erro de transação sendo processada por outro worker com lease válido | Error for a transaction being processed by another worker with a valid lease
+var ErrTransactionInProgress = errors.New(
+	"transaction in progress",
+)
+
+// Erro de lease perdido: venceu e outro worker assumiu, ou a transação já terminou | Error for a lost lease: it expired and another worker took over, or the transaction already finished
+var ErrLeaseLost = errors.New(
+	"transaction lease lost",
+)
+
+// Erro de transição não permitida pela máquina de estados | Error for a transition the state machine doesn't allow
+var ErrInvalidTransition = errors.New(
+	"invalid transaction state transition",
+)
+
+// Erro de ID de transação vazio ou fora do formato | Error for an empty or malformed transaction ID
+var ErrInvalidID = errors.New(
+	"invalid transaction ID",
+)
+
+// Erro de cliente não informado | Error for a missing customer
+var ErrEmptyCustomer = errors.New(
+	"empty customer",
+)
+
+// Erro de valor zero ou negativo | Error for a zero or negative amount
+var ErrInvalidAmount = errors.New(
+	"amount must be positive",
+)
+
+// Erro de moeda fora do formato ISO 4217 | Error for a currency outside the ISO 4217 format
+var ErrInvalidCurrency = errors.New(
+	"invalid ISO 4217 currency code",
+)
+
+// Erro de valor acima do limite por transação | Error for an amount above the per-transaction limit
+var ErrAmountOverLimit = errors.New(
+	"amount over the per-transaction limit",
+)
+
+// Erro de tipo de transação que não é débito nem crédito | Error for a transaction type that is neither debit nor credit
+var ErrInvalidType = errors.New(
+	"transaction type must be debit or credit",
+)
@@ -0,0 +1,26 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint é o hash SHA-256 da forma canônica do conteúdo da transação (sem o ID, que é a chave) | Fingerprint is the SHA-256 hash of the canonical form of the transaction contents (without the ID, which is the key)
+// A forma canônica tem os campos em ordem fixa, um por linha, e o valor sem zeros à direita | The canonical form has the fields in a fixed order, one per line, and the amount without trailing zeros
+// A moeda e o tipo só entram quando não são os padrões: as impressões gravadas antes deles continuam valendo | The currency and the type are only included when they aren't the defaults: fingerprints stored before them stay valid
+func (t Transaction) Fingerprint() string {
+	var canonical strings.Builder
+	canonical.WriteString("customer=" + strconv.Quote(t.Customer) + "\n")
+	canonical.WriteString("amount=" + t.Amount.Decimal() + "\n")
+	if currency := t.Amount.code(); currency != DefaultCurrency {
+		canonical.WriteString("currency=" + currency + "\n")
+	}
+	if kind := t.Kind(); kind != TypeDebit {
+		canonical.WriteString("type=" + string(kind) + "\n")
+	}
+
+	sum := sha256.Sum256([]byte(canonical.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package transaction
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprintIsCanonical(t *testing.T) {
+	a := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+	b := Transaction{ID: "tx85", Customer: "Duarte", Amount: NewMoney(100000000, "")}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("same contents under different IDs should share a fingerprint")
+	}
+	if a.Fingerprint() == (Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000001)}).Fingerprint() {
+		t.Errorf("different amounts should not share a fingerprint")
+	}
+	// o nome vai entre aspas: uma quebra de linha nele não imita o campo amount | the name is quoted: a newline in it can't mimic the amount field
+	if (Transaction{Customer: "a\namount=1"}).Fingerprint() == (Transaction{Customer: "a", Amount: BRL(100)}).Fingerprint() {
+		t.Errorf("customer contents leaked into the amount field")
+	}
+}
+
+func TestProcessDetectsKeyReuse(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+			service := NewService(repo)
+
+			original := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+			if _, err := service.Process(original); err != nil {
+				t.Fatalf("first Process: %v", err)
+			}
+			if result, err := service.Process(original); err != nil || !result.Replayed {
+				t.Errorf("exact replay = %+v, %v; want replayed result", result, err)
+			}
+
+			changed := []Transaction{
+				{ID: "tx84", Customer: "Duarte", Amount: BRL(99900)},
+				{ID: "tx84", Customer: "Rodrigo", Amount: BRL(100000000)},
+			}
+			for _, tx := range changed {
+				if _, err := service.Process(tx); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+					t.Errorf("Process(%+v) = %v; want ErrIdempotencyKeyMismatch", tx, err)
+				}
+			}
+		})
+	}
+}
+
+// registros gravados antes das impressões digitais ainda são comparados pelo conteúdo | records stored before fingerprints still compare by contents
+func TestKeyReuseWithLegacyRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	walPath := filepath.Join(dir, "legacy.wal")
+	os.WriteFile(walPath, []byte(`{"ID":"tx84","Customer":"Duarte","Amount":1000000}`+"\n"), 0o644)
+
+	dbPath := filepath.Join(dir, "legacy.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(sqliteMigrations[0])
+	db.Exec(`INSERT INTO processed_transactions (id, customer, amount) VALUES ('tx84', 'Duarte', 1000000)`)
+	db.Close()
+
+	for name, path := range map[string]string{"file": walPath, "sqlite": dbPath} {
+		repo, err := backends[name](path)
+		if err != nil {
+			t.Fatalf("%s: open legacy store: %v", name, err)
+		}
+		service := NewService(repo)
+		result, err := service.Process(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)})
+		if err != nil || !result.Replayed || result.Status != StatusSucceeded {
+			t.Errorf("%s: legacy replay = %+v, %v; want replayed success", name, result, err)
+		}
+		if _, err := service.Process(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+			t.Errorf("%s: legacy mismatch = %v; want ErrIdempotencyKeyMismatch", name, err)
+		}
+		repo.Close()
+	}
+}
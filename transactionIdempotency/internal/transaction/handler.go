@@ -0,0 +1,47 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+)
+
+// Handler faz o trabalho de verdade da transação (ex: debitar a conta) | Handler performs the actual work of the transaction (e.g. debiting the account)
+// Handle não deve causar efeitos externos diretamente: eles voltam em Effects.Messages e só saem (pelo OutboxRelay) depois que o desfecho foi gravado | Handle must not cause external effects directly: they come back in Effects.Messages and only leave (through the OutboxRelay) after the outcome is stored
+// Assim uma queda antes da gravação só repete um cálculo, nunca uma cobrança | So a crash before the write only repeats a computation, never a charge
+type Handler interface {
+	Handle(ctx context.Context, t Transaction) (Effects, error)
+}
+
+// HandlerFunc adapta uma função comum para Handler | HandlerFunc adapts a plain function to Handler
+type HandlerFunc func(ctx context.Context, t Transaction) (Effects, error)
+
+// Handle chama a função | Handle calls the function
+func (f HandlerFunc) Handle(ctx context.Context, t Transaction) (Effects, error) {
+	return f(ctx, t)
+}
+
+// Effects é o que o Handler produziu: a resposta e as mensagens gravadas junto do desfecho | Effects is what the Handler produced: the response and the messages stored with the outcome
+type Effects struct {
+	Result   []byte
+	Messages []Message
+}
+
+// retryableError marca um erro do Handler como temporário | retryableError marks a Handler error as temporary
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// Retryable marca o erro do Handler como temporário: a transação fica failed_retryable e a próxima chamada tenta de novo | Retryable marks the Handler error as temporary: the transaction becomes failed_retryable and the next call tries again
+// Erros sem essa marca são finais (failed_final) e as repetições recebem o mesmo erro | Errors without this mark are final (failed_final) and replays get the same error
+func Retryable(err error) error {
+	return retryableError{err: err}
+}
+
+// IsRetryable diz se o erro foi marcado com Retryable; cancelamento e prazo esgotado também contam | IsRetryable reports whether the error was marked with Retryable; cancellation and deadline also count
+func IsRetryable(err error) bool {
+	var retryable retryableError
+	return errors.As(err, &retryable) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,173 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// IdempotencyKeyHeader é o cabeçalho do rascunho da IETF com a chave de idempotência | IdempotencyKeyHeader is the IETF draft header carrying the idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength limita o tamanho da chave aceita | maxIdempotencyKeyLength caps the accepted key length
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodyBytes limita o corpo lido para calcular a impressão digital | maxIdempotentBodyBytes caps the body read to compute the fingerprint
+const maxIdempotentBodyBytes = 1 << 20
+
+// httpKeyPrefix separa as chaves HTTP dos IDs de transação no mesmo armazenamento | httpKeyPrefix keeps HTTP keys apart from transaction IDs in the same store
+const httpKeyPrefix = "http:"
+
+// storedResponse é a primeira resposta, guardada no Result do desfecho | storedResponse is the first response, kept in the outcome Result
+type storedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// HTTPMiddleware aplica a semântica do cabeçalho Idempotency-Key a qualquer http.Handler | HTTPMiddleware applies the Idempotency-Key header semantics to any http.Handler
+// A primeira resposta (status, cabeçalhos e corpo) é gravada antes de sair e as repetições a recebem byte a byte | The first response (status, headers and body) is stored before it leaves and retries get it byte for byte
+// Duplicado em andamento: 409; mesma chave com outro método, caminho ou corpo: 422; requisições sem a chave passam direto | In-flight duplicate: 409; same key with another method, path or body: 422; requests without the key pass straight through
+// Respostas que não são finais (5xx, 408, 409, 425 e 429) não são guardadas: a transação fica failed_retryable e a próxima tentativa roda de novo | Non-final responses (5xx, 408, 409, 425 and 429) are not kept: the transaction becomes failed_retryable and the next try runs again
+// Corpos acima de maxIdempotentBodyBytes recebem 413 sem chegar ao handler | Bodies above maxIdempotentBodyBytes get 413 without reaching the handler
+func HTTPMiddleware(s *Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key longa demais | Idempotency-Key too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "corpo grande demais | body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "erro ao ler o corpo | error reading the body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			lease, result, err := s.begin(Transaction{ID: httpKeyPrefix + key}, requestFingerprint(r, body))
+			switch {
+			case errors.Is(err, ErrTransactionInProgress):
+				http.Error(w, "requisição com essa chave ainda em andamento | request with this key still in progress", http.StatusConflict)
+				return
+			case errors.Is(err, ErrIdempotencyKeyMismatch):
+				http.Error(w, "chave já usada com outra requisição | key already used with another request", http.StatusUnprocessableEntity)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if result.Replayed {
+				replayResponse(w, result)
+				return
+			}
+
+			capture := &captureWriter{header: http.Header{}}
+			defer func() {
+				// o handler entrou em pânico: libera a chave para a próxima tentativa | the handler panicked: release the key for the next try
+				if p := recover(); p != nil {
+					s.Finish(lease, StatusFailedRetryable, nil, errors.New("panic"))
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(capture, r)
+
+			response := capture.response()
+			if retryableStatus(response.Status) {
+				s.Finish(lease, StatusFailedRetryable, nil, errors.New(http.StatusText(response.Status)))
+			} else {
+				encoded, _ := json.Marshal(response) // cabeçalhos e bytes sempre viram JSON | headers and bytes always encode
+				// sem gravar, a resposta não sai: um cliente que a recebeu sempre recebe a mesma na repetição | without storing, the response doesn't leave: a client that got it always gets the same one on retry
+				if _, err := s.Finish(lease, StatusSucceeded, encoded, nil); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			writeResponse(w, response)
+		})
+	}
+}
+
+// retryableStatus indica as respostas que dependem do momento e não devem ser repetidas para sempre | retryableStatus reports responses that depend on timing and must not be replayed forever
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
+
+// requestFingerprint é o hash do método, do caminho e do corpo da requisição | requestFingerprint is the hash of the request method, path and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte("method=" + strconv.Quote(r.Method) + "\n"))
+	hash.Write([]byte("path=" + strconv.Quote(r.URL.RequestURI()) + "\n"))
+	hash.Write([]byte("body="))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayResponse devolve a resposta gravada; uma chave que terminou em falha final vira 422 | replayResponse sends back the stored response; a key that ended in a final failure becomes 422
+func replayResponse(w http.ResponseWriter, result ProcessResult) {
+	var response storedResponse
+	if result.Status != StatusSucceeded || json.Unmarshal(result.Result, &response) != nil || response.Status == 0 {
+		http.Error(w, "chave já usada sem resposta guardada | key already used without a stored response", http.StatusUnprocessableEntity)
+		return
+	}
+	writeResponse(w, response)
+}
+
+// writeResponse escreve status, cabeçalhos e corpo no cliente | writeResponse writes status, headers and body to the client
+func writeResponse(w http.ResponseWriter, response storedResponse) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// captureWriter guarda a resposta do handler em vez de enviá-la | captureWriter keeps the handler response instead of sending it
+type captureWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	return c.body.Write(b)
+}
+
+// response devolve o que foi capturado; os cabeçalhos são copiados como estavam no fim do handler | response returns what was captured; headers are copied as they were when the handler finished
+func (c *captureWriter) response() storedResponse {
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return storedResponse{Status: status, Header: c.header.Clone(), Body: c.body.Bytes()}
+}
@@ -0,0 +1,174 @@
+package transaction
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// orderHandler cria um pedido por chamada e responde 201 com o número dele | orderHandler creates one order per call and answers 201 with its number
+type orderHandler struct {
+	calls atomic.Int32
+}
+
+func (h *orderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := h.calls.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d", n))
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"order":%d,"request":%s}`, n, body)
+}
+
+// send faz uma requisição ao handler com a chave e o corpo dados | send makes a request to the handler with the given key and body
+func send(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHTTPMiddlewareReplaysFirstResponse(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			orders := &orderHandler{}
+			handler := HTTPMiddleware(NewService(repo))(orders)
+			first := send(handler, "key-1", `{"amount":10}`)
+			if first.Code != http.StatusCreated {
+				t.Fatalf("first status = %d", first.Code)
+			}
+
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				handler = HTTPMiddleware(NewService(repo))(orders)
+			}
+			defer repo.Close()
+
+			retry := send(handler, "key-1", `{"amount":10}`)
+			if retry.Code != first.Code || !reflect.DeepEqual(retry.Header(), first.Header()) || retry.Body.String() != first.Body.String() {
+				t.Errorf("retry = %d %v %q; want %d %v %q", retry.Code, retry.Header(), retry.Body, first.Code, first.Header(), first.Body)
+			}
+			if calls := orders.calls.Load(); calls != 1 {
+				t.Errorf("handler called %d times; want 1", calls)
+			}
+
+			if mismatch := send(handler, "key-1", `{"amount":99}`); mismatch.Code != http.StatusUnprocessableEntity {
+				t.Errorf("reused key with another body = %d; want 422", mismatch.Code)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareConcurrentDuplicate(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("done"))
+	})
+	handler := HTTPMiddleware(NewService(NewMemoryRepository()))(slow)
+
+	firstDone := make(chan *httptest.ResponseRecorder)
+	go func() { firstDone <- send(handler, "key-1", "{}") }()
+	<-entered
+
+	if duplicate := send(handler, "key-1", "{}"); duplicate.Code != http.StatusConflict {
+		t.Errorf("in-flight duplicate = %d; want 409", duplicate.Code)
+	}
+	close(release)
+	if first := <-firstDone; first.Code != http.StatusOK || first.Body.String() != "done" {
+		t.Errorf("first = %d %q", first.Code, first.Body)
+	}
+	if retry := send(handler, "key-1", "{}"); retry.Code != http.StatusOK || retry.Body.String() != "done" {
+		t.Errorf("retry after completion = %d %q; want the stored response", retry.Code, retry.Body)
+	}
+}
+
+func TestHTTPMiddlewareServerErrorsAreRetried(t *testing.T) {
+	var calls atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "database down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	handler := HTTPMiddleware(NewService(NewMemoryRepository()))(flaky)
+
+	if first := send(handler, "key-1", "{}"); first.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first = %d; want 503", first.Code)
+	}
+	if retry := send(handler, "key-1", "{}"); retry.Code != http.StatusOK || retry.Body.String() != "ok" {
+		t.Errorf("retry after 503 = %d %q; want a new run", retry.Code, retry.Body)
+	}
+	if again := send(handler, "key-1", "{}"); again.Body.String() != "ok" || calls.Load() != 2 {
+		t.Errorf("replay = %q after %d calls; want ok after 2", again.Body, calls.Load())
+	}
+}
+
+func TestHTTPMiddlewareTransientStatusesAreRetried(t *testing.T) {
+	for _, status := range []int{http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			busy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					http.Error(w, "try later", status)
+					return
+				}
+				w.Write([]byte("ok"))
+			})
+			handler := HTTPMiddleware(NewService(NewMemoryRepository()))(busy)
+
+			if first := send(handler, "key-1", "{}"); first.Code != status {
+				t.Fatalf("first = %d; want %d", first.Code, status)
+			}
+			if retry := send(handler, "key-1", "{}"); retry.Code != http.StatusOK || calls.Load() != 2 {
+				t.Errorf("retry after %d = %d after %d calls; want a new run", status, retry.Code, calls.Load())
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareRejectsOversizedBody(t *testing.T) {
+	orders := &orderHandler{}
+	handler := HTTPMiddleware(NewService(NewMemoryRepository()))(orders)
+	if large := send(handler, "key-1", strings.Repeat("x", maxIdempotentBodyBytes+1)); large.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body = %d; want 413", large.Code)
+	}
+	if calls := orders.calls.Load(); calls != 0 {
+		t.Errorf("handler called %d times for an oversized body; want 0", calls)
+	}
+	if fits := send(handler, "key-1", `{"amount":10}`); fits.Code != http.StatusCreated {
+		t.Errorf("request after a rejected body = %d; want 201", fits.Code)
+	}
+}
+
+func TestHTTPMiddlewareWithoutKey(t *testing.T) {
+	orders := &orderHandler{}
+	handler := HTTPMiddleware(NewService(NewMemoryRepository()))(orders)
+	send(handler, "", "{}")
+	send(handler, "", "{}")
+	if calls := orders.calls.Load(); calls != 2 {
+		t.Errorf("requests without a key ran %d times; want 2", calls)
+	}
+	if long := send(handler, strings.Repeat("k", 300), "{}"); long.Code != http.StatusBadRequest {
+		t.Errorf("oversized key = %d; want 400", long.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package transaction
+
+import (
+	"context"
+	"time"
+)
+
+// TransactionType diz se a transação tira (débito) ou põe (crédito) dinheiro na conta do cliente | TransactionType tells whether the transaction takes money out of (debit) or puts it into (credit) the customer account
+type TransactionType string
+
+const (
+	TypeDebit  TransactionType = "debit"
+	TypeCredit TransactionType = "credit"
+)
+
+// Kind devolve o tipo da transação; transações sem tipo, inclusive as antigas, são débitos | Kind returns the transaction type; transactions without a type, including old ones, are debits
+func (t Transaction) Kind() TransactionType {
+	if t.Type == "" {
+		return TypeDebit
+	}
+	return t.Type
+}
+
+// SettlementAccount é a contrapartida de todas as transações dos clientes | SettlementAccount is the counterpart of every customer transaction
+const SettlementAccount = "settlement"
+
+// CustomerAccount devolve a conta do razão de um cliente | CustomerAccount returns a customer's ledger account
+func CustomerAccount(customer string) string {
+	return "customer:" + customer
+}
+
+// Posting é um lançamento numa conta: positivo aumenta o saldo, negativo diminui | Posting is an entry in one account: positive raises the balance, negative lowers it
+// Cada transação gera lançamentos que somam zero (partidas dobradas) | Each transaction produces postings that add up to zero (double entry)
+type Posting struct {
+	TransactionID string
+	Account       string
+	Amount        Money
+	PostedAt      time.Time
+}
+
+// LedgerStore guarda os lançamentos do razão | LedgerStore stores the ledger postings
+// Implementações: memória, arquivo (JSON lines) e SQLite | Implementations: memory, file (JSON lines) and SQLite
+type LedgerStore interface {
+	// Append grava os lançamentos de uma transação de uma vez; false se a transação já foi lançada | Append stores one transaction's postings all at once; false if the transaction was already posted
+	Append(postings []Posting) (bool, error)
+	// Postings devolve os lançamentos da conta com from <= PostedAt < to, em ordem; from e to zero não limitam | Postings returns the account postings with from <= PostedAt < to, in order; a zero from or to doesn't limit
+	Postings(account string, from, to time.Time) ([]Posting, error)
+	// Balance soma os lançamentos da conta na moeda | Balance adds up the account postings in the currency
+	Balance(account, currency string) (Money, error)
+	// Close libera os recursos | Close releases resources
+	Close() error
+}
+
+// Ledger lança cada transação processada no razão e responde saldos e extratos | Ledger posts each processed transaction to the ledger and answers balances and statements
+// Como Handler do Service, a idempotência da chave evita rodar de novo; o Append por ID cobre a queda entre o lançamento e a gravação do desfecho | As the Service Handler, key idempotency avoids running again; the per-ID Append covers a crash between posting and storing the outcome
+type Ledger struct {
+	store LedgerStore
+	now   func() time.Time
+}
+
+// NewLedger cria o razão sobre o armazenamento; now nil usa time.Now | NewLedger creates the ledger on top of the store; a nil now uses time.Now
+func NewLedger(store LedgerStore, now func() time.Time) *Ledger {
+	if now == nil {
+		now = time.Now
+	}
+	return &Ledger{store: store, now: now}
+}
+
+// Postings monta os lançamentos da transação: débito tira do cliente e põe na liquidação; crédito faz o contrário | Postings builds the transaction postings: a debit takes from the customer and puts into settlement; a credit does the opposite
+func (t Transaction) Postings(postedAt time.Time) []Posting {
+	amount := t.Amount
+	if t.Kind() == TypeCredit {
+		amount.Cents = -amount.Cents
+	}
+	return []Posting{
+		{TransactionID: t.ID, Account: CustomerAccount(t.Customer), Amount: Money{Cents: -amount.Cents, Currency: amount.Currency}, PostedAt: postedAt},
+		{TransactionID: t.ID, Account: SettlementAccount, Amount: amount, PostedAt: postedAt},
+	}
+}
+
+// Post lança a transação; false se ela já estava lançada | Post posts the transaction; false if it was already posted
+func (l *Ledger) Post(t Transaction) (bool, error) {
+	return l.store.Append(t.Postings(l.now().UTC()))
+}
+
+// Handle lança a transação e responde com o novo saldo do cliente | Handle posts the transaction and answers with the customer's new balance
+func (l *Ledger) Handle(ctx context.Context, t Transaction) (Effects, error) {
+	if _, err := l.Post(t); err != nil {
+		return Effects{}, Retryable(err)
+	}
+	balance, err := l.Balance(t.Customer, t.Amount.Currency)
+	if err != nil {
+		return Effects{}, Retryable(err)
+	}
+	return Effects{Result: []byte(balance.String())}, nil
+}
+
+// Balance devolve o saldo do cliente na moeda | Balance returns the customer balance in the currency
+func (l *Ledger) Balance(customer, currency string) (Money, error) {
+	return l.store.Balance(CustomerAccount(customer), currency)
+}
+
+// StatementLine é um lançamento do extrato com o saldo logo depois dele | StatementLine is a statement posting with the balance right after it
+type StatementLine struct {
+	Posting
+	Balance Money
+}
+
+// Statement é o extrato de um cliente numa moeda entre From (inclusive) e To (exclusive) | Statement is a customer statement in one currency between From (inclusive) and To (exclusive)
+type Statement struct {
+	Customer string
+	From, To time.Time
+	Opening  Money
+	Lines    []StatementLine
+	Closing  Money
+}
+
+// Statement monta o extrato do cliente no período, com saldo inicial, saldo a cada lançamento e saldo final | Statement builds the customer statement for the period, with opening balance, balance after each posting and closing balance
+func (l *Ledger) Statement(customer, currency string, from, to time.Time) (Statement, error) {
+	account := CustomerAccount(customer)
+	statement := Statement{Customer: customer, From: from, To: to, Opening: NewMoney(0, currency)}
+
+	if !from.IsZero() {
+		earlier, err := l.store.Postings(account, time.Time{}, from)
+		if err != nil {
+			return Statement{}, err
+		}
+		for _, posting := range earlier {
+			if posting.Amount.Currency == currency {
+				statement.Opening.Cents += posting.Amount.Cents
+			}
+		}
+	}
+
+	postings, err := l.store.Postings(account, from, to)
+	if err != nil {
+		return Statement{}, err
+	}
+	balance := statement.Opening
+	for _, posting := range postings {
+		if posting.Amount.Currency != currency {
+			continue
+		}
+		balance.Cents += posting.Amount.Cents
+		statement.Lines = append(statement.Lines, StatementLine{Posting: posting, Balance: balance})
+	}
+	statement.Closing = balance
+	return statement, nil
+}
+
+// inRange diz se from <= t < to; from e to zero não limitam | inRange reports whether from <= t < to; a zero from or to doesn't limit
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
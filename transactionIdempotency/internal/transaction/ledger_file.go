@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLedgerStore grava os lançamentos de cada transação numa linha JSON de um log só de acréscimo com fsync | FileLedgerStore appends each transaction's postings as one JSON line to an append-only log with fsync
+// Uma linha por transação: os lançamentos dela entram todos ou nenhum | One line per transaction: its postings all go in or none do
+type FileLedgerStore struct {
+	memory *MemoryLedgerStore // índice relido do log; o lock dele protege também o arquivo | index replayed from the log; its lock also guards the file
+	file   *os.File
+	broken error // ErrLogUnwritable depois de uma escrita que não pôde ser desfeita | ErrLogUnwritable after a write that couldn't be undone
+}
+
+// OpenFileLedgerStore abre (ou cria) o log no caminho informado e relê os lançamentos | OpenFileLedgerStore opens (or creates) the log at path and replays the postings
+func OpenFileLedgerStore(path string) (*FileLedgerStore, error) {
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileLedgerStore{memory: NewMemoryLedgerStore(), file: file}
+	err = replayJSONLines(file, func(data []byte) error {
+		var postings []Posting
+		if err := json.Unmarshal(data, &postings); err != nil {
+			return err
+		}
+		if len(postings) > 0 {
+			s.memory.add(postings)
+		}
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if created {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Append acrescenta a linha e só atualiza o índice depois do fsync | Append writes the line and only updates the index after fsync
+// Uma escrita que falhou é desfeita (appendLine), para não deixar meia linha no meio do log | A failed write is undone (appendLine), so no half line is left in the middle of the log
+func (s *FileLedgerStore) Append(postings []Posting) (bool, error) {
+	if len(postings) == 0 {
+		return false, nil
+	}
+	data, err := json.Marshal(postings)
+	if err != nil {
+		return false, err
+	}
+	data = append(data, '\n')
+
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if s.file == nil {
+		return false, ErrRepositoryClosed
+	}
+	if s.memory.posted[postings[0].TransactionID] {
+		return false, nil
+	}
+	if s.broken != nil {
+		return false, s.broken
+	}
+	if err := appendLine(s.file, data); err != nil { // appendLine vem de repository_file.go | appendLine comes from repository_file.go
+		if errors.Is(err, ErrLogUnwritable) {
+			s.broken = err
+		}
+		return false, err
+	}
+	return s.memory.add(postings), nil
+}
+
+// Postings consulta o índice em memória | Postings queries the in-memory index
+func (s *FileLedgerStore) Postings(account string, from, to time.Time) ([]Posting, error) {
+	return s.memory.Postings(account, from, to)
+}
+
+// Balance consulta o índice em memória | Balance queries the in-memory index
+func (s *FileLedgerStore) Balance(account, currency string) (Money, error) {
+	return s.memory.Balance(account, currency)
+}
+
+// Close fecha o arquivo; usar o razão depois disso dá ErrRepositoryClosed | Close closes the file; using the ledger afterwards gives ErrRepositoryClosed
+func (s *FileLedgerStore) Close() error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,90 @@
+package transaction
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryLedgerStore guarda os lançamentos só em memória; eles se perdem ao reiniciar | MemoryLedgerStore keeps postings in memory only; they are lost on restart
+type MemoryLedgerStore struct {
+	mu        sync.RWMutex
+	posted    map[string]bool      // IDs de transação já lançados | transaction IDs already posted
+	byAccount map[string][]Posting // lançamentos de cada conta | postings of each account
+}
+
+// NewMemoryLedgerStore cria um razão vazio em memória | NewMemoryLedgerStore creates an empty in-memory ledger
+func NewMemoryLedgerStore() *MemoryLedgerStore {
+	return &MemoryLedgerStore{
+		posted:    make(map[string]bool),
+		byAccount: make(map[string][]Posting),
+	}
+}
+
+// Append grava os lançamentos com o lock travado entre a consulta e a escrita | Append stores the postings holding the lock between lookup and write
+func (s *MemoryLedgerStore) Append(postings []Posting) (bool, error) {
+	if len(postings) == 0 {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(postings), nil
+}
+
+// add grava os lançamentos se a transação ainda não foi lançada (mu travado por quem chama) | add stores the postings if the transaction wasn't posted yet (mu locked by the caller)
+func (s *MemoryLedgerStore) add(postings []Posting) bool {
+	id := postings[0].TransactionID
+	if s.posted[id] {
+		return false
+	}
+	s.posted[id] = true
+	for _, posting := range postings {
+		s.byAccount[posting.Account] = append(s.byAccount[posting.Account], posting)
+	}
+	return true
+}
+
+// Postings filtra os lançamentos da conta pelo período e os ordena pela data | Postings filters the account postings by period and sorts them by date
+func (s *MemoryLedgerStore) Postings(account string, from, to time.Time) ([]Posting, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var postings []Posting
+	for _, posting := range s.byAccount[account] {
+		if inRange(posting.PostedAt, from, to) {
+			postings = append(postings, posting)
+		}
+	}
+	sortPostings(postings)
+	return postings, nil
+}
+
+// Balance soma os lançamentos da conta na moeda | Balance adds up the account postings in the currency
+func (s *MemoryLedgerStore) Balance(account, currency string) (Money, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balance := NewMoney(0, currency)
+	for _, posting := range s.byAccount[account] {
+		if posting.Amount.Currency == currency {
+			balance.Cents += posting.Amount.Cents
+		}
+	}
+	return balance, nil
+}
+
+// Close não tem nada a liberar | Close has nothing to release
+func (s *MemoryLedgerStore) Close() error {
+	return nil
+}
+
+// sortPostings ordena por data e, no empate, pelo ID da transação | sortPostings orders by date and, on ties, by transaction ID
+func sortPostings(postings []Posting) {
+	sort.SliceStable(postings, func(i, j int) bool {
+		if !postings[i].PostedAt.Equal(postings[j].PostedAt) {
+			return postings[i].PostedAt.Before(postings[j].PostedAt)
+		}
+		return postings[i].TransactionID < postings[j].TransactionID
+	})
+}
@@ -0,0 +1,93 @@
+package transaction
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// SQLiteLedgerStore grava os lançamentos na tabela ledger_postings do mesmo banco do SQLiteRepository | SQLiteLedgerStore stores postings in the ledger_postings table of the SQLiteRepository database
+type SQLiteLedgerStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteLedgerStore usa o banco já aberto (e migrado) pelo repositório; Close do repositório fecha os dois | NewSQLiteLedgerStore uses the database already opened (and migrated) by the repository; closing the repository closes both
+func NewSQLiteLedgerStore(repo *SQLiteRepository) *SQLiteLedgerStore {
+	return &SQLiteLedgerStore{db: repo.db}
+}
+
+// Append insere os lançamentos numa transação do SQLite; a chave primária recusa a mesma transação de novo | Append inserts the postings in one SQLite transaction; the primary key refuses the same transaction again
+func (s *SQLiteLedgerStore) Append(postings []Posting) (bool, error) {
+	if len(postings) == 0 {
+		return false, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() // sem efeito depois do Commit | no effect after Commit
+
+	for i, posting := range postings {
+		result, err := tx.Exec(
+			"INSERT INTO ledger_postings (transaction_id, account, cents, currency, posted_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+			posting.TransactionID, posting.Account, posting.Amount.Cents, posting.Amount.code(), posting.PostedAt.UnixNano(),
+		)
+		if err != nil {
+			return false, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		if inserted == 0 && i == 0 {
+			return false, nil // já lançada | already posted
+		}
+	}
+	return true, tx.Commit()
+}
+
+// Postings busca os lançamentos da conta pelo índice (account, posted_at) | Postings fetches the account postings using the (account, posted_at) index
+func (s *SQLiteLedgerStore) Postings(account string, from, to time.Time) ([]Posting, error) {
+	var upper int64 = math.MaxInt64
+	if !to.IsZero() {
+		upper = to.UnixNano()
+	}
+	var lower int64 = math.MinInt64
+	if !from.IsZero() {
+		lower = from.UnixNano()
+	}
+	rows, err := s.db.Query(
+		"SELECT transaction_id, account, cents, currency, posted_at FROM ledger_postings WHERE account = ? AND posted_at >= ? AND posted_at < ? ORDER BY posted_at, transaction_id",
+		account, lower, upper,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var postings []Posting
+	for rows.Next() {
+		var posting Posting
+		var postedAt int64
+		if err := rows.Scan(&posting.TransactionID, &posting.Account, &posting.Amount.Cents, &posting.Amount.Currency, &postedAt); err != nil {
+			return nil, err
+		}
+		posting.PostedAt = time.Unix(0, postedAt).UTC()
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
+
+// Balance soma os lançamentos no banco | Balance adds up the postings in the database
+func (s *SQLiteLedgerStore) Balance(account, currency string) (Money, error) {
+	balance := NewMoney(0, currency)
+	err := s.db.QueryRow(
+		"SELECT COALESCE(SUM(cents), 0) FROM ledger_postings WHERE account = ? AND currency = ?", account, currency,
+	).Scan(&balance.Cents)
+	return balance, err
+}
+
+// Close não fecha nada: o banco é do repositório | Close closes nothing: the database belongs to the repository
+func (s *SQLiteLedgerStore) Close() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ledgerBackends abre cada armazenamento junto do razão correspondente | ledgerBackends opens each store together with its matching ledger
+var ledgerBackends = map[string]func(path string) (Repository, LedgerStore, error){
+	"memory": func(string) (Repository, LedgerStore, error) {
+		return NewMemoryRepository(), NewMemoryLedgerStore(), nil
+	},
+	"file": func(path string) (Repository, LedgerStore, error) {
+		repo, err := OpenFileRepository(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		ledger, err := OpenFileLedgerStore(path + ".ledger")
+		return repo, ledger, err
+	},
+	"sqlite": func(path string) (Repository, LedgerStore, error) {
+		repo, err := OpenSQLiteRepository(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, NewSQLiteLedgerStore(repo), nil
+	},
+}
+
+func TestLedgerPostsExactlyOnce(t *testing.T) {
+	for name, open := range ledgerBackends {
+		t.Run(name, func(t *testing.T) {
+			repo, store, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+			defer store.Close()
+			ledger := NewLedger(store, nil)
+			service := NewService(repo, WithHandler(ledger))
+
+			txs := []Transaction{
+				{ID: "tx1", Customer: "Duarte", Amount: BRL(100000), Type: TypeCredit},
+				{ID: "tx2", Customer: "Duarte", Amount: BRL(25050)},
+				{ID: "tx3", Customer: "Duarte", Amount: BRL(1000), Type: TypeDebit},
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 30; i++ {
+				wg.Add(1)
+				go func(tx Transaction) {
+					defer wg.Done()
+					if _, err := service.Process(tx); err != nil && !errors.Is(err, ErrTransactionInProgress) {
+						t.Errorf("%s: %v", tx.ID, err)
+					}
+				}(txs[i%len(txs)])
+			}
+			wg.Wait()
+
+			if balance, err := ledger.Balance("Duarte", "BRL"); err != nil || balance != BRL(73950) {
+				t.Errorf("Balance = %v, %v; want R$ 739,50", balance, err)
+			}
+			// partidas dobradas: a liquidação espelha o cliente | double entry: settlement mirrors the customer
+			if settlement, err := store.Balance(SettlementAccount, "BRL"); err != nil || settlement != BRL(-73950) {
+				t.Errorf("settlement = %v, %v; want -R$ 739,50", settlement, err)
+			}
+			if replay, _ := service.Process(txs[1]); !replay.Replayed || string(replay.Result) == "" {
+				t.Errorf("replay = %+v; want the stored balance", replay)
+			}
+		})
+	}
+}
+
+func TestLedgerCrashBeforeCommitPostsOnce(t *testing.T) {
+	for name, open := range ledgerBackends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, store, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(5000), Type: TypeCredit}
+
+			// o worker lança no razão e cai antes de gravar o desfecho | the worker posts to the ledger and crashes before storing the outcome
+			service := NewService(repo, WithClock(clock.Now), WithLeaseTTL(time.Minute))
+			if _, _, err := service.Begin(tx); err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			if posted, err := NewLedger(store, clock.Now).Post(tx); err != nil || !posted {
+				t.Fatalf("Post = %v, %v", posted, err)
+			}
+			if name != "memory" {
+				store.Close()
+				repo.Close()
+				if repo, store, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+			}
+			defer repo.Close()
+			defer store.Close()
+
+			clock.Advance(2 * time.Minute)
+			ledger := NewLedger(store, clock.Now)
+			service = NewService(repo, WithClock(clock.Now), WithLeaseTTL(time.Minute), WithHandler(ledger))
+			result, err := service.Process(tx)
+			if err != nil || result.Status != StatusSucceeded || string(result.Result) != "R$ 50,00" {
+				t.Fatalf("Process after crash = %+v, %v", result, err)
+			}
+			if balance, _ := ledger.Balance("Duarte", "BRL"); balance != BRL(5000) {
+				t.Errorf("Balance = %v; want R$ 50,00 posted once", balance)
+			}
+		})
+	}
+}
+
+func TestLedgerStatement(t *testing.T) {
+	for name, open := range ledgerBackends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, store, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			clock := &fakeClock{now: day}
+			service := NewService(repo, WithClock(clock.Now), WithHandler(NewLedger(store, clock.Now)))
+			entries := []struct {
+				kind  TransactionType
+				cents int64
+			}{
+				{TypeCredit, 100000}, // 1/5
+				{TypeDebit, 20000},   // 2/5
+				{TypeDebit, 5000},    // 3/5
+				{TypeCredit, 1000},   // 4/5
+			}
+			for i, entry := range entries {
+				tx := Transaction{ID: fmt.Sprintf("tx%d", i), Customer: "Duarte", Amount: BRL(entry.cents), Type: entry.kind}
+				if _, err := service.Process(tx); err != nil {
+					t.Fatalf("Process: %v", err)
+				}
+				// outro cliente e outra moeda não entram no extrato | another customer and another currency stay out of the statement
+				service.Process(Transaction{ID: fmt.Sprintf("rodrigo%d", i), Customer: "Rodrigo", Amount: BRL(999), Type: TypeCredit})
+				service.Process(Transaction{ID: fmt.Sprintf("usd%d", i), Customer: "Duarte", Amount: NewMoney(999, "USD"), Type: TypeCredit})
+				clock.Advance(24 * time.Hour)
+			}
+			if name != "memory" {
+				store.Close()
+				repo.Close()
+				if repo, store, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+			}
+			defer repo.Close()
+			defer store.Close()
+
+			statement, err := NewLedger(store, clock.Now).Statement("Duarte", "BRL", day.Add(24*time.Hour), day.Add(3*24*time.Hour))
+			if err != nil {
+				t.Fatalf("Statement: %v", err)
+			}
+			if statement.Opening != BRL(100000) || statement.Closing != BRL(75000) || len(statement.Lines) != 2 {
+				t.Fatalf("Statement = %+v; want opening R$ 1.000,00, 2 lines, closing R$ 750,00", statement)
+			}
+			first, second := statement.Lines[0], statement.Lines[1]
+			if first.TransactionID != "tx1" || first.Amount != BRL(-20000) || first.Balance != BRL(80000) {
+				t.Errorf("first line = %+v", first)
+			}
+			if second.TransactionID != "tx2" || second.Amount != BRL(-5000) || second.Balance != BRL(75000) {
+				t.Errorf("second line = %+v", second)
+			}
+
+			all, _ := NewLedger(store, nil).Statement("Duarte", "BRL", time.Time{}, time.Time{})
+			if all.Opening != BRL(0) || len(all.Lines) != 4 || all.Closing != BRL(76000) {
+				t.Errorf("full statement = %+v; want 4 lines closing at R$ 760,00", all)
+			}
+		})
+	}
+}
+
+func TestTransactionType(t *testing.T) {
+	debit := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}
+	credit := debit
+	credit.Type = TypeCredit
+	if debit.Fingerprint() == credit.Fingerprint() {
+		t.Errorf("debit and credit should not share a fingerprint")
+	}
+	explicit := debit
+	explicit.Type = TypeDebit
+	if debit.Fingerprint() != explicit.Fingerprint() {
+		t.Errorf("an empty type is a debit and should share its fingerprint")
+	}
+
+	invalid := debit
+	invalid.Type = "refund"
+	if _, err := NewService(NewMemoryRepository()).Process(invalid); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("Process(refund) = %v; want ErrInvalidType", err)
+	}
+}
+
+func TestFileLedgerStoreStopsAfterUndoneWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.ledger")
+	store, err := OpenFileLedgerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ledger := NewLedger(store, nil)
+
+	// somente leitura: a escrita falha e o corte também | read-only: the write fails and so does the truncation
+	file := store.file
+	readOnly, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	store.file = readOnly
+	tx := Transaction{ID: "tx1", Customer: "Duarte", Amount: BRL(1000), Type: TypeCredit}
+	if _, err := ledger.Post(tx); !errors.Is(err, ErrLogUnwritable) {
+		t.Fatalf("Post on a log that can't be repaired = %v; want ErrLogUnwritable", err)
+	}
+
+	// a linha pela metade pode ter ficado: nada mais é acrescentado depois dela | the half line may be there: nothing else is appended after it
+	store.file = file
+	if _, err := ledger.Post(tx); !errors.Is(err, ErrLogUnwritable) {
+		t.Errorf("Post after ErrLogUnwritable = %v; want ErrLogUnwritable", err)
+	}
+}
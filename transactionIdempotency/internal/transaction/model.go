@@ -4,5 +4,6 @@ package transaction
 type Transaction struct {
 	ID string 
 	Customer string
-	Amount float64
+	Amount Money // centavos e moeda; antes era float64 em reais | cents and currency; it used to be a float64 in reais
+	Type TransactionType `json:",omitempty"` // débito ou crédito; vazio é débito | debit or credit; empty is debit
 }
\ No newline at end of file
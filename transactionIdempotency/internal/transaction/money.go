@@ -0,0 +1,157 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency é a moeda dos valores sem código, inclusive os registros gravados quando Amount era float64 | DefaultCurrency is the currency of values without a code, including records stored when Amount was a float64
+const DefaultCurrency = "BRL"
+
+// Money é um valor exato em centavos (a menor unidade da moeda) com o código ISO 4217 | Money is an exact amount in cents (the currency minor unit) with its ISO 4217 code
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+// currencyInfo é o símbolo, as casas decimais e a localidade de formatação de uma moeda | currencyInfo is a currency symbol, decimal places and formatting locale
+type currencyInfo struct {
+	symbol   string
+	decimals int
+	locale   Locale
+}
+
+// currencies conhecidas; outros códigos ISO 4217 válidos usam o próprio código, 2 casas e LocalePtBR | known currencies; other valid ISO 4217 codes use the code itself, 2 places and LocalePtBR
+var currencies = map[string]currencyInfo{
+	"BRL": {symbol: "R$", decimals: 2, locale: LocalePtBR},
+	"USD": {symbol: "US$", decimals: 2, locale: LocaleEnUS},
+	"EUR": {symbol: "€", decimals: 2, locale: LocalePtPT},
+	"JPY": {symbol: "¥", decimals: 0, locale: LocaleEnUS},
+}
+
+// Locale são os separadores de milhar e de decimais de uma localidade | Locale is the thousands and decimal separators of a locale
+type Locale struct {
+	Thousands string
+	Decimal   string
+}
+
+// localidades prontas | ready-made locales
+var (
+	LocalePtBR = Locale{Thousands: ".", Decimal: ","}
+	LocalePtPT = Locale{Thousands: " ", Decimal: ","}
+	LocaleEnUS = Locale{Thousands: ",", Decimal: "."}
+)
+
+// NewMoney cria um valor em centavos na moeda dada | NewMoney creates an amount in cents in the given currency
+func NewMoney(cents int64, currency string) Money {
+	return Money{Cents: cents, Currency: currency}
+}
+
+// BRL cria um valor em centavos de real | BRL creates an amount in cents of real
+func BRL(cents int64) Money {
+	return NewMoney(cents, "BRL")
+}
+
+// FromFloat converte um valor em unidades (ex: 10.5 reais) para centavos, arredondando | FromFloat converts an amount in units (e.g. 10.5 reais) to cents, rounding
+func FromFloat(units float64, currency string) Money {
+	scale := math.Pow10(lookupCurrency(currency).decimals)
+	return NewMoney(int64(math.Round(units*scale)), currency)
+}
+
+// lookupCurrency devolve os dados da moeda; sem código vale DefaultCurrency | lookupCurrency returns the currency data; without a code DefaultCurrency applies
+func lookupCurrency(code string) currencyInfo {
+	if code == "" {
+		code = DefaultCurrency
+	}
+	if info, ok := currencies[code]; ok {
+		return info
+	}
+	return currencyInfo{symbol: code, decimals: 2, locale: LocalePtBR}
+}
+
+// code devolve o código da moeda, com DefaultCurrency quando vazio | code returns the currency code, with DefaultCurrency when empty
+func (m Money) code() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// Units devolve o valor em unidades como float64, só para exibição e para a coluna antiga do SQLite | Units returns the amount in units as a float64, only for display and the old SQLite column
+func (m Money) Units() float64 {
+	return float64(m.Cents) / math.Pow10(lookupCurrency(m.Currency).decimals)
+}
+
+// Decimal devolve o valor sem símbolo e sem zeros à direita (ex: "10.5", "1000000") | Decimal returns the amount without symbol and trailing zeros (e.g. "10.5", "1000000")
+// É a mesma forma que strconv.FormatFloat(v, 'f', -1, 64) dava ao antigo Amount float64 | It is the same form strconv.FormatFloat(v, 'f', -1, 64) gave the old float64 Amount
+func (m Money) Decimal() string {
+	whole, fraction := m.split(lookupCurrency(m.Currency).decimals)
+	fraction = strings.TrimRight(fraction, "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+// Format escreve o valor com o símbolo da moeda e os separadores da localidade (ex: "R$ 1.000.000,00") | Format writes the amount with the currency symbol and the locale separators (e.g. "R$ 1.000.000,00")
+func (m Money) Format(locale Locale) string {
+	info := lookupCurrency(m.Currency)
+	whole, fraction := m.split(info.decimals)
+	sign := ""
+	if strings.HasPrefix(whole, "-") {
+		sign, whole = "-", whole[1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(locale.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+	if fraction != "" {
+		grouped.WriteString(locale.Decimal + fraction)
+	}
+	return sign + info.symbol + " " + grouped.String()
+}
+
+// String formata o valor na localidade da moeda | String formats the amount in the currency locale
+func (m Money) String() string {
+	return m.Format(lookupCurrency(m.Currency).locale)
+}
+
+// split separa a parte inteira (com sinal) e as casas decimais | split separates the whole part (with sign) and the decimal places
+func (m Money) split(decimals int) (string, string) {
+	digits := strconv.FormatInt(m.Cents, 10)
+	sign := ""
+	if m.Cents < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if decimals == 0 {
+		return sign + digits, ""
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	cut := len(digits) - decimals
+	return sign + digits[:cut], digits[cut:]
+}
+
+// UnmarshalJSON aceita o formato {"Cents":..,"Currency":..} e o número em reais dos registros antigos | UnmarshalJSON accepts the {"Cents":..,"Currency":..} form and the number in reais of old records
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		var units float64
+		if err := json.Unmarshal(data, &units); err != nil {
+			return fmt.Errorf("amount: %w", err)
+		}
+		*m = FromFloat(units, DefaultCurrency)
+		return nil
+	}
+	type plain Money // sem o método, para não voltar aqui | without the method, so it doesn't come back here
+	return json.Unmarshal(data, (*plain)(m))
+}
@@ -0,0 +1,164 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoneyFormat(t *testing.T) {
+	cases := []struct {
+		money Money
+		want  string
+	}{
+		{BRL(100000000), "R$ 1.000.000,00"},
+		{BRL(5), "R$ 0,05"},
+		{BRL(-123456), "-R$ 1.234,56"},
+		{NewMoney(123456789, "USD"), "US$ 1,234,567.89"},
+		{NewMoney(150000, "EUR"), "€ 1 500,00"},
+		{NewMoney(1500, "JPY"), "¥ 1,500"},
+		{NewMoney(1050, "CHF"), "CHF 10,50"},
+	}
+	for _, c := range cases {
+		if got := c.money.String(); got != c.want {
+			t.Errorf("%+v.String() = %q; want %q", c.money, got, c.want)
+		}
+	}
+	if got := NewMoney(123456789, "USD").Format(LocalePtBR); got != "US$ 1.234.567,89" {
+		t.Errorf("USD in pt-BR = %q", got)
+	}
+}
+
+func TestMoneyDecimalMatchesOldFloat(t *testing.T) {
+	for _, c := range []struct {
+		money Money
+		want  string
+	}{
+		{BRL(100000000), "1000000"},
+		{BRL(1050), "10.5"},
+		{BRL(1), "0.01"},
+		{NewMoney(1500, "JPY"), "1500"},
+	} {
+		if got := c.money.Decimal(); got != c.want {
+			t.Errorf("%+v.Decimal() = %q; want %q", c.money, got, c.want)
+		}
+	}
+	if got := FromFloat(0.1+0.2, "BRL"); got != BRL(30) {
+		t.Errorf("FromFloat(0.1+0.2) = %+v; want 30 cents", got)
+	}
+}
+
+// impressões gravadas quando Amount era float64 continuam batendo | fingerprints stored when Amount was a float64 still match
+func TestFingerprintCompatibleWithFloatAmounts(t *testing.T) {
+	sum := sha256.Sum256([]byte("customer=\"Duarte\"\namount=1000000.5\n"))
+	old := hex.EncodeToString(sum[:])
+	tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000050)}
+	if !(Record{Fingerprint: old}).Matches(tx.Fingerprint()) {
+		t.Errorf("BRL fingerprint changed from the float64 form")
+	}
+	if (Transaction{Customer: "Duarte", Amount: NewMoney(100000050, "USD")}).Fingerprint() == tx.Fingerprint() {
+		t.Errorf("different currencies should not share a fingerprint")
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var legacy Transaction
+	if err := json.Unmarshal([]byte(`{"ID":"tx84","Customer":"Duarte","Amount":1000000.1}`), &legacy); err != nil {
+		t.Fatalf("legacy: %v", err)
+	}
+	if legacy.Amount != BRL(100000010) {
+		t.Errorf("legacy amount = %+v; want R$ 1.000.000,10", legacy.Amount)
+	}
+
+	tx := Transaction{ID: "tx85", Customer: "Duarte", Amount: NewMoney(999, "USD")}
+	encoded, _ := json.Marshal(tx)
+	var decoded Transaction
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != tx {
+		t.Errorf("round trip = %+v, %v; want %+v", decoded, err, tx)
+	}
+}
+
+func TestValidationBeforeIdempotency(t *testing.T) {
+	service := NewService(NewMemoryRepository(), WithMaxAmount(NewMoney(100000, "USD")))
+	valid := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}
+	cases := []struct {
+		tx    Transaction
+		field string
+		want  error
+	}{
+		{Transaction{ID: "", Customer: "Duarte", Amount: BRL(100)}, "ID", ErrInvalidID},
+		{Transaction{ID: "tx 84", Customer: "Duarte", Amount: BRL(100)}, "ID", ErrInvalidID},
+		{Transaction{ID: "tx84", Customer: " ", Amount: BRL(100)}, "Customer", ErrEmptyCustomer},
+		{Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(0)}, "Amount", ErrInvalidAmount},
+		{Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(-100)}, "Amount", ErrInvalidAmount},
+		{Transaction{ID: "tx84", Customer: "Duarte", Amount: NewMoney(100, "real")}, "Amount.Currency", ErrInvalidCurrency},
+		{Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(DefaultMaxAmount.Cents + 1)}, "Amount", ErrAmountOverLimit},
+		{Transaction{ID: "tx84", Customer: "Duarte", Amount: NewMoney(100001, "USD")}, "Amount", ErrAmountOverLimit},
+	}
+	for _, c := range cases {
+		_, err := service.Process(c.tx)
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) || invalid.Field != c.field || !errors.Is(err, c.want) {
+			t.Errorf("Process(%+v) = %v; want %v on %s", c.tx, err, c.want, c.field)
+		}
+	}
+
+	// as transações recusadas não gastaram a chave | the rejected transactions didn't use up the key
+	if result, err := service.Process(valid); err != nil || result.Replayed {
+		t.Errorf("Process(valid) = %+v, %v; want a fresh run", result, err)
+	}
+	if err := service.Receive(Transaction{ID: "tx85", Customer: "", Amount: BRL(100)}); !errors.Is(err, ErrEmptyCustomer) {
+		t.Errorf("Receive without customer = %v; want ErrEmptyCustomer", err)
+	}
+}
+
+// moeda vazia vale BRL na validação, no razão e na chave, como em Money | an empty currency means BRL in validation, the ledger and the key, as in Money
+func TestEmptyCurrencyMeansDefault(t *testing.T) {
+	ledger := NewLedger(NewMemoryLedgerStore(), nil)
+	service := NewService(NewMemoryRepository(), WithHandler(ledger))
+	tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: Money{Cents: 100}, Type: TypeCredit}
+	if err := tx.Validate(); err != nil {
+		t.Errorf("Validate without currency = %v; want nil", err)
+	}
+	if result, err := service.Process(tx); err != nil || result.Status != StatusSucceeded {
+		t.Fatalf("Process without currency = %+v, %v; want succeeded", result, err)
+	}
+	if balance, err := ledger.Balance("Duarte", DefaultCurrency); err != nil || balance != BRL(100) {
+		t.Errorf("Balance = %v, %v; want R$ 1,00 in %s", balance, err, DefaultCurrency)
+	}
+	tx.Amount = BRL(100)
+	if replay, err := service.Process(tx); err != nil || !replay.Replayed {
+		t.Errorf("retry with an explicit BRL = %+v, %v; want the stored outcome", replay, err)
+	}
+}
+
+// o SQLite antigo, com amount REAL, ganha amount_cents na migração | the old SQLite, with amount REAL, gets amount_cents in the migration
+func TestSQLiteMigratesAmountToCents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range sqliteMigrations[:6] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatalf("migration: %v", err)
+		}
+	}
+	db.Exec(`PRAGMA user_version = 6`)
+	db.Exec(`INSERT INTO processed_transactions (id, customer, amount) VALUES ('tx84', 'Duarte', 1000000.1)`)
+	db.Close()
+
+	repo, err := OpenSQLiteRepository(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer repo.Close()
+	record, exists, err := repo.Find("tx84")
+	if err != nil || !exists || record.Amount != BRL(100000010) {
+		t.Errorf("migrated record = %+v, %v, %v; want R$ 1.000.000,10", record.Amount, exists, err)
+	}
+}
@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Message é um efeito externo da transação (ex: o pedido de débito ao banco) guardado no registro até ser publicado | Message is an external effect of the transaction (e.g. the debit request to the bank) kept in the record until published
+// O ID é estável entre reentregas, para o consumidor descartar duplicadas | The ID is stable across redeliveries, so the consumer can drop duplicates
+type Message struct {
+	ID          string
+	Topic       string
+	Payload     []byte
+	DeliveredAt time.Time
+}
+
+// Delivered diz se a mensagem já foi publicada | Delivered reports whether the message was already published
+func (m Message) Delivered() bool {
+	return !m.DeliveredAt.IsZero()
+}
+
+// PendingOutbox diz se o registro tem mensagens ainda não publicadas | PendingOutbox reports whether the record has unpublished messages
+func (r Record) PendingOutbox() bool {
+	for _, message := range r.Outbox {
+		if !message.Delivered() {
+			return true
+		}
+	}
+	return false
+}
+
+// Publisher entrega as mensagens do outbox ao mundo externo | Publisher delivers outbox messages to the outside world
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+// PublisherFunc adapta uma função comum para Publisher | PublisherFunc adapts a plain function to Publisher
+type PublisherFunc func(ctx context.Context, m Message) error
+
+// Publish chama a função | Publish calls the function
+func (f PublisherFunc) Publish(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// OutboxRelay publica as mensagens gravadas e marca cada uma como entregue | OutboxRelay publishes the stored messages and marks each one as delivered
+// A entrega é pelo menos uma vez: uma queda entre Publish e a marcação publica a mensagem de novo com o mesmo ID | Delivery is at least once: a crash between Publish and the mark publishes the message again with the same ID
+type OutboxRelay struct {
+	repo      Repository
+	publisher Publisher
+	now       func() time.Time
+}
+
+// NewOutboxRelay cria o relay; now nil usa time.Now | NewOutboxRelay creates the relay; a nil now uses time.Now
+func NewOutboxRelay(repo Repository, publisher Publisher, now func() time.Time) *OutboxRelay {
+	if now == nil {
+		now = time.Now
+	}
+	return &OutboxRelay{repo: repo, publisher: publisher, now: now}
+}
+
+// Deliver publica as mensagens pendentes de uma transação | Deliver publishes the pending messages of one transaction
+func (r *OutboxRelay) Deliver(ctx context.Context, id string) error {
+	record, exists, err := r.repo.Find(id)
+	if err != nil || !exists {
+		return err
+	}
+	for i, message := range record.Outbox {
+		if message.Delivered() {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, message); err != nil {
+			return fmt.Errorf("publish %s: %w", message.ID, err)
+		}
+		if err := r.markDelivered(id, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverPending publica as mensagens de até limit transações com pendências; é o que recupera as entregas interrompidas por uma queda | DeliverPending publishes the messages of up to limit transactions with pending messages; it recovers deliveries interrupted by a crash
+func (r *OutboxRelay) DeliverPending(ctx context.Context, limit int) (int, error) {
+	records, err := r.repo.ListPendingOutbox(limit)
+	if err != nil {
+		return 0, err
+	}
+	for i, record := range records {
+		if err := r.Deliver(ctx, record.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
+
+// markDelivered grava a data de entrega da mensagem i | markDelivered stores the delivery time of message i
+func (r *OutboxRelay) markDelivered(id string, i int) error {
+	_, err := r.repo.Update(id, func(current Record, exists bool) (Record, bool, error) {
+		if !exists || i >= len(current.Outbox) || current.Outbox[i].Delivered() {
+			return current, false, nil
+		}
+		outbox := append([]Message(nil), current.Outbox...) // cópia: o registro atual pode ser compartilhado | copy: the current record may be shared
+		outbox[i].DeliveredAt = r.now()
+		current.Outbox = outbox
+		return current, true, nil
+	})
+	return err
+}
@@ -0,0 +1,220 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// debitHandler conta as execuções e pede um débito ao banco pelo outbox | debitHandler counts its runs and asks the bank for a debit through the outbox
+type debitHandler struct {
+	runs atomic.Int32
+}
+
+func (h *debitHandler) Handle(ctx context.Context, t Transaction) (Effects, error) {
+	h.runs.Add(1)
+	return Effects{
+		Result:   []byte("debited " + t.ID),
+		Messages: []Message{{Topic: "bank.debit", Payload: []byte(t.Customer)}},
+	}, nil
+}
+
+// recordingPublisher guarda os IDs publicados; fail faz a próxima publicação falhar depois de sair | recordingPublisher keeps the published IDs; fail makes the next publish fail after it went out
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []string
+	fail      bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, m Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, m.ID)
+	if p.fail {
+		p.fail = false
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (p *recordingPublisher) IDs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+func TestHandlerRunsOncePerKey(t *testing.T) {
+	const ids, duplicates = 20, 25
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+			handler := &debitHandler{}
+			service := NewService(repo, WithHandler(handler))
+
+			var wg sync.WaitGroup
+			for i := 0; i < ids*duplicates; i++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					_, err := service.Process(Transaction{ID: fmt.Sprintf("tx%d", n), Customer: "Duarte", Amount: BRL(100)})
+					if err != nil && !errors.Is(err, ErrTransactionInProgress) {
+						t.Errorf("tx%d: unexpected error %v", n, err)
+					}
+				}(i % ids)
+			}
+			wg.Wait()
+
+			if runs := handler.runs.Load(); runs != ids {
+				t.Errorf("handler ran %d times; want %d", runs, ids)
+			}
+			publisher := &recordingPublisher{}
+			relay := NewOutboxRelay(repo, publisher, nil)
+			if _, err := relay.DeliverPending(context.Background(), 2*ids); err != nil {
+				t.Fatalf("DeliverPending: %v", err)
+			}
+			if got := len(publisher.IDs()); got != ids {
+				t.Errorf("published %d messages; want %d", got, ids)
+			}
+		})
+	}
+}
+
+func TestCrashBeforeCommitRerunsHandlerButPublishesOnce(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			handler := &debitHandler{}
+			service := NewService(repo, WithClock(clock.Now), WithLeaseTTL(time.Minute), WithHandler(handler))
+			tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+
+			// o worker roda o handler e cai antes de gravar o desfecho | the worker runs the handler and crashes before storing the outcome
+			if _, _, err := service.Begin(tx); err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			handler.Handle(context.Background(), tx)
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				service = NewService(repo, WithClock(clock.Now), WithLeaseTTL(time.Minute), WithHandler(handler))
+			}
+			defer repo.Close()
+
+			clock.Advance(2 * time.Minute)
+			result, err := service.Process(tx)
+			if err != nil || result.Status != StatusSucceeded || string(result.Result) != "debited tx84" {
+				t.Fatalf("Process after crash = %+v, %v", result, err)
+			}
+			if runs := handler.runs.Load(); runs != 2 {
+				t.Errorf("handler ran %d times; want 2 (the crashed run is repeated)", runs)
+			}
+
+			publisher := &recordingPublisher{}
+			relay := NewOutboxRelay(repo, publisher, clock.Now)
+			for i := 0; i < 2; i++ {
+				if _, err := relay.DeliverPending(context.Background(), 10); err != nil {
+					t.Fatalf("DeliverPending: %v", err)
+				}
+			}
+			if ids := publisher.IDs(); len(ids) != 1 || ids[0] != "tx84-0" {
+				t.Errorf("published %v; want only tx84-0", ids)
+			}
+		})
+	}
+}
+
+func TestCrashBeforeDeliveryRedeliversSameMessage(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			service := NewService(repo, WithClock(clock.Now), WithRetention(KeepFor(time.Hour)), WithHandler(&debitHandler{}))
+			if _, err := service.Process(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}); err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+
+			// a mensagem sai, mas a conexão cai antes da confirmação | the message goes out, but the connection drops before the acknowledgement
+			publisher := &recordingPublisher{fail: true}
+			relay := NewOutboxRelay(repo, publisher, clock.Now)
+			if _, err := relay.DeliverPending(context.Background(), 10); err == nil {
+				t.Fatalf("DeliverPending should report the failed publish")
+			}
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				relay = NewOutboxRelay(repo, publisher, clock.Now)
+			}
+			defer repo.Close()
+
+			// a retenção venceu, mas o registro com mensagem pendente fica | retention is over, but the record with a pending message stays
+			clock.Advance(2 * time.Hour)
+			if removed, err := repo.DeleteExpired(clock.Now()); err != nil || removed != 0 {
+				t.Fatalf("DeleteExpired = %d, %v; want the pending record kept", removed, err)
+			}
+			if delivered, err := relay.DeliverPending(context.Background(), 10); err != nil || delivered != 1 {
+				t.Fatalf("DeliverPending after restart = %d, %v", delivered, err)
+			}
+			if ids := publisher.IDs(); len(ids) != 2 || ids[0] != ids[1] {
+				t.Errorf("published %v; want the same ID twice", ids)
+			}
+			if pending, _ := repo.ListPendingOutbox(10); len(pending) != 0 {
+				t.Errorf("still pending after delivery: %+v", pending)
+			}
+			if removed, err := repo.DeleteExpired(clock.Now()); err != nil || removed != 1 {
+				t.Errorf("DeleteExpired after delivery = %d, %v; want 1", removed, err)
+			}
+		})
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	calls := 0
+	handler := HandlerFunc(func(ctx context.Context, t Transaction) (Effects, error) {
+		calls++
+		if calls == 1 {
+			return Effects{Messages: []Message{{Topic: "bank.debit"}}}, Retryable(errors.New("bank timeout"))
+		}
+		return Effects{}, errors.New("card blocked")
+	})
+	repo := NewMemoryRepository()
+	service := NewService(repo, WithHandler(handler))
+	tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+
+	result, err := service.Process(tx)
+	if err != nil || result.Status != StatusFailedRetryable || result.Error != "bank timeout" {
+		t.Fatalf("first Process = %+v, %v; want failed_retryable", result, err)
+	}
+	if pending, _ := repo.ListPendingOutbox(10); len(pending) != 0 {
+		t.Errorf("a failed attempt must not leave messages: %+v", pending)
+	}
+
+	result, err = service.Process(tx)
+	if err != nil || result.Status != StatusFailedFinal || result.Err() == nil {
+		t.Fatalf("second Process = %+v, %v; want failed_final", result, err)
+	}
+	if replay, _ := service.Process(tx); !replay.Replayed || replay.Error != "card blocked" || calls != 2 {
+		t.Errorf("replay = %+v after %d calls; want the final failure without a new call", replay, calls)
+	}
+}
@@ -1,13 +1,76 @@
 package transaction
 
-// Repository armazena transações processadas para garantir idempotência | Repository stores processed transactions to ensure idempotency
-type Repository struct {
-	processed map[string]Transaction
+import "time"
+
+// Record é o que fica gravado para cada chave: a transação, a impressão digital do conteúdo e o desfecho | Record is what is stored for each key: the transaction, its payload fingerprint and the outcome
+// Transaction vai embutida para o JSON continuar plano (registros antigos, sem Fingerprint, continuam legíveis) | Transaction is embedded so the JSON stays flat (old records, without Fingerprint, remain readable)
+type Record struct {
+	Transaction
+	Fingerprint    string
+	Outcome        ProcessResult
+	Lease          string    `json:",omitempty"` // token do worker que está processando | token of the worker processing it
+	LeaseExpiresAt time.Time // depois disso outro worker pode assumir | after this another worker may take over
+	Attempts       int       // quantas vezes um worker começou o processamento | how many times a worker started processing
+	ExpiresAt      time.Time // fim da retenção; zero guarda para sempre | end of retention; zero keeps it forever
+	Outbox         []Message `json:",omitempty"` // efeitos gravados junto do desfecho (outbox.go) | effects stored with the outcome (outbox.go)
+}
+
+// NewRecord cria o registro da transação com a sua impressão digital | NewRecord creates the transaction record with its fingerprint
+func NewRecord(t Transaction) Record {
+	return Record{Transaction: t, Fingerprint: t.Fingerprint()}
 }
 
-// NewRepository cria um novo repositório de transações | NewRepository creates a new transaction repository
-func NewRepository() *Repository {
-	return &Repository{
-		processed: make(map[string]Transaction),
+// Matches diz se o registro guardado tem o mesmo conteúdo da impressão digital informada | Matches reports whether the stored record has the same contents as the given fingerprint
+func (r Record) Matches(fingerprint string) bool {
+	stored := r.Fingerprint
+	if stored == "" {
+		stored = r.Transaction.Fingerprint() // registro gravado antes das impressões digitais | record stored before fingerprints existed
 	}
-}
\ No newline at end of file
+	return stored == fingerprint
+}
+
+// Status devolve a situação do registro; registros anteriores à máquina de estados eram todos bem-sucedidos | Status returns the record state; records from before the state machine were all successful
+func (r Record) Status() Status {
+	if r.Outcome.Status == "" {
+		return StatusSucceeded
+	}
+	return r.Outcome.Status
+}
+
+// Replay devolve o desfecho gravado marcado como repetição | Replay returns the stored outcome flagged as a replay
+// Registros gravados antes dos desfechos só existiam para transações bem-sucedidas | Records stored before outcomes only existed for successful transactions
+func (r Record) Replay() ProcessResult {
+	outcome := r.Outcome
+	if outcome.Status == "" {
+		outcome = ProcessResult{TransactionID: r.ID, Status: StatusSucceeded}
+	}
+	outcome.Replayed = true
+	return outcome
+}
+
+// UpdateFunc recebe o registro atual e decide o próximo; write=false não grava nada | UpdateFunc gets the current record and decides the next one; write=false stores nothing
+// Um erro cancela a atualização e é devolvido por Update | An error cancels the update and is returned by Update
+type UpdateFunc func(current Record, exists bool) (next Record, write bool, err error)
+
+// Repository armazena transações processadas para garantir idempotência | Repository stores processed transactions to ensure idempotency
+// Implementações: memória, arquivo (WAL) e SQLite | Implementations: memory, file (WAL) and SQLite
+type Repository interface {
+	// Find busca o registro pelo ID | Find looks up the record by ID
+	Find(id string) (Record, bool, error)
+	// Claim grava o registro só se o ID ainda não existe, numa única operação atômica | Claim stores the record only if its ID is new, in a single atomic operation
+	// Retorna claimed=false e o registro já gravado quando o ID existe | Returns claimed=false and the stored record when the ID exists
+	Claim(r Record) (stored Record, claimed bool, err error)
+	// Update lê, decide (fn) e grava o registro do ID sem que outra escrita no mesmo ID aconteça no meio | Update reads, decides (fn) and writes the ID's record with no other write to the same ID in between
+	// Devolve o registro como ficou: o gravado, ou o atual quando fn não grava | Returns the record as it ended up: the written one, or the current one when fn doesn't write
+	Update(id string, fn UpdateFunc) (Record, error)
+	// DeleteExpired remove os registros com retenção vencida em now e devolve quantos saíram | DeleteExpired removes the records whose retention is over at now and returns how many were removed
+	DeleteExpired(now time.Time) (int, error)
+	// ListPendingOutbox devolve até limit registros com mensagens ainda não publicadas | ListPendingOutbox returns up to limit records with unpublished messages
+	ListPendingOutbox(limit int) ([]Record, error)
+	// Stats devolve o tamanho do armazenamento e as remoções | Stats returns the store size and removals
+	Stats() (StoreStats, error)
+	// Save grava o registro de forma durável antes de retornar | Save durably stores the record before returning
+	Save(r Record) error
+	// Close libera os recursos do armazenamento | Close releases the store resources
+	Close() error
+}
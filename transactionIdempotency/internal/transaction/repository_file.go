@@ -0,0 +1,346 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileRepository grava cada transação numa linha JSON de um log só de acréscimo (WAL) com fsync | FileRepository appends each transaction as a JSON line to an append-only log (WAL) with fsync
+// Ao abrir, o log é relido para montar o índice em memória | On open, the log is replayed to rebuild the in-memory index
+// Cada ID tem o seu lock (keys) entre a consulta e a escrita; mu protege o arquivo e o índice | Each ID has its own lock (keys) between lookup and write; mu guards the file and the index
+type FileRepository struct {
+	keys      shardedLocks
+	mu        sync.RWMutex
+	path      string
+	file      *os.File
+	processed map[string]Record
+	expired   int64
+	broken    error // ErrLogUnwritable depois de uma escrita que não pôde ser desfeita | ErrLogUnwritable after a write that couldn't be undone
+}
+
+// OpenFileRepository abre (ou cria) o log no caminho informado e relê as transações gravadas | OpenFileRepository opens (or creates) the log at path and replays the stored transactions
+// Uma última linha incompleta, deixada por uma queda no meio da escrita, é descartada | A torn last line, left by a crash mid-write, is discarded
+func OpenFileRepository(path string) (*FileRepository, error) {
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FileRepository{
+		path:      path,
+		file:      file,
+		processed: make(map[string]Record),
+	}
+	if err := r.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// o arquivo novo só sobrevive a uma queda depois do fsync do diretório | a new file only survives a crash after the directory is fsynced
+	if created {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// replay relê o log do início e corta a cauda incompleta | replay reads the log from the start and truncates the torn tail
+func (r *FileRepository) replay() error {
+	return replayJSONLines(r.file, func(data []byte) error {
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		r.processed[record.ID] = record
+		return nil
+	})
+}
+
+// replayJSONLines passa cada linha completa do log para apply e corta a cauda incompleta | replayJSONLines hands each complete log line to apply and truncates the torn tail
+// Um erro de apply na última linha é uma escrita interrompida; no meio do arquivo é ErrCorruptLog | An apply error on the last line is an interrupted write; in the middle of the file it is ErrCorruptLog
+func replayJSONLines(file *os.File, apply func(data []byte) error) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	var valid int64 // fim da última linha completa | end of the last complete line
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				return file.Truncate(valid) // linha sem '\n': escrita interrompida | line without '\n': interrupted write
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := apply(bytes.TrimSpace(data)); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return file.Truncate(valid) // só a última linha está estragada | only the last line is damaged
+			}
+			return fmt.Errorf("%w: line %d: %v", ErrCorruptLog, line, err)
+		}
+		valid += int64(len(data))
+	}
+}
+
+// logFile é o que appendLine usa do arquivo de log; *os.File o implementa | logFile is what appendLine uses from the log file; *os.File implements it
+type logFile interface {
+	io.Writer
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// appendLine acrescenta a linha ao log com fsync; se a escrita ou o fsync falhar, corta o arquivo de volta ao tamanho anterior | appendLine appends the line to the log with fsync; if the write or the fsync fails, it truncates the file back to its previous size
+// Sem o corte, o próximo acréscimo ficaria depois da linha pela metade e a reabertura daria ErrCorruptLog | Without the truncation, the next append would land after the partial line and reopening would give ErrCorruptLog
+// Se nem o corte der certo, o erro traz ErrLogUnwritable e quem chama não deve acrescentar mais nada | If even the truncation fails, the error carries ErrLogUnwritable and the caller must not append anything else
+func appendLine(file logFile, data []byte) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+	if truncErr := file.Truncate(info.Size()); truncErr != nil {
+		return fmt.Errorf("%w: %w (truncate: %v)", ErrLogUnwritable, err, truncErr)
+	}
+	return err
+}
+
+// Find busca a transação no índice em memória | Find looks up the transaction in the in-memory index
+func (r *FileRepository) Find(id string) (Record, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.file == nil {
+		return Record{}, false, ErrRepositoryClosed
+	}
+	record, exists := r.processed[id]
+	return record, exists, nil
+}
+
+// Claim acrescenta a transação ao log só se o ID for novo | Claim appends the transaction to the log only if the ID is new
+func (r *FileRepository) Claim(record Record) (Record, bool, error) {
+	unlock := r.keys.lock(record.ID)
+	defer unlock()
+
+	stored, exists, err := r.Find(record.ID)
+	if err != nil || exists {
+		return stored, false, err
+	}
+	if err := r.append(record); err != nil {
+		return Record{}, false, err
+	}
+	return record, true, nil
+}
+
+// Update roda fn com o ID travado e acrescenta o novo registro ao log | Update runs fn holding the ID lock and appends the new record to the log
+func (r *FileRepository) Update(id string, fn UpdateFunc) (Record, error) {
+	unlock := r.keys.lock(id)
+	defer unlock()
+
+	current, exists, err := r.Find(id)
+	if err != nil {
+		return Record{}, err
+	}
+	next, write, err := fn(current, exists)
+	if err != nil {
+		return Record{}, err
+	}
+	if !write {
+		return current, nil
+	}
+	if err := r.append(next); err != nil {
+		return Record{}, err
+	}
+	return next, nil
+}
+
+// Save acrescenta a transação ao log e só retorna depois do fsync | Save appends the transaction to the log and only returns after fsync
+func (r *FileRepository) Save(record Record) error {
+	unlock := r.keys.lock(record.ID)
+	defer unlock()
+
+	return r.append(record)
+}
+
+// append escreve a linha, faz o fsync e atualiza o índice | append writes the line, fsyncs and updates the index
+// Uma escrita que falhou é desfeita (appendLine); o registro só entra no índice se ficou no disco | A failed write is undone (appendLine); the record only enters the index if it reached the disk
+func (r *FileRepository) append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return ErrRepositoryClosed
+	}
+	if r.broken != nil {
+		return r.broken
+	}
+	if err := appendLine(r.file, data); err != nil {
+		if errors.Is(err, ErrLogUnwritable) {
+			r.broken = err
+		}
+		return err
+	}
+	r.processed[record.ID] = record
+	return nil
+}
+
+// DeleteExpired tira os registros vencidos do índice e compacta o log | DeleteExpired drops expired records from the index and compacts the log
+// A compactação reescreve só a última versão de cada registro vivo num arquivo novo e troca os dois com rename | Compaction rewrites only the latest version of each live record to a new file and swaps the two with rename
+func (r *FileRepository) DeleteExpired(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, ErrRepositoryClosed
+	}
+	var expired []string
+	for id, record := range r.processed {
+		if record.Expired(now) {
+			expired = append(expired, id)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	live := make([]Record, 0, len(r.processed)-len(expired))
+	for _, record := range r.processed {
+		if !record.Expired(now) {
+			live = append(live, record)
+		}
+	}
+	if err := r.compact(live); err != nil {
+		return 0, err
+	}
+	for _, id := range expired {
+		delete(r.processed, id)
+	}
+	r.expired += int64(len(expired))
+	return len(expired), nil
+}
+
+// compact grava os registros num arquivo temporário com fsync e o coloca no lugar do log (mu travado por quem chama) | compact writes the records to a temp file with fsync and puts it in place of the log (mu locked by the caller)
+// Uma queda no meio deixa o log antigo intacto | A crash midway leaves the old log intact
+func (r *FileRepository) compact(records []Record) error {
+	tmpPath := r.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer) // Encode já termina cada registro com '\n' | Encode already ends each record with '\n'
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(r.path)); err != nil {
+		return err
+	}
+
+	// o descritor antigo aponta para o arquivo substituído: escrever nele perderia dados | the old descriptor points at the replaced file: writing to it would lose data
+	r.file.Close()
+	r.file, err = os.OpenFile(r.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		r.file = nil
+		return err
+	}
+	r.broken = nil // o arquivo novo não tem a linha pela metade | the new file doesn't have the partial line
+	return nil
+}
+
+// ListPendingOutbox procura no índice os registros com mensagens por publicar | ListPendingOutbox searches the index for records with messages to publish
+func (r *FileRepository) ListPendingOutbox(limit int) ([]Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.file == nil {
+		return nil, ErrRepositoryClosed
+	}
+	var pending []Record
+	for _, record := range r.processed {
+		if len(pending) == limit {
+			break
+		}
+		if record.PendingOutbox() {
+			pending = append(pending, record)
+		}
+	}
+	return pending, nil
+}
+
+// Stats devolve o tamanho do índice e as remoções | Stats returns the index size and removals
+func (r *FileRepository) Stats() (StoreStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return StoreStats{Size: len(r.processed), Expired: r.expired}, nil
+}
+
+// Close fecha o arquivo do log | Close closes the log file
+func (r *FileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// syncDir faz fsync do diretório para persistir a criação do arquivo | syncDir fsyncs the directory to persist the file creation
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,253 @@
+package transaction
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memoryEntry é um registro e a sua posição na lista LRU (nil sem limite de tamanho) | memoryEntry is a record and its position in the LRU list (nil without a size limit)
+type memoryEntry struct {
+	record  Record
+	element *list.Element
+}
+
+// memoryShard é uma fatia do mapa com o seu próprio lock | memoryShard is a slice of the map with its own lock
+type memoryShard struct {
+	mu        sync.RWMutex
+	processed map[string]*memoryEntry
+}
+
+// MemoryRepository guarda as transações só em memória; elas se perdem ao reiniciar | MemoryRepository keeps transactions in memory only; they are lost on restart
+// O mapa é dividido em fatias para muitas chaves não disputarem um único lock | The map is sharded so many keys don't contend on a single lock
+type MemoryRepository struct {
+	shards [shardCount]memoryShard
+
+	maxEntries int
+	lruMu      sync.Mutex
+	lru        *list.List // frente = usado por último; valores são IDs | front = most recently used; values are IDs
+
+	size    atomic.Int64
+	expired atomic.Int64
+	evicted atomic.Int64
+}
+
+// MemoryOption configura o MemoryRepository | MemoryOption configures the MemoryRepository
+type MemoryOption func(*MemoryRepository)
+
+// WithMaxEntries limita o repositório a max registros, descartando os usados há mais tempo (LRU) | WithMaxEntries caps the repository at max records, dropping the least recently used (LRU)
+// Registros em andamento ou com mensagens pendentes no outbox não são descartados; o limite pode ser passado por instantes entre a escrita e o descarte | In-progress records and records with pending outbox messages are never dropped; the cap may be exceeded briefly between the write and the eviction
+func WithMaxEntries(max int) MemoryOption {
+	return func(r *MemoryRepository) {
+		r.maxEntries = max
+	}
+}
+
+// NewMemoryRepository cria um novo repositório em memória | NewMemoryRepository creates a new in-memory repository
+func NewMemoryRepository(options ...MemoryOption) *MemoryRepository {
+	r := &MemoryRepository{}
+	for i := range r.shards {
+		r.shards[i].processed = make(map[string]*memoryEntry)
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if r.maxEntries > 0 {
+		r.lru = list.New()
+	}
+	return r
+}
+
+// shard devolve a fatia do ID | shard returns the ID's shard
+func (r *MemoryRepository) shard(id string) *memoryShard {
+	return &r.shards[shardIndex(id)]
+}
+
+// Find busca a transação pelo ID | Find looks up the transaction by ID
+func (r *MemoryRepository) Find(id string) (Record, bool, error) {
+	shard := r.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.processed[id]
+	if !exists {
+		return Record{}, false, nil
+	}
+	r.touch(entry)
+	return entry.record, true, nil
+}
+
+// Claim grava a transação se o ID for novo, com a fatia travada entre a leitura e a escrita | Claim stores the transaction if the ID is new, holding the shard lock between read and write
+func (r *MemoryRepository) Claim(record Record) (Record, bool, error) {
+	defer r.evict()
+	shard := r.shard(record.ID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if entry, exists := shard.processed[record.ID]; exists {
+		r.touch(entry)
+		return entry.record, false, nil
+	}
+	r.store(shard, record)
+	return record, true, nil
+}
+
+// Update roda fn com a fatia travada | Update runs fn holding the shard lock
+func (r *MemoryRepository) Update(id string, fn UpdateFunc) (Record, error) {
+	defer r.evict()
+	shard := r.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var current Record
+	entry, exists := shard.processed[id]
+	if exists {
+		current = entry.record
+	}
+	next, write, err := fn(current, exists)
+	if err != nil {
+		return Record{}, err
+	}
+	if !write {
+		return current, nil
+	}
+	r.store(shard, next)
+	return next, nil
+}
+
+// Save grava a transação no mapa | Save stores the transaction in the map
+func (r *MemoryRepository) Save(record Record) error {
+	defer r.evict()
+	shard := r.shard(record.ID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	r.store(shard, record)
+	return nil
+}
+
+// DeleteExpired percorre as fatias removendo os registros vencidos | DeleteExpired walks the shards removing expired records
+func (r *MemoryRepository) DeleteExpired(now time.Time) (int, error) {
+	removed := 0
+	for i := range r.shards {
+		shard := &r.shards[i]
+		shard.mu.Lock()
+		for id, entry := range shard.processed {
+			if entry.record.Expired(now) {
+				r.remove(shard, id, entry)
+				removed++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	r.expired.Add(int64(removed))
+	return removed, nil
+}
+
+// ListPendingOutbox percorre as fatias atrás de mensagens por publicar | ListPendingOutbox walks the shards looking for messages to publish
+func (r *MemoryRepository) ListPendingOutbox(limit int) ([]Record, error) {
+	var pending []Record
+	for i := range r.shards {
+		shard := &r.shards[i]
+		shard.mu.RLock()
+		for _, entry := range shard.processed {
+			if len(pending) < limit && entry.record.PendingOutbox() {
+				pending = append(pending, entry.record)
+			}
+		}
+		shard.mu.RUnlock()
+		if len(pending) >= limit {
+			break
+		}
+	}
+	return pending, nil
+}
+
+// Stats devolve o tamanho e as remoções | Stats returns the size and removals
+func (r *MemoryRepository) Stats() (StoreStats, error) {
+	return StoreStats{
+		Size:    int(r.size.Load()),
+		Expired: r.expired.Load(),
+		Evicted: r.evicted.Load(),
+	}, nil
+}
+
+// Close não tem nada a liberar | Close has nothing to release
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// store grava o registro na fatia (travada por quem chama) | store writes the record into the shard (locked by the caller)
+func (r *MemoryRepository) store(shard *memoryShard, record Record) {
+	if entry, exists := shard.processed[record.ID]; exists {
+		entry.record = record
+		r.touch(entry)
+		return
+	}
+	entry := &memoryEntry{record: record}
+	if r.lru != nil {
+		r.lruMu.Lock()
+		entry.element = r.lru.PushFront(record.ID)
+		r.lruMu.Unlock()
+	}
+	shard.processed[record.ID] = entry
+	r.size.Add(1)
+}
+
+// remove tira o registro da fatia (travada por quem chama) e da lista LRU | remove takes the record out of the shard (locked by the caller) and the LRU list
+func (r *MemoryRepository) remove(shard *memoryShard, id string, entry *memoryEntry) {
+	delete(shard.processed, id)
+	if entry.element != nil {
+		r.lruMu.Lock()
+		r.lru.Remove(entry.element)
+		r.lruMu.Unlock()
+	}
+	r.size.Add(-1)
+}
+
+// touch marca o registro como usado agora na lista LRU | touch marks the record as just used in the LRU list
+func (r *MemoryRepository) touch(entry *memoryEntry) {
+	if entry.element == nil {
+		return
+	}
+	r.lruMu.Lock()
+	r.lru.MoveToFront(entry.element)
+	r.lruMu.Unlock()
+}
+
+// evict descarta os registros menos usados até o tamanho voltar ao limite | evict drops the least recently used records until the size is back under the cap
+// Roda sem lock de fatia, depois da escrita: travar outra fatia com a atual travada poderia dar deadlock | Runs without a shard lock, after the write: locking another shard while holding the current one could deadlock
+func (r *MemoryRepository) evict() {
+	if r.lru == nil {
+		return
+	}
+	for skipped := 0; r.size.Load() > int64(r.maxEntries); {
+		r.lruMu.Lock()
+		if skipped >= r.lru.Len() {
+			r.lruMu.Unlock()
+			return // só sobraram registros em andamento ou com outbox pendente | only in-progress records or records with a pending outbox are left
+		}
+		oldest := r.lru.Back()
+		id := oldest.Value.(string)
+		r.lruMu.Unlock()
+
+		shard := r.shard(id)
+		shard.mu.Lock()
+		entry, exists := shard.processed[id]
+		switch {
+		case !exists || entry.element != oldest:
+			// outra goroutine já mexeu nesse registro | another goroutine already changed this record
+		case entry.record.Status() == StatusInProgress || entry.record.PendingOutbox():
+			// como em Record.Expired, a mensagem pendente segura o registro | as in Record.Expired, the pending message keeps the record
+			r.lruMu.Lock()
+			r.lru.MoveToFront(oldest)
+			r.lruMu.Unlock()
+			skipped++
+		default:
+			r.remove(shard, id, entry)
+			r.evicted.Add(1)
+		}
+		shard.mu.Unlock()
+	}
+}
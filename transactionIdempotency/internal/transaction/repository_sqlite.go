@@ -0,0 +1,339 @@
+package transaction
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // driver SQLite (cgo) | SQLite driver (cgo)
+)
+
+// migrações do esquema, aplicadas em ordem; PRAGMA user_version guarda quantas já rodaram | schema migrations, applied in order; PRAGMA user_version stores how many already ran
+// Nunca altere uma migração existente: acrescente uma nova no fim | Never change an existing migration: append a new one
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS processed_transactions (
+		id       TEXT PRIMARY KEY,
+		customer TEXT NOT NULL,
+		amount   REAL NOT NULL
+	)`,
+	`ALTER TABLE processed_transactions ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE processed_transactions ADD COLUMN status TEXT NOT NULL DEFAULT '';
+	ALTER TABLE processed_transactions ADD COLUMN processed_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE processed_transactions ADD COLUMN result BLOB;
+	ALTER TABLE processed_transactions ADD COLUMN error TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE processed_transactions ADD COLUMN lease TEXT NOT NULL DEFAULT '';
+	ALTER TABLE processed_transactions ADD COLUMN lease_expires_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE processed_transactions ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0`,
+	// expires_at em nanossegundos Unix (0 = nunca): comparar números é seguro, comparar textos RFC 3339 não | expires_at in Unix nanoseconds (0 = never): comparing numbers is safe, comparing RFC 3339 text is not
+	`ALTER TABLE processed_transactions ADD COLUMN expires_at INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS processed_transactions_expires_at ON processed_transactions (expires_at) WHERE expires_at > 0`,
+	// outbox em JSON na mesma linha: o desfecho e as mensagens entram no mesmo commit | outbox as JSON in the same row: the outcome and the messages go in the same commit
+	`ALTER TABLE processed_transactions ADD COLUMN outbox BLOB;
+	ALTER TABLE processed_transactions ADD COLUMN outbox_pending INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS processed_transactions_outbox_pending ON processed_transactions (outbox_pending) WHERE outbox_pending = 1`,
+	// valor exato em centavos; amount (REAL) continua gravado só para leitura humana e consultas antigas | exact amount in cents; amount (REAL) is still written only for humans and old queries
+	`ALTER TABLE processed_transactions ADD COLUMN amount_cents INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE processed_transactions ADD COLUMN currency TEXT NOT NULL DEFAULT 'BRL';
+	UPDATE processed_transactions SET amount_cents = CAST(ROUND(amount * 100) AS INTEGER)`,
+	// tipo da transação e lançamentos do razão (ledger_sqlite.go); a chave primária impede lançar a mesma transação duas vezes | transaction type and ledger postings (ledger_sqlite.go); the primary key prevents posting the same transaction twice
+	`ALTER TABLE processed_transactions ADD COLUMN type TEXT NOT NULL DEFAULT '';
+	CREATE TABLE IF NOT EXISTS ledger_postings (
+		transaction_id TEXT NOT NULL,
+		account        TEXT NOT NULL,
+		cents          INTEGER NOT NULL,
+		currency       TEXT NOT NULL,
+		posted_at      INTEGER NOT NULL,
+		PRIMARY KEY (transaction_id, account)
+	);
+	CREATE INDEX IF NOT EXISTS ledger_postings_account ON ledger_postings (account, posted_at)`,
+}
+
+// colunas gravadas e lidas, na ordem de sqliteValues e do Scan do Find | columns written and read, in the order of sqliteValues and Find's Scan
+const sqliteColumns = "id, customer, amount, amount_cents, currency, type, fingerprint, status, processed_at, result, error, lease, lease_expires_at, attempts, expires_at, outbox, outbox_pending"
+
+// placeholders de sqliteColumns | sqliteColumns placeholders
+const sqlitePlaceholders = "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+
+// atualização de todas as colunas no upsert | update of every column in the upsert
+const sqliteUpsert = `ON CONFLICT (id) DO UPDATE SET customer = excluded.customer, amount = excluded.amount,
+	amount_cents = excluded.amount_cents, currency = excluded.currency, type = excluded.type, fingerprint = excluded.fingerprint,
+	status = excluded.status, processed_at = excluded.processed_at, result = excluded.result, error = excluded.error,
+	lease = excluded.lease, lease_expires_at = excluded.lease_expires_at, attempts = excluded.attempts, expires_at = excluded.expires_at,
+	outbox = excluded.outbox, outbox_pending = excluded.outbox_pending`
+
+// sqliteValues devolve os valores do registro na ordem de sqliteColumns | sqliteValues returns the record values in sqliteColumns order
+func sqliteValues(record Record) ([]any, error) {
+	var outbox []byte
+	if len(record.Outbox) > 0 {
+		var err error
+		if outbox, err = json.Marshal(record.Outbox); err != nil {
+			return nil, err
+		}
+	}
+	return []any{
+		record.ID, record.Customer, record.Amount.Units(), record.Amount.Cents, record.Amount.code(), string(record.Type), record.Fingerprint,
+		string(record.Outcome.Status), formatSQLiteTime(record.Outcome.ProcessedAt), record.Outcome.Result, record.Outcome.Error,
+		record.Lease, formatSQLiteTime(record.LeaseExpiresAt), record.Attempts, unixNanos(record.ExpiresAt),
+		outbox, record.PendingOutbox(),
+	}, nil
+}
+
+// unixNanos converte a data para nanossegundos Unix; a data zero vira 0 | unixNanos converts the time to Unix nanoseconds; the zero time becomes 0
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// formatSQLiteTime grava a data como texto RFC 3339 em UTC; a data zero vira texto vazio | formatSQLiteTime stores the time as RFC 3339 text in UTC; the zero time becomes empty text
+func formatSQLiteTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseSQLiteTime lê a data gravada por formatSQLiteTime | parseSQLiteTime reads a time written by formatSQLiteTime
+func parseSQLiteTime(text string) (time.Time, error) {
+	if text == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, text)
+}
+
+// sqliteQuerier é o que Find precisa: serve o banco e uma transação | sqliteQuerier is what Find needs: both the database and a transaction satisfy it
+type sqliteQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// SQLiteRepository grava as transações num banco SQLite em modo WAL com synchronous=FULL | SQLiteRepository stores transactions in a SQLite database in WAL mode with synchronous=FULL
+type SQLiteRepository struct {
+	db      *sql.DB
+	expired atomic.Int64
+}
+
+// OpenSQLiteRepository abre (ou cria) o banco no caminho informado e atualiza o esquema | OpenSQLiteRepository opens (or creates) the database at path and upgrades the schema
+func OpenSQLiteRepository(path string) (*SQLiteRepository, error) {
+	// _txlock=immediate: Begin já pega o lock de escrita, então Update não perde corrida entre o SELECT e o INSERT | _txlock=immediate: Begin takes the write lock up front, so Update can't lose a race between SELECT and INSERT
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=FULL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+// migrateSQLite roda as migrações que faltam, cada uma na sua transação | migrateSQLite runs the missing migrations, each in its own transaction
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlite migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find busca o registro pelo ID | Find looks up the record by ID
+func (r *SQLiteRepository) Find(id string) (Record, bool, error) {
+	return findSQLite(r.db, id)
+}
+
+// findSQLite lê o registro no banco ou dentro de uma transação | findSQLite reads the record from the database or inside a transaction
+func findSQLite(q sqliteQuerier, id string) (Record, bool, error) {
+	record, err := scanSQLiteRecord(q.QueryRow(
+		"SELECT "+sqliteColumns+" FROM processed_transactions WHERE id = ?", id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	return record, true, nil
+}
+
+// scanSQLiteRecord monta o registro a partir de uma linha com sqliteColumns | scanSQLiteRecord builds the record from a row with sqliteColumns
+func scanSQLiteRecord(row interface{ Scan(dest ...any) error }) (Record, error) {
+	var record Record
+	var status, processedAt, leaseExpiresAt string
+	var expiresAt int64
+	var outbox []byte
+	var outboxPending bool
+	var units float64 // só amount_cents vale; amount é a cópia em REAL | only amount_cents counts; amount is the REAL copy
+	err := row.Scan(
+		&record.ID, &record.Customer, &units, &record.Amount.Cents, &record.Amount.Currency, &record.Type, &record.Fingerprint,
+		&status, &processedAt, &record.Outcome.Result, &record.Outcome.Error,
+		&record.Lease, &leaseExpiresAt, &record.Attempts, &expiresAt,
+		&outbox, &outboxPending,
+	)
+	if err != nil {
+		return Record{}, err
+	}
+
+	record.Outcome.Status = Status(status)
+	if status != "" {
+		record.Outcome.TransactionID = record.ID
+	}
+	if record.Outcome.ProcessedAt, err = parseSQLiteTime(processedAt); err != nil {
+		return Record{}, err
+	}
+	if record.LeaseExpiresAt, err = parseSQLiteTime(leaseExpiresAt); err != nil {
+		return Record{}, err
+	}
+	if expiresAt > 0 {
+		record.ExpiresAt = time.Unix(0, expiresAt).UTC()
+	}
+	if len(outbox) > 0 {
+		if err := json.Unmarshal(outbox, &record.Outbox); err != nil {
+			return Record{}, err
+		}
+	}
+	return record, nil
+}
+
+// Claim insere o registro só se o ID for novo; a chave primária garante a atomicidade | Claim inserts the record only if the ID is new; the primary key makes it atomic
+func (r *SQLiteRepository) Claim(record Record) (Record, bool, error) {
+	values, err := sqliteValues(record)
+	if err != nil {
+		return Record{}, false, err
+	}
+	result, err := r.db.Exec(
+		"INSERT INTO processed_transactions ("+sqliteColumns+") VALUES ("+sqlitePlaceholders+") ON CONFLICT (id) DO NOTHING",
+		values...,
+	)
+	if err != nil {
+		return Record{}, false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return Record{}, false, err
+	}
+	if inserted == 1 {
+		return record, true, nil
+	}
+
+	stored, _, err := r.Find(record.ID)
+	return stored, false, err
+}
+
+// Update roda fn dentro de uma transação IMMEDIATE do SQLite | Update runs fn inside an IMMEDIATE SQLite transaction
+func (r *SQLiteRepository) Update(id string, fn UpdateFunc) (Record, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return Record{}, err
+	}
+	defer tx.Rollback() // sem efeito depois do Commit | no effect after Commit
+
+	current, exists, err := findSQLite(tx, id)
+	if err != nil {
+		return Record{}, err
+	}
+	next, write, err := fn(current, exists)
+	if err != nil {
+		return Record{}, err
+	}
+	if !write {
+		return current, nil
+	}
+	values, err := sqliteValues(next)
+	if err != nil {
+		return Record{}, err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO processed_transactions ("+sqliteColumns+") VALUES ("+sqlitePlaceholders+") "+sqliteUpsert,
+		values...,
+	); err != nil {
+		return Record{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Record{}, err
+	}
+	return next, nil
+}
+
+// Save grava o registro; o commit do SQLite já faz o fsync | Save stores the record; the SQLite commit already fsyncs
+func (r *SQLiteRepository) Save(record Record) error {
+	values, err := sqliteValues(record)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(
+		"INSERT INTO processed_transactions ("+sqliteColumns+") VALUES ("+sqlitePlaceholders+") "+sqliteUpsert,
+		values...,
+	)
+	return err
+}
+
+// DeleteExpired apaga os registros vencidos com um DELETE pelo índice de expires_at | DeleteExpired deletes the expired records with one DELETE using the expires_at index
+func (r *SQLiteRepository) DeleteExpired(now time.Time) (int, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM processed_transactions WHERE expires_at > 0 AND expires_at <= ? AND outbox_pending = 0", now.UnixNano(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	r.expired.Add(removed)
+	return int(removed), nil
+}
+
+// ListPendingOutbox busca pelo índice parcial de outbox_pending os registros com mensagens por publicar | ListPendingOutbox uses the outbox_pending partial index to find records with messages to publish
+func (r *SQLiteRepository) ListPendingOutbox(limit int) ([]Record, error) {
+	rows, err := r.db.Query(
+		"SELECT "+sqliteColumns+" FROM processed_transactions WHERE outbox_pending = 1 LIMIT ?", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []Record
+	for rows.Next() {
+		record, err := scanSQLiteRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, record)
+	}
+	return pending, rows.Err()
+}
+
+// Stats conta os registros da tabela | Stats counts the table records
+func (r *SQLiteRepository) Stats() (StoreStats, error) {
+	var size int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM processed_transactions").Scan(&size); err != nil {
+		return StoreStats{}, err
+	}
+	return StoreStats{Size: size, Expired: r.expired.Load()}, nil
+}
+
+// Close fecha o banco | Close closes the database
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
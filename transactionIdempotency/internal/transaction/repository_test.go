@@ -0,0 +1,213 @@
+package transaction
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// backends abre cada armazenamento num diretório temporário | backends opens each store in a temp directory
+var backends = map[string]func(path string) (Repository, error){
+	"memory": func(string) (Repository, error) { return NewMemoryRepository(), nil },
+	"file":   func(path string) (Repository, error) { return OpenFileRepository(path) },
+	"sqlite": func(path string) (Repository, error) { return OpenSQLiteRepository(path) },
+}
+
+func TestRepositoryContract(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+
+			if _, exists, err := repo.Find("tx84"); exists || err != nil {
+				t.Fatalf("Find on empty store = %v, %v; want false, nil", exists, err)
+			}
+			want := NewRecord(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)})
+			want.Outcome = ProcessResult{
+				TransactionID: "tx84",
+				Status:        StatusSucceeded,
+				ProcessedAt:   time.Date(2024, 5, 30, 9, 0, 0, 123, time.UTC),
+				Result:        []byte(`{"receipt":"r-1"}`),
+			}
+			if err := repo.Save(want); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			got, exists, err := repo.Find("tx84")
+			if !exists || err != nil || !reflect.DeepEqual(got, want) {
+				t.Fatalf("Find = %+v, %v, %v; want %+v", got, exists, err, want)
+			}
+
+			service := NewService(repo)
+			if result, err := service.Process(want.Transaction); err != nil || !result.Replayed {
+				t.Fatalf("Process duplicate = %+v, %v; want replayed result", result, err)
+			}
+		})
+	}
+}
+
+// TestCrashHelper roda no processo filho: grava as transações e espera ser morto | TestCrashHelper runs in the child process: saves the transactions and waits to be killed
+func TestCrashHelper(t *testing.T) {
+	backend, path := os.Getenv("CRASH_BACKEND"), os.Getenv("CRASH_PATH")
+	if backend == "" {
+		t.Skip("only runs as the child of TestRepositoriesSurviveKill")
+	}
+	repo, err := backends[backend](path)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	service := NewService(repo)
+	for i := 0; i < 50; i++ {
+		if _, err := service.Process(Transaction{ID: fmt.Sprintf("tx%d", i), Customer: "Duarte", Amount: BRL(int64(i+1) * 100)}); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("saved") // sem Close: o pai mata o processo agora | no Close: the parent kills the process now
+	select {}
+}
+
+func TestRepositoriesSurviveKill(t *testing.T) {
+	for _, backend := range []string{"file", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelper$")
+			cmd.Env = append(os.Environ(), "CRASH_BACKEND="+backend, "CRASH_PATH="+path)
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			line, _ := bufio.NewReader(stdout).ReadString('\n')
+			cmd.Process.Kill() // SIGKILL: nenhum defer ou Close roda | SIGKILL: no defer or Close runs
+			cmd.Wait()
+			if line != "saved\n" {
+				t.Fatalf("child did not save: %q", line)
+			}
+
+			repo, err := backends[backend](path)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer repo.Close()
+			for i := 0; i < 50; i++ {
+				result, err := NewService(repo).Process(Transaction{ID: fmt.Sprintf("tx%d", i), Customer: "Duarte", Amount: BRL(int64(i+1) * 100)})
+				if err != nil || !result.Replayed {
+					t.Fatalf("tx%d after restart: %+v, %v; want replayed result", i, result, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileRepositoryDiscardsTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.wal")
+	repo, err := OpenFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Save(NewRecord(Transaction{ID: "tx1", Customer: "Duarte", Amount: BRL(1000)}))
+	repo.Close()
+
+	// queda no meio da segunda escrita | crash in the middle of the second write
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"ID":"tx2","Cust`)
+	file.Close()
+
+	repo, err = OpenFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopen after torn write: %v", err)
+	}
+	if _, exists, _ := repo.Find("tx1"); !exists {
+		t.Errorf("tx1 lost after torn write")
+	}
+	if _, exists, _ := repo.Find("tx2"); exists {
+		t.Errorf("torn tx2 should not be visible")
+	}
+	if err := repo.Save(NewRecord(Transaction{ID: "tx3", Customer: "Duarte", Amount: BRL(3000)})); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	repo, err = OpenFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopen after repair: %v", err)
+	}
+	defer repo.Close()
+	if _, exists, _ := repo.Find("tx3"); !exists {
+		t.Errorf("tx3 written after the repair was lost")
+	}
+}
+
+func TestFileRepositoryRejectsCorruptMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.wal")
+	os.WriteFile(path, []byte("{\"ID\":\"tx1\"}\nnot json\n{\"ID\":\"tx2\"}\n"), 0o644)
+
+	if _, err := OpenFileRepository(path); !errors.Is(err, ErrCorruptLog) {
+		t.Fatalf("open corrupt log = %v; want ErrCorruptLog", err)
+	}
+}
+
+// shortWriteFile escreve só metade da linha e falha, como um disco cheio | shortWriteFile writes only half the line and fails, like a full disk
+type shortWriteFile struct {
+	*os.File
+	truncateErr error
+}
+
+func (f *shortWriteFile) Write(data []byte) (int, error) {
+	n, _ := f.File.Write(data[:len(data)/2])
+	return n, errors.New("no space left on device")
+}
+
+func (f *shortWriteFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.File.Truncate(size)
+}
+
+func TestAppendLineUndoesShortWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.wal")
+	repo, err := OpenFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Save(NewRecord(Transaction{ID: "tx1", Customer: "Duarte", Amount: BRL(1000)}))
+
+	// a escrita pela metade é cortada e o próximo acréscimo continua uma linha inteira | the partial write is cut and the next append stays a whole line
+	if err := appendLine(&shortWriteFile{File: repo.file}, []byte("{\"ID\":\"tx2\"}\n")); err == nil || errors.Is(err, ErrLogUnwritable) {
+		t.Fatalf("appendLine with a short write = %v; want the write error only", err)
+	}
+	if err := repo.Save(NewRecord(Transaction{ID: "tx3", Customer: "Duarte", Amount: BRL(3000)})); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	repo, err = OpenFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopen after a failed append: %v", err)
+	}
+	for _, id := range []string{"tx1", "tx3"} {
+		if _, exists, _ := repo.Find(id); !exists {
+			t.Errorf("%s lost after a failed append", id)
+		}
+	}
+
+	// sem conseguir cortar, o erro avisa que o log não aceita mais acréscimos | unable to truncate, the error says the log takes no more appends
+	broken := &shortWriteFile{File: repo.file, truncateErr: errors.New("read-only file system")}
+	if err := appendLine(broken, []byte("{\"ID\":\"tx4\"}\n")); !errors.Is(err, ErrLogUnwritable) {
+		t.Errorf("appendLine without truncate = %v; want ErrLogUnwritable", err)
+	}
+	repo.Close()
+}
@@ -0,0 +1,24 @@
+package transaction
+
+import (
+	"errors"
+	"time"
+)
+
+// ProcessResult é o desfecho da primeira tentativa, gravado junto da transação e devolvido nas repetições | ProcessResult is the outcome of the first attempt, stored next to the transaction and returned on replays
+type ProcessResult struct {
+	TransactionID string
+	Status        Status
+	ProcessedAt   time.Time
+	Result        []byte `json:",omitempty"` // resposta da primeira tentativa, quando houver | first attempt response, if any
+	Error         string `json:",omitempty"` // erro da primeira tentativa, quando houver | first attempt error, if any
+	Replayed      bool   `json:"-"`          // true quando veio do repositório, não de um novo processamento | true when it came from the repository, not from a new run
+}
+
+// Err devolve o erro da primeira tentativa (nil quando ela deu certo) | Err returns the first attempt error (nil when it succeeded)
+func (r ProcessResult) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return errors.New(r.Error)
+}
@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetentionPolicy diz por quanto tempo guardar a chave depois que a transação termina; 0 guarda para sempre | RetentionPolicy says how long to keep the key after the transaction finishes; 0 keeps it forever
+type RetentionPolicy func(t Transaction) time.Duration
+
+// KeepFor guarda todas as chaves pelo mesmo tempo (ex: 24h, 30 dias) | KeepFor keeps every key for the same duration (e.g. 24h, 30 days)
+func KeepFor(d time.Duration) RetentionPolicy {
+	return func(Transaction) time.Duration {
+		return d
+	}
+}
+
+// StoreStats são as métricas do armazenamento | StoreStats are the store metrics
+type StoreStats struct {
+	Size    int   // registros guardados agora | records stored right now
+	Expired int64 // registros removidos por DeleteExpired desde a abertura | records removed by DeleteExpired since open
+	Evicted int64 // registros descartados pelo limite de tamanho (LRU) | records dropped by the size limit (LRU)
+}
+
+// Expired diz se a retenção do registro já venceu; registros sem ExpiresAt não vencem | Expired reports whether the record retention is over; records without ExpiresAt never expire
+// Registros com mensagens do outbox por publicar também não vencem | Records with outbox messages still to publish don't expire either
+func (r Record) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt) && !r.PendingOutbox()
+}
+
+// Janitor remove periodicamente as chaves vencidas do armazenamento | Janitor periodically removes expired keys from the store
+type Janitor struct {
+	repo     Repository
+	interval time.Duration
+	now      func() time.Time
+
+	runs    atomic.Int64
+	removed atomic.Int64
+	mu      sync.Mutex
+	lastErr error
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+	started   atomic.Bool
+}
+
+// DefaultJanitorInterval é o intervalo do faxineiro quando o informado não é positivo | DefaultJanitorInterval is the janitor interval when the given one isn't positive
+const DefaultJanitorInterval = time.Hour
+
+// NewJanitor cria o faxineiro do armazenamento; now nil usa time.Now e interval <= 0 usa DefaultJanitorInterval | NewJanitor creates the store janitor; a nil now uses time.Now and interval <= 0 uses DefaultJanitorInterval
+func NewJanitor(repo Repository, interval time.Duration, now func() time.Time) *Janitor {
+	if now == nil {
+		now = time.Now
+	}
+	if interval <= 0 {
+		interval = DefaultJanitorInterval // time.NewTicker entra em pânico com intervalo <= 0 | time.NewTicker panics with an interval <= 0
+	}
+	return &Janitor{
+		repo:     repo,
+		interval: interval,
+		now:      now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start roda a limpeza a cada intervalo numa goroutine até Stop | Start runs the cleanup every interval in a goroutine until Stop
+func (j *Janitor) Start() {
+	j.startOnce.Do(j.start)
+}
+
+// start sobe a goroutine do faxineiro | start launches the janitor goroutine
+func (j *Janitor) start() {
+	j.started.Store(true)
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop encerra a goroutine e espera a limpeza em andamento terminar; pode ser chamado mais de uma vez | Stop ends the goroutine and waits for the running cleanup to finish; safe to call more than once
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	if j.started.Load() {
+		<-j.done
+	}
+}
+
+// RunOnce remove agora as chaves vencidas e devolve quantas saíram | RunOnce removes the expired keys now and returns how many were removed
+func (j *Janitor) RunOnce() (int, error) {
+	removed, err := j.repo.DeleteExpired(j.now())
+	j.runs.Add(1)
+	j.removed.Add(int64(removed))
+
+	j.mu.Lock()
+	j.lastErr = err
+	j.mu.Unlock()
+	return removed, err
+}
+
+// Runs devolve quantas limpezas já rodaram | Runs returns how many cleanups have run
+func (j *Janitor) Runs() int64 {
+	return j.runs.Load()
+}
+
+// Removed devolve quantas chaves o faxineiro já removeu | Removed returns how many keys the janitor has removed
+func (j *Janitor) Removed() int64 {
+	return j.removed.Load()
+}
+
+// Err devolve o erro da última limpeza (nil quando deu certo) | Err returns the last cleanup error (nil when it succeeded)
+func (j *Janitor) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lastErr
+}
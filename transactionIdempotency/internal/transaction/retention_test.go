@@ -0,0 +1,218 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// vipRetention guarda os clientes VIP por 30 dias e os demais por 24h | vipRetention keeps VIP customers for 30 days and the others for 24h
+func vipRetention(t Transaction) time.Duration {
+	if t.Customer == "VIP" {
+		return 30 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func TestRetentionAndJanitor(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			service := NewService(repo, WithClock(clock.Now), WithRetention(vipRetention))
+			regular := Transaction{ID: "tx1", Customer: "Duarte", Amount: BRL(1000)}
+			vip := Transaction{ID: "tx2", Customer: "VIP", Amount: BRL(2000)}
+			service.Process(regular)
+			service.Process(vip)
+
+			clock.Advance(23 * time.Hour)
+			if result, _ := service.Process(regular); !result.Replayed {
+				t.Errorf("tx1 within retention should replay")
+			}
+
+			// vencida, a chave conta como nova mesmo antes do faxineiro passar | once expired, the key counts as new even before the janitor runs
+			clock.Advance(2 * time.Hour)
+			janitor := NewJanitor(repo, time.Hour, clock.Now)
+			if removed, err := janitor.RunOnce(); err != nil || removed != 1 {
+				t.Fatalf("RunOnce = %d, %v; want 1 removed", removed, err)
+			}
+			stats, err := repo.Stats()
+			if err != nil || stats.Size != 1 || stats.Expired != 1 {
+				t.Errorf("Stats = %+v, %v; want size 1 and 1 expired", stats, err)
+			}
+			if _, exists, _ := repo.Find("tx1"); exists {
+				t.Errorf("tx1 should be gone after the janitor")
+			}
+
+			// a compactação não pode perder o que ficou nem impedir novas escritas | compaction must not lose what stayed nor block new writes
+			if result, err := service.Process(regular); err != nil || result.Replayed {
+				t.Fatalf("tx1 after expiry = %+v, %v; want a fresh run", result, err)
+			}
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				service = NewService(repo, WithClock(clock.Now), WithRetention(vipRetention))
+			}
+			defer repo.Close()
+			for _, tx := range []Transaction{regular, vip} {
+				if result, err := service.Process(tx); err != nil || !result.Replayed {
+					t.Errorf("%s after compaction = %+v, %v; want replay", tx.ID, result, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRepositoryLRU(t *testing.T) {
+	repo := NewMemoryRepository(WithMaxEntries(3))
+	service := NewService(repo)
+	process := func(id string) {
+		if _, err := service.Process(Transaction{ID: id, Customer: "Duarte", Amount: BRL(100)}); err != nil {
+			t.Fatalf("Process(%s): %v", id, err)
+		}
+	}
+	process("tx1")
+	process("tx2")
+	process("tx3")
+	repo.Find("tx1") // tx1 passa a ser o mais recente; tx2 vira o mais antigo | tx1 becomes the most recent; tx2 becomes the oldest
+	process("tx4")
+
+	if _, exists, _ := repo.Find("tx2"); exists {
+		t.Errorf("tx2 was the least recently used and should have been evicted")
+	}
+	for _, id := range []string{"tx1", "tx3", "tx4"} {
+		if _, exists, _ := repo.Find(id); !exists {
+			t.Errorf("%s should still be stored", id)
+		}
+	}
+	if stats, _ := repo.Stats(); stats.Size != 3 || stats.Evicted != 1 {
+		t.Errorf("Stats = %+v; want size 3 and 1 evicted", stats)
+	}
+}
+
+func TestMemoryRepositoryLRUKeepsInProgress(t *testing.T) {
+	repo := NewMemoryRepository(WithMaxEntries(2))
+	service := NewService(repo)
+	var leases []Lease
+	for i := 0; i < 3; i++ {
+		lease, _, err := service.Begin(Transaction{ID: fmt.Sprintf("tx%d", i), Customer: "Duarte", Amount: BRL(100)})
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		leases = append(leases, lease)
+	}
+	if stats, _ := repo.Stats(); stats.Size != 3 || stats.Evicted != 0 {
+		t.Fatalf("Stats = %+v; in-progress records must not be evicted", stats)
+	}
+
+	// ao terminar, o registro pode sair e o limite volta a valer | once finished, the record may go and the cap applies again
+	if _, err := service.Finish(leases[0], StatusSucceeded, nil, nil); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if stats, _ := repo.Stats(); stats.Size != 2 || stats.Evicted != 1 {
+		t.Errorf("Stats = %+v; want size 2 and 1 evicted", stats)
+	}
+}
+
+func TestJanitorStartStop(t *testing.T) {
+	NewJanitor(NewMemoryRepository(), time.Hour, nil).Stop() // sem Start, Stop não pode travar | without Start, Stop must not block
+
+	janitor := NewJanitor(NewMemoryRepository(), time.Millisecond, nil)
+	janitor.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for janitor.Runs() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor never ran")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	janitor.Stop()
+	janitor.Stop()
+
+	runs := janitor.Runs()
+	time.Sleep(10 * time.Millisecond)
+	if janitor.Runs() != runs {
+		t.Errorf("janitor kept running after Stop")
+	}
+	if janitor.Err() != nil {
+		t.Errorf("Err = %v", janitor.Err())
+	}
+}
+
+func TestJanitorNonPositiveInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		janitor := NewJanitor(NewMemoryRepository(), interval, nil)
+		if janitor.interval != DefaultJanitorInterval {
+			t.Errorf("interval %v became %v; want DefaultJanitorInterval", interval, janitor.interval)
+		}
+		janitor.Start() // não pode entrar em pânico | must not panic
+		janitor.Stop()
+	}
+}
+
+func TestMemoryRepositoryLRUKeepsPendingOutbox(t *testing.T) {
+	repo := NewMemoryRepository(WithMaxEntries(1))
+	service := NewService(repo, WithHandler(&debitHandler{})) // debitHandler vem de outbox_test.go | debitHandler comes from outbox_test.go
+	for _, id := range []string{"tx1", "tx2"} {
+		if _, err := service.Process(Transaction{ID: id, Customer: "Duarte", Amount: BRL(100)}); err != nil {
+			t.Fatalf("Process(%s): %v", id, err)
+		}
+	}
+	if stats, _ := repo.Stats(); stats.Size != 2 || stats.Evicted != 0 {
+		t.Fatalf("Stats = %+v; records with a pending outbox must not be evicted", stats)
+	}
+
+	publisher := &recordingPublisher{}
+	if delivered, err := NewOutboxRelay(repo, publisher, nil).DeliverPending(context.Background(), 10); err != nil || delivered != 2 {
+		t.Fatalf("DeliverPending = %d, %v; want both messages", delivered, err)
+	}
+	// entregue, o registro mais antigo pode sair | once delivered, the oldest record may go
+	if stats, _ := repo.Stats(); stats.Size != 1 || stats.Evicted != 1 {
+		t.Errorf("Stats = %+v; want size 1 and 1 evicted", stats)
+	}
+}
+
+func TestAbandonedAttemptExpires(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			service := NewService(repo, WithClock(clock.Now), WithLeaseTTL(time.Hour), WithRetention(KeepFor(time.Minute)))
+			tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}
+
+			// o worker cai sem chamar Finish | the worker crashes without calling Finish
+			if _, _, err := service.Begin(tx); err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			// a retenção curta não derruba o lease ainda válido | the short retention doesn't drop the lease that is still valid
+			clock.Advance(30 * time.Minute)
+			if removed, err := repo.DeleteExpired(clock.Now()); err != nil || removed != 0 {
+				t.Fatalf("DeleteExpired within the lease = %d, %v; want 0", removed, err)
+			}
+			if _, _, err := service.Begin(tx); !errors.Is(err, ErrTransactionInProgress) {
+				t.Fatalf("Begin within the lease = %v; want ErrTransactionInProgress", err)
+			}
+
+			clock.Advance(time.Hour)
+			if removed, err := repo.DeleteExpired(clock.Now()); err != nil || removed != 1 {
+				t.Fatalf("DeleteExpired after the lease = %d, %v; want the abandoned attempt removed", removed, err)
+			}
+			if _, exists, _ := repo.Find(tx.ID); exists {
+				t.Errorf("the abandoned attempt is still stored")
+			}
+		})
+	}
+}
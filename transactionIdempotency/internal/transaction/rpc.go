@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// rpcKeyPrefix separa as chaves RPC dos IDs de transação e das chaves HTTP | rpcKeyPrefix keeps RPC keys apart from transaction IDs and HTTP keys
+const rpcKeyPrefix = "rpc:"
+
+// KeyedRequest é o argumento de um método RPC que traz a sua chave de idempotência | KeyedRequest is an RPC method argument carrying its idempotency key
+type KeyedRequest interface {
+	IdempotencyKey() string
+}
+
+// IdempotentRPC embrulha um método no formato do net/rpc com a idempotência do Service; funciona com gob e JSON-RPC | IdempotentRPC wraps a net/rpc style method with the Service idempotency; it works with gob and JSON-RPC
+// A resposta da primeira chamada é gravada em JSON e as repetições com a mesma chave a recebem sem rodar o método | The first call reply is stored as JSON and retries with the same key get it without running the method
+// Mesma chave em outro método ou com outros argumentos dá ErrIdempotencyKeyMismatch; chamada em andamento, ErrTransactionInProgress | The same key on another method or with other arguments gives ErrIdempotencyKeyMismatch; an in-flight call, ErrTransactionInProgress
+// Um erro do método marcado com Retryable libera a chave; os demais são finais e repetidos nas próximas chamadas | A method error marked with Retryable releases the key; the others are final and repeated on the next calls
+// Exemplo | Example:
+//
+//	func (o *Orders) Create(args *CreateArgs, reply *CreateReply) error {
+//		return o.create(args, reply) // o.create = IdempotentRPC(service, "Orders.Create", o.doCreate)
+//	}
+func IdempotentRPC[Args KeyedRequest, Reply any](s *Service, method string, fn func(Args, *Reply) error) func(Args, *Reply) error {
+	return func(args Args, reply *Reply) error {
+		key := args.IdempotencyKey()
+		if key == "" {
+			return fn(args, reply)
+		}
+		fingerprint, err := rpcFingerprint(method, args)
+		if err != nil {
+			return err
+		}
+
+		lease, result, err := s.begin(Transaction{ID: rpcKeyPrefix + key}, fingerprint)
+		if err != nil {
+			return err
+		}
+		if result.Replayed {
+			return replayRPC(result, reply)
+		}
+
+		defer func() {
+			// o método entrou em pânico: libera a chave para a próxima tentativa | the method panicked: release the key for the next try
+			if p := recover(); p != nil {
+				s.Finish(lease, StatusFailedRetryable, nil, errors.New("panic"))
+				panic(p)
+			}
+		}()
+		if cause := fn(args, reply); cause != nil {
+			status := StatusFailedFinal
+			if IsRetryable(cause) {
+				status = StatusFailedRetryable
+			}
+			s.Finish(lease, status, nil, cause)
+			return cause
+		}
+
+		encoded, err := json.Marshal(reply)
+		if err != nil {
+			s.Finish(lease, StatusFailedRetryable, nil, err)
+			return fmt.Errorf("encode reply: %w", err)
+		}
+		_, err = s.Finish(lease, StatusSucceeded, encoded, nil)
+		return err
+	}
+}
+
+// rpcFingerprint é o hash do nome do método e dos argumentos em JSON | rpcFingerprint is the hash of the method name and the JSON arguments
+func rpcFingerprint(method string, args any) (string, error) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("encode args: %w", err)
+	}
+	hash := sha256.New()
+	hash.Write([]byte("method=" + strconv.Quote(method) + "\n"))
+	hash.Write([]byte("args="))
+	hash.Write(encoded)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replayRPC preenche reply com a resposta gravada ou devolve o erro final da primeira chamada | replayRPC fills reply with the stored reply or returns the first call final error
+func replayRPC[Reply any](result ProcessResult, reply *Reply) error {
+	if result.Status != StatusSucceeded {
+		return result.Err()
+	}
+	if len(result.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Result, reply)
+}
@@ -0,0 +1,146 @@
+package transaction
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// DebitArgs é o pedido de débito com a chave de idempotência | DebitArgs is the debit request with the idempotency key
+type DebitArgs struct {
+	Key      string
+	Customer string
+	Amount   float64
+}
+
+func (a *DebitArgs) IdempotencyKey() string { return a.Key }
+
+// DebitReply é a resposta com o número do débito criado | DebitReply is the reply with the created debit number
+type DebitReply struct {
+	Debit int32
+}
+
+// Bank é o serviço RPC de teste; cada débito criado ganha um número novo | Bank is the test RPC service; each created debit gets a new number
+type Bank struct {
+	debits atomic.Int32
+	debit  func(*DebitArgs, *DebitReply) error
+}
+
+func newBank(service *Service) *Bank {
+	b := &Bank{}
+	b.debit = IdempotentRPC(service, "Bank.Debit", func(args *DebitArgs, reply *DebitReply) error {
+		if args.Amount <= 0 {
+			return errors.New("invalid amount")
+		}
+		reply.Debit = b.debits.Add(1)
+		return nil
+	})
+	return b
+}
+
+func (b *Bank) Debit(args *DebitArgs, reply *DebitReply) error {
+	return b.debit(args, reply)
+}
+
+// dialBank serve o Bank por JSON-RPC num net.Pipe e devolve o cliente | dialBank serves the Bank over JSON-RPC on a net.Pipe and returns the client
+func dialBank(t *testing.T, bank *Bank) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.Register(bank); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodec(jsonrpc.NewServerCodec(serverConn))
+	client := jsonrpc.NewClient(clientConn)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestIdempotentRPCReplaysReply(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			bank := newBank(NewService(repo))
+			client := dialBank(t, bank)
+
+			args := &DebitArgs{Key: "k1", Customer: "Duarte", Amount: 10}
+			var first DebitReply
+			if err := client.Call("Bank.Debit", args, &first); err != nil || first.Debit != 1 {
+				t.Fatalf("first call = %+v, %v", first, err)
+			}
+
+			// a repetição chega depois de reiniciar o servidor | the retry arrives after the server restarts
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				restarted := newBank(NewService(repo))
+				restarted.debits.Store(bank.debits.Load())
+				bank, client = restarted, dialBank(t, restarted)
+			}
+			defer repo.Close()
+
+			var retry DebitReply
+			if err := client.Call("Bank.Debit", args, &retry); err != nil || retry != first {
+				t.Errorf("retry = %+v, %v; want %+v", retry, err, first)
+			}
+			if debits := bank.debits.Load(); debits != 1 {
+				t.Errorf("%d debits created; want 1", debits)
+			}
+
+			var mismatch DebitReply
+			err = client.Call("Bank.Debit", &DebitArgs{Key: "k1", Customer: "Duarte", Amount: 99}, &mismatch)
+			if err == nil || err.Error() != ErrIdempotencyKeyMismatch.Error() {
+				t.Errorf("reused key with other args = %v; want ErrIdempotencyKeyMismatch", err)
+			}
+		})
+	}
+}
+
+func TestIdempotentRPCErrors(t *testing.T) {
+	bank := newBank(NewService(NewMemoryRepository()))
+	var reply DebitReply
+
+	// erro final: a repetição devolve o mesmo erro sem chamar o método | final error: the retry returns the same error without calling the method
+	invalid := &DebitArgs{Key: "k1", Customer: "Duarte", Amount: -1}
+	if err := bank.Debit(invalid, &reply); err == nil || err.Error() != "invalid amount" {
+		t.Fatalf("first call = %v; want invalid amount", err)
+	}
+	if err := bank.Debit(invalid, &reply); err == nil || err.Error() != "invalid amount" {
+		t.Errorf("retry = %v; want the stored error", err)
+	}
+
+	// erro retryable: a próxima chamada roda o método de novo | retryable error: the next call runs the method again
+	calls := 0
+	flaky := IdempotentRPC(NewService(NewMemoryRepository()), "Bank.Debit", func(args *DebitArgs, reply *DebitReply) error {
+		calls++
+		if calls == 1 {
+			return Retryable(errors.New("bank timeout"))
+		}
+		reply.Debit = 7
+		return nil
+	})
+	args := &DebitArgs{Key: "k2", Customer: "Duarte", Amount: 10}
+	if err := flaky(args, &reply); err == nil {
+		t.Fatalf("first call should fail")
+	}
+	if err := flaky(args, &reply); err != nil || reply.Debit != 7 || calls != 2 {
+		t.Errorf("retry = %+v, %v after %d calls; want debit 7 after 2", reply, err, calls)
+	}
+
+	// sem chave, não há idempotência | without a key there is no idempotency
+	before := bank.debits.Load()
+	bank.Debit(&DebitArgs{Customer: "Duarte", Amount: 1}, &reply)
+	bank.Debit(&DebitArgs{Customer: "Duarte", Amount: 1}, &reply)
+	if created := bank.debits.Load() - before; created != 2 {
+		t.Errorf("calls without a key created %d debits; want 2", created)
+	}
+}
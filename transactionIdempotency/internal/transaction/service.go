@@ -1,25 +1,276 @@
 package transaction
 
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultLeaseTTL é quanto tempo um worker tem para terminar antes que outro possa assumir | DefaultLeaseTTL is how long a worker has to finish before another may take over
+const DefaultLeaseTTL = 30 * time.Second
+
 // Service fornece operações para processar transações com idempotência | Service provides operations to process transactions with idempotency
 type Service struct {
-	repo *Repository
+	repo      Repository
+	now       func() time.Time // relógio; os testes trocam por um fixo | clock; tests swap in a fixed one
+	leaseTTL  time.Duration
+	retention RetentionPolicy  // nil guarda as chaves para sempre | nil keeps keys forever
+	handler   Handler          // nil só registra a transação como processada | nil only records the transaction as processed
+	limits    map[string]Money // limite por transação de cada moeda; moedas fora do mapa não têm limite | per-transaction limit of each currency; currencies outside the map have no limit
+}
+
+// Option configura o Service em NewService | Option configures the Service in NewService
+type Option func(*Service)
+
+// WithLeaseTTL troca a duração do lease (padrão DefaultLeaseTTL) | WithLeaseTTL changes the lease duration (default DefaultLeaseTTL)
+func WithLeaseTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.leaseTTL = ttl
+	}
+}
+
+// WithClock troca o relógio do serviço (padrão time.Now) | WithClock replaces the service clock (default time.Now)
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
+}
+
+// WithRetention define por quanto tempo cada chave fica guardada depois de terminar | WithRetention sets how long each key is kept after it finishes
+// Depois disso a chave é tratada como nova e o Janitor pode removê-la | After that the key is treated as new and the Janitor may remove it
+func WithRetention(policy RetentionPolicy) Option {
+	return func(s *Service) {
+		s.retention = policy
+	}
+}
+
+// WithHandler define quem faz o trabalho de cada transação em Process | WithHandler sets who does the work of each transaction in Process
+func WithHandler(h Handler) Option {
+	return func(s *Service) {
+		s.handler = h
+	}
+}
+
+// WithMaxAmount define o limite por transação na moeda de max (padrão DefaultMaxAmount para BRL) | WithMaxAmount sets the per-transaction limit in max's currency (default DefaultMaxAmount for BRL)
+func WithMaxAmount(max Money) Option {
+	return func(s *Service) {
+		s.limits[max.code()] = max
+	}
 }
 
 // NewService cria um novo serviço de transações | NewService creates a new transaction service
-func NewService(repo *Repository) *Service {
-	return &Service{
-		repo: repo, // 1º repo = campo da struct Service; 2º repo = parâmetro da função NewService 
+func NewService(repo Repository, options ...Option) *Service {
+	s := &Service{
+		repo:     repo, // 1º repo = campo da struct Service; 2º repo = parâmetro da função NewService
+		now:      time.Now,
+		leaseTTL: DefaultLeaseTTL,
+		limits:   map[string]Money{DefaultMaxAmount.code(): DefaultMaxAmount},
+	}
+	for _, option := range options {
+		option(s)
 	}
+	return s
+}
+
+// Lease é a posse temporária de uma transação por um worker; só quem tem o token pode terminá-la | Lease is a worker's temporary ownership of a transaction; only the token holder can finish it
+type Lease struct {
+	TransactionID string
+	Token         string
+	ExpiresAt     time.Time
+	Attempt       int
 }
 
 // Process processa uma transação garantindo idempotência | Process processes a transaction ensuring idempotency
-func (s *Service) Process(t Transaction) error {
-	_, exists := s.repo.processed[t.ID]
+// Numa repetição devolve o desfecho da primeira tentativa com Replayed=true | On a replay it returns the first attempt outcome with Replayed=true
+// Um duplicado simultâneo recebe ErrTransactionInProgress | A concurrent duplicate gets ErrTransactionInProgress
+func (s *Service) Process(t Transaction) (ProcessResult, error) {
+	return s.ProcessContext(context.Background(), t)
+}
+
+// ProcessContext é o Process com contexto para o Handler | ProcessContext is Process with a context for the Handler
+// O Handler roda só com o lease na mão; a resposta e as mensagens dele são gravadas junto do desfecho, numa só escrita | The Handler runs only while holding the lease; its response and messages are stored with the outcome, in a single write
+// Um erro do Handler não volta em error: fica no desfecho (failed_retryable se marcado com Retryable, senão failed_final) | A Handler error is not returned as error: it goes into the outcome (failed_retryable if marked with Retryable, otherwise failed_final)
+func (s *Service) ProcessContext(ctx context.Context, t Transaction) (ProcessResult, error) {
+	t = t.withDefaults() // o Handler recebe a moeda preenchida | the Handler gets the filled currency
+	lease, result, err := s.Begin(t)
+	if err != nil || result.Replayed {
+		return result, err
+	}
+	if s.handler == nil {
+		return s.Finish(lease, StatusSucceeded, nil, nil)
+	}
+
+	effects, cause := s.handler.Handle(ctx, t)
+	switch {
+	case cause == nil:
+		return s.finish(lease, StatusSucceeded, effects.Result, nil, effects.Messages)
+	case IsRetryable(cause):
+		return s.finish(lease, StatusFailedRetryable, nil, cause, nil)
+	default:
+		return s.finish(lease, StatusFailedFinal, nil, cause, nil)
+	}
+}
+
+// Receive registra a transação como recebida, sem começar o processamento | Receive records the transaction as received, without starting to process it
+// Se o ID já existe com o mesmo conteúdo nada muda | If the ID already exists with the same contents nothing changes
+func (s *Service) Receive(t Transaction) error {
+	t = t.withDefaults()
+	if err := s.validate(t); err != nil {
+		return err
+	}
+	incoming := NewRecord(t)
+	now := s.now()
+	_, err := s.repo.Update(t.ID, func(current Record, exists bool) (Record, bool, error) {
+		if exists && !current.Expired(now) {
+			if !current.Matches(incoming.Fingerprint) {
+				return Record{}, false, ErrIdempotencyKeyMismatch
+			}
+			return current, false, nil
+		}
+		incoming.Outcome = ProcessResult{TransactionID: t.ID, Status: StatusReceived}
+		incoming.ExpiresAt = s.expiresAt(t, now)
+		return incoming, true, nil
+	})
+	return err
+}
+
+// Begin toma o lease da transação para processá-la | Begin takes the transaction lease to process it
+// Quando a transação já terminou (succeeded ou failed_final) não há lease: devolve o desfecho gravado com Replayed=true | When the transaction already finished (succeeded or failed_final) there is no lease: it returns the stored outcome with Replayed=true
+// Um lease vencido (worker que caiu) ou uma falha retryable podem ser assumidos; um lease válido dá ErrTransactionInProgress | An expired lease (crashed worker) or a retryable failure can be taken over; a valid lease gives ErrTransactionInProgress
+// Uma transação inválida devolve *ValidationError antes de qualquer consulta à chave | An invalid transaction returns *ValidationError before the key is looked up
+func (s *Service) Begin(t Transaction) (Lease, ProcessResult, error) {
+	t = t.withDefaults()
+	if err := s.validate(t); err != nil {
+		return Lease{}, ProcessResult{}, err
+	}
+	return s.begin(t, t.Fingerprint())
+}
+
+// begin é o Begin com a impressão digital dada por quem chama (ex: o corpo de uma requisição HTTP) | begin is Begin with the fingerprint given by the caller (e.g. the body of an HTTP request)
+func (s *Service) begin(t Transaction, fingerprint string) (Lease, ProcessResult, error) {
+	incoming := Record{Transaction: t, Fingerprint: fingerprint}
+	now := s.now()
+	token := newLeaseToken()
 
-	if exists {
-		return ErrTransactionAlreadyProcessed
+	// consulta e gravação numa só operação: dois duplicados simultâneos não passam juntos | lookup and write in one operation: two concurrent duplicates can't both pass
+	record, err := s.repo.Update(t.ID, func(current Record, exists bool) (Record, bool, error) {
+		// chave com retenção vencida conta como nova | a key past its retention counts as new
+		if exists && !current.Expired(now) {
+			// mesmo ID com outro conteúdo não é repetição, é reuso indevido da chave | same ID with other contents is not a retry, it is a misused key
+			if !current.Matches(incoming.Fingerprint) {
+				return Record{}, false, ErrIdempotencyKeyMismatch
+			}
+			status := current.Status()
+			if status.Final() {
+				return current, false, nil
+			}
+			if status == StatusInProgress && now.Before(current.LeaseExpiresAt) {
+				return Record{}, false, ErrTransactionInProgress
+			}
+			if !CanTransition(status, StatusInProgress) {
+				return Record{}, false, ErrInvalidTransition
+			}
+			incoming.Attempts = current.Attempts
+		}
+
+		incoming.Outcome = ProcessResult{TransactionID: t.ID, Status: StatusInProgress}
+		incoming.Lease = token
+		incoming.LeaseExpiresAt = now.Add(s.leaseTTL)
+		incoming.Attempts++
+		// a retenção vale também para a tentativa abandonada, mas nunca antes do fim do lease | retention also covers an abandoned attempt, but never before the lease ends
+		incoming.ExpiresAt = s.expiresAt(t, now)
+		if !incoming.ExpiresAt.IsZero() && incoming.ExpiresAt.Before(incoming.LeaseExpiresAt) {
+			incoming.ExpiresAt = incoming.LeaseExpiresAt
+		}
+		return incoming, true, nil
+	})
+	if err != nil {
+		return Lease{}, ProcessResult{}, err
+	}
+	if record.Lease != token {
+		return Lease{}, record.Replay(), nil
 	}
 
-	s.repo.processed[t.ID] = t
+	lease := Lease{TransactionID: t.ID, Token: token, ExpiresAt: record.LeaseExpiresAt, Attempt: record.Attempts}
+	return lease, record.Outcome, nil
+}
+
+// Finish grava o desfecho da tentativa e libera o lease | Finish stores the attempt outcome and releases the lease
+// status deve ser succeeded, failed_retryable ou failed_final; cause vira o Error do desfecho | status must be succeeded, failed_retryable or failed_final; cause becomes the outcome Error
+// Se o lease foi assumido por outro worker, nada é gravado e volta ErrLeaseLost | If another worker took over the lease, nothing is stored and ErrLeaseLost is returned
+func (s *Service) Finish(lease Lease, status Status, result []byte, cause error) (ProcessResult, error) {
+	return s.finish(lease, status, result, cause, nil)
+}
+
+// finish é o Finish que grava também as mensagens do outbox; sem ID, cada uma recebe "<id da transação>-<posição>" | finish is Finish that also stores the outbox messages; without an ID, each one gets "<transaction id>-<position>"
+func (s *Service) finish(lease Lease, status Status, result []byte, cause error, messages []Message) (ProcessResult, error) {
+	if status == StatusInProgress || !CanTransition(StatusInProgress, status) {
+		return ProcessResult{}, ErrInvalidTransition
+	}
+	outcome := ProcessResult{
+		TransactionID: lease.TransactionID,
+		Status:        status,
+		ProcessedAt:   s.now(),
+		Result:        result,
+	}
+	if cause != nil {
+		outcome.Error = cause.Error()
+	}
+	var outbox []Message
+	for i, message := range messages {
+		if message.ID == "" {
+			message.ID = lease.TransactionID + "-" + strconv.Itoa(i)
+		}
+		message.DeliveredAt = time.Time{}
+		outbox = append(outbox, message)
+	}
+
+	record, err := s.repo.Update(lease.TransactionID, func(current Record, exists bool) (Record, bool, error) {
+		if !exists || current.Lease != lease.Token || current.Status() != StatusInProgress {
+			return Record{}, false, ErrLeaseLost
+		}
+		current.Outcome = outcome
+		current.Outbox = outbox
+		current.Lease = ""
+		current.LeaseExpiresAt = time.Time{}
+		current.ExpiresAt = s.expiresAt(current.Transaction, outcome.ProcessedAt)
+		return current, true, nil
+	})
+	if err != nil {
+		return ProcessResult{}, err
+	}
+	return record.Outcome, nil
+}
+
+// validate confere a transação e o limite por transação da moeda dela | validate checks the transaction and the per-transaction limit of its currency
+func (s *Service) validate(t Transaction) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if limit, ok := s.limits[t.Amount.Currency]; ok && t.Amount.Cents > limit.Cents {
+		return &ValidationError{Field: "Amount", Err: fmt.Errorf("%w (%s)", ErrAmountOverLimit, limit)}
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// expiresAt calcula o fim da retenção da chave a partir de now | expiresAt computes the end of the key retention from now
+func (s *Service) expiresAt(t Transaction, now time.Time) time.Time {
+	if s.retention == nil {
+		return time.Time{}
+	}
+	keep := s.retention(t)
+	if keep <= 0 {
+		return time.Time{}
+	}
+	return now.Add(keep)
+}
+
+// newLeaseToken sorteia um token de lease | newLeaseToken draws a random lease token
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Roda com -race: milhares de duplicados simultâneos devem ser processados uma única vez | Run with -race: thousands of concurrent duplicates must be processed exactly once
+func TestProcessExactlyOnceUnderConcurrency(t *testing.T) {
+	const ids, duplicates = 50, 40 // 2000 chamadas | 2000 calls
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, err := open(filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer repo.Close()
+			service := NewService(repo)
+
+			var processed [ids]atomic.Int32
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < ids*duplicates; i++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					<-start // todas partem juntas para maximizar a disputa | all start together to maximize contention
+					result, err := service.Process(Transaction{ID: fmt.Sprintf("tx%d", n), Customer: "Duarte", Amount: BRL(100)})
+					switch {
+					case errors.Is(err, ErrTransactionInProgress):
+						// outro worker está com o lease agora | another worker holds the lease right now
+					case err != nil:
+						t.Errorf("tx%d: unexpected error %v", n, err)
+					case !result.Replayed:
+						processed[n].Add(1)
+					}
+				}(i % ids)
+			}
+			close(start)
+			wg.Wait()
+
+			for n := range processed {
+				if got := processed[n].Load(); got != 1 {
+					t.Errorf("tx%d processed %d times; want exactly 1", n, got)
+				}
+			}
+		})
+	}
+}
+
+func TestShardIndexSpreadsKeys(t *testing.T) {
+	used := map[int]bool{}
+	for i := 0; i < 10000; i++ {
+		used[shardIndex(fmt.Sprintf("tx%d", i))] = true
+	}
+	if len(used) != shardCount {
+		t.Errorf("10000 keys used %d of %d shards", len(used), shardCount)
+	}
+}
+
+func TestProcessReplaysStoredResult(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			service := NewService(repo)
+			first := time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)
+			service.now = func() time.Time { return first }
+
+			tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+			result, err := service.Process(tx)
+			if err != nil || result.Replayed || result.Status != StatusSucceeded || !result.ProcessedAt.Equal(first) {
+				t.Fatalf("first Process = %+v, %v", result, err)
+			}
+
+			// a repetição vem depois de reiniciar: o desfecho sai do armazenamento | the replay comes after a restart: the outcome comes from the store
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				service = NewService(repo)
+			}
+			defer repo.Close()
+			service.now = func() time.Time { return first.Add(time.Hour) }
+
+			replay, err := service.Process(tx)
+			if err != nil || !replay.Replayed {
+				t.Fatalf("replay = %+v, %v; want Replayed", replay, err)
+			}
+			if replay.TransactionID != "tx84" || replay.Status != StatusSucceeded || !replay.ProcessedAt.Equal(first) || replay.Err() != nil {
+				t.Errorf("replay = %+v; want the first attempt outcome", replay)
+			}
+
+			if _, err := service.Process(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+				t.Errorf("changed payload = %v; want ErrIdempotencyKeyMismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package transaction
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// número de fatias; potência de 2 para o índice sair de uma máscara | number of shards; a power of 2 so the index is a mask
+const shardCount = 256
+
+// shardedLocks espalha os IDs por vários mutexes: IDs diferentes raramente disputam o mesmo lock | shardedLocks spreads IDs over many mutexes: different IDs rarely contend for the same lock
+type shardedLocks [shardCount]sync.Mutex
+
+// lock trava a fatia do ID e devolve a função que destrava | lock locks the ID's shard and returns the unlock function
+func (l *shardedLocks) lock(id string) func() {
+	mu := &l[shardIndex(id)]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// shardIndex escolhe a fatia do ID pelo hash FNV-1a | shardIndex picks the ID's shard by its FNV-1a hash
+func shardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() & (shardCount - 1))
+}
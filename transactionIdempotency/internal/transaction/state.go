@@ -0,0 +1,40 @@
+package transaction
+
+// Status é a situação de uma transação na máquina de estados | Status is the state of a transaction in the state machine
+//
+//	received ──► in_progress ──► succeeded
+//	                 │   ▲  ├──► failed_final
+//	                 ▼   │  └──► failed_retryable ──► in_progress (nova tentativa | retry)
+//	      lease vencido | expired lease ──► in_progress (outro worker | another worker)
+type Status string
+
+// Situações possíveis | Possible statuses
+const (
+	StatusReceived        Status = "received"         // registrada, ainda não iniciada | recorded, not started yet
+	StatusInProgress      Status = "in_progress"      // um worker tem o lease | a worker holds the lease
+	StatusSucceeded       Status = "succeeded"        // final: repetições recebem este desfecho | final: replays get this outcome
+	StatusFailedRetryable Status = "failed_retryable" // falhou, mas a próxima chamada tenta de novo | failed, but the next call retries
+	StatusFailedFinal     Status = "failed_final"     // final: repetições recebem o mesmo erro | final: replays get the same error
+)
+
+// transições permitidas a partir de cada situação | allowed transitions from each status
+var transitions = map[Status][]Status{
+	StatusReceived:        {StatusInProgress},
+	StatusInProgress:      {StatusInProgress, StatusSucceeded, StatusFailedRetryable, StatusFailedFinal},
+	StatusFailedRetryable: {StatusInProgress},
+}
+
+// CanTransition diz se a máquina de estados permite ir de from para to | CanTransition reports whether the state machine allows moving from from to to
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Final diz se a situação não muda mais; repetições recebem o desfecho gravado | Final reports whether the status no longer changes; replays get the stored outcome
+func (s Status) Final() bool {
+	return s == StatusSucceeded || s == StatusFailedFinal
+}
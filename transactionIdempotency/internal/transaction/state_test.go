@@ -0,0 +1,149 @@
+package transaction
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock é um relógio que o teste adianta | fakeClock is a clock the test moves forward
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestCanTransition(t *testing.T) {
+	allowed := [][2]Status{
+		{StatusReceived, StatusInProgress},
+		{StatusInProgress, StatusSucceeded},
+		{StatusInProgress, StatusFailedRetryable},
+		{StatusInProgress, StatusFailedFinal},
+		{StatusFailedRetryable, StatusInProgress},
+	}
+	for _, pair := range allowed {
+		if !CanTransition(pair[0], pair[1]) {
+			t.Errorf("%s -> %s should be allowed", pair[0], pair[1])
+		}
+	}
+	denied := [][2]Status{
+		{StatusReceived, StatusSucceeded},
+		{StatusSucceeded, StatusInProgress},
+		{StatusFailedFinal, StatusInProgress},
+		{StatusFailedRetryable, StatusSucceeded},
+	}
+	for _, pair := range denied {
+		if CanTransition(pair[0], pair[1]) {
+			t.Errorf("%s -> %s should be denied", pair[0], pair[1])
+		}
+	}
+}
+
+func TestExpiredLeaseCanBeTakenOver(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			clock := &fakeClock{now: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)}
+			service := NewService(repo, WithLeaseTTL(time.Minute))
+			service.now = clock.Now
+			tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+
+			crashed, result, err := service.Begin(tx)
+			if err != nil || result.Status != StatusInProgress || crashed.Attempt != 1 {
+				t.Fatalf("Begin = %+v, %+v, %v", crashed, result, err)
+			}
+			if _, _, err := service.Begin(tx); !errors.Is(err, ErrTransactionInProgress) {
+				t.Fatalf("concurrent Begin = %v; want ErrTransactionInProgress", err)
+			}
+
+			// o worker cai sem chamar Finish; o lease sobrevive ao reinício | the worker crashes without calling Finish; the lease survives the restart
+			if name != "memory" {
+				repo.Close()
+				if repo, err = open(path); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				service = NewService(repo, WithLeaseTTL(time.Minute))
+				service.now = clock.Now
+			}
+			defer repo.Close()
+
+			clock.Advance(30 * time.Second)
+			if _, _, err := service.Begin(tx); !errors.Is(err, ErrTransactionInProgress) {
+				t.Fatalf("Begin before expiry = %v; want ErrTransactionInProgress", err)
+			}
+
+			clock.Advance(31 * time.Second)
+			retry, _, err := service.Begin(tx)
+			if err != nil || retry.Attempt != 2 || retry.Token == crashed.Token {
+				t.Fatalf("Begin after expiry = %+v, %v; want a new lease on attempt 2", retry, err)
+			}
+
+			// o worker antigo volta tarde demais | the old worker comes back too late
+			if _, err := service.Finish(crashed, StatusSucceeded, nil, nil); !errors.Is(err, ErrLeaseLost) {
+				t.Errorf("Finish with the expired lease = %v; want ErrLeaseLost", err)
+			}
+			done, err := service.Finish(retry, StatusSucceeded, []byte("ok"), nil)
+			if err != nil || done.Status != StatusSucceeded || string(done.Result) != "ok" {
+				t.Fatalf("Finish = %+v, %v", done, err)
+			}
+
+			replay, err := service.Process(tx)
+			if err != nil || !replay.Replayed || string(replay.Result) != "ok" {
+				t.Errorf("Process after success = %+v, %v; want replay", replay, err)
+			}
+		})
+	}
+}
+
+func TestRetryableAndFinalFailures(t *testing.T) {
+	service := NewService(NewMemoryRepository())
+	tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+
+	lease, _, _ := service.Begin(tx)
+	if _, err := service.Finish(lease, StatusFailedRetryable, nil, errors.New("bank timeout")); err != nil {
+		t.Fatalf("Finish retryable: %v", err)
+	}
+
+	// falha retryable: a próxima chamada tenta de novo | retryable failure: the next call tries again
+	lease, result, err := service.Begin(tx)
+	if err != nil || result.Replayed || lease.Attempt != 2 {
+		t.Fatalf("Begin after retryable failure = %+v, %+v, %v; want attempt 2", lease, result, err)
+	}
+	if _, err := service.Finish(lease, StatusFailedFinal, nil, errors.New("card blocked")); err != nil {
+		t.Fatalf("Finish final: %v", err)
+	}
+
+	// falha final: a repetição devolve o mesmo erro sem tentar de novo | final failure: the replay returns the same error without trying again
+	replay, err := service.Process(tx)
+	if err != nil || !replay.Replayed || replay.Status != StatusFailedFinal || replay.Err() == nil || replay.Err().Error() != "card blocked" {
+		t.Errorf("Process after final failure = %+v, %v; want replayed failure", replay, err)
+	}
+}
+
+func TestReceiveThenBegin(t *testing.T) {
+	repo := NewMemoryRepository()
+	service := NewService(repo)
+	tx := Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100000000)}
+
+	if err := service.Receive(tx); err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if record, _, _ := repo.Find("tx84"); record.Status() != StatusReceived || record.Lease != "" {
+		t.Errorf("after Receive = %+v; want received without lease", record)
+	}
+	if err := service.Receive(Transaction{ID: "tx84", Customer: "Duarte", Amount: BRL(100)}); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Errorf("Receive with other contents = %v; want ErrIdempotencyKeyMismatch", err)
+	}
+
+	lease, _, err := service.Begin(tx)
+	if err != nil || lease.Attempt != 1 {
+		t.Fatalf("Begin after Receive = %+v, %v", lease, err)
+	}
+	if _, err := service.Finish(lease, StatusReceived, nil, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Finish back to received = %v; want ErrInvalidTransition", err)
+	}
+}
@@ -0,0 +1,55 @@
+package transaction
+
+import (
+	"regexp"
+	"strings"
+)
+
+// idPattern é o formato aceito para IDs: letras, dígitos, '.', '_', ':' e '-', até 128 caracteres | idPattern is the accepted ID format: letters, digits, '.', '_', ':' and '-', up to 128 characters
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// currencyPattern é o formato de um código ISO 4217: três letras maiúsculas | currencyPattern is the format of an ISO 4217 code: three uppercase letters
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// DefaultMaxAmount é o limite por transação em reais quando o Service não recebe WithMaxAmount | DefaultMaxAmount is the per-transaction limit in reais when the Service gets no WithMaxAmount
+var DefaultMaxAmount = BRL(10_000_000_00)
+
+// ValidationError diz qual campo da transação é inválido; errors.Is com ErrInvalidID, ErrEmptyCustomer etc. diz por quê | ValidationError tells which transaction field is invalid; errors.Is with ErrInvalidID, ErrEmptyCustomer etc. tells why
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate confere o formato do ID, o cliente, a moeda, o sinal do valor e o tipo | Validate checks the ID format, the customer, the currency, the amount sign and the type
+// Moeda vazia vale DefaultCurrency, como em Money | An empty currency means DefaultCurrency, as in Money
+// O limite por transação depende do Service e é conferido em Begin e Receive | The per-transaction limit depends on the Service and is checked in Begin and Receive
+func (t Transaction) Validate() error {
+	t = t.withDefaults()
+	switch {
+	case !idPattern.MatchString(t.ID):
+		return &ValidationError{Field: "ID", Err: ErrInvalidID}
+	case strings.TrimSpace(t.Customer) == "":
+		return &ValidationError{Field: "Customer", Err: ErrEmptyCustomer}
+	case !currencyPattern.MatchString(t.Amount.Currency):
+		return &ValidationError{Field: "Amount.Currency", Err: ErrInvalidCurrency}
+	case t.Amount.Cents <= 0:
+		return &ValidationError{Field: "Amount", Err: ErrInvalidAmount}
+	case t.Kind() != TypeDebit && t.Kind() != TypeCredit:
+		return &ValidationError{Field: "Type", Err: ErrInvalidType}
+	}
+	return nil
+}
+
+// withDefaults preenche a moeda vazia com DefaultCurrency, para o razão e o armazenamento verem sempre o código | withDefaults fills an empty currency with DefaultCurrency, so the ledger and the store always see the code
+func (t Transaction) withDefaults() Transaction {
+	t.Amount.Currency = t.Amount.code()
+	return t
+}